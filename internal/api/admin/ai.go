@@ -0,0 +1,806 @@
+package admin
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AIExplanationBatchRequest 批量生成解析请求
+type AIExplanationBatchRequest struct {
+	CourseID    uint `json:"course_id" binding:"required"`
+	OverwriteAI bool `json:"overwrite_ai"` // 是否重新生成AI生成的解析及待审核的草稿
+	AutoApprove bool `json:"auto_approve"` // 是否直接采用，否则进入审核队列
+}
+
+// AIExplanationItemQuery 批量生成解析的题目状态查询参数
+type AIExplanationItemQuery struct {
+	Page   int    `form:"page,default=1"`
+	Size   int    `form:"size,default=20"`
+	Status string `form:"status"` // pending, succeeded, failed, skipped，为空时返回全部
+}
+
+// ExplanationDraftQuery AI解析审核队列查询参数
+type ExplanationDraftQuery struct {
+	Page     int    `form:"page,default=1"`
+	Size     int    `form:"size,default=10"`
+	Status   string `form:"status,default=draft"` // draft, approved, rejected, all
+	CourseID uint   `form:"course_id"`
+}
+
+// ApproveExplanationDraftRequest 采用草稿请求，content 为空时按原文采用
+type ApproveExplanationDraftRequest struct {
+	Content string `json:"content"`
+}
+
+// RejectExplanationDraftRequest 驳回草稿请求
+type RejectExplanationDraftRequest struct {
+	Note string `json:"note"`
+}
+
+// SubmitAIExplanations 提交批量生成课程题目解析的任务，人工编写的解析不会被覆盖
+func SubmitAIExplanations(c *gin.Context) {
+	var req AIExplanationBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	job, err := service.AIExplanation.SubmitBatch(service.AIExplanationParams{
+		CourseID:    req.CourseID,
+		OverwriteAI: req.OverwriteAI,
+		AutoApprove: req.AutoApprove,
+	}, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "解析生成任务已提交",
+	})
+}
+
+// GetAIExplanationItems 获取批量生成任务中每道题目的处理状态和用量
+func GetAIExplanationItems(c *gin.Context) {
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	var query AIExplanationItemQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 100 {
+		query.Size = 20
+	}
+
+	items, total, err := service.AIExplanation.ListItems(jobID, query.Status, query.Page, query.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取题目状态失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// GetExplanationDrafts 获取AI解析审核队列
+func GetExplanationDrafts(c *gin.Context) {
+	var query ExplanationDraftQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 100 {
+		query.Size = 10
+	}
+	status := query.Status
+	switch status {
+	case "all":
+		status = ""
+	case model.DraftStatusDraft, model.DraftStatusApproved, model.DraftStatusRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的审核状态",
+		})
+		return
+	}
+
+	items, total, err := service.ExplanationDraft.Queue(status, query.CourseID, query.Page, query.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取审核队列失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// ApproveExplanationDraft 采用AI解析草稿，可先修改内容
+func ApproveExplanationDraft(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req ApproveExplanationDraftRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := service.ExplanationDraft.Approve(uint(id), c.GetUint("userId"), req.Content); err != nil {
+		respondDraftError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "已采用",
+	})
+}
+
+// RejectExplanationDraft 驳回AI解析草稿
+func RejectExplanationDraft(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req RejectExplanationDraftRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := service.ExplanationDraft.Reject(uint(id), c.GetUint("userId"), req.Note); err != nil {
+		respondDraftError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "已驳回",
+	})
+}
+
+// respondDraftError 草稿不存在返回404，其他错误返回400
+func respondDraftError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrDraftNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"code": 400,
+		"msg":  err.Error(),
+	})
+}
+
+// QuestionCandidateQuery AI生成题目审核队列查询参数
+type QuestionCandidateQuery struct {
+	Page     int    `form:"page,default=1"`
+	Size     int    `form:"size,default=10"`
+	Status   string `form:"status,default=pending"` // pending, approved, rejected, all
+	CourseID uint   `form:"course_id"`
+	JobID    uint   `form:"job_id"`
+}
+
+// RejectQuestionCandidateRequest 驳回候选题目请求
+type RejectQuestionCandidateRequest struct {
+	Note string `json:"note"`
+}
+
+// SubmitQuestionGeneration 提交根据学习资料生成题目的任务
+// 资料可上传 txt、md、html 文件（file），或直接粘贴文本（material）
+func SubmitQuestionGeneration(c *gin.Context) {
+	courseID, _ := strconv.ParseUint(c.PostForm("course_id"), 10, 32)
+	count, _ := strconv.Atoi(c.PostForm("count"))
+	if courseID == 0 || count <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	var types []string
+	for _, t := range strings.Split(c.PostForm("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	name := "material.txt"
+	var reader io.Reader
+	if file, err := c.FormFile("file"); err == nil {
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "无法打开文件",
+			})
+			return
+		}
+		defer src.Close()
+		name = file.Filename
+		reader = src
+	} else if material := c.PostForm("material"); strings.TrimSpace(material) != "" {
+		reader = strings.NewReader(material)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请上传学习资料或粘贴资料内容",
+		})
+		return
+	}
+
+	job, err := service.QuestionGeneration.Submit(service.QuestionGenerationParams{
+		CourseID: uint(courseID),
+		Count:    count,
+		Types:    types,
+	}, name, reader, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "出题任务已提交",
+	})
+}
+
+// GetQuestionCandidates 获取AI生成题目审核队列
+func GetQuestionCandidates(c *gin.Context) {
+	var query QuestionCandidateQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 100 {
+		query.Size = 10
+	}
+	status := query.Status
+	switch status {
+	case "all":
+		status = ""
+	case model.CandidateStatusPending, model.CandidateStatusApproved, model.CandidateStatusRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的审核状态",
+		})
+		return
+	}
+
+	items, total, err := service.QuestionGeneration.Candidates(status, query.CourseID, query.JobID, query.Page, query.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取审核队列失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// ApproveQuestionCandidate 采用AI生成的题目，可先修改内容，修改后需通过题目校验
+func ApproveQuestionCandidate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req service.CandidateEdit
+	_ = c.ShouldBindJSON(&req)
+
+	question, err := service.QuestionGeneration.Approve(uint(id), c.GetUint("userId"), req)
+	if err != nil {
+		respondCandidateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"question_id": question.ID},
+		"msg":  "已采用",
+	})
+}
+
+// RejectQuestionCandidate 驳回AI生成的题目
+func RejectQuestionCandidate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req RejectQuestionCandidateRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := service.QuestionGeneration.Reject(uint(id), c.GetUint("userId"), req.Note); err != nil {
+		respondCandidateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "已驳回",
+	})
+}
+
+// respondCandidateError 候选题目不存在返回404，其他错误返回400
+func respondCandidateError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrCandidateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"code": 400,
+		"msg":  err.Error(),
+	})
+}
+
+// AnswerVerificationRequest 答案校验请求
+type AnswerVerificationRequest struct {
+	CourseID uint `json:"course_id" binding:"required"`
+}
+
+// AnswerCheckReportQuery 疑似错误报告查询参数
+type AnswerCheckReportQuery struct {
+	Page       int    `form:"page,default=1"`
+	Size       int    `form:"size,default=20"`
+	CourseID   uint   `form:"course_id"`
+	JobID      uint   `form:"job_id"`
+	Status     string `form:"status,default=disagreed"` // pending, agreed, disagreed, failed, skipped, all
+	Resolution string `form:"resolution,default=open"`  // open, accepted, corrected, dismissed, all
+	Sort       string `form:"sort,default=confidence"`  // confidence, id
+	Order      string `form:"order,default=desc"`       // asc, desc
+}
+
+// ResolveAnswerCheckRequest 处理疑似错误请求
+type ResolveAnswerCheckRequest struct {
+	Resolution string `json:"resolution" binding:"required"` // accepted, corrected, dismissed
+	Note       string `json:"note"`
+}
+
+// SubmitAnswerVerification 提交校验课程题目答案的任务
+func SubmitAnswerVerification(c *gin.Context) {
+	var req AnswerVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	job, err := service.AnswerVerification.SubmitSweep(service.AnswerVerificationParams{
+		CourseID: req.CourseID,
+	}, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "答案校验任务已提交",
+	})
+}
+
+// GetAnswerCheckReport 获取课程的答案校验报告，默认返回待处理的疑似错误，按模型把握从高到低排列
+func GetAnswerCheckReport(c *gin.Context) {
+	var query AnswerCheckReportQuery
+	if err := c.ShouldBindQuery(&query); err != nil || (query.CourseID == 0 && query.JobID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 100 {
+		query.Size = 20
+	}
+
+	status := query.Status
+	switch status {
+	case "all":
+		status = ""
+	case model.AnswerCheckPending, model.AnswerCheckAgreed, model.AnswerCheckDisagreed,
+		model.AnswerCheckFailed, model.AnswerCheckSkipped:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的校验状态",
+		})
+		return
+	}
+	resolution := query.Resolution
+	switch resolution {
+	case "all":
+		resolution = ""
+	case "open", model.AnswerCheckResolutionAccepted, model.AnswerCheckResolutionCorrected, model.AnswerCheckResolutionDismissed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的处理结果",
+		})
+		return
+	}
+	if query.Sort != "confidence" && query.Sort != "id" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的排序字段",
+		})
+		return
+	}
+
+	items, total, jobID, err := service.AnswerVerification.Report(service.AnswerCheckQuery{
+		CourseID:   query.CourseID,
+		JobID:      query.JobID,
+		Status:     status,
+		Resolution: resolution,
+		Sort:       query.Sort,
+		Desc:       query.Order != "asc",
+		Page:       query.Page,
+		Size:       query.Size,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取校验报告失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"job_id": jobID,
+			"total":  total,
+			"items":  items,
+		},
+	})
+}
+
+// ResolveAnswerCheck 处理疑似错误的题目
+func ResolveAnswerCheck(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req ResolveAnswerCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if err := service.AnswerVerification.Resolve(uint(id), c.GetUint("userId"), req.Resolution, req.Note); err != nil {
+		if errors.Is(err, service.ErrAnswerCheckNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code": 404,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "已处理",
+	})
+}
+
+// TutorConversationQuery 答疑对话列表查询参数
+type TutorConversationQuery struct {
+	Page       int  `form:"page,default=1"`
+	Size       int  `form:"size,default=20"`
+	CourseID   uint `form:"course_id"`
+	QuestionID uint `form:"question_id"`
+	UserID     uint `form:"user_id"`
+}
+
+// TutorQuestionStatQuery 按题目汇总答疑情况的查询参数
+type TutorQuestionStatQuery struct {
+	Page     int  `form:"page,default=1"`
+	Size     int  `form:"size,default=20"`
+	CourseID uint `form:"course_id"`
+}
+
+// GetTutorConversations 获取学生答疑对话列表
+func GetTutorConversations(c *gin.Context) {
+	var query TutorConversationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 100 {
+		query.Size = 20
+	}
+
+	items, total, err := service.Tutor.Conversations(query.CourseID, query.QuestionID, query.UserID, query.Page, query.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取对话列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// GetTutorTranscript 获取答疑对话的完整记录
+func GetTutorTranscript(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	conversation, messages, err := service.Tutor.Transcript(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"conversation": conversation,
+			"messages":     messages,
+		},
+	})
+}
+
+// GetTutorQuestionStats 按题目汇总答疑情况，提问人数多的题目在前
+func GetTutorQuestionStats(c *gin.Context) {
+	var query TutorQuestionStatQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 100 {
+		query.Size = 20
+	}
+
+	items, total, err := service.Tutor.QuestionStats(query.CourseID, query.Page, query.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取答疑统计失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// AIUsageQuery AI调用记录查询参数
+type AIUsageQuery struct {
+	Page     int    `form:"page,default=1"`
+	Size     int    `form:"size,default=20"`
+	UserID   uint   `form:"user_id"`
+	CourseID uint   `form:"course_id"`
+	Feature  string `form:"feature"`
+	Status   string `form:"status"`
+}
+
+// GetAIUsages 获取AI调用记录
+func GetAIUsages(c *gin.Context) {
+	var query AIUsageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 100 {
+		query.Size = 20
+	}
+
+	items, total, err := service.AIUsage.List(query.UserID, query.CourseID, query.Feature, query.Status, query.Page, query.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取调用记录失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// GetAIUsageStats 统计AI用量和费用，默认统计最近30天
+func GetAIUsageStats(c *gin.Context) {
+	startTimeStr := c.Query("start_time")
+	endTimeStr := c.Query("end_time")
+	courseId, _ := strconv.ParseUint(c.Query("course_id"), 10, 32)
+
+	var startTime, endTime time.Time
+	var err error
+	if startTimeStr == "" {
+		startTime = time.Now().AddDate(0, 0, -30)
+	} else {
+		startTime, err = time.ParseInLocation("2006-01-02 15:04:05", startTimeStr, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "开始时间格式错误，正确格式为：2006-01-02 15:04:05",
+			})
+			return
+		}
+	}
+	if endTimeStr == "" {
+		endTime = time.Now()
+	} else {
+		endTime, err = time.ParseInLocation("2006-01-02 15:04:05", endTimeStr, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "结束时间格式错误，正确格式为：2006-01-02 15:04:05",
+			})
+			return
+		}
+	}
+	if startTime.After(endTime) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "开始时间不能晚于结束时间",
+		})
+		return
+	}
+
+	stats, err := service.AIUsage.Stats(startTime, endTime, uint(courseId), c.Query("feature"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取AI用量统计失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": stats,
+	})
+}
@@ -0,0 +1,163 @@
+package admin
+
+import (
+	"exam-system/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BundleRequest 创建或修改套餐请求
+type BundleRequest struct {
+	Name        string  `json:"name" binding:"required"`
+	Cover       string  `json:"cover"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	ExpireDays  int     `json:"expire_days"` // 有效期（天），0表示永久有效
+	Sort        int     `json:"sort"`
+	OnSale      bool    `json:"on_sale"`
+	CourseIDs   []uint  `json:"course_ids" binding:"required"` // 包含的课程，按顺序排列
+}
+
+func (r BundleRequest) input() service.BundleInput {
+	return service.BundleInput{
+		Name:        r.Name,
+		Cover:       r.Cover,
+		Description: r.Description,
+		Price:       r.Price,
+		ExpireDays:  r.ExpireDays,
+		Sort:        r.Sort,
+		OnSale:      r.OnSale,
+		CourseIDs:   r.CourseIDs,
+	}
+}
+
+// GetBundles 获取套餐列表，包括已下架的套餐
+func GetBundles(c *gin.Context) {
+	bundles, err := service.Bundle.List(0, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取套餐列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": bundles,
+	})
+}
+
+// GetBundle 获取单个套餐
+func GetBundle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	bundle, err := service.Bundle.Get(0, uint(id), false)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": bundle,
+	})
+}
+
+// CreateBundle 创建套餐
+func CreateBundle(c *gin.Context) {
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	bundle, err := service.Bundle.Create(req.input())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": bundle,
+	})
+}
+
+// UpdateBundle 修改套餐，包含的课程整体替换
+func UpdateBundle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	bundle, err := service.Bundle.Update(uint(id), req.input())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": bundle,
+	})
+}
+
+// DeleteBundle 删除套餐
+func DeleteBundle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if err := service.Bundle.Delete(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "删除成功",
+	})
+}
@@ -3,6 +3,7 @@ package admin
 import (
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
 	"exam-system/internal/utils"
 	"math/rand"
 	"net/http"
@@ -542,7 +543,7 @@ func GetCardRecords(c *gin.Context) {
 			"order_id":    record.OrderID,
 			"order_no":    record.OrderNo,
 			"course_id":   record.CourseID,
-			"course_name": course.CategoryLevel2 + "-" + course.Name,
+			"course_name": service.CourseCategory.CourseDisplayName(&course),
 			"amount":      record.Amount,
 			"created_at":  record.CreatedAt,
 		})
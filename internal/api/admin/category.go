@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"exam-system/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CategoryRequest 创建或修改课程分类请求
+type CategoryRequest struct {
+	ParentID uint   `json:"parent_id"` // 父分类ID，0表示一级分类
+	Name     string `json:"name" binding:"required"`
+	Icon     string `json:"icon"`
+	Sort     int    `json:"sort"`
+}
+
+func (r CategoryRequest) input() service.CategoryInput {
+	return service.CategoryInput{
+		ParentID: r.ParentID,
+		Name:     r.Name,
+		Icon:     r.Icon,
+		Sort:     r.Sort,
+	}
+}
+
+// GetCategories 获取课程分类树
+func GetCategories(c *gin.Context) {
+	tree, err := service.CourseCategory.Tree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取课程分类失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": tree,
+	})
+}
+
+// CreateCategory 创建课程分类
+func CreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	category, err := service.CourseCategory.Create(req.input())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": category,
+	})
+}
+
+// UpdateCategory 修改课程分类，可移动到其他父分类下
+func UpdateCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	category, err := service.CourseCategory.Update(uint(id), req.input())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": category,
+	})
+}
+
+// DeleteCategory 删除课程分类
+func DeleteCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if err := service.CourseCategory.Delete(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "删除成功",
+	})
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,15 +17,35 @@ func GetCourses(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
 	keyword := c.Query("keyword")
+	categoryId, _ := strconv.ParseUint(c.Query("category_id"), 10, 32)
+	status := c.Query("status")
 
 	var courses []model.Course
 	var total int64
 	query := database.DB.Model(&model.Course{})
 
-	// 关键字搜索
+	// 关键字搜索，匹配课程名称或所属分类名称
 	if keyword != "" {
-		query = query.Where("name LIKE ? OR category_level1 LIKE ? OR category_level2 LIKE ?",
-			"%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
+		query = query.Where("name LIKE ? OR category_id IN (?)", "%"+keyword+"%",
+			database.DB.Model(&model.CourseCategory{}).Select("id").Where("name LIKE ?", "%"+keyword+"%"))
+	}
+
+	// 按分类筛选，包括子分类中的课程
+	if categoryId > 0 {
+		categoryIds, err := service.CourseCategory.SubtreeIDs(uint(categoryId))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": 500,
+				"msg":  "获取课程分类失败",
+			})
+			return
+		}
+		query = query.Where("category_id IN ?", categoryIds)
+	}
+
+	// 按发布状态筛选
+	if status != "" {
+		query = query.Where("status = ?", status)
 	}
 
 	// 统计总数
@@ -45,21 +67,33 @@ func GetCourses(c *gin.Context) {
 		return
 	}
 
+	// 分类路径，如 "职业资格/会计"
+	paths, err := service.CourseCategory.Paths()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取课程分类失败",
+		})
+		return
+	}
+
 	// 处理返回数据
 	var courseList []gin.H
 	for _, course := range courses {
 		courseList = append(courseList, gin.H{
-			"id":              course.ID,
-			"name":            course.Name,
-			"cover":           course.Cover,
-			"category_level1": course.CategoryLevel1,
-			"category_level2": course.CategoryLevel2,
-			"price":           course.Price,
-			"description":     course.Description,
-			"expire_days":     course.ExpireDays,
-			"sort":            course.Sort,
-			"category_sort1":  course.CategorySort1,
-			"category_sort2":  course.CategorySort2,
+			"id":            course.ID,
+			"name":          course.Name,
+			"cover":         course.Cover,
+			"category_id":   course.CategoryID,
+			"category_path": paths[course.CategoryID],
+			"price":         course.Price,
+			"description":   course.Description,
+			"expire_days":   course.ExpireDays,
+			"sort":          course.Sort,
+			"status":        course.Status,
+			"publish_at":    course.PublishAt,
+			"unpublish_at":  course.UnpublishAt,
+			"trial_count":   course.TrialCount,
 		})
 	}
 
@@ -98,22 +132,32 @@ func GetCourse(c *gin.Context) {
 	// 获取模拟考试配置
 	mockExamConfig, _ := course.GetMockExamConfig()
 
+	paths, _ := service.CourseCategory.Paths()
+
+	plans, _ := service.CoursePlan.List(course.ID)
+
+	chapters, _ := service.Chapter.Tree(course.ID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": gin.H{
 			"id":               course.ID,
 			"name":             course.Name,
 			"cover":            course.Cover,
-			"category_level1":  course.CategoryLevel1,
-			"category_level2":  course.CategoryLevel2,
+			"category_id":      course.CategoryID,
+			"category_path":    paths[course.CategoryID],
 			"price":            course.Price,
 			"description":      course.Description,
 			"expire_days":      course.ExpireDays,
 			"sort":             course.Sort,
-			"category_sort1":   course.CategorySort1,
-			"category_sort2":   course.CategorySort2,
 			"exam_config":      examConfig,
 			"mock_exam_config": mockExamConfig,
+			"plans":            plans,
+			"chapters":         chapters,
+			"status":           course.Status,
+			"publish_at":       course.PublishAt,
+			"unpublish_at":     course.UnpublishAt,
+			"trial_count":      course.TrialCount,
 		},
 	})
 }
@@ -122,16 +166,29 @@ func GetCourse(c *gin.Context) {
 type CreateCourseRequest struct {
 	Name           string                 `json:"name" binding:"required"`
 	Cover          string                 `json:"cover" binding:"required"`
-	CategoryLevel1 string                 `json:"category_level1" binding:"required"`
-	CategoryLevel2 string                 `json:"category_level2" binding:"required"`
+	CategoryID     uint                   `json:"category_id" binding:"required"`
 	Price          float64                `json:"price" binding:"required"`
 	Description    string                 `json:"description"`
 	ExpireDays     int                    `json:"expire_days"`
 	Sort           int                    `json:"sort"`
-	CategorySort1  int                    `json:"category_sort1"`
-	CategorySort2  int                    `json:"category_sort2"`
 	ExamConfig     []model.ExamConfigItem `json:"exam_config"`
 	MockExamConfig model.MockExamConfig   `json:"mock_exam_config"`
+	Status         string                 `json:"status"`       // 发布状态，默认为草稿
+	PublishAt      string                 `json:"publish_at"`   // 定时发布时间，格式 2006-01-02 15:04:05
+	UnpublishAt    string                 `json:"unpublish_at"` // 定时归档时间，格式 2006-01-02 15:04:05
+	TrialCount     int                    `json:"trial_count"`  // 免费试用的题目数，0表示只开放标记为试用的题目
+}
+
+// parseScheduleTime 解析定时发布或归档时间，空字符串表示不设置
+func parseScheduleTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
 }
 
 // CreateCourse 创建课程
@@ -145,21 +202,79 @@ func CreateCourse(c *gin.Context) {
 		return
 	}
 
+	if err := service.CourseCategory.Exists(req.CategoryID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	// 新建课程默认为草稿，发布前学生不可见
+	if req.Status == "" {
+		req.Status = model.CourseStatusDraft
+	}
+	if !service.Course.ValidStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "发布状态错误",
+		})
+		return
+	}
+	publishAt, err := parseScheduleTime(req.PublishAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "定时发布时间格式错误",
+		})
+		return
+	}
+	unpublishAt, err := parseScheduleTime(req.UnpublishAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "定时归档时间格式错误",
+		})
+		return
+	}
+	if err := service.Course.CheckSchedule(publishAt, unpublishAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if req.TrialCount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "试用题目数不能为负数",
+		})
+		return
+	}
+
 	course := model.Course{
-		Name:           req.Name,
-		Cover:          req.Cover,
-		CategoryLevel1: req.CategoryLevel1,
-		CategoryLevel2: req.CategoryLevel2,
-		Price:          req.Price,
-		Description:    req.Description,
-		ExpireDays:     req.ExpireDays,
-		Sort:           req.Sort,
-		CategorySort1:  req.CategorySort1,
-		CategorySort2:  req.CategorySort2,
-	}
-
-	// 设置考试配置
+		Name:        req.Name,
+		Cover:       req.Cover,
+		CategoryID:  req.CategoryID,
+		Price:       req.Price,
+		Description: req.Description,
+		ExpireDays:  req.ExpireDays,
+		Sort:        req.Sort,
+		Status:      req.Status,
+		PublishAt:   publishAt,
+		UnpublishAt: unpublishAt,
+		TrialCount:  req.TrialCount,
+	}
+
+	// 设置考试配置，新课程还没有章节，不能按章抽题
 	if req.ExamConfig != nil {
+		if err := service.Chapter.CheckExamConfig(0, req.ExamConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  err.Error(),
+			})
+			return
+		}
 		if err := course.SetExamConfig(req.ExamConfig); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"code": 400,
@@ -200,16 +315,17 @@ func CreateCourse(c *gin.Context) {
 type UpdateCourseRequest struct {
 	Name           string                 `json:"name"`
 	Cover          string                 `json:"cover"`
-	CategoryLevel1 string                 `json:"category_level1"`
-	CategoryLevel2 string                 `json:"category_level2"`
+	CategoryID     uint                   `json:"category_id"`
 	Price          float64                `json:"price"`
 	Description    string                 `json:"description"`
 	ExpireDays     int                    `json:"expire_days"`
 	Sort           int                    `json:"sort"`
-	CategorySort1  int                    `json:"category_sort1"`
-	CategorySort2  int                    `json:"category_sort2"`
 	ExamConfig     []model.ExamConfigItem `json:"exam_config"`
 	MockExamConfig model.MockExamConfig   `json:"mock_exam_config"`
+	Status         string                 `json:"status"`       // 发布状态，不填时不修改
+	PublishAt      *string                `json:"publish_at"`   // 定时发布时间，不填时不修改，空字符串表示取消
+	UnpublishAt    *string                `json:"unpublish_at"` // 定时归档时间，不填时不修改，空字符串表示取消
+	TrialCount     *int                   `json:"trial_count"`  // 免费试用的题目数，不填时不修改
 }
 
 // UpdateCourse 更新课程
@@ -251,11 +367,15 @@ func UpdateCourse(c *gin.Context) {
 	if req.Cover != "" {
 		updates["cover"] = req.Cover
 	}
-	if req.CategoryLevel1 != "" {
-		updates["category_level1"] = req.CategoryLevel1
-	}
-	if req.CategoryLevel2 != "" {
-		updates["category_level2"] = req.CategoryLevel2
+	if req.CategoryID != 0 {
+		if err := service.CourseCategory.Exists(req.CategoryID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		updates["category_id"] = req.CategoryID
 	}
 	if req.Price >= 0 {
 		updates["price"] = req.Price
@@ -269,15 +389,66 @@ func UpdateCourse(c *gin.Context) {
 	if req.Sort != 0 {
 		updates["sort"] = req.Sort
 	}
-	if req.CategorySort1 != 0 {
-		updates["category_sort1"] = req.CategorySort1
+	if req.TrialCount != nil {
+		if *req.TrialCount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "试用题目数不能为负数",
+			})
+			return
+		}
+		updates["trial_count"] = *req.TrialCount
 	}
-	if req.CategorySort2 != 0 {
-		updates["category_sort2"] = req.CategorySort2
+
+	// 发布状态和定时发布、归档时间
+	if req.Status != "" {
+		if !service.Course.ValidStatus(req.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "发布状态错误",
+			})
+			return
+		}
+		updates["status"] = req.Status
+	}
+	publishAt, unpublishAt := course.PublishAt, course.UnpublishAt
+	if req.PublishAt != nil {
+		if publishAt, err = parseScheduleTime(*req.PublishAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "定时发布时间格式错误",
+			})
+			return
+		}
+		updates["publish_at"] = publishAt
+	}
+	if req.UnpublishAt != nil {
+		if unpublishAt, err = parseScheduleTime(*req.UnpublishAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "定时归档时间格式错误",
+			})
+			return
+		}
+		updates["unpublish_at"] = unpublishAt
+	}
+	if err := service.Course.CheckSchedule(publishAt, unpublishAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
 	}
 
-	// 更新考试配置
+	// 更新考试配置，按章抽题的章必须属于该课程
 	if req.ExamConfig != nil {
+		if err := service.Chapter.CheckExamConfig(uint(id), req.ExamConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  err.Error(),
+			})
+			return
+		}
 		examConfigJson, err := json.Marshal(req.ExamConfig)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -377,3 +548,86 @@ func DeleteCourse(c *gin.Context) {
 		"msg":  "删除成功",
 	})
 }
+
+// CoursePlanRequest 保存课程购买方案请求
+type CoursePlanRequest struct {
+	Plans []struct {
+		ID         uint    `json:"id"` // 为0时新建方案
+		Name       string  `json:"name" binding:"required"`
+		Price      float64 `json:"price"`
+		ExpireDays int     `json:"expire_days"` // 有效期（天），0表示永久有效
+		Sort       int     `json:"sort"`
+	} `json:"plans" binding:"dive"`
+}
+
+// GetCoursePlans 获取课程购买方案
+func GetCoursePlans(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	plans, err := service.CoursePlan.List(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取购买方案失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": plans,
+	})
+}
+
+// SaveCoursePlans 整体保存课程购买方案，未包含的方案将被删除
+func SaveCoursePlans(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req CoursePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	inputs := make([]service.CoursePlanInput, 0, len(req.Plans))
+	for _, plan := range req.Plans {
+		inputs = append(inputs, service.CoursePlanInput{
+			ID:         plan.ID,
+			Name:       plan.Name,
+			Price:      plan.Price,
+			ExpireDays: plan.ExpireDays,
+			Sort:       plan.Sort,
+		})
+	}
+
+	plans, err := service.CoursePlan.Save(uint(id), inputs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": plans,
+	})
+}
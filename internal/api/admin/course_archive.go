@@ -0,0 +1,111 @@
+package admin
+
+import (
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportCourseArchive 提交课程归档包导出任务
+// 归档包包含课程信息、考试配置、分类路径、购买方案、章节、题目和媒体文件，完成后通过任务下载接口获取
+func ExportCourseArchive(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if err := database.DB.First(&model.Course{}, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "课程不存在",
+		})
+		return
+	}
+
+	job, err := service.Job.Submit(service.JobTypeCourseExport, service.CourseExportParams{
+		CourseID: uint(id),
+	}, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "导出任务已提交",
+	})
+}
+
+// ImportCourseArchive 提交课程归档包导入任务
+// category_id 指定导入的分类，不指定时按归档包中的分类路径导入；conflict 指定同名课程的处理方式
+func ImportCourseArchive(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请选择要上传的文件",
+		})
+		return
+	}
+	if strings.ToLower(filepath.Ext(file.Filename)) != ".zip" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "课程归档包必须为zip文件",
+		})
+		return
+	}
+
+	conflict := c.DefaultPostForm("conflict", service.CourseConflictError)
+	if !service.CourseArchive.ValidConflict(conflict) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "同名课程处理方式错误",
+		})
+		return
+	}
+
+	categoryID, _ := strconv.ParseUint(c.PostForm("category_id"), 10, 32)
+	if categoryID > 0 {
+		if err := service.CourseCategory.Exists(uint(categoryID)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  err.Error(),
+			})
+			return
+		}
+	}
+
+	job, err := service.Job.SubmitWithUpload(service.JobTypeCourseImport, service.CourseImportParams{
+		Size:       file.Size,
+		UploaderID: c.GetUint("userId"),
+		CategoryID: uint(categoryID),
+		Conflict:   conflict,
+	}, file, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "导入任务已提交",
+	})
+}
@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"exam-system/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EntitlementListQuery 课程权限列表查询参数
+type EntitlementListQuery struct {
+	Page     int    `form:"page,default=1"`
+	Size     int    `form:"size,default=10"`
+	UserID   uint   `form:"user_id"`
+	CourseID uint   `form:"course_id"`
+	Source   string `form:"source"` // purchase, card, admin, bundle
+	Active   bool   `form:"active"` // 只返回生效中的权限
+}
+
+// GrantEntitlementRequest 赠送课程权限请求
+type GrantEntitlementRequest struct {
+	UserID   uint   `json:"user_id" binding:"required"`
+	CourseID uint   `json:"course_id" binding:"required"`
+	Days     int    `json:"days"` // 有效天数，0表示永久有效
+	Remark   string `json:"remark"`
+}
+
+// GetEntitlements 获取课程权限列表
+func GetEntitlements(c *gin.Context) {
+	var query EntitlementListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 {
+		query.Size = 10
+	}
+
+	items, total, err := service.Entitlement.List(service.EntitlementQuery{
+		UserID:   query.UserID,
+		CourseID: query.CourseID,
+		Source:   query.Source,
+		Active:   query.Active,
+		Page:     query.Page,
+		PageSize: query.Size,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取课程权限失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// GrantEntitlement 向用户赠送课程权限
+func GrantEntitlement(c *gin.Context) {
+	var req GrantEntitlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	entitlement, err := service.Entitlement.Grant(c.GetUint("userId"), req.UserID, req.CourseID, req.Days, req.Remark)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": entitlement,
+	})
+}
+
+// RevokeEntitlement 撤销管理员赠送的课程权限
+func RevokeEntitlement(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if err := service.Entitlement.Revoke(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "撤销成功",
+	})
+}
@@ -0,0 +1,209 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobQuery 后台任务查询参数
+type JobQuery struct {
+	Page   int    `form:"page,default=1"`
+	Size   int    `form:"size,default=10"`
+	Type   string `form:"type"`
+	Status string `form:"status"`
+}
+
+// jobResponse 任务响应数据，result 按JSON原样返回
+func jobResponse(job *model.Job) gin.H {
+	var result json.RawMessage
+	if job.Result != "" {
+		result = json.RawMessage(job.Result)
+	}
+
+	return gin.H{
+		"id":               job.ID,
+		"type":             job.Type,
+		"status":           job.Status,
+		"progress":         job.Progress,
+		"message":          job.Message,
+		"result":           result,
+		"input_name":       job.InputName,
+		"artifact_name":    job.ArtifactName,
+		"has_artifact":     job.Status == model.JobStatusSucceeded && job.ArtifactKey != "",
+		"cancel_requested": job.CancelRequested,
+		"creator_id":       job.CreatorID,
+		"started_at":       job.StartedAt,
+		"finished_at":      job.FinishedAt,
+		"created_at":       job.CreatedAt,
+	}
+}
+
+// parseJobID 解析路径中的任务ID
+func parseJobID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// GetJobList 获取后台任务列表
+func GetJobList(c *gin.Context) {
+	var query JobQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 {
+		query.Size = 10
+	}
+
+	db := database.DB.Model(&model.Job{})
+	if query.Type != "" {
+		db = db.Where("type = ?", query.Type)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取任务总数失败",
+		})
+		return
+	}
+
+	var jobs []model.Job
+	if err := db.Order("id DESC").
+		Offset((query.Page - 1) * query.Size).
+		Limit(query.Size).
+		Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取任务列表失败",
+		})
+		return
+	}
+
+	items := make([]gin.H, 0, len(jobs))
+	for i := range jobs {
+		items = append(items, jobResponse(&jobs[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// GetJob 获取任务详情（用于轮询进度）
+func GetJob(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := service.Job.Get(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrJobNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": jobResponse(job),
+	})
+}
+
+// CancelJob 取消任务
+func CancelJob(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	if err := service.Job.Cancel(id); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrJobNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "已提交取消请求",
+	})
+}
+
+// DownloadJobArtifact 下载任务结果文件
+func DownloadJobArtifact(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, reader, err := service.Job.OpenArtifact(id)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrJobNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	defer reader.Close()
+
+	contentType := "application/octet-stream"
+	switch path.Ext(job.ArtifactName) {
+	case ".csv":
+		contentType = "text/csv; charset=utf-8"
+	case ".zip":
+		contentType = "application/zip"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": job.ArtifactName}))
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, reader)
+}
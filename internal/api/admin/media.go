@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaQuery 媒体文件查询参数
+type MediaQuery struct {
+	Page        int    `form:"page,default=1"`
+	Size        int    `form:"size,default=10"`
+	FileName    string `form:"file_name"`
+	ContentType string `form:"content_type"`
+}
+
+// UploadMedia 上传媒体文件
+func UploadMedia(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请选择要上传的文件",
+		})
+		return
+	}
+
+	media, err := service.Media.Upload(file, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"id":           media.ID,
+			"file_name":    media.FileName,
+			"content_type": media.ContentType,
+			"size":         media.Size,
+			"url":          service.MediaURL(media.ID),
+		},
+	})
+}
+
+// GetMediaList 获取媒体文件列表
+func GetMediaList(c *gin.Context) {
+	var query MediaQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 {
+		query.Size = 10
+	}
+
+	db := database.DB.Model(&model.Media{})
+	if query.FileName != "" {
+		db = db.Where("file_name LIKE ?", "%"+query.FileName+"%")
+	}
+	if query.ContentType != "" {
+		db = db.Where("content_type LIKE ?", query.ContentType+"%")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取媒体文件总数失败",
+		})
+		return
+	}
+
+	var medias []model.Media
+	if err := db.Order("id DESC").
+		Offset((query.Page - 1) * query.Size).
+		Limit(query.Size).
+		Find(&medias).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取媒体文件列表失败",
+		})
+		return
+	}
+
+	items := make([]gin.H, 0, len(medias))
+	for _, m := range medias {
+		items = append(items, gin.H{
+			"id":           m.ID,
+			"file_name":    m.FileName,
+			"content_type": m.ContentType,
+			"size":         m.Size,
+			"url":          service.MediaURL(m.ID),
+			"created_at":   m.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// DeleteMedia 删除媒体文件
+func DeleteMedia(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if err := service.Media.Delete(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "删除成功",
+	})
+}
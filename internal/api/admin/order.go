@@ -3,6 +3,7 @@ package admin
 import (
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,6 +23,7 @@ type OrderQuery struct {
 	PaymentType string `form:"payment_type"`
 	StartTime   string `form:"start_time"`
 	EndTime     string `form:"end_time"`
+	ParentID    uint   `form:"parent_order_id"` // 套餐订单ID，指定时查询该套餐订单的子订单
 }
 
 // GetOrders 获取订单列表
@@ -55,6 +57,8 @@ func GetOrders(c *gin.Context) {
 	if query.PaymentType != "" {
 		db = db.Where("payment_type = ?", query.PaymentType)
 	}
+	// 套餐子订单默认不展示，指定套餐订单ID时只查询其子订单
+	db = db.Where("orders.parent_order_id = ?", query.ParentID)
 	// 按用户ID查询
 	if query.UserID > 0 {
 		db = db.Where("user_id = ?", query.UserID)
@@ -114,12 +118,8 @@ func GetOrders(c *gin.Context) {
 	// 处理返回数据
 	orderList := make([]gin.H, 0)
 	for _, order := range orders {
-		// 查询课程信息
-		var course model.Course
-		courseName := "未知课程"
-		if err := database.DB.First(&course, order.CourseID).Error; err == nil {
-			courseName = course.CategoryLevel2 + "-" + course.Name
-		}
+		// 课程名称，套餐订单为套餐名称
+		courseName := service.Order.ProductName(&order)
 
 		// 构建用户信息
 		userInfo := gin.H{
@@ -129,17 +129,19 @@ func GetOrders(c *gin.Context) {
 		}
 
 		orderList = append(orderList, gin.H{
-			"id":           order.ID,
-			"order_no":     order.OrderNo,
-			"user":         userInfo,
-			"course_id":    order.CourseID,
-			"course_name":  courseName,
-			"amount":       order.Amount,
-			"status":       order.Status,
-			"payment_type": order.PaymentType,
-			"pay_time":     order.PayTime,
-			"expire_time":  order.ExpireTime,
-			"created_at":   order.CreatedAt,
+			"id":              order.ID,
+			"order_no":        order.OrderNo,
+			"user":            userInfo,
+			"course_id":       order.CourseID,
+			"bundle_id":       order.BundleID,
+			"parent_order_id": order.ParentOrderID,
+			"course_name":     courseName,
+			"amount":          order.Amount,
+			"status":          order.Status,
+			"payment_type":    order.PaymentType,
+			"pay_time":        order.PayTime,
+			"expire_time":     order.ExpireTime,
+			"created_at":      order.CreatedAt,
 		})
 	}
 
@@ -174,12 +176,8 @@ func GetOrder(c *gin.Context) {
 		return
 	}
 
-	// 查询课程信息
-	var course model.Course
-	courseName := "未知课程"
-	if err = database.DB.First(&course, order.CourseID).Error; err == nil {
-		courseName = course.CategoryLevel2 + "-" + course.Name
-	}
+	// 课程名称，套餐订单为套餐名称
+	courseName := service.Order.ProductName(&order)
 
 	// 构建用户信息
 	userInfo := gin.H{
@@ -191,17 +189,19 @@ func GetOrder(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": gin.H{
-			"id":           order.ID,
-			"order_no":     order.OrderNo,
-			"user":         userInfo,
-			"course_id":    order.CourseID,
-			"course_name":  courseName,
-			"amount":       order.Amount,
-			"status":       order.Status,
-			"payment_type": order.PaymentType,
-			"pay_time":     order.PayTime,
-			"expire_time":  order.ExpireTime,
-			"created_at":   order.CreatedAt,
+			"id":              order.ID,
+			"order_no":        order.OrderNo,
+			"user":            userInfo,
+			"course_id":       order.CourseID,
+			"bundle_id":       order.BundleID,
+			"parent_order_id": order.ParentOrderID,
+			"course_name":     courseName,
+			"amount":          order.Amount,
+			"status":          order.Status,
+			"payment_type":    order.PaymentType,
+			"pay_time":        order.PayTime,
+			"expire_time":     order.ExpireTime,
+			"created_at":      order.CreatedAt,
 		},
 	})
 }
@@ -246,7 +246,7 @@ func CreateOrder(c *gin.Context) {
 
 	// 创建订单
 	order := model.Order{
-		OrderNo:  time.Now().Format("20060102150405") + strconv.Itoa(int(req.UserID)),
+		OrderNo:  service.Order.GenerateOrderNo(),
 		UserID:   req.UserID,
 		CourseID: req.CourseID,
 		Amount:   req.Amount,
@@ -333,8 +333,32 @@ func UpdateOrder(c *gin.Context) {
 		updates["amount"] = req.Amount
 	}
 
-	result := database.DB.Model(&model.Order{}).Where("id = ?", id).Updates(updates)
-	if result.Error != nil {
+	var order model.Order
+	if err := database.DB.First(&order, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "订单不存在",
+		})
+		return
+	}
+
+	// 手动将套餐订单标记为已支付时，先开通套餐中的课程
+	if order.BundleID > 0 && order.ParentOrderID == 0 && req.Status == "paid" && order.Status != "paid" {
+		paymentType := req.PaymentType
+		if paymentType == "" {
+			paymentType = "admin"
+		}
+		if err := service.Bundle.Fulfill(order.ID, paymentType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": 500,
+				"msg":  err.Error(),
+			})
+			return
+		}
+	}
+
+	previousExpire := order.ExpireTime
+	if err := database.DB.Model(&order).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
 			"msg":  "更新订单失败",
@@ -342,10 +366,20 @@ func UpdateOrder(c *gin.Context) {
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "订单不存在",
+	// 套餐订单的状态和有效期同步到子订单
+	if err := service.Bundle.SyncChildren(&order, previousExpire, updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "更新套餐子订单失败",
+		})
+		return
+	}
+
+	// 按订单的新状态和有效期同步课程权限
+	if err := service.Entitlement.SyncOrder(database.DB, order.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "同步课程权限失败",
 		})
 		return
 	}
@@ -384,6 +418,15 @@ func DeleteOrder(c *gin.Context) {
 		return
 	}
 
+	// 删除的订单不再提供课程权限
+	if err := service.Entitlement.SyncOrder(database.DB, uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "撤销课程权限失败",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "删除成功",
@@ -1,14 +1,12 @@
 package admin
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"errors"
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
 	"fmt"
-	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -19,11 +17,14 @@ import (
 
 // 题库查询参数
 type QuestionQuery struct {
-	Page     int    `form:"page,default=1"`
-	Size     int    `form:"size,default=10"`
-	Type     string `form:"type"`
-	Question string `form:"question"`
-	CourseID uint   `form:"course_id"`
+	Page      int    `form:"page,default=1"`
+	Size      int    `form:"size,default=10"`
+	Type      string `form:"type"`
+	Question  string `form:"question"`
+	CourseID  uint   `form:"course_id"`
+	ChapterID uint   `form:"chapter_id"` // 按章筛选
+	SectionID uint   `form:"section_id"` // 按小节筛选
+	Suspect   bool   `form:"suspect"`    // 只看答案疑似错误的题目
 }
 
 // GetQuestions 获取题库列表
@@ -45,17 +46,17 @@ func GetQuestions(c *gin.Context) {
 		query.Size = 10
 	}
 
-	db := database.DB.Model(&model.Question{})
-
 	// 构建查询条件
-	if query.Type != "" {
-		db = db.Where("type = ?", query.Type)
-	}
-	if query.Question != "" {
-		db = db.Where("question LIKE ?", "%"+query.Question+"%")
-	}
-	if query.CourseID > 0 {
-		db = db.Where("course_id = ?", query.CourseID)
+	db := service.QuestionFilter{
+		Type:      query.Type,
+		Question:  query.Question,
+		CourseID:  query.CourseID,
+		ChapterID: query.ChapterID,
+		SectionID: query.SectionID,
+	}.Apply(database.DB.Model(&model.Question{}))
+	if query.Suspect {
+		db = db.Where("id IN (?)", database.DB.Model(&model.QuestionStat{}).
+			Select("question_id").Where("suspect_key = ?", true))
 	}
 
 	var total int64
@@ -69,16 +70,21 @@ func GetQuestions(c *gin.Context) {
 
 	// 使用临时结构体接收原始数据
 	type RawQuestion struct {
-		ID          uint           `json:"id" gorm:"column:id"`
-		Type        string         `json:"type" gorm:"column:type"`
-		Question    string         `json:"question" gorm:"column:question"`
-		Options     string         `json:"options" gorm:"column:options"` // 接收原始字符串
-		Answer      string         `json:"answer" gorm:"column:answer"`
-		Explanation string         `json:"explanation" gorm:"column:explanation"`
-		CourseID    uint           `json:"course_id" gorm:"column:course_id"`
-		CreatedAt   time.Time      `json:"created_at" gorm:"column:created_at"`
-		UpdatedAt   time.Time      `json:"updated_at" gorm:"column:updated_at"`
-		DeletedAt   gorm.DeletedAt `json:"-" gorm:"column:deleted_at"`
+		ID                uint            `json:"id" gorm:"column:id"`
+		Type              string          `json:"type" gorm:"column:type"`
+		Question          string          `json:"question" gorm:"column:question"`
+		StemMedia         model.MediaRefs `json:"stem_media" gorm:"column:stem_media"`
+		Options           string          `json:"options" gorm:"column:options"` // 接收原始字符串
+		Answer            string          `json:"answer" gorm:"column:answer"`
+		Explanation       string          `json:"explanation" gorm:"column:explanation"`
+		ExplanationMedia  model.MediaRefs `json:"explanation_media" gorm:"column:explanation_media"`
+		ExplanationSource string          `json:"explanation_source" gorm:"column:explanation_source"`
+		CourseID          uint            `json:"course_id" gorm:"column:course_id"`
+		SectionID         uint            `json:"section_id" gorm:"column:section_id"`
+		IsTrial           bool            `json:"is_trial" gorm:"column:is_trial"`
+		CreatedAt         time.Time       `json:"created_at" gorm:"column:created_at"`
+		UpdatedAt         time.Time       `json:"updated_at" gorm:"column:updated_at"`
+		DeletedAt         gorm.DeletedAt  `json:"-" gorm:"column:deleted_at"`
 	}
 
 	var rawQuestions []RawQuestion
@@ -93,6 +99,20 @@ func GetQuestions(c *gin.Context) {
 		return
 	}
 
+	// 查询题目分析结果
+	questionIds := make([]uint, 0, len(rawQuestions))
+	for _, q := range rawQuestions {
+		questionIds = append(questionIds, q.ID)
+	}
+	stats, err := service.ItemAnalysis.GetStats(questionIds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取题目分析结果失败",
+		})
+		return
+	}
+
 	// 处理返回数据
 	questionList := make([]gin.H, 0)
 	for _, q := range rawQuestions {
@@ -107,51 +127,25 @@ func GetQuestions(c *gin.Context) {
 		typeDesc := getQuestionTypeDesc(q.Type)
 
 		// 解析选项
-		var questionOptions model.QuestionOptions
-		if q.Type == "judge" {
-			// 判断题使用固定格式
-			questionOptions = model.QuestionOptions{
-				{Label: "A", Text: "正确"},
-				{Label: "B", Text: "错误"},
-			}
-		} else {
-			// 尝试解析选项
-			var options []string
-			if err := json.Unmarshal([]byte(q.Options), &options); err != nil {
-				// 如果解析失败，使用空数组
-				options = []string{}
-			}
-
-			// 转换为 QuestionOption 格式
-			questionOptions = make(model.QuestionOptions, len(options))
-			for i, text := range options {
-				// 如果文本包含标签前缀（如 "A.选项内容"），则提取实际内容
-				parts := strings.SplitN(text, ".", 2)
-				if len(parts) == 2 {
-					questionOptions[i] = model.QuestionOption{
-						Label: parts[0],
-						Text:  strings.TrimSpace(parts[1]),
-					}
-				} else {
-					questionOptions[i] = model.QuestionOption{
-						Label: string(rune('A' + i)),
-						Text:  text,
-					}
-				}
-			}
-		}
+		questionOptions, _ := model.DecodeQuestionOptions(q.Type, q.Options)
 
 		questionList = append(questionList, gin.H{
-			"id":          q.ID,
-			"type":        q.Type,
-			"type_desc":   typeDesc,
-			"question":    q.Question,
-			"options":     questionOptions,
-			"answer":      q.Answer,
-			"explanation": q.Explanation,
-			"course_id":   q.CourseID,
-			"course_name": courseName,
-			"created_at":  q.CreatedAt,
+			"id":                 q.ID,
+			"type":               q.Type,
+			"type_desc":          typeDesc,
+			"question":           q.Question,
+			"stem_media":         q.StemMedia,
+			"options":            questionOptions,
+			"answer":             q.Answer,
+			"explanation":        q.Explanation,
+			"explanation_media":  q.ExplanationMedia,
+			"explanation_source": q.ExplanationSource,
+			"course_id":          q.CourseID,
+			"section_id":         q.SectionID,
+			"is_trial":           q.IsTrial,
+			"course_name":        courseName,
+			"created_at":         q.CreatedAt,
+			"stats":              questionStatResponse(stats, q.ID),
 		})
 	}
 
@@ -177,16 +171,21 @@ func GetQuestion(c *gin.Context) {
 
 	// 使用临时结构体接收原始数据
 	type RawQuestion struct {
-		ID          uint           `json:"id" gorm:"column:id"`
-		Type        string         `json:"type" gorm:"column:type"`
-		Question    string         `json:"question" gorm:"column:question"`
-		Options     string         `json:"options" gorm:"column:options"` // 接收原始字符串
-		Answer      string         `json:"answer" gorm:"column:answer"`
-		Explanation string         `json:"explanation" gorm:"column:explanation"`
-		CourseID    uint           `json:"course_id" gorm:"column:course_id"`
-		CreatedAt   time.Time      `json:"created_at" gorm:"column:created_at"`
-		UpdatedAt   time.Time      `json:"updated_at" gorm:"column:updated_at"`
-		DeletedAt   gorm.DeletedAt `json:"-" gorm:"column:deleted_at"`
+		ID                uint            `json:"id" gorm:"column:id"`
+		Type              string          `json:"type" gorm:"column:type"`
+		Question          string          `json:"question" gorm:"column:question"`
+		StemMedia         model.MediaRefs `json:"stem_media" gorm:"column:stem_media"`
+		Options           string          `json:"options" gorm:"column:options"` // 接收原始字符串
+		Answer            string          `json:"answer" gorm:"column:answer"`
+		Explanation       string          `json:"explanation" gorm:"column:explanation"`
+		ExplanationMedia  model.MediaRefs `json:"explanation_media" gorm:"column:explanation_media"`
+		ExplanationSource string          `json:"explanation_source" gorm:"column:explanation_source"`
+		CourseID          uint            `json:"course_id" gorm:"column:course_id"`
+		SectionID         uint            `json:"section_id" gorm:"column:section_id"`
+		IsTrial           bool            `json:"is_trial" gorm:"column:is_trial"`
+		CreatedAt         time.Time       `json:"created_at" gorm:"column:created_at"`
+		UpdatedAt         time.Time       `json:"updated_at" gorm:"column:updated_at"`
+		DeletedAt         gorm.DeletedAt  `json:"-" gorm:"column:deleted_at"`
 	}
 
 	var question RawQuestion
@@ -205,61 +204,164 @@ func GetQuestion(c *gin.Context) {
 		courseName = course.Name
 	}
 
+	// 查询题目分析结果
+	stats, _ := service.ItemAnalysis.GetStats([]uint{question.ID})
+
 	// 获取题目类型的中文描述
 	typeDesc := getQuestionTypeDesc(question.Type)
 
 	// 解析选项
-	var questionOptions model.QuestionOptions
-	if question.Type == "judge" {
-		// 判断题使用固定格式
-		questionOptions = model.QuestionOptions{
-			{Label: "A", Text: "正确"},
-			{Label: "B", Text: "错误"},
-		}
-	} else {
-		// 尝试解析选项
-		var options []string
-		if err := json.Unmarshal([]byte(question.Options), &options); err != nil {
-			// 如果解析失败，使用空数组
-			options = []string{}
-		}
+	questionOptions, _ := model.DecodeQuestionOptions(question.Type, question.Options)
 
-		// 转换为 QuestionOption 格式
-		questionOptions = make(model.QuestionOptions, len(options))
-		for i, text := range options {
-			// 如果文本包含标签前缀（如 "A.选项内容"），则提取实际内容
-			parts := strings.SplitN(text, ".", 2)
-			if len(parts) == 2 {
-				questionOptions[i] = model.QuestionOption{
-					Label: parts[0],
-					Text:  strings.TrimSpace(parts[1]),
-				}
-			} else {
-				questionOptions[i] = model.QuestionOption{
-					Label: string(rune('A' + i)),
-					Text:  text,
-				}
-			}
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"id":                 question.ID,
+			"type":               question.Type,
+			"type_desc":          typeDesc,
+			"question":           question.Question,
+			"stem_media":         question.StemMedia,
+			"options":            questionOptions,
+			"answer":             question.Answer,
+			"explanation":        question.Explanation,
+			"explanation_media":  question.ExplanationMedia,
+			"explanation_source": question.ExplanationSource,
+			"course_id":          question.CourseID,
+			"section_id":         question.SectionID,
+			"is_trial":           question.IsTrial,
+			"course_name":        courseName,
+			"created_at":         question.CreatedAt,
+			"stats":              questionStatResponse(stats, question.ID),
+		},
+	})
+}
+
+// questionStatResponse 题目分析结果，尚无作答数据时为nil
+func questionStatResponse(stats map[uint]model.QuestionStat, id uint) *model.QuestionStat {
+	stat, exists := stats[id]
+	if !exists {
+		return nil
+	}
+	return &stat
+}
+
+// RefreshQuestionStats 提交重新计算题目分析结果的任务
+func RefreshQuestionStats(c *gin.Context) {
+	job, err := service.Job.Submit(service.JobTypeItemAnalysis, nil, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "题目分析任务已提交",
+	})
+}
+
+// SearchQuestions 全文检索题目，检索题干、选项和解析
+func SearchQuestions(c *gin.Context) {
+	var query struct {
+		Page     int    `form:"page,default=1"`
+		Size     int    `form:"size,default=10"`
+		Keyword  string `form:"keyword"`
+		Type     string `form:"type"`
+		CourseID uint   `form:"course_id"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 || query.Size > 100 {
+		query.Size = 10
+	}
+
+	hits, total, err := service.QuestionSearch.Search(service.SearchQuery{
+		Keyword:  query.Keyword,
+		CourseID: query.CourseID,
+		Type:     query.Type,
+		Page:     query.Page,
+		Size:     query.Size,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	// 附带题目分析结果，与题目列表一致
+	questionIds := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		questionIds = append(questionIds, hit.ID)
+	}
+	stats, err := service.ItemAnalysis.GetStats(questionIds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取题目分析结果失败",
+		})
+		return
+	}
+
+	items := make([]gin.H, 0, len(hits))
+	for _, hit := range hits {
+		items = append(items, gin.H{
+			"id":                hit.ID,
+			"type":              hit.Type,
+			"type_desc":         getQuestionTypeDesc(hit.Type),
+			"question":          hit.Question,
+			"stem_media":        hit.StemMedia,
+			"options":           hit.Options,
+			"answer":            hit.Answer,
+			"explanation":       hit.Explanation,
+			"explanation_media": hit.ExplanationMedia,
+			"course_id":         hit.CourseID,
+			"course_name":       hit.CourseName,
+			"score":             hit.Score,
+			"highlights":        hit.Highlights,
+			"stats":             questionStatResponse(stats, hit.ID),
+		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": gin.H{
-			"id":          question.ID,
-			"type":        question.Type,
-			"type_desc":   typeDesc,
-			"question":    question.Question,
-			"options":     questionOptions,
-			"answer":      question.Answer,
-			"explanation": question.Explanation,
-			"course_id":   question.CourseID,
-			"course_name": courseName,
-			"created_at":  question.CreatedAt,
+			"total": total,
+			"items": items,
 		},
 	})
 }
 
+// RebuildSearchIndex 提交重建题目检索索引的任务
+func RebuildSearchIndex(c *gin.Context) {
+	job, err := service.Job.Submit(service.JobTypeSearchReindex, nil, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "重建检索索引任务已提交",
+	})
+}
+
 // getQuestionTypeDesc 获取题目类型的中文描述
 func getQuestionTypeDesc(qType string) string {
 	switch qType {
@@ -276,56 +378,28 @@ func getQuestionTypeDesc(qType string) string {
 
 // QuestionRequest 创建/更新题目请求
 type QuestionRequest struct {
-	Type        string                `json:"type" binding:"required"`
-	Question    string                `json:"question" binding:"required"`
-	Options     model.QuestionOptions `json:"options" binding:"required"`
-	Answer      string                `json:"answer" binding:"required"`
-	Explanation string                `json:"explanation"`
-	CourseID    uint                  `json:"course_id" binding:"required"`
+	Type             string                `json:"type" binding:"required"`
+	Question         string                `json:"question" binding:"required"`
+	StemMedia        model.MediaRefs       `json:"stem_media"`
+	Options          model.QuestionOptions `json:"options" binding:"required"`
+	Answer           string                `json:"answer" binding:"required"`
+	Explanation      string                `json:"explanation"`
+	ExplanationMedia model.MediaRefs       `json:"explanation_media"`
+	CourseID         uint                  `json:"course_id" binding:"required"`
+	SectionID        uint                  `json:"section_id"` // 所属小节，0表示不分配章节
+	IsTrial          bool                  `json:"is_trial"`   // 是否为试用题目
 }
 
-// validateQuestionOptions 验证题目选项和答案格式
-func validateQuestionOptions(qType string, options model.QuestionOptions, answer string) error {
-	switch qType {
-	case "judge":
-		// 判断题必须只有两个选项：A.正确、B.错误
-		if len(options) != 2 {
-			return errors.New("判断题必须有且只有两个选项")
-		}
-		if options[0].Text != "正确" || options[1].Text != "错误" {
-			return errors.New("判断题选项必须为：正确、错误")
-		}
-		if answer != "A" && answer != "B" {
-			return errors.New("判断题答案必须为A或B")
-		}
-	case "single":
-		// 单选题选项必须是A-D，答案必须是其中之一
-		if len(options) < 2 || len(options) > 26 {
-			return errors.New("单选题选项数量必须在2-26之间")
-		}
-		if answer < "A" || answer > string(rune('A'+len(options)-1)) {
-			return errors.New("单选题答案必须是选项中的一个字母")
-		}
-	case "multiple":
-		// 多选题选项必须是A-Z，答案必须是其中的一个或多个
-		if len(options) < 2 || len(options) > 26 {
-			return errors.New("多选题选项数量必须在2-26之间")
-		}
-		// 验证答案格式（必须是大写字母组合，如"ABC"）
-		answerMap := make(map[rune]bool)
-		for _, a := range answer {
-			if a < 'A' || a > rune('A'+len(options)-1) {
-				return errors.New("多选题答案必须是选项标签的组合")
-			}
-			answerMap[a] = true
-		}
-		if len(answer) == 0 {
-			return errors.New("多选题答案不能为空")
-		}
-	default:
-		return errors.New("不支持的题目类型")
+// normalizeQuestionMedia 校验题目中引用的媒体并补全类型和访问地址
+func normalizeQuestionMedia(req *QuestionRequest) error {
+	var err error
+	if req.StemMedia, err = service.Media.NormalizeRefs(req.StemMedia); err != nil {
+		return fmt.Errorf("题干媒体: %v", err)
 	}
-	return nil
+	if req.ExplanationMedia, err = service.Media.NormalizeRefs(req.ExplanationMedia); err != nil {
+		return fmt.Errorf("解析媒体: %v", err)
+	}
+	return service.Media.NormalizeOptionRefs(req.Options)
 }
 
 // CreateQuestion 创建题目
@@ -349,7 +423,7 @@ func CreateQuestion(c *gin.Context) {
 	}
 
 	// 验证选项和答案格式
-	if err := validateQuestionOptions(req.Type, req.Options, req.Answer); err != nil {
+	if err := service.ValidateQuestionOptions(req.Type, req.Options, req.Answer); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": 400,
 			"msg":  err.Error(),
@@ -367,37 +441,45 @@ func CreateQuestion(c *gin.Context) {
 		return
 	}
 
-	// 创建题目 - 将选项格式转换为字符串数组格式
-	var optionsJSON []byte
-	var err error
+	// 验证小节属于该课程
+	if err := service.Chapter.CheckSection(req.CourseID, req.SectionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
 
-	if req.Type == "judge" {
-		// 判断题使用固定格式
-		optionsJSON = []byte(`["A.正确","B.错误"]`)
-	} else {
-		// 构建格式化的选项数组
-		options := make([]string, len(req.Options))
-		for i, opt := range req.Options {
-			// 只使用文本内容，不包含标签
-			options[i] = string(rune('A'+i)) + "." + opt.Text
-		}
-		optionsJSON, err = json.Marshal(options)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code": 500,
-				"msg":  "处理选项数据失败",
-			})
-			return
-		}
+	// 校验题目中引用的媒体
+	if err := normalizeQuestionMedia(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	// 创建题目 - 将选项格式转换为存储格式
+	optionsJSON, err := service.EncodeQuestionOptions(req.Type, req.Options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "处理选项数据失败",
+		})
+		return
 	}
 
 	// 创建题目记录
 	question := model.Question{
-		Type:        req.Type,
-		Question:    req.Question,
-		Answer:      req.Answer,
-		Explanation: req.Explanation,
-		CourseID:    req.CourseID,
+		Type:             req.Type,
+		Question:         req.Question,
+		StemMedia:        req.StemMedia,
+		Answer:           req.Answer,
+		Explanation:      req.Explanation,
+		ExplanationMedia: req.ExplanationMedia,
+		CourseID:         req.CourseID,
+		SectionID:        req.SectionID,
+		IsTrial:          req.IsTrial,
 	}
 
 	// 使用原生SQL语句来插入JSON格式的选项
@@ -412,7 +494,7 @@ func CreateQuestion(c *gin.Context) {
 	}
 
 	// 使用原生SQL更新options字段为正确的JSON格式
-	if err := tx.Exec("UPDATE questions SET options = ? WHERE id = ?", string(optionsJSON), question.ID).Error; err != nil {
+	if err := tx.Exec("UPDATE questions SET options = ? WHERE id = ?", optionsJSON, question.ID).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
@@ -421,6 +503,16 @@ func CreateQuestion(c *gin.Context) {
 		return
 	}
 
+	// 更新检索文档
+	if err := service.QuestionSearch.IndexTx(tx, question.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "更新检索索引失败",
+		})
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
@@ -467,7 +559,7 @@ func UpdateQuestion(c *gin.Context) {
 	}
 
 	// 验证选项和答案格式
-	if err := validateQuestionOptions(req.Type, req.Options, req.Answer); err != nil {
+	if err := service.ValidateQuestionOptions(req.Type, req.Options, req.Answer); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": 400,
 			"msg":  err.Error(),
@@ -485,6 +577,15 @@ func UpdateQuestion(c *gin.Context) {
 		return
 	}
 
+	// 验证小节属于该课程
+	if err := service.Chapter.CheckSection(req.CourseID, req.SectionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
 	// 检查题目是否存在
 	var count int64
 	if err := database.DB.Model(&model.Question{}).Where("id = ?", id).Count(&count).Error; err != nil {
@@ -503,39 +604,51 @@ func UpdateQuestion(c *gin.Context) {
 		return
 	}
 
-	// 创建题目 - 将选项格式转换为字符串数组格式
-	var optionsJSON []byte
+	// 校验题目中引用的媒体
+	if err := normalizeQuestionMedia(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
 
-	if req.Type == "judge" {
-		// 判断题使用固定格式
-		optionsJSON = []byte(`["A.正确","B.错误"]`)
-	} else {
-		// 构建格式化的选项数组
-		options := make([]string, len(req.Options))
-		for i, opt := range req.Options {
-			// 只使用文本内容，不包含标签
-			options[i] = string(rune('A'+i)) + "." + opt.Text
-		}
-		optionsJSON, err = json.Marshal(options)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code": 500,
-				"msg":  "处理选项数据失败",
-			})
-			return
-		}
+	// 将选项格式转换为存储格式
+	optionsJSON, err := service.EncodeQuestionOptions(req.Type, req.Options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "处理选项数据失败",
+		})
+		return
 	}
 
 	// 开始事务
 	tx := database.DB.Begin()
 
+	// 修改了解析内容的视为人工解析
+	if err := tx.Model(&model.Question{}).
+		Where("id = ? AND (explanation IS NULL OR explanation <> ?)", id, req.Explanation).
+		UpdateColumn("explanation_source", model.ExplanationSourceManual).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "更新题目失败",
+		})
+		return
+	}
+
 	// 更新基本字段
 	if err := tx.Model(&model.Question{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"type":        req.Type,
-		"question":    req.Question,
-		"answer":      req.Answer,
-		"explanation": req.Explanation,
-		"course_id":   req.CourseID,
+		"type":              req.Type,
+		"question":          req.Question,
+		"stem_media":        req.StemMedia,
+		"answer":            req.Answer,
+		"explanation":       req.Explanation,
+		"explanation_media": req.ExplanationMedia,
+		"course_id":         req.CourseID,
+		"section_id":        req.SectionID,
+		"is_trial":          req.IsTrial,
 	}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -546,7 +659,7 @@ func UpdateQuestion(c *gin.Context) {
 	}
 
 	// 使用原生SQL更新options字段为正确的JSON格式
-	if err := tx.Exec("UPDATE questions SET options = ? WHERE id = ?", string(optionsJSON), id).Error; err != nil {
+	if err := tx.Exec("UPDATE questions SET options = ? WHERE id = ?", optionsJSON, id).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
@@ -555,6 +668,16 @@ func UpdateQuestion(c *gin.Context) {
 		return
 	}
 
+	// 更新检索文档
+	if err := service.QuestionSearch.IndexTx(tx, uint(id)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "更新检索索引失败",
+		})
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
@@ -603,364 +726,105 @@ func DeleteQuestion(c *gin.Context) {
 	})
 }
 
-// ExportQuestions 导出题库
+// ExportQuestions 提交题库导出任务
+// format=csv（默认）导出CSV文件，format=zip 导出包含媒体文件的压缩包，任务完成后通过任务接口下载
 func ExportQuestions(c *gin.Context) {
 	courseID, _ := strconv.ParseUint(c.Query("course_id"), 10, 32)
-
-	// 构建查询条件
-	db := database.DB.Model(&model.Question{})
-	if courseID > 0 {
-		db = db.Where("course_id = ?", courseID)
+	format := c.DefaultQuery("format", "csv")
+	if !questionFormats[format] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "不支持的导出格式",
+		})
+		return
 	}
 
-	// 使用临时结构体接收原始数据
-	type RawQuestion struct {
-		ID          uint           `json:"id" gorm:"column:id"`
-		Type        string         `json:"type" gorm:"column:type"`
-		Question    string         `json:"question" gorm:"column:question"`
-		Options     string         `json:"options" gorm:"column:options"` // 接收原始字符串
-		Answer      string         `json:"answer" gorm:"column:answer"`
-		Explanation string         `json:"explanation" gorm:"column:explanation"`
-		CourseID    uint           `json:"course_id" gorm:"column:course_id"`
-		CreatedAt   time.Time      `json:"created_at" gorm:"column:created_at"`
-		UpdatedAt   time.Time      `json:"updated_at" gorm:"column:updated_at"`
-		DeletedAt   gorm.DeletedAt `json:"-" gorm:"column:deleted_at"`
-	}
-
-	var questions []RawQuestion
-	if err := db.Find(&questions).Error; err != nil {
+	job, err := service.Job.Submit(service.JobTypeQuestionExport, service.QuestionExportParams{
+		CourseID: uint(courseID),
+		Format:   format,
+	}, c.GetUint("userId"))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
-			"msg":  "获取题目失败",
+			"msg":  err.Error(),
 		})
 		return
 	}
 
-	// 返回CSV格式数据
-	c.Header("Content-Type", "text/csv; charset=utf-8")
-	c.Header("Content-Disposition", "attachment; filename=questions.csv")
-
-	// 添加BOM头，解决Excel打开中文乱码问题
-	c.Writer.Write([]byte{0xEF, 0xBB, 0xBF})
-
-	// 创建CSV写入器
-	writer := csv.NewWriter(c.Writer)
-	defer writer.Flush()
-
-	// 写入CSV头
-	header := []string{"ID", "题目类型", "题目内容", "选项", "答案", "解析", "课程ID", "题目类型说明"}
-	writer.Write(header)
-
-	// 写入数据
-	for _, q := range questions {
-		// 获取题目类型的中文描述
-		typeDesc := getQuestionTypeDesc(q.Type)
-
-		// 处理选项格式
-		var optionsStr string
-		if q.Type == "judge" {
-			// 判断题使用固定格式，不需要从数据库读取
-			optionsStr = `["A.正确","B.错误"]`
-		} else {
-			// 直接使用数据库中的选项字符串
-			optionsStr = q.Options
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "导出任务已提交",
+	})
+}
 
-		record := []string{
-			strconv.FormatUint(uint64(q.ID), 10),
-			q.Type,
-			q.Question,
-			optionsStr,
-			q.Answer,
-			q.Explanation,
-			strconv.FormatUint(uint64(q.CourseID), 10),
-			typeDesc,
-		}
+// questionFormats 支持的题库导入导出格式
+var questionFormats = map[string]bool{"csv": true, "zip": true, "gift": true, "aiken": true, "qti": true}
 
-		writer.Write(record)
-	}
-}
+// importFormatByExt 根据文件扩展名推断导入格式，.txt 需明确指定 GIFT 或 Aiken
+var importFormatByExt = map[string]string{".csv": "csv", ".zip": "zip", ".gift": "gift"}
 
-// ImportQuestions 导入题库
+// ImportQuestions 提交题库导入任务
+// 支持CSV文件、由导出接口生成的包含媒体文件的zip压缩包，以及 GIFT、Aiken 文本和 QTI 2.1 题目包
 func ImportQuestions(c *gin.Context) {
 	// 获取上传的文件
 	file, err := c.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": 400,
-			"msg":  "请选择要上传的CSV文件",
+			"msg":  "请选择要上传的文件",
 		})
 		return
 	}
 
-	// 打开文件
-	src, err := file.Open()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "无法打开文件",
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		format = importFormatByExt[strings.ToLower(filepath.Ext(file.Filename))]
+	}
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无法识别文件格式，请指定 format",
 		})
 		return
 	}
-	defer src.Close()
-
-	// 检测并跳过BOM头
-	bomBuffer := make([]byte, 3)
-	if _, err := src.Read(bomBuffer); err != nil || bomBuffer[0] != 0xEF || bomBuffer[1] != 0xBB || bomBuffer[2] != 0xBF {
-		// 如果不是BOM头，回到文件开始处
-		src.Seek(0, 0)
-	}
-
-	// 读取CSV文件内容
-	reader := csv.NewReader(src)
-	reader.FieldsPerRecord = -1 // 允许每行不同的字段数
-
-	// 读取并跳过第一行（表头）
-	if _, err := reader.Read(); err != nil {
+	if !questionFormats[format] {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": 400,
-			"msg":  "CSV文件格式不正确",
+			"msg":  "不支持的导入格式",
 		})
 		return
 	}
 
-	// 开始事务
-	tx := database.DB.Begin()
-
-	// 导入数据
-	importCount := 0
-	errorCount := 0
-	errorMessages := make([]string, 0)
-
-	for lineNum := 2; ; lineNum++ { // 从第2行开始（表头为第1行）
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			errorCount++
-			errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 读取错误", lineNum))
-			continue
-		}
-
-		if len(record) < 7 {
-			errorCount++
-			errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 字段数量不足", lineNum))
-			continue // 跳过格式不正确的行
-		}
-
-		// 解析数据
-		courseID, err := strconv.ParseUint(record[6], 10, 32)
-		if err != nil {
-			errorCount++
-			errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 课程ID格式错误", lineNum))
-			continue
-		}
-
-		// 验证课程是否存在
-		var courseCount int64
-		if err := tx.Model(&model.Course{}).Where("id = ?", courseID).Count(&courseCount).Error; err != nil || courseCount == 0 {
-			errorCount++
-			errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 课程ID %d 不存在", lineNum, courseID))
-			continue
-		}
-
-		questionType := strings.TrimSpace(record[1])
-		// 验证题目类型
-		if questionType != "single" && questionType != "multiple" && questionType != "judge" {
-			errorCount++
-			errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 题目类型错误，只支持single(单选题)、multiple(多选题)或judge(判断题)", lineNum))
-			continue
-		}
-
-		// 处理选项
-		var optionsJSON string
-		var questionOptions model.QuestionOptions
-
-		if questionType == "judge" {
-			// 判断题固定选项格式
-			optionsJSON = `["A.正确","B.错误"]`
-			questionOptions = model.QuestionOptions{
-				{Label: "A", Text: "正确"},
-				{Label: "B", Text: "错误"},
-			}
-		} else {
-			// 尝试解析选项JSON
-			optionsField := strings.TrimSpace(record[3])
-			if optionsField == "" {
-				errorCount++
-				errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 选项为空", lineNum))
-				continue
-			}
-
-			var options []string
-			if err = json.Unmarshal([]byte(optionsField), &options); err != nil {
-				errorCount++
-				errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 选项JSON格式错误", lineNum))
-				continue
-			}
-
-			// 转换为 QuestionOption 格式
-			questionOptions = make(model.QuestionOptions, len(options))
-			formattedOptions := make([]string, len(options))
-
-			for i, text := range options {
-				// 清理选项文本，确保只保留实际内容
-				optionText := strings.TrimSpace(text)
-
-				// 如果文本包含标签前缀（如 "A.选项内容"），则提取实际内容
-				parts := strings.SplitN(optionText, ".", 2)
-				if len(parts) == 2 {
-					optionText = strings.TrimSpace(parts[1])
-				}
-
-				// 创建选项对象
-				questionOptions[i] = model.QuestionOption{
-					Label: string(rune('A' + i)),
-					Text:  optionText,
-				}
-
-				// 创建格式化的选项文本
-				formattedOptions[i] = string(rune('A'+i)) + "." + optionText
-			}
-
-			// 将格式化后的选项转为JSON字符串
-			optionsBytes, err := json.Marshal(formattedOptions)
-			if err != nil {
-				errorCount++
-				errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 选项格式化失败", lineNum))
-				continue
-			}
-			optionsJSON = string(optionsBytes)
-		}
-
-		// 验证答案
-		answer := strings.TrimSpace(record[4])
-		if answer == "" {
-			errorCount++
-			errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 答案为空", lineNum))
-			continue
-		}
-
-		// 清理答案，确保只包含选项序号（ABCDE等）
-		answer = cleanAnswer(answer)
-
-		// 根据题目类型验证答案格式
-		switch questionType {
-		case "judge":
-			if answer != "A" && answer != "B" {
-				errorCount++
-				errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 判断题答案必须为A(正确)或B(错误)", lineNum))
-				continue
-			}
-		case "single":
-			// 单选题答案必须是A-Z中的一个字母
-			if len(answer) != 1 || answer[0] < 'A' || answer[0] > 'Z' {
-				errorCount++
-				errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 单选题答案必须是A-Z中的一个字母", lineNum))
-				continue
-			}
-			// 检查答案是否在选项范围内
-			if int(answer[0]-'A') >= len(questionOptions) {
-				errorCount++
-				errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 答案%s超出了选项范围", lineNum, answer))
-				continue
-			}
-		case "multiple":
-			// 多选题答案必须是A-Z的组合
-			for _, ch := range answer {
-				if ch < 'A' || ch > 'Z' {
-					errorCount++
-					errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 多选题答案必须是A-Z的组合", lineNum))
-					continue
-				}
-				// 检查答案是否在选项范围内
-				if int(ch-'A') >= len(questionOptions) {
-					errorCount++
-					errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 答案%s中的%c超出了选项范围", lineNum, answer, ch))
-					continue
-				}
-			}
-		}
-
-		// 创建题目
-		question := model.Question{
-			Type:        questionType,
-			Question:    record[2],
-			Answer:      answer,
-			Explanation: record[5],
-			CourseID:    uint(courseID),
-		}
-
-		// 跳过ID字段，让数据库自动生成
-		if err := tx.Create(&question).Error; err != nil {
-			errorCount++
-			errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 创建题目失败: %s", lineNum, err.Error()))
-			continue
-		}
-
-		// 使用原生SQL更新options字段为正确的JSON格式
-		if err := tx.Exec("UPDATE questions SET options = ? WHERE id = ?", optionsJSON, question.ID).Error; err != nil {
-			errorCount++
-			errorMessages = append(errorMessages, fmt.Sprintf("第%d行: 更新选项数据失败: %s", lineNum, err.Error()))
-			continue
-		}
-
-		importCount++
+	// GIFT、Aiken、QTI 不包含课程信息，需指定导入的课程；zip 可能是 QTI 题目包，课程在任务中校验
+	courseID, _ := strconv.ParseUint(c.PostForm("course_id"), 10, 32)
+	if courseID == 0 && (format == "gift" || format == "aiken" || format == "qti") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请选择导入的课程",
+		})
+		return
 	}
 
-	// 如果有错误但也有成功导入的记录，仍然提交事务
-	if importCount > 0 {
-		if err := tx.Commit().Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code": 500,
-				"msg":  "导入失败: " + err.Error(),
-			})
-			return
-		}
-	} else if errorCount > 0 {
-		// 如果全部导入失败，回滚事务
-		tx.Rollback()
-	} else {
-		// 如果没有记录，也提交事务
-		tx.Commit()
+	job, err := service.Job.SubmitWithUpload(service.JobTypeQuestionImport, service.QuestionImportParams{
+		Format:     format,
+		Size:       file.Size,
+		UploaderID: c.GetUint("userId"),
+		CourseID:   uint(courseID),
+	}, file, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
 	}
 
-	response := gin.H{
+	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
-		"data": gin.H{
-			"import_count": importCount,
-			"error_count":  errorCount,
-		},
-		"msg": "导入完成",
-	}
-
-	// 如果有错误，添加错误信息
-	if errorCount > 0 {
-		if len(errorMessages) > 10 {
-			errorMessages = append(errorMessages[:10], "...")
-		}
-		response["errors"] = errorMessages
-	}
-
-	c.JSON(http.StatusOK, response)
-}
-
-// cleanAnswer 清理答案，确保只包含选项序号（ABCDE等）
-func cleanAnswer(answer string) string {
-	// 将答案转为大写
-	answer = strings.ToUpper(answer)
-
-	// 过滤出所有A-Z的字符
-	var result strings.Builder
-	for _, ch := range answer {
-		if ch >= 'A' && ch <= 'Z' {
-			result.WriteRune(ch)
-		}
-	}
-
-	return result.String()
+		"data": gin.H{"job_id": job.ID},
+		"msg":  "导入任务已提交",
+	})
 }
 
 // BatchDeleteQuestions 批量删除题目
@@ -1004,6 +868,77 @@ func BatchDeleteQuestions(c *gin.Context) {
 	})
 }
 
+// BatchMoveQuestions 批量移动题目到其他课程
+func BatchMoveQuestions(c *gin.Context) {
+	var req struct {
+		service.QuestionSelector
+		TargetCourseID uint `json:"target_course_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	result, err := service.QuestionBulk.Move(req.QuestionSelector, req.TargetCourseID)
+	respondBulkResult(c, result, err, "移动完成")
+}
+
+// BatchCopyQuestions 批量复制题目到其他课程
+func BatchCopyQuestions(c *gin.Context) {
+	var req struct {
+		service.QuestionSelector
+		TargetCourseID uint `json:"target_course_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	result, err := service.QuestionBulk.Copy(req.QuestionSelector, req.TargetCourseID)
+	respondBulkResult(c, result, err, "复制完成")
+}
+
+// BatchUpdateQuestions 批量修改题目类型或解析
+func BatchUpdateQuestions(c *gin.Context) {
+	var req struct {
+		service.QuestionSelector
+		service.BulkUpdateFields
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	result, err := service.QuestionBulk.Update(req.QuestionSelector, req.BulkUpdateFields)
+	respondBulkResult(c, result, err, "修改完成")
+}
+
+// respondBulkResult 返回批量操作结果
+func respondBulkResult(c *gin.Context, result *service.BulkResult, err error, msg string) {
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  msg,
+		"data": result,
+	})
+}
+
 // ClearQuestionsByCourse 一键清空指定课程的全部题目
 func ClearQuestionsByCourse(c *gin.Context) {
 	courseId, err := strconv.ParseUint(c.Param("course_id"), 10, 32)
@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuestionReportQuery 纠错队列查询参数
+type QuestionReportQuery struct {
+	Page     int    `form:"page,default=1"`
+	Size     int    `form:"size,default=10"`
+	Status   string `form:"status,default=pending"` // pending, resolved, rejected, all
+	CourseID uint   `form:"course_id"`
+}
+
+// ResolveQuestionReportRequest 处理纠错请求
+type ResolveQuestionReportRequest struct {
+	Status string `json:"status" binding:"required"` // resolved: 已修正，rejected: 驳回
+	Note   string `json:"note"`
+}
+
+// GetQuestionReportQueue 获取按题目汇总的纠错队列
+func GetQuestionReportQueue(c *gin.Context) {
+	var query QuestionReportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 {
+		query.Size = 10
+	}
+
+	status := query.Status
+	if status == "all" {
+		status = ""
+	}
+
+	items, total, err := service.QuestionReport.Queue(status, query.CourseID, query.Page, query.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取纠错队列失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": items,
+		},
+	})
+}
+
+// GetQuestionReports 获取题目的纠错详情
+func GetQuestionReports(c *gin.Context) {
+	questionId, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	// 返回题目完整内容，便于直接编辑
+	var question model.Question
+	if err := database.DB.First(&question, questionId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "题目不存在",
+		})
+		return
+	}
+
+	reports, err := service.QuestionReport.ListByQuestion(uint(questionId))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取纠错列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"question": question,
+			"items":    reports,
+		},
+	})
+}
+
+// ResolveQuestionReports 处理题目的全部待处理纠错
+func ResolveQuestionReports(c *gin.Context) {
+	questionId, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	var req ResolveQuestionReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	count, err := service.QuestionReport.Resolve(uint(questionId), req.Status, req.Note, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	msg := "已驳回"
+	if req.Status == model.ReportStatusResolved {
+		msg = "已标记为修正并通知学生"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  msg,
+		"data": gin.H{
+			"resolved_count": count,
+		},
+	})
+}
@@ -12,8 +12,6 @@ import (
 func GetSalesStatistics(c *gin.Context) {
 	// 获取查询参数
 	dimension := c.DefaultQuery("dimension", "day") // 默认按天统计
-	startTimeStr := c.Query("start_time")           // 开始时间
-	endTimeStr := c.Query("end_time")               // 结束时间
 
 	// 处理时间维度
 	var timeDimension service.TimeDimension
@@ -29,11 +27,42 @@ func GetSalesStatistics(c *gin.Context) {
 	}
 
 	// 处理时间范围
+	startTime, endTime, ok := statisticsRange(c)
+	if !ok {
+		return
+	}
+
+	// 获取销售统计数据
+	statistics, err := service.Statistics.GetSalesStatistics(startTime, endTime, timeDimension)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取销售统计数据失败：" + err.Error(),
+		})
+		return
+	}
+
+	// 返回结果
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"statistics": statistics,
+			"query": gin.H{
+				"dimension":  dimension,
+				"start_time": startTime.Format("2006-01-02 15:04:05"),
+				"end_time":   endTime.Format("2006-01-02 15:04:05"),
+			},
+		},
+	})
+}
+
+// statisticsRange 解析统计的时间范围，默认为最近30天，格式错误时返回400并返回 false
+func statisticsRange(c *gin.Context) (time.Time, time.Time, bool) {
 	var startTime, endTime time.Time
 	var err error
 
 	// 如果没有提供开始时间，默认为当前时间前30天
-	if startTimeStr == "" {
+	if startTimeStr := c.Query("start_time"); startTimeStr == "" {
 		startTime = time.Now().AddDate(0, 0, -30)
 	} else {
 		startTime, err = time.ParseInLocation("2006-01-02 15:04:05", startTimeStr, time.Local)
@@ -42,12 +71,12 @@ func GetSalesStatistics(c *gin.Context) {
 				"code": 400,
 				"msg":  "开始时间格式错误，正确格式为：2006-01-02 15:04:05",
 			})
-			return
+			return startTime, endTime, false
 		}
 	}
 
 	// 如果没有提供结束时间，默认为当前时间
-	if endTimeStr == "" {
+	if endTimeStr := c.Query("end_time"); endTimeStr == "" {
 		endTime = time.Now()
 	} else {
 		endTime, err = time.ParseInLocation("2006-01-02 15:04:05", endTimeStr, time.Local)
@@ -56,27 +85,34 @@ func GetSalesStatistics(c *gin.Context) {
 				"code": 400,
 				"msg":  "结束时间格式错误，正确格式为：2006-01-02 15:04:05",
 			})
-			return
+			return startTime, endTime, false
 		}
 	}
 
-	// 获取销售统计数据
-	statistics, err := service.Statistics.GetSalesStatistics(startTime, endTime, timeDimension)
+	return startTime, endTime, true
+}
+
+// GetTrialStatistics 获取试用转化统计，按首次试用时间筛选
+func GetTrialStatistics(c *gin.Context) {
+	startTime, endTime, ok := statisticsRange(c)
+	if !ok {
+		return
+	}
+
+	statistics, err := service.Statistics.GetTrialStatistics(startTime, endTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
-			"msg":  "获取销售统计数据失败：" + err.Error(),
+			"msg":  "获取试用转化统计失败：" + err.Error(),
 		})
 		return
 	}
 
-	// 返回结果
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": gin.H{
 			"statistics": statistics,
 			"query": gin.H{
-				"dimension":  dimension,
 				"start_time": startTime.Format("2006-01-02 15:04:05"),
 				"end_time":   endTime.Format("2006-01-02 15:04:05"),
 			},
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"exam-system/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBundles 获取在售套餐列表
+func GetBundles(c *gin.Context) {
+	bundles, err := service.Bundle.List(c.GetUint("userId"), true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取套餐列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": bundles,
+	})
+}
+
+// GetBundleDetail 获取套餐详情
+func GetBundleDetail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	bundle, err := service.Bundle.Get(c.GetUint("userId"), uint(id), true)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": bundle,
+	})
+}
+
+// createBundlePayment 购买套餐，金额以套餐价格为准，免费套餐直接开通
+func createBundlePayment(c *gin.Context, bundleID uint, openID, orderNo string) {
+	params, order, err := service.Payment.CreateBundle(c.GetUint("userId"), bundleID, openID, orderNo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	if params == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 200,
+			"data": gin.H{
+				"orderNo": order.OrderNo,
+				"status":  "paid",
+				"message": "免费套餐已开通",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"orderNo": order.OrderNo,
+			"params":  params,
+		},
+	})
+}
@@ -3,8 +3,8 @@ package api
 import (
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
+	"exam-system/internal/service"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -76,6 +76,13 @@ func RedeemCard(c *gin.Context) {
 		})
 		return
 	}
+	if err := service.Course.CheckPurchasable(&course); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
 
 	// 验证卡券是否可以兑换指定课程
 	if card.CourseID != nil && *card.CourseID > 0 && *card.CourseID != req.CourseID {
@@ -88,16 +95,11 @@ func RedeemCard(c *gin.Context) {
 
 	now := time.Now()
 
-	// 验证用户是否已经购买过该课程（忽略已过期订单）
-	var orderCount int64
-	database.DB.Model(&model.Order{}).
-		Where("user_id = ? AND course_id = ? AND status = ?", userID, req.CourseID, "paid").
-		Where("(expire_time IS NULL OR expire_time > ?)", now).
-		Count(&orderCount)
-	if orderCount > 0 {
+	// 已永久拥有时无需兑换，未到期时兑换作为续费
+	if err := service.Entitlement.CheckRenewable(userID.(uint), req.CourseID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": 400,
-			"msg":  "您已经购买过该课程",
+			"msg":  err.Error(),
 		})
 		return
 	}
@@ -105,18 +107,25 @@ func RedeemCard(c *gin.Context) {
 	// 开始事务
 	tx := database.DB.Begin()
 
-	// 创建订单
-	orderExpireTime := now.AddDate(0, 0, card.ExpireDays)
-	orderNo := now.Format("20060102150405") + strconv.Itoa(int(userID.(uint)))
+	// 创建订单，用户仍有未到期的权限时在其到期时间上叠加
+	orderExpireTime, err := service.Entitlement.RenewalExpireTime(tx, userID.(uint), req.CourseID, card.ExpireDays)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
+	}
 	order := model.Order{
-		OrderNo:     orderNo,
+		OrderNo:     service.Order.GenerateOrderNo(),
 		UserID:      userID.(uint),
 		CourseID:    req.CourseID,
 		Amount:      card.Amount,
 		Status:      "paid", // 直接设置为已支付
 		PaymentType: "card", // 支付方式为卡券
 		PayTime:     &now,
-		ExpireTime:  &orderExpireTime,
+		ExpireTime:  orderExpireTime,
 	}
 
 	if err := tx.Create(&order).Error; err != nil {
@@ -128,6 +137,16 @@ func RedeemCard(c *gin.Context) {
 		return
 	}
 
+	// 开通课程权限
+	if err := service.Entitlement.SyncOrder(tx, order.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "开通课程权限失败",
+		})
+		return
+	}
+
 	// 创建卡券兑换记录
 	record := model.CardRecord{
 		CardID:   card.ID,
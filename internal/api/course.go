@@ -41,7 +41,7 @@ func GetCourseDetail(c *gin.Context) {
 		return
 	}
 
-	course, err := service.Course.GetDetail(uint(courseId))
+	course, err := service.Course.GetDetail(uint(courseId), c.GetUint("userId"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
@@ -158,10 +158,11 @@ func SubmitCourseExam(c *gin.Context) {
 
 	// 解析请求体
 	var req struct {
-		UserID       uint    `json:"user_id"`
-		CourseID     uint    `json:"course_id"`
-		Score        float64 `json:"score"`
-		WrongAnswers []uint  `json:"wrong_answers"`
+		UserID       uint              `json:"user_id"`
+		CourseID     uint              `json:"course_id"`
+		Score        float64           `json:"score"`
+		WrongAnswers []uint            `json:"wrong_answers"`
+		Answers      map[uint][]string `json:"answers"` // 各题所选选项，可选
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -192,7 +193,7 @@ func SubmitCourseExam(c *gin.Context) {
 	}
 
 	// 记录考试结果
-	record, err := service.Course.SubmitExamAnswers(userId, uint(courseId), req.Score, req.WrongAnswers)
+	record, err := service.Course.SubmitExamAnswers(userId, uint(courseId), req.Score, req.WrongAnswers, req.Answers)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
@@ -205,7 +206,7 @@ func SubmitCourseExam(c *gin.Context) {
 	passScore := 60.0 // 默认及格分数为60分
 
 	// 获取课程信息以确定实际及格分数
-	course, err := service.Course.GetDetail(uint(courseId))
+	course, err := service.Course.GetDetail(uint(courseId), userId)
 	if err == nil && course.MockExamConfig != nil && course.MockExamConfig.Score > 0 {
 		passScore = float64(course.MockExamConfig.Score)
 	}
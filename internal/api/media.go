@@ -0,0 +1,45 @@
+package api
+
+import (
+	"exam-system/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMediaFile 获取题目中引用的媒体文件
+func GetMediaFile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	media, reader, err := service.Media.Open(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "媒体文件不存在",
+		})
+		return
+	}
+	defer reader.Close()
+
+	// 媒体文件内容不可变，允许浏览器长期缓存
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	// 本地文件支持 Range 请求，方便音视频拖动播放
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		c.Header("Content-Type", media.ContentType)
+		http.ServeContent(c.Writer, c.Request, media.FileName, media.CreatedAt, seeker)
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, media.Size, media.ContentType, reader, nil)
+}
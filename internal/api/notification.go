@@ -0,0 +1,81 @@
+package api
+
+import (
+	"exam-system/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications 获取当前用户的站内通知，unread=true 时只返回未读通知
+func GetNotifications(c *gin.Context) {
+	page, size := parsePageSize(c)
+	unreadOnly := c.Query("unread") == "true"
+
+	notifications, total, err := service.Notification.List(c.GetUint("userId"), unreadOnly, page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取通知列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": notifications,
+		},
+	})
+}
+
+// GetUnreadNotificationCount 获取未读通知数量
+func GetUnreadNotificationCount(c *gin.Context) {
+	count, err := service.Notification.UnreadCount(c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取未读通知数量失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"count": count,
+		},
+	})
+}
+
+// MarkNotificationsRead 将通知标记为已读，ids 为空时全部标记
+func MarkNotificationsRead(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	count, err := service.Notification.MarkRead(c.GetUint("userId"), req.IDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "标记已读失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "操作成功",
+		"data": gin.H{
+			"updated_count": count,
+		},
+	})
+}
@@ -11,6 +11,7 @@ import (
 func CreateOrder(c *gin.Context) {
 	var req struct {
 		CourseID uint `json:"courseId" binding:"required"`
+		PlanID   uint `json:"planId"` // 购买方案ID，课程设置了购买方案时必填
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -21,7 +22,7 @@ func CreateOrder(c *gin.Context) {
 	}
 
 	userId := c.GetUint("userId")
-	order, err := service.Order.Create(userId, req.CourseID)
+	order, err := service.Order.Create(userId, req.CourseID, req.PlanID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
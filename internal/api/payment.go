@@ -50,6 +50,7 @@ func CreatePayment(c *gin.Context) {
 		CourseID string `json:"course_id" binding:"required"`
 		TotalFee int    `json:"total_fee" binding:"required"`
 		OpenID   string `json:"open_id" binding:"required"`
+		PlanID   uint   `json:"plan_id"`            // 购买方案ID，课程设置了购买方案时必填
 		OrderNo  string `json:"order_no,omitempty"` // 可选参数，用于重新发起支付
 	}
 
@@ -58,6 +59,17 @@ func CreatePayment(c *gin.Context) {
 	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(rawData))
 	fmt.Printf("CreatePayment 原始请求数据: %s\n", string(rawData))
 
+	// 购买套餐：不需要 course_id 和 total_fee，金额以套餐价格为准
+	var bundleReq struct {
+		BundleID uint   `json:"bundle_id"`
+		OpenID   string `json:"open_id"`
+		OrderNo  string `json:"order_no"`
+	}
+	if json.Unmarshal(rawData, &bundleReq) == nil && bundleReq.BundleID > 0 {
+		createBundlePayment(c, bundleReq.BundleID, bundleReq.OpenID, bundleReq.OrderNo)
+		return
+	}
+
 	if err := c.ShouldBindJSON(&req); err != nil {
 		fmt.Printf("CreatePayment 参数绑定错误: %v\n", err)
 
@@ -84,6 +96,10 @@ func CreatePayment(c *gin.Context) {
 
 					// 继续处理请求
 					userID := c.GetUint("userId")
+					var planID uint
+					if planIDVal, ok := manualReq["plan_id"].(float64); ok {
+						planID = uint(planIDVal)
+					}
 
 					// 处理金额为0的情况（免费课程）
 					if totalFeeInt == 0 {
@@ -95,7 +111,7 @@ func CreatePayment(c *gin.Context) {
 						}
 
 						fmt.Println("手动处理: 检测到免费课程，使用 CreateFreeOrder")
-						order, err := service.Payment.CreateFreeOrder(userID, courseIDStr, orderNo)
+						order, err := service.Payment.CreateFreeOrder(userID, courseIDStr, planID, orderNo)
 						if err != nil {
 							c.JSON(http.StatusInternalServerError, gin.H{
 								"code": 500,
@@ -124,7 +140,7 @@ func CreatePayment(c *gin.Context) {
 					}
 
 					fmt.Println("手动处理: 执行正常支付流程")
-					params, order, err := service.Payment.Create(userID, courseIDStr, totalFeeInt, openIDStr, orderNo)
+					params, order, err := service.Payment.Create(userID, courseIDStr, planID, openIDStr, orderNo)
 					if err != nil {
 						c.JSON(http.StatusInternalServerError, gin.H{
 							"code": 500,
@@ -132,6 +148,17 @@ func CreatePayment(c *gin.Context) {
 						})
 						return
 					}
+					if params == nil {
+						c.JSON(http.StatusOK, gin.H{
+							"code": 200,
+							"data": gin.H{
+								"orderNo": order.OrderNo,
+								"status":  "paid",
+								"message": "免费课程已开通",
+							},
+						})
+						return
+					}
 
 					c.JSON(http.StatusOK, gin.H{
 						"code": 200,
@@ -152,8 +179,8 @@ func CreatePayment(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("CreatePayment 请求参数: CourseID=%s, TotalFee=%d, OpenID=%s, OrderNo=%s\n",
-		req.CourseID, req.TotalFee, req.OpenID, req.OrderNo)
+	fmt.Printf("CreatePayment 请求参数: CourseID=%s, PlanID=%d, TotalFee=%d, OpenID=%s, OrderNo=%s\n",
+		req.CourseID, req.PlanID, req.TotalFee, req.OpenID, req.OrderNo)
 
 	userID := c.GetUint("userId")
 	fmt.Printf("CreatePayment 用户ID: %d\n", userID)
@@ -162,7 +189,7 @@ func CreatePayment(c *gin.Context) {
 	if req.TotalFee == 0 {
 		fmt.Println("CreatePayment 检测到免费课程，使用 CreateFreeOrder")
 		// 直接创建订单并标记为已支付
-		order, err := service.Payment.CreateFreeOrder(userID, req.CourseID, req.OrderNo)
+		order, err := service.Payment.CreateFreeOrder(userID, req.CourseID, req.PlanID, req.OrderNo)
 		if err != nil {
 			fmt.Printf("CreatePayment 创建免费订单失败: %v\n", err)
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -185,7 +212,7 @@ func CreatePayment(c *gin.Context) {
 
 	// 正常支付流程
 	fmt.Println("CreatePayment 执行正常支付流程")
-	params, order, err := service.Payment.Create(userID, req.CourseID, req.TotalFee, req.OpenID, req.OrderNo)
+	params, order, err := service.Payment.Create(userID, req.CourseID, req.PlanID, req.OpenID, req.OrderNo)
 	if err != nil {
 		fmt.Printf("CreatePayment 创建支付订单失败: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -195,6 +222,19 @@ func CreatePayment(c *gin.Context) {
 		return
 	}
 
+	// 所选购买方案价格为0时已直接开通
+	if params == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 200,
+			"data": gin.H{
+				"orderNo": order.OrderNo,
+				"status":  "paid",
+				"message": "免费课程已开通",
+			},
+		})
+		return
+	}
+
 	fmt.Printf("CreatePayment 创建支付订单成功: OrderNo=%s\n", order.OrderNo)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
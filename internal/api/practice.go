@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"exam-system/internal/service"
 	"exam-system/internal/types"
 	"net/http"
@@ -38,7 +39,7 @@ func GetWrongQuestions(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 
-	questions, total, err := service.Practice.GetWrongQuestions(userId, courseId, page, pageSize)
+	questions, total, err := service.Practice.GetWrongQuestions(userId, courseId, chapterFilter(c), page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
@@ -70,7 +71,7 @@ func GetWrongQuestionsByCourse(c *gin.Context) {
 	}
 
 	// 直接获取全部错题，不使用分页
-	questions, total, err := service.Practice.GetAllWrongQuestionsByCourse(userId, courseId)
+	questions, total, err := service.Practice.GetAllWrongQuestionsByCourse(userId, courseId, chapterFilter(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
@@ -88,6 +89,13 @@ func GetWrongQuestionsByCourse(c *gin.Context) {
 	})
 }
 
+// chapterFilter 从查询参数 chapter_id、section_id 获取章节筛选条件
+func chapterFilter(c *gin.Context) service.ChapterFilter {
+	chapterId, _ := strconv.ParseUint(c.Query("chapter_id"), 10, 32)
+	sectionId, _ := strconv.ParseUint(c.Query("section_id"), 10, 32)
+	return service.ChapterFilter{ChapterID: uint(chapterId), SectionID: uint(sectionId)}
+}
+
 // 清空所有错题
 func ClearWrongQuestions(c *gin.Context) {
 	userId := c.GetUint("userId")
@@ -123,8 +131,22 @@ func GenerateExplanation(c *gin.Context) {
 	var req GenerateExplanationRequest
 	_ = c.ShouldBindJSON(&req)
 
-	explanation, err := service.Practice.GenerateQuestionExplanation(uint(questionId), req.Force)
+	view, err := service.Practice.GenerateQuestionExplanation(c.Request.Context(), c.GetUint("userId"), uint(questionId), req.Force)
 	if err != nil {
+		if errors.Is(err, service.ErrAIQuotaExceeded) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code": 429,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, service.ErrNoCourseAccess) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": 403,
+				"msg":  err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
 			"msg":  err.Error(),
@@ -135,11 +157,66 @@ func GenerateExplanation(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": gin.H{
-			"explanation": explanation,
+			"explanation":    view.Content,
+			"ai_explanation": view,
 		},
 	})
 }
 
+// StreamExplanation 以 Server-Sent Events 流式返回AI生成的题目解析
+// 事件依次为若干 delta（解析片段）和一个 done（完整结果），出错时为 error；客户端断开时停止生成，不保存不完整的解析
+func StreamExplanation(c *gin.Context) {
+	questionId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的题目ID",
+		})
+		return
+	}
+
+	var req GenerateExplanationRequest
+	_ = c.ShouldBindJSON(&req)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 Nginx 缓冲，片段到达后立即发送
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	view, err := service.Practice.StreamQuestionExplanation(ctx, c.GetUint("userId"), uint(questionId), req.Force, func(delta string) error {
+		c.SSEvent("delta", gin.H{"content": delta})
+		c.Writer.Flush()
+		return ctx.Err()
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// 客户端已断开
+			return
+		}
+		code := 500
+		if errors.Is(err, service.ErrAIQuotaExceeded) {
+			code = 429
+		} else if errors.Is(err, service.ErrNoCourseAccess) {
+			code = 403
+		}
+		c.SSEvent("error", gin.H{
+			"code": code,
+			"msg":  err.Error(),
+		})
+		c.Writer.Flush()
+		return
+	}
+
+	c.SSEvent("done", gin.H{
+		"explanation":    view.Content,
+		"ai_explanation": view,
+	})
+	c.Writer.Flush()
+}
+
 // 提交练习答案
 func SubmitPractice(c *gin.Context) {
 	userId := c.GetUint("userId")
@@ -154,6 +231,13 @@ func SubmitPractice(c *gin.Context) {
 
 	correct, err := service.Practice.Submit(userId, req.QuestionID, req.Answer)
 	if err != nil {
+		if errors.Is(err, service.ErrNoCourseAccess) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": 403,
+				"msg":  err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
 			"msg":  err.Error(),
@@ -24,6 +24,9 @@ func GetCourseQuestions(c *gin.Context) {
 	// 获取题目类型（single、multiple、judge）
 	questionType := c.DefaultQuery("type", "")
 
+	// 按章或小节练习
+	chapter := chapterFilter(c)
+
 	// 获取用户ID
 	userId := c.GetUint("userId")
 	if userId == 0 {
@@ -35,7 +38,7 @@ func GetCourseQuestions(c *gin.Context) {
 	}
 
 	// 调用服务获取题目
-	questions, err := service.Question.GetQuestionsByCourse(userId, uint(courseId), questionType)
+	questions, err := service.Question.GetQuestionsByCourse(userId, uint(courseId), questionType, chapter)
 	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{
 			"code": 403,
@@ -49,3 +52,58 @@ func GetCourseQuestions(c *gin.Context) {
 		"data": questions,
 	})
 }
+
+// GetTrialQuestions 获取课程的试用题目，未购买的用户也可以练习
+func GetTrialQuestions(c *gin.Context) {
+	courseId, err := strconv.ParseUint(c.Param("course_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "课程ID格式错误",
+		})
+		return
+	}
+
+	questions, err := service.Trial.GetQuestions(c.GetUint("userId"), uint(courseId), c.DefaultQuery("type", ""))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": questions,
+	})
+}
+
+// SearchQuestions 在已购买的课程中全文检索题目
+func SearchQuestions(c *gin.Context) {
+	page, size := parsePageSize(c)
+	courseId, _ := strconv.ParseUint(c.Query("course_id"), 10, 32)
+
+	hits, total, err := service.QuestionSearch.SearchForUser(c.GetUint("userId"), service.SearchQuery{
+		Keyword:  c.Query("keyword"),
+		CourseID: uint(courseId),
+		Type:     c.Query("type"),
+		Page:     page,
+		Size:     size,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": hits,
+		},
+	})
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"exam-system/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportQuestionRequest 题目纠错请求
+type ReportQuestionRequest struct {
+	Reason  string `json:"reason" binding:"required"` // wrong_answer, wrong_explanation, typo, unclear, other
+	Comment string `json:"comment"`
+}
+
+// ReportQuestion 提交题目纠错
+func ReportQuestion(c *gin.Context) {
+	questionId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "题目ID格式错误",
+		})
+		return
+	}
+
+	var req ReportQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	report, err := service.QuestionReport.Create(c.GetUint("userId"), uint(questionId), req.Reason, req.Comment)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "纠错提交成功",
+		"data": report,
+	})
+}
+
+// GetMyQuestionReports 获取当前用户提交的题目纠错
+func GetMyQuestionReports(c *gin.Context) {
+	page, size := parsePageSize(c)
+
+	reports, total, err := service.QuestionReport.ListByUser(c.GetUint("userId"), page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "获取纠错列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"total": total,
+			"items": reports,
+		},
+	})
+}
+
+// parsePageSize 解析分页参数，size 最大为100
+func parsePageSize(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil || size < 1 || size > 100 {
+		size = 10
+	}
+
+	return page, size
+}
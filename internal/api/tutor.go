@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"exam-system/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TutorAskRequest 答疑提问请求
+type TutorAskRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// GetTutorHistory 获取题目答疑对话记录及当日剩余提问次数
+func GetTutorHistory(c *gin.Context) {
+	questionId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的题目ID",
+		})
+		return
+	}
+
+	history, err := service.Tutor.History(c.GetUint("userId"), uint(questionId))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": history,
+	})
+}
+
+// AskTutor 针对题目向AI提问
+func AskTutor(c *gin.Context) {
+	questionId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的题目ID",
+		})
+		return
+	}
+
+	var req TutorAskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请输入问题",
+		})
+		return
+	}
+
+	reply, err := service.Tutor.Ask(c.Request.Context(), c.GetUint("userId"), uint(questionId), req.Message)
+	if err != nil {
+		if errors.Is(err, service.ErrTutorQuotaExceeded) || errors.Is(err, service.ErrAIQuotaExceeded) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code": 429,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": reply,
+	})
+}
+
+// ResetTutor 清空题目答疑对话
+func ResetTutor(c *gin.Context) {
+	questionId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "无效的题目ID",
+		})
+		return
+	}
+
+	if err := service.Tutor.Reset(c.GetUint("userId"), uint(questionId)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "清空对话失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "对话已清空",
+	})
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	_ "embed"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -59,18 +60,107 @@ type Config struct {
 	} `yaml:"wechat"`
 
 	AI AIConfig `yaml:"ai"`
+
+	Media MediaConfig `yaml:"media"`
+
+	Job JobConfig `yaml:"job"`
+}
+
+// JobConfig 后台任务配置
+type JobConfig struct {
+	Workers       int `yaml:"workers"`        // 并发执行任务的工作协程数
+	RetentionDays int `yaml:"retention_days"` // 已结束任务及结果文件保留天数
 }
 
+// MediaConfig 媒体文件（题目图片、音视频等）存储配置
+type MediaConfig struct {
+	Storage      string   `yaml:"storage"`       // 存储后端: local
+	LocalDir     string   `yaml:"local_dir"`     // 本地存储目录
+	MaxSize      int64    `yaml:"max_size"`      // 单个文件最大大小(MB)
+	AllowedTypes []string `yaml:"allowed_types"` // 允许上传的文件类型(Content-Type)
+}
+
+// AIConfig AI功能配置
 type AIConfig struct {
-	Explanation ExplanationConfig `yaml:"explanation"`
+	Provider    string                  `yaml:"provider"` // 模型服务: openai（OpenAI兼容接口）、stub（本地测试，不调用外部接口）
+	OpenAI      OpenAIConfig            `yaml:"openai"`
+	Stub        StubConfig              `yaml:"stub"`
+	Prompts     map[string]PromptConfig `yaml:"prompts"` // 提示词模板，按功能名称配置，未配置的使用内置默认模板
+	Pricing     map[string]ModelPrice   `yaml:"pricing"` // 模型价格，按模型名称配置，用于统计费用
+	Batch       AIBatchConfig           `yaml:"batch"`
+	Generation  AIGenerationConfig      `yaml:"generation"`
+	Tutor       AITutorConfig           `yaml:"tutor"`
+	Quota       AIQuotaConfig           `yaml:"quota"`
+	Explanation ExplanationConfig       `yaml:"explanation"`
+}
+
+// ModelPrice 模型价格，单位：元/百万token
+type ModelPrice struct {
+	Prompt     float64 `yaml:"prompt"`     // 输入价格
+	Completion float64 `yaml:"completion"` // 输出价格
+}
+
+// AIBatchConfig AI批量任务配置
+type AIBatchConfig struct {
+	Concurrency       int `yaml:"concurrency"`         // 同时进行的请求数
+	RequestsPerMinute int `yaml:"requests_per_minute"` // 每分钟最多发起的请求数
+}
+
+// AIGenerationConfig 根据学习资料生成题目的配置
+type AIGenerationConfig struct {
+	ChunkSize       int `yaml:"chunk_size"`        // 资料按段落切分后每段的最大字符数，每段单独请求一次
+	MaxQuestions    int `yaml:"max_questions"`     // 单个任务最多生成的题目数
+	MaxMaterialSize int `yaml:"max_material_size"` // 资料文件大小上限(KB)
+}
+
+// AITutorConfig 题目答疑对话配置
+type AITutorConfig struct {
+	DailyLimit       int `yaml:"daily_limit"`        // 每个用户每天最多发送的消息数
+	HistoryMessages  int `yaml:"history_messages"`   // 每次请求带上的历史消息数
+	MaxMessageLength int `yaml:"max_message_length"` // 单条消息最大字符数
+}
+
+// AIQuotaConfig 学生端AI功能的使用额度，按自然日计算
+type AIQuotaConfig struct {
+	UserDailyRequests int     `yaml:"user_daily_requests"` // 每个用户每天最多调用次数，-1表示不限制
+	UserDailyCost     float64 `yaml:"user_daily_cost"`     // 每个用户每天最多产生的费用(元)，0表示不限制
+	DailyBudget       float64 `yaml:"daily_budget"`        // 全站每天的AI费用上限(元)，包括后台任务，达到后暂停学生端AI功能，0表示不限制
+}
+
+// OpenAIConfig OpenAI兼容接口配置，DeepSeek、通义千问、Ollama 等均可使用
+type OpenAIConfig struct {
+	APIURL      string   `yaml:"api_url"`     // Chat Completions 接口地址
+	APIKey      string   `yaml:"api_key"`     // API Key，也可通过环境变量 AI_API_KEY 设置
+	Model       string   `yaml:"model"`       // 默认模型名称
+	Temperature *float64 `yaml:"temperature"` // 默认采样温度
+	MaxTokens   int      `yaml:"max_tokens"`  // 单次回复最大token数，0表示不限制
+	Timeout     int      `yaml:"timeout"`     // 单次请求超时时间(秒)，流式请求为两次收到内容的最长间隔
+	MaxRetries  int      `yaml:"max_retries"` // 网络错误、限流或服务端错误时的重试次数，-1表示不重试
+}
+
+// StubConfig 本地测试模型配置
+type StubConfig struct {
+	Reply string `yaml:"reply"` // 固定回复内容，为空时根据请求生成
+}
+
+// PromptConfig 提示词模板，使用 Go text/template 语法
+type PromptConfig struct {
+	Version     string   `yaml:"version"`     // 模板版本，记录在AI生成的内容中，修改模板时应同时修改
+	System      string   `yaml:"system"`      // 系统提示词
+	User        string   `yaml:"user"`        // 用户提示词
+	Model       string   `yaml:"model"`       // 覆盖默认模型，可选
+	Temperature *float64 `yaml:"temperature"` // 覆盖默认采样温度，可选
 }
 
 type ExplanationConfig struct {
 	AllowOverride bool   `yaml:"allow_override"`
-	APIKey        string `yaml:"api_key"`
-	APIURL        string `yaml:"api_url"`
+	APIKey        string `yaml:"api_key"` // 已废弃，请使用 ai.openai.api_key
+	APIURL        string `yaml:"api_url"` // 已废弃，请使用 ai.openai.api_url
 }
 
+//go:embed prompts.yaml
+var defaultPromptsYAML []byte
+
 var GlobalConfig *Config
 
 func Load() (*Config, error) {
@@ -163,11 +253,37 @@ func Load() (*Config, error) {
 	}
 
 	// AI配置默认值和环境变量覆盖
-	if config.AI.Explanation.APIURL == "" {
-		config.AI.Explanation.APIURL = "https://api.deepseek.com/v1/chat/completions"
+	if err := setAIDefaults(&config.AI); err != nil {
+		return nil, err
 	}
-	if apiKey := os.Getenv("DEEPSEEK_API_KEY"); apiKey != "" {
-		config.AI.Explanation.APIKey = apiKey
+
+	// 后台任务默认值
+	if config.Job.Workers <= 0 {
+		config.Job.Workers = 2
+	}
+	if config.Job.RetentionDays <= 0 {
+		config.Job.RetentionDays = 7
+	}
+
+	// 媒体存储默认值
+	if config.Media.Storage == "" {
+		config.Media.Storage = "local"
+	}
+	if config.Media.LocalDir == "" {
+		config.Media.LocalDir = "uploads"
+	}
+	if config.Media.MaxSize == 0 {
+		config.Media.MaxSize = 10 // 10MB
+	}
+	if len(config.Media.AllowedTypes) == 0 {
+		config.Media.AllowedTypes = []string{
+			"image/png",
+			"image/jpeg",
+			"image/gif",
+			"image/webp",
+			"audio/mpeg",
+			"video/mp4",
+		}
 	}
 
 	// 如果配置了 base_url，则自动拼接相对路径的 URL
@@ -216,3 +332,90 @@ func Load() (*Config, error) {
 	GlobalConfig = config
 	return config, nil
 }
+
+// setAIDefaults 设置AI配置默认值，兼容旧版 ai.explanation 下的接口配置
+func setAIDefaults(ai *AIConfig) error {
+	if ai.Provider == "" {
+		ai.Provider = "openai"
+	}
+
+	if ai.OpenAI.APIURL == "" {
+		ai.OpenAI.APIURL = ai.Explanation.APIURL
+	}
+	if ai.OpenAI.APIURL == "" {
+		ai.OpenAI.APIURL = "https://api.deepseek.com/v1/chat/completions"
+	}
+	if ai.OpenAI.APIKey == "" {
+		ai.OpenAI.APIKey = ai.Explanation.APIKey
+	}
+	if apiKey := os.Getenv("DEEPSEEK_API_KEY"); apiKey != "" {
+		ai.OpenAI.APIKey = apiKey
+	}
+	if apiKey := os.Getenv("AI_API_KEY"); apiKey != "" {
+		ai.OpenAI.APIKey = apiKey
+	}
+	if ai.OpenAI.Model == "" {
+		ai.OpenAI.Model = "deepseek-chat"
+	}
+	if ai.OpenAI.Temperature == nil {
+		temperature := 1.0
+		ai.OpenAI.Temperature = &temperature
+	}
+	if ai.OpenAI.Timeout <= 0 {
+		ai.OpenAI.Timeout = 60
+	}
+	if ai.OpenAI.MaxRetries == 0 {
+		ai.OpenAI.MaxRetries = 2
+	} else if ai.OpenAI.MaxRetries < 0 {
+		ai.OpenAI.MaxRetries = 0
+	}
+
+	if ai.Batch.Concurrency <= 0 {
+		ai.Batch.Concurrency = 4
+	}
+	if ai.Batch.RequestsPerMinute <= 0 {
+		ai.Batch.RequestsPerMinute = 60
+	}
+	if ai.Generation.ChunkSize <= 0 {
+		ai.Generation.ChunkSize = 3000
+	}
+	if ai.Generation.MaxQuestions <= 0 {
+		ai.Generation.MaxQuestions = 50
+	}
+	if ai.Generation.MaxMaterialSize <= 0 {
+		ai.Generation.MaxMaterialSize = 1024
+	}
+	if ai.Tutor.DailyLimit <= 0 {
+		ai.Tutor.DailyLimit = 30
+	}
+	if ai.Tutor.HistoryMessages <= 0 {
+		ai.Tutor.HistoryMessages = 10
+	}
+	if ai.Tutor.MaxMessageLength <= 0 {
+		ai.Tutor.MaxMessageLength = 500
+	}
+	if ai.Quota.UserDailyRequests == 0 {
+		ai.Quota.UserDailyRequests = 50
+	}
+
+	// 未配置的提示词使用内置默认模板
+	var defaults map[string]PromptConfig
+	if err := yaml.Unmarshal(defaultPromptsYAML, &defaults); err != nil {
+		return fmt.Errorf("解析默认提示词模板失败: %v", err)
+	}
+	if ai.Prompts == nil {
+		ai.Prompts = make(map[string]PromptConfig)
+	}
+	for name, prompt := range ai.Prompts {
+		if prompt.Version == "" {
+			prompt.Version = "custom"
+			ai.Prompts[name] = prompt
+		}
+	}
+	for name, prompt := range defaults {
+		if _, ok := ai.Prompts[name]; !ok {
+			ai.Prompts[name] = prompt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CourseAccess 课程权限中间件，param 为路径中课程ID的参数名
+// check 检查用户能否访问课程（由路由注入课程权限服务），不通过时返回403
+func CourseAccess(param string, check func(userId, courseId uint) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseId, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "无效的课程ID",
+			})
+			c.Abort()
+			return
+		}
+
+		if err := check(c.GetUint("userId"), uint(courseId)); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": 403,
+				"msg":  err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
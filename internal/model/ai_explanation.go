@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// 批量生成解析的题目处理状态
+const (
+	AIItemStatusPending   = "pending"   // 等待处理
+	AIItemStatusSucceeded = "succeeded" // 已生成解析草稿
+	AIItemStatusFailed    = "failed"    // 生成失败
+	AIItemStatusSkipped   = "skipped"   // 已跳过（题目已删除或已有人工解析）
+)
+
+// AIExplanationItem 批量生成解析任务中每道题目的处理记录，任务重启后只处理仍在等待的题目
+type AIExplanationItem struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	JobID            uint      `json:"job_id" gorm:"uniqueIndex:idx_ai_item_job_question;comment:任务ID"`
+	QuestionID       uint      `json:"question_id" gorm:"uniqueIndex:idx_ai_item_job_question;index;comment:题目ID"`
+	Status           string    `json:"status" gorm:"size:20;index;default:pending;comment:处理状态"`
+	Message          string    `json:"message" gorm:"size:500;comment:失败或跳过原因"`
+	DraftID          uint      `json:"draft_id" gorm:"default:0;comment:生成的解析草稿ID"`
+	Model            string    `json:"model" gorm:"size:100;comment:使用的模型"`
+	PromptTokens     int       `json:"prompt_tokens" gorm:"default:0;comment:输入token数"`
+	CompletionTokens int       `json:"completion_tokens" gorm:"default:0;comment:输出token数"`
+	Cost             float64   `json:"cost" gorm:"type:decimal(12,6);default:0;comment:费用(元)"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+)
+
+// AI功能，用于统计各功能的用量
+const (
+	AIFeatureExplanation        = "explanation"         // 学生请求题目解析
+	AIFeatureExplanationBatch   = "explanation_batch"   // 批量生成题目解析
+	AIFeatureTutor              = "tutor"               // 题目答疑对话
+	AIFeatureQuestionGeneration = "question_generation" // 根据学习资料出题
+	AIFeatureAnswerVerification = "answer_verification" // 校验题目答案
+)
+
+// AI调用状态
+const (
+	AIUsageStatusPending   = "pending"   // 调用中，计入额度
+	AIUsageStatusSucceeded = "succeeded" // 调用成功
+	AIUsageStatusFailed    = "failed"    // 调用失败，不计入次数额度
+	AIUsageStatusCanceled  = "canceled"  // 调用方取消（如客户端断开连接），计入额度
+)
+
+// AIUsage AI调用记录，每次调用模型服务记录一条，用于额度控制和费用统计
+type AIUsage struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	UserID           uint      `json:"user_id" gorm:"index:idx_ai_usage_user_time;comment:发起调用的用户，后台任务为提交任务的管理员"`
+	Feature          string    `json:"feature" gorm:"size:30;index;comment:AI功能"`
+	CourseID         uint      `json:"course_id" gorm:"index;comment:课程ID"`
+	QuestionID       uint      `json:"question_id" gorm:"default:0;comment:题目ID"`
+	JobID            uint      `json:"job_id" gorm:"default:0;comment:后台任务ID"`
+	Status           string    `json:"status" gorm:"size:20;default:pending;comment:调用状态"`
+	Message          string    `json:"message" gorm:"size:500;comment:失败原因"`
+	Model            string    `json:"model" gorm:"size:100;comment:使用的模型"`
+	PromptVersion    string    `json:"prompt_version" gorm:"size:100;comment:提示词模板版本"`
+	PromptTokens     int       `json:"prompt_tokens" gorm:"default:0;comment:输入token数"`
+	CompletionTokens int       `json:"completion_tokens" gorm:"default:0;comment:输出token数"`
+	Cost             float64   `json:"cost" gorm:"type:decimal(12,6);default:0;comment:费用(元)"`
+	CreatedAt        time.Time `json:"created_at" gorm:"index:idx_ai_usage_user_time;index"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
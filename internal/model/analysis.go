@@ -0,0 +1,76 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 作答来源
+const (
+	AnswerSourcePractice = "practice" // 练习
+	AnswerSourceExam     = "exam"     // 模拟考试
+)
+
+// AnswerLog 学生作答记录，用于题目分析
+type AnswerLog struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	UserID       uint      `json:"user_id" gorm:"index"`
+	QuestionID   uint      `json:"question_id" gorm:"index"`
+	CourseID     uint      `json:"course_id" gorm:"index"`
+	Source       string    `json:"source" gorm:"size:20;comment:作答来源"`
+	ExamRecordID uint      `json:"exam_record_id" gorm:"index;comment:模拟考试记录ID，练习为0"`
+	Answer       string    `json:"answer" gorm:"size:50;comment:所选选项，按字母排序"`
+	Correct      bool      `json:"correct"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// QuestionStat 题目分析统计结果，定期根据作答记录重新计算
+type QuestionStat struct {
+	QuestionID     uint         `json:"question_id" gorm:"primarykey;autoIncrement:false"`
+	Attempts       int          `json:"attempts" gorm:"comment:作答次数"`
+	CorrectCount   int          `json:"correct_count" gorm:"comment:答对次数"`
+	CorrectRate    float64      `json:"correct_rate" gorm:"comment:正确率(0-1)"`
+	OptionCounts   OptionCounts `json:"option_counts" gorm:"type:json;comment:各选项被选次数"`
+	Discrimination *float64     `json:"discrimination" gorm:"comment:区分度，样本不足时为空"`
+	SuspectKey     bool         `json:"suspect_key" gorm:"index;comment:答案疑似错误"`
+	SuspectReason  string       `json:"suspect_reason" gorm:"size:255"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// OptionCounts 选项标签到被选次数的映射
+type OptionCounts map[string]int
+
+// 实现 Scanner 接口
+func (o *OptionCounts) Scan(value interface{}) error {
+	if value == nil {
+		*o = nil
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("failed to unmarshal JSON value")
+	}
+
+	if len(bytes) == 0 {
+		*o = nil
+		return nil
+	}
+
+	return json.Unmarshal(bytes, o)
+}
+
+// 实现 Valuer 接口
+func (o OptionCounts) Value() (driver.Value, error) {
+	if o == nil {
+		return nil, nil
+	}
+	return json.Marshal(o)
+}
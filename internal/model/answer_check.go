@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+)
+
+// 答案校验的题目状态
+const (
+	AnswerCheckPending   = "pending"   // 等待处理
+	AnswerCheckAgreed    = "agreed"    // 模型答案与题目答案一致
+	AnswerCheckDisagreed = "disagreed" // 模型答案与题目答案不一致，疑似答案错误
+	AnswerCheckFailed    = "failed"    // 调用模型失败或回复无法解析
+	AnswerCheckSkipped   = "skipped"   // 已跳过（题目已删除或包含模型无法查看的图片）
+)
+
+// 疑似错误的处理结果
+const (
+	AnswerCheckResolutionOpen      = ""          // 待处理
+	AnswerCheckResolutionAccepted  = "accepted"  // 采用模型答案，已更新题目答案
+	AnswerCheckResolutionCorrected = "corrected" // 已人工修正题目
+	AnswerCheckResolutionDismissed = "dismissed" // 题目答案无误
+)
+
+// AnswerCheck 答案校验任务中每道题目的校验记录，任务重启后只处理仍在等待的题目
+type AnswerCheck struct {
+	ID               uint       `json:"id" gorm:"primarykey"`
+	JobID            uint       `json:"job_id" gorm:"uniqueIndex:idx_answer_check_job_question;comment:任务ID"`
+	QuestionID       uint       `json:"question_id" gorm:"uniqueIndex:idx_answer_check_job_question;index;comment:题目ID"`
+	CourseID         uint       `json:"course_id" gorm:"index;comment:课程ID"`
+	Status           string     `json:"status" gorm:"size:20;index;default:pending;comment:校验状态"`
+	Message          string     `json:"message" gorm:"size:500;comment:失败或跳过原因"`
+	StoredAnswer     string     `json:"stored_answer" gorm:"size:255;comment:校验时的题目答案"`
+	ModelAnswer      string     `json:"model_answer" gorm:"size:255;comment:模型给出的答案"`
+	Confidence       float64    `json:"confidence" gorm:"type:decimal(4,3);default:0;comment:模型自评把握(0-1)"`
+	Reasoning        string     `json:"reasoning" gorm:"type:text;comment:模型推理过程"`
+	Model            string     `json:"model" gorm:"size:100;comment:使用的模型"`
+	PromptVersion    string     `json:"prompt_version" gorm:"size:100;comment:提示词模板版本"`
+	PromptTokens     int        `json:"prompt_tokens" gorm:"default:0;comment:输入token数"`
+	CompletionTokens int        `json:"completion_tokens" gorm:"default:0;comment:输出token数"`
+	Cost             float64    `json:"cost" gorm:"type:decimal(12,6);default:0;comment:费用(元)"`
+	Resolution       string     `json:"resolution" gorm:"size:20;default:'';comment:疑似错误的处理结果"`
+	ResolvedBy       uint       `json:"resolved_by" gorm:"default:0;comment:处理人"`
+	ResolvedAt       *time.Time `json:"resolved_at" gorm:"comment:处理时间"`
+	ResolutionNote   string     `json:"resolution_note" gorm:"size:500;comment:处理说明"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 课程套餐，一次购买开通多门课程
+type Bundle struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	Name        string         `json:"name" gorm:"size:64"`
+	Cover       string         `json:"cover" gorm:"size:255"`
+	Description string         `json:"description" gorm:"type:text"`
+	Price       float64        `json:"price"`
+	ExpireDays  int            `json:"expire_days" gorm:"default:0"` // 套餐有效期（天），0表示永久有效
+	Sort        int            `json:"sort" gorm:"default:0"`        // 排序，按从小到大排列
+	OnSale      bool           `json:"on_sale" gorm:"default:false"` // 是否上架，下架后不再出售，已购买的不受影响
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// 套餐包含的课程
+type BundleCourse struct {
+	ID       uint `gorm:"primarykey"`
+	BundleID uint `gorm:"uniqueIndex:idx_bundle_course"`
+	CourseID uint `gorm:"uniqueIndex:idx_bundle_course;index"`
+	Sort     int  // 课程在套餐中的顺序
+}
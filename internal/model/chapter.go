@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 课程的章，按教材章节组织题目
+type CourseChapter struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	CourseID  uint           `json:"course_id" gorm:"index"`
+	Name      string         `json:"name" gorm:"size:128"`
+	Sort      int            `json:"sort" gorm:"default:0"` // 排序，按从小到大排列
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// 章下的小节，题目通过 SectionID 关联小节
+type CourseSection struct {
+	ID        uint           `json:"id" gorm:"primarykey"`
+	CourseID  uint           `json:"course_id" gorm:"index"`
+	ChapterID uint           `json:"chapter_id" gorm:"index"`
+	Name      string         `json:"name" gorm:"size:128"`
+	Sort      int            `json:"sort" gorm:"default:0"` // 排序，同一章内按从小到大排列
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -9,9 +9,10 @@ import (
 
 // ExamConfigItem 考试配置项
 type ExamConfigItem struct {
-	Type  string `json:"type"`
-	Count int    `json:"count"`
-	Score int    `json:"score"`
+	Type      string `json:"type"`
+	Count     int    `json:"count"`
+	Score     int    `json:"score"`
+	ChapterID uint   `json:"chapter_id,omitempty"` // 只从指定章抽题，0表示从整门课程抽题
 }
 
 // MockExamConfig 模拟考试配置
@@ -21,33 +22,46 @@ type MockExamConfig struct {
 	Score int `json:"score"`
 }
 
-// 课程分类
+// 课程分类，层级不限
 type CourseCategory struct {
-	ID        uint   `gorm:"primarykey"`
-	ParentID  uint   `gorm:"index"` // 父分类ID，0表示一级分类
-	Name      string `gorm:"size:64"`
-	Sort      int    // 排序
-	Level     int    // 层级：1=一级分类，2=二级分类，3=三级分类
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID        uint           `json:"id" gorm:"primarykey"`
+	ParentID  uint           `json:"parent_id" gorm:"index"` // 父分类ID，0表示一级分类
+	Name      string         `json:"name" gorm:"size:64"`
+	Icon      string         `json:"icon" gorm:"size:255"` // 图标地址
+	Sort      int            `json:"sort"`                 // 排序，同级分类按从小到大排列
+	Level     int            `json:"level"`                // 层级：1=一级分类，2=二级分类，依此类推
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// 课程发布状态
+const (
+	CourseStatusDraft     = "draft"     // 草稿，学生不可见
+	CourseStatusPublished = "published" // 已发布，在课程目录中显示并可购买
+	CourseStatusArchived  = "archived"  // 已归档，不在课程目录中显示也不可购买，已购买的学生仍可访问
+)
+
 // 课程
 type Course struct {
 	ID             uint   `gorm:"primarykey"`
-	Sort           int    `gorm:"default:0"` // 课程自身排序
-	CategorySort1  int    `gorm:"default:0"` // 一级分类排序
-	CategorySort2  int    `gorm:"default:0"` // 二级分类排序
+	Sort           int    `gorm:"default:0"`       // 课程自身排序
+	CategoryID     uint   `gorm:"index;default:0"` // 所属分类ID，0表示未分类
+	CategorySort1  int    `gorm:"default:0"`       // 已废弃：旧版一级分类排序，仅用于迁移到分类表
+	CategorySort2  int    `gorm:"default:0"`       // 已废弃：旧版二级分类排序，仅用于迁移到分类表
 	Name           string `gorm:"size:64"`
 	Cover          string `gorm:"size:255"`
-	CategoryLevel1 string `gorm:"size:50"` // 一级分类
-	CategoryLevel2 string `gorm:"size:50"` // 二级分类
+	CategoryLevel1 string `gorm:"size:50"` // 已废弃：旧版一级分类名称，仅用于迁移到分类表
+	CategoryLevel2 string `gorm:"size:50"` // 已废弃：旧版二级分类名称，仅用于迁移到分类表
 	Price          float64
-	Description    string `gorm:"type:text"`
-	ExpireDays     int    `gorm:"default:0"` // 课程有效期（天）
-	ExamConfig     string `gorm:"type:json"` // 考试配置，JSON字符串
-	MockExamConfig string `gorm:"type:json"` // 模拟考试配置，JSON字符串
+	Description    string     `gorm:"type:text"`
+	ExpireDays     int        `gorm:"default:0"`                       // 课程有效期（天）
+	ExamConfig     string     `gorm:"type:json"`                       // 考试配置，JSON字符串
+	MockExamConfig string     `gorm:"type:json"`                       // 模拟考试配置，JSON字符串
+	Status         string     `gorm:"size:20;index;default:published"` // 发布状态：draft、published、archived
+	PublishAt      *time.Time // 定时发布时间，到期后草稿或已归档的课程自动发布
+	UnpublishAt    *time.Time // 定时归档时间，到期后已发布的课程自动归档
+	TrialCount     int        `gorm:"default:0"` // 免费试用的题目数，未购买的用户可以练习前N道题，0表示只开放标记为试用的题目
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	DeletedAt      gorm.DeletedAt `gorm:"index"`
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 课程购买方案，如30天、180天、永久，每个方案有各自的价格和有效期
+type CoursePlan struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	CourseID   uint           `json:"course_id" gorm:"index"`
+	Name       string         `json:"name" gorm:"size:64"`
+	Price      float64        `json:"price"`
+	ExpireDays int            `json:"expire_days" gorm:"default:0"` // 有效期（天），0表示永久有效
+	Sort       int            `json:"sort" gorm:"default:0"`        // 排序，按从小到大排列
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
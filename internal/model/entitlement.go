@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// 课程权限来源
+const (
+	EntitlementSourcePurchase = "purchase" // 购买课程（含免费开通）
+	EntitlementSourceCard     = "card"     // 卡券兑换
+	EntitlementSourceAdmin    = "admin"    // 管理员赠送
+	EntitlementSourceBundle   = "bundle"   // 购买课程套餐
+)
+
+// 课程权限，记录每一次授予用户的课程访问权，用户有任意一条生效中的权限即可访问课程
+// 订单产生的权限随订单状态和到期时间同步，撤销后保留记录
+type Entitlement struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	UserID    uint       `json:"user_id" gorm:"index:idx_entitlement_user_course"`
+	CourseID  uint       `json:"course_id" gorm:"index:idx_entitlement_user_course"`
+	Source    string     `json:"source" gorm:"size:20"`           // 权限来源
+	OrderID   uint       `json:"order_id" gorm:"index;default:0"` // 来源订单，管理员赠送时为0
+	GrantedBy uint       `json:"granted_by" gorm:"default:0"`     // 赠送的管理员ID
+	Remark    string     `json:"remark" gorm:"size:255"`
+	StartAt   time.Time  `json:"start_at"`
+	EndAt     *time.Time `json:"end_at"`     // 到期时间，NULL表示永久有效
+	RevokedAt *time.Time `json:"revoked_at"` // 撤销时间，如订单退款、删除
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
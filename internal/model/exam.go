@@ -4,7 +4,6 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
-	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -43,8 +42,9 @@ func (a StringArray) Value() (driver.Value, error) {
 
 // 问题选项
 type QuestionOption struct {
-	Label string `json:"label"`
-	Text  string `json:"text"`
+	Label string     `json:"label"`
+	Text  string     `json:"text"`
+	Media []MediaRef `json:"media,omitempty"` // 选项中的图片等媒体
 }
 
 // QuestionOptions 类型用于存储选项数组
@@ -67,30 +67,13 @@ func (o *QuestionOptions) Scan(value interface{}) error {
 		return errors.New("failed to unmarshal JSON value")
 	}
 
-	var opts []QuestionOption
-	if err := json.Unmarshal(bytes, &opts); err == nil {
-		*o = opts
-		return nil
-	}
-
-	var strOpts []string
-	if err := json.Unmarshal(bytes, &strOpts); err == nil {
-		for i, text := range strOpts {
-			if strings.TrimSpace(text) == "" {
-				continue
-			}
-			parts := strings.SplitN(text, ".", 2)
-			if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
-				*o = append(*o, QuestionOption{Label: parts[0], Text: strings.TrimSpace(parts[1])})
-			} else {
-				label := string(rune('A' + i))
-				*o = append(*o, QuestionOption{Label: label, Text: strings.TrimSpace(text)})
-			}
-		}
-		return nil
+	// 与 DecodeQuestionOptions 使用同一解码逻辑，标签与位置不一致时保留存储的标签，由 normalize-options 命令报告后人工处理
+	options, err := decodeOptions(bytes)
+	if err != nil && len(options) == 0 {
+		return err
 	}
-
-	return errors.New("failed to unmarshal JSON value for options")
+	*o = options
+	return nil
 }
 
 // 实现 Valuer 接口
@@ -101,17 +84,28 @@ func (o QuestionOptions) Value() (driver.Value, error) {
 	return json.Marshal(o)
 }
 
+// 题目解析来源
+const (
+	ExplanationSourceManual = ""   // 人工编写
+	ExplanationSourceAI     = "ai" // AI生成
+)
+
 type Question struct {
-	ID          uint            `json:"id" gorm:"primarykey"`
-	Type        string          `json:"type" gorm:"size:20"` // single或multiple
-	Question    string          `json:"question" gorm:"type:text"`
-	Options     QuestionOptions `json:"options" gorm:"type:json"` // JSON格式存储选项
-	Answer      string          `json:"answer" gorm:"size:255"`   // 改回字符串类型
-	Explanation string          `json:"explanation" gorm:"type:text"`
-	CourseID    uint            `json:"course_id" gorm:"index"`
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt  `json:"-" gorm:"index"`
+	ID                uint            `json:"id" gorm:"primarykey"`
+	Type              string          `json:"type" gorm:"size:20"` // single或multiple
+	Question          string          `json:"question" gorm:"type:text"`
+	StemMedia         MediaRefs       `json:"stem_media" gorm:"type:json"` // 题干中的图片等媒体
+	Options           QuestionOptions `json:"options" gorm:"type:json"`    // JSON格式存储选项
+	Answer            string          `json:"answer" gorm:"size:255"`      // 改回字符串类型
+	Explanation       string          `json:"explanation" gorm:"type:text"`
+	ExplanationMedia  MediaRefs       `json:"explanation_media" gorm:"type:json"`                        // 解析中的图片等媒体
+	ExplanationSource string          `json:"explanation_source" gorm:"size:20;default:'';comment:解析来源"` // 空为人工编写，ai为AI生成
+	CourseID          uint            `json:"course_id" gorm:"index"`
+	SectionID         uint            `json:"section_id" gorm:"index;default:0"` // 所属小节ID，0表示未分配章节
+	IsTrial           bool            `json:"is_trial" gorm:"default:false"`     // 是否为试用题目，未购买的用户也可以练习
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt  `json:"-" gorm:"index"`
 }
 
 // type Exam struct {
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+)
+
+// AI解析草稿状态
+const (
+	DraftStatusDraft    = "draft"    // 待审核
+	DraftStatusApproved = "approved" // 已采用，内容已写入题目解析
+	DraftStatusRejected = "rejected" // 已驳回
+)
+
+// AI解析草稿来源
+const (
+	DraftOriginStudent = "student" // 学生练习时请求生成
+	DraftOriginBatch   = "batch"   // 管理员批量生成
+)
+
+// ExplanationDraft AI生成的题目解析，经管理员审核后才写入题目解析
+// 每道题目最多有一条待审核的草稿，重新生成时覆盖该草稿
+type ExplanationDraft struct {
+	ID               uint       `json:"id" gorm:"primarykey"`
+	QuestionID       uint       `json:"question_id" gorm:"index"`
+	Content          string     `json:"content" gorm:"type:text;comment:AI生成的解析"`
+	Status           string     `json:"status" gorm:"size:20;index;default:draft;comment:审核状态"`
+	Origin           string     `json:"origin" gorm:"size:20;comment:生成来源"`
+	Model            string     `json:"model" gorm:"size:100;comment:使用的模型"`
+	PromptVersion    string     `json:"prompt_version" gorm:"size:100;comment:提示词模板版本"`
+	PromptTokens     int        `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens int        `json:"completion_tokens" gorm:"default:0"`
+	Cost             float64    `json:"cost" gorm:"type:decimal(12,6);default:0;comment:费用(元)"`
+	RequestedBy      uint       `json:"requested_by" gorm:"comment:请求生成的用户ID"`
+	JobID            uint       `json:"job_id" gorm:"default:0;comment:批量生成任务ID"`
+	GeneratedAt      time.Time  `json:"generated_at" gorm:"comment:生成时间"`
+	Edited           bool       `json:"edited" gorm:"default:false;comment:采用时是否经过修改"`
+	ReviewedBy       uint       `json:"reviewed_by" gorm:"comment:审核人ID"`
+	ReviewedAt       *time.Time `json:"reviewed_at"`
+	ReviewNote       string     `json:"review_note" gorm:"size:500;comment:审核说明"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
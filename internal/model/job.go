@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+)
+
+// 后台任务状态
+const (
+	JobStatusPending   = "pending"   // 等待执行
+	JobStatusRunning   = "running"   // 执行中
+	JobStatusSucceeded = "succeeded" // 执行成功
+	JobStatusFailed    = "failed"    // 执行失败
+	JobStatusCanceled  = "canceled"  // 已取消
+)
+
+// Job 后台任务（题库导入导出等耗时操作）
+type Job struct {
+	ID              uint       `json:"id" gorm:"primarykey"`
+	Type            string     `json:"type" gorm:"size:50;index;comment:任务类型"`
+	Status          string     `json:"status" gorm:"size:20;index;default:pending;comment:任务状态"`
+	Progress        int        `json:"progress" gorm:"default:0;comment:进度百分比"`
+	Message         string     `json:"message" gorm:"size:500;comment:状态说明或错误信息"`
+	Params          string     `json:"-" gorm:"type:text;comment:任务参数(JSON)"`
+	Result          string     `json:"-" gorm:"type:text;comment:任务结果(JSON)"`
+	InputKey        string     `json:"-" gorm:"size:255;comment:上传文件存储路径"`
+	InputName       string     `json:"input_name" gorm:"size:255;comment:上传文件名"`
+	ArtifactKey     string     `json:"-" gorm:"size:255;comment:结果文件存储路径"`
+	ArtifactName    string     `json:"artifact_name" gorm:"size:255;comment:结果文件名"`
+	CancelRequested bool       `json:"cancel_requested" gorm:"default:false;comment:是否已请求取消"`
+	CreatorID       uint       `json:"creator_id" gorm:"index;comment:提交人ID"`
+	WorkerID        string     `json:"-" gorm:"size:64;index;comment:执行任务的实例ID"`
+	HeartbeatAt     *time.Time `json:"-" gorm:"comment:执行中任务所在实例的最近心跳时间"`
+	StartedAt       *time.Time `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// IsFinished 任务是否已结束
+func (j *Job) IsFinished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCanceled
+}
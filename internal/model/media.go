@@ -0,0 +1,68 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Media 上传的媒体文件（题目中的图片、音频、视频）
+type Media struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	FileName    string         `json:"file_name" gorm:"size:255;comment:原始文件名"`
+	StorageKey  string         `json:"-" gorm:"size:255;uniqueIndex;comment:存储路径"`
+	ContentType string         `json:"content_type" gorm:"size:100;comment:文件类型"`
+	Size        int64          `json:"size" gorm:"comment:文件大小(字节)"`
+	SHA256      string         `json:"sha256" gorm:"size:64;index;comment:文件哈希"`
+	UploaderID  uint           `json:"uploader_id" gorm:"index;comment:上传者ID"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// MediaRef 题干、选项、解析中引用的媒体
+type MediaRef struct {
+	ID   uint   `json:"id"`
+	Type string `json:"type"`          // image, audio, video
+	URL  string `json:"url,omitempty"` // 访问地址，保存时根据ID生成
+	Alt  string `json:"alt,omitempty"` // 替代文本
+}
+
+// MediaRefs 媒体引用数组
+type MediaRefs []MediaRef
+
+// 实现 Scanner 接口
+func (m *MediaRefs) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("failed to unmarshal JSON value")
+	}
+
+	if len(bytes) == 0 {
+		*m = nil
+		return nil
+	}
+
+	return json.Unmarshal(bytes, m)
+}
+
+// 实现 Valuer 接口
+func (m MediaRefs) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
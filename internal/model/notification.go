@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// 站内通知类型
+const (
+	NotificationQuestionFixed = "question_fixed" // 纠错的题目已修正
+)
+
+// Notification 用户站内通知
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	Type      string     `json:"type" gorm:"size:30"`
+	Title     string     `json:"title" gorm:"size:100"`
+	Content   string     `json:"content" gorm:"type:text"`
+	RelatedID uint       `json:"related_id" gorm:"comment:关联对象ID，如题目ID"`
+	ReadAt    *time.Time `json:"read_at" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at"`
+}
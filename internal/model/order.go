@@ -7,19 +7,22 @@ import (
 )
 
 type Order struct {
-	ID          uint   `gorm:"primarykey"`
-	OrderNo     string `gorm:"size:64;index"`
-	UserID      uint   `gorm:"index"`
-	User        User   `gorm:"foreignKey:UserID"`
-	CourseID    uint   `gorm:"index"`
-	Amount      float64
-	Status      string     `gorm:"size:20"` // pending, paid, canceled
-	PaymentType string     `gorm:"size:20"` // 支付方式
-	PayTime     *time.Time // 使用指针类型，可以为 NULL
-	ExpireTime  *time.Time // 订单过期时间，可以为 NULL
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	ID            uint   `gorm:"primarykey"`
+	OrderNo       string `gorm:"size:64;index"`
+	UserID        uint   `gorm:"index"`
+	User          User   `gorm:"foreignKey:UserID"`
+	CourseID      uint   `gorm:"index"`
+	PlanID        uint   `gorm:"index;default:0"` // 购买方案ID，0表示课程未设置方案，按课程价格和有效期购买
+	BundleID      uint   `gorm:"index;default:0"` // 套餐ID，购买套餐时 CourseID 为0
+	ParentOrderID uint   `gorm:"index;default:0"` // 套餐订单ID，套餐支付后为每门课程生成的子订单
+	Amount        float64
+	Status        string     `gorm:"size:20"` // pending, paid, canceled
+	PaymentType   string     `gorm:"size:20"` // 支付方式
+	PayTime       *time.Time // 使用指针类型，可以为 NULL
+	ExpireTime    *time.Time // 订单过期时间，可以为 NULL
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
 }
 
 // 检查订单是否过期（15分钟未支付）
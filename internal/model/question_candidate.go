@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+)
+
+// AI生成题目的审核状态
+const (
+	CandidateStatusPending  = "pending"  // 待审核
+	CandidateStatusApproved = "approved" // 已采用，已创建题目
+	CandidateStatusRejected = "rejected" // 已驳回
+)
+
+// QuestionCandidate 根据学习资料生成的候选题目，管理员采用后才创建为正式题目
+type QuestionCandidate struct {
+	ID            uint            `json:"id" gorm:"primarykey"`
+	CourseID      uint            `json:"course_id" gorm:"index;comment:课程ID"`
+	JobID         uint            `json:"job_id" gorm:"index;comment:生成任务ID"`
+	ChunkIndex    int             `json:"chunk_index" gorm:"default:0;comment:资料片段序号"`
+	Type          string          `json:"type" gorm:"size:20;comment:题目类型"`
+	Question      string          `json:"question" gorm:"type:text;comment:题干"`
+	Options       QuestionOptions `json:"options" gorm:"type:json;comment:选项"`
+	Answer        string          `json:"answer" gorm:"size:255;comment:答案"`
+	Explanation   string          `json:"explanation" gorm:"type:text;comment:解析"`
+	Source        string          `json:"source" gorm:"type:text;comment:资料中的依据原文"`
+	Issues        string          `json:"issues" gorm:"size:500;comment:校验未通过的原因，为空表示通过"`
+	Status        string          `json:"status" gorm:"size:20;index;default:pending;comment:审核状态"`
+	Model         string          `json:"model" gorm:"size:100;comment:使用的模型"`
+	PromptVersion string          `json:"prompt_version" gorm:"size:100;comment:提示词模板版本"`
+	QuestionID    uint            `json:"question_id" gorm:"default:0;comment:采用后创建的题目ID"`
+	Edited        bool            `json:"edited" gorm:"default:false;comment:采用前是否修改过"`
+	ReviewedBy    uint            `json:"reviewed_by" gorm:"default:0;comment:审核人"`
+	ReviewedAt    *time.Time      `json:"reviewed_at" gorm:"comment:审核时间"`
+	ReviewNote    string          `json:"review_note" gorm:"size:500;comment:驳回原因"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 选项的规范存储格式为结构化数组：[{"label":"A","text":"选项内容","media":[...]}]
+// 标签按位置依次为 A、B、C……，判断题固定为 A.正确、B.错误
+// 早期数据为 "A.选项内容" 字符串数组，读取时兼容，可通过 normalize-options 命令改写为规范格式
+
+// JudgeOptions 判断题的固定选项
+func JudgeOptions() QuestionOptions {
+	return QuestionOptions{
+		{Label: "A", Text: "正确"},
+		{Label: "B", Text: "错误"},
+	}
+}
+
+// legacyLabelPattern 旧格式选项文本中的标签前缀，如 "A." "B、" "C)"
+var legacyLabelPattern = regexp.MustCompile(`^([A-Za-z])\s*[.．、)]\s*`)
+
+// CanonicalOptions 转换为规范格式：按位置重新编号标签，去除文本首尾空白，判断题使用固定选项
+func CanonicalOptions(qType string, options QuestionOptions) QuestionOptions {
+	if qType == "judge" {
+		return JudgeOptions()
+	}
+	canonical := make(QuestionOptions, len(options))
+	for i, opt := range options {
+		canonical[i] = QuestionOption{
+			Label: string(rune('A' + i)),
+			Text:  strings.TrimSpace(opt.Text),
+			Media: opt.Media,
+		}
+	}
+	return canonical
+}
+
+// DecodeQuestionOptions 解析数据库中存储的选项，所有读取选项的地方都使用此函数
+// 兼容规范格式和旧的字符串数组，结果为规范格式；无法完整解析时返回已解析的部分和错误原因，
+// 其中标签与位置不一致时保留存储的标签，以免与答案对应错位
+func DecodeQuestionOptions(qType string, raw string) (QuestionOptions, error) {
+	if qType == "judge" {
+		return JudgeOptions(), nil
+	}
+
+	options, err := decodeOptions([]byte(raw))
+	if err != nil {
+		return options, err
+	}
+	if len(options) == 0 {
+		return options, errors.New("选项为空")
+	}
+	return options, nil
+}
+
+// decodeOptions 解析选项JSON，不区分题目类型
+// 标签按位置编号；有选项的标签与位置不一致时保留存储的标签（没有标签的选项仍按位置编号），并返回错误
+func decodeOptions(data []byte) (QuestionOptions, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" || trimmed == "null" {
+		return QuestionOptions{}, nil
+	}
+
+	var structured []QuestionOption
+	if err := json.Unmarshal(data, &structured); err == nil {
+		options := make(QuestionOptions, 0, len(structured))
+		stored := make([]string, 0, len(structured))
+		var mismatch error
+		for _, opt := range structured {
+			label := string(rune('A' + len(options)))
+			if opt.Label != "" && !strings.EqualFold(strings.TrimSpace(opt.Label), label) && mismatch == nil {
+				mismatch = fmt.Errorf("第%d个选项的标签为%s，与位置不一致", len(options)+1, opt.Label)
+			}
+			options = append(options, QuestionOption{
+				Label: label,
+				Text:  strings.TrimSpace(opt.Text),
+				Media: opt.Media,
+			})
+			stored = append(stored, strings.TrimSpace(opt.Label))
+		}
+		return keepStoredLabels(options, stored, mismatch), mismatch
+	}
+
+	var texts []string
+	if err := json.Unmarshal(data, &texts); err == nil {
+		options := make(QuestionOptions, 0, len(texts))
+		stored := make([]string, 0, len(texts))
+		var mismatch error
+		for _, text := range texts {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				continue
+			}
+			label := string(rune('A' + len(options)))
+			storedLabel := ""
+			if m := legacyLabelPattern.FindStringSubmatch(text); m != nil {
+				if !strings.EqualFold(m[1], label) && mismatch == nil {
+					mismatch = fmt.Errorf("第%d个选项的标签为%s，与位置不一致", len(options)+1, m[1])
+				}
+				storedLabel = m[1]
+				text = strings.TrimSpace(text[len(m[0]):])
+			}
+			options = append(options, QuestionOption{Label: label, Text: text})
+			stored = append(stored, storedLabel)
+		}
+		return keepStoredLabels(options, stored, mismatch), mismatch
+	}
+
+	return QuestionOptions{}, errors.New("选项JSON格式错误")
+}
+
+// keepStoredLabels 标签与位置不一致时改回存储的标签，答案按存储的标签记录，按位置重新编号会与答案对应错位
+func keepStoredLabels(options QuestionOptions, stored []string, mismatch error) QuestionOptions {
+	if mismatch == nil {
+		return options
+	}
+	for i, label := range stored {
+		if label != "" {
+			options[i].Label = label
+		}
+	}
+	return options
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 题目纠错原因
+const (
+	ReportReasonWrongAnswer      = "wrong_answer"      // 答案错误
+	ReportReasonWrongExplanation = "wrong_explanation" // 解析错误
+	ReportReasonTypo             = "typo"              // 题干或选项有错别字
+	ReportReasonUnclear          = "unclear"           // 题意不清
+	ReportReasonOther            = "other"             // 其他
+)
+
+// 题目纠错处理状态
+const (
+	ReportStatusPending  = "pending"  // 待处理
+	ReportStatusResolved = "resolved" // 已修正
+	ReportStatusRejected = "rejected" // 已驳回（题目无误）
+)
+
+// QuestionReport 学生提交的题目纠错
+type QuestionReport struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	QuestionID     uint           `json:"question_id" gorm:"index"`
+	UserID         uint           `json:"user_id" gorm:"index"`
+	User           User           `json:"-" gorm:"foreignKey:UserID"`
+	Reason         string         `json:"reason" gorm:"size:30;comment:纠错原因"`
+	Comment        string         `json:"comment" gorm:"type:text;comment:补充说明"`
+	Status         string         `json:"status" gorm:"size:20;index;default:pending;comment:处理状态"`
+	ResolutionNote string         `json:"resolution_note" gorm:"type:text;comment:处理说明"`
+	ResolvedBy     uint           `json:"resolved_by" gorm:"comment:处理人ID"`
+	ResolvedAt     *time.Time     `json:"resolved_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// QuestionSearchDoc 题目全文检索文档
+// 题干、选项、解析分列存储，使用 ngram 分词的全文索引以支持中文检索
+type QuestionSearchDoc struct {
+	QuestionID  uint      `json:"question_id" gorm:"primarykey;autoIncrement:false"`
+	Stem        string    `json:"stem" gorm:"type:text;index:idx_question_search,class:FULLTEXT,option:WITH PARSER ngram;comment:题干"`
+	OptionsText string    `json:"options_text" gorm:"type:text;index:idx_question_search,class:FULLTEXT,option:WITH PARSER ngram;comment:选项文本，每行一个选项"`
+	Explanation string    `json:"explanation" gorm:"type:text;index:idx_question_search,class:FULLTEXT,option:WITH PARSER ngram;comment:解析"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
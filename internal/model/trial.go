@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+)
+
+// 课程试用记录，每个用户每门课程一条，用于统计试用到购买的转化
+type TrialUsage struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	UserID      uint      `json:"user_id" gorm:"uniqueIndex:idx_trial_user_course"`
+	CourseID    uint      `json:"course_id" gorm:"uniqueIndex:idx_trial_user_course;index"`
+	ViewCount   int       `json:"view_count" gorm:"default:0"`   // 获取试用题目的次数
+	AnswerCount int       `json:"answer_count" gorm:"default:0"` // 提交试用题目答案的次数
+	FirstAt     time.Time `json:"first_at" gorm:"index"`         // 首次试用时间
+	LastAt      time.Time `json:"last_at"`                       // 最近试用时间
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+)
+
+// 答疑对话消息的角色
+const (
+	TutorRoleUser      = "user"      // 学生提问
+	TutorRoleAssistant = "assistant" // AI回答
+)
+
+// TutorConversation 学生针对一道题目的答疑对话，每个学生每道题目一个对话
+type TutorConversation struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	UserID        uint       `json:"user_id" gorm:"uniqueIndex:idx_tutor_user_question;comment:用户ID"`
+	QuestionID    uint       `json:"question_id" gorm:"uniqueIndex:idx_tutor_user_question;index;comment:题目ID"`
+	CourseID      uint       `json:"course_id" gorm:"index;comment:课程ID"`
+	MessageCount  int        `json:"message_count" gorm:"default:0;comment:学生提问数"`
+	ResetAt       *time.Time `json:"reset_at" gorm:"comment:学生清空对话的时间，之前的消息不再作为上下文"`
+	LastMessageAt time.Time  `json:"last_message_at" gorm:"index;comment:最后一条消息时间"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TutorMessage 答疑对话中的一条消息，学生清空对话后仍保留，供管理员查看
+type TutorMessage struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	ConversationID   uint      `json:"conversation_id" gorm:"index;comment:对话ID"`
+	UserID           uint      `json:"user_id" gorm:"index:idx_tutor_message_user_time;comment:用户ID"`
+	Role             string    `json:"role" gorm:"size:20;comment:角色"`
+	Content          string    `json:"content" gorm:"type:text;comment:消息内容"`
+	Model            string    `json:"model" gorm:"size:100;comment:使用的模型"`
+	PromptVersion    string    `json:"prompt_version" gorm:"size:100;comment:提示词模板版本"`
+	PromptTokens     int       `json:"prompt_tokens" gorm:"default:0;comment:输入token数"`
+	CompletionTokens int       `json:"completion_tokens" gorm:"default:0;comment:输出token数"`
+	Cost             float64   `json:"cost" gorm:"type:decimal(12,6);default:0;comment:费用(元)"`
+	CreatedAt        time.Time `json:"created_at" gorm:"index:idx_tutor_message_user_time"`
+}
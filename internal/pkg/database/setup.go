@@ -39,14 +39,36 @@ func Setup() error {
 	if err := DB.AutoMigrate(
 		&model.User{},
 		&model.Course{},
+		&model.CourseCategory{},
+		&model.CoursePlan{},
+		&model.CourseChapter{},
+		&model.CourseSection{},
 		&model.Question{},
 		&model.Order{},
+		&model.Entitlement{},
+		&model.TrialUsage{},
+		&model.Bundle{},
+		&model.BundleCourse{},
 		&model.QRCode{},
 		&model.AdminLoginLog{},
 		&model.UserFeedback{},
 		&model.ExamRecord{},
 		&model.Card{},
 		&model.CardRecord{},
+		&model.Media{},
+		&model.Job{},
+		&model.AnswerLog{},
+		&model.QuestionStat{},
+		&model.QuestionReport{},
+		&model.Notification{},
+		&model.QuestionSearchDoc{},
+		&model.AIExplanationItem{},
+		&model.ExplanationDraft{},
+		&model.QuestionCandidate{},
+		&model.AnswerCheck{},
+		&model.TutorConversation{},
+		&model.TutorMessage{},
+		&model.AIUsage{},
 	); err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
 	}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"exam-system/internal/config"
+)
+
+// ErrNotConfigured 未配置模型服务
+var ErrNotConfigured = errors.New("未配置AI模型服务")
+
+// Message 对话消息
+type Message struct {
+	Role    string `json:"role"` // system、user 或 assistant
+	Content string `json:"content"`
+}
+
+// Request 对话请求，Model、Temperature 为空时使用模型服务的默认值
+type Request struct {
+	Messages    []Message
+	Model       string
+	Temperature *float64
+	MaxTokens   int
+	// PromptVersion 生成请求所用的模板及版本，如 explanation@1，仅用于记录，不发送给模型
+	PromptVersion string
+}
+
+// Usage token 用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Response 对话回复
+type Response struct {
+	Content string
+	Model   string // 实际使用的模型
+	Usage   Usage
+}
+
+// Provider 大模型服务接口
+type Provider interface {
+	// Name 服务名称，用于日志和记录
+	Name() string
+	// Chat 发送对话请求并返回完整回复
+	Chat(ctx context.Context, req Request) (*Response, error)
+}
+
+// StreamProvider 支持流式回复的模型服务
+type StreamProvider interface {
+	Provider
+	// ChatStream 发送对话请求，回复内容按收到的片段依次传给 onDelta，结束后返回完整回复
+	// onDelta 返回错误时停止接收并返回该错误；开始接收后出错时同时返回已收到的部分回复及其用量，用于记录费用
+	ChatStream(ctx context.Context, req Request, onDelta func(delta string) error) (*Response, error)
+}
+
+// Default 全局模型服务
+var Default Provider
+
+// Setup 根据配置初始化模型服务并校验提示词模板
+func Setup() error {
+	cfg := config.GlobalConfig.AI
+
+	switch cfg.Provider {
+	case "", "openai":
+		Default = NewOpenAI(cfg.OpenAI)
+	case "stub":
+		Default = NewStub(cfg.Stub.Reply)
+	default:
+		return fmt.Errorf("不支持的AI模型服务: %s", cfg.Provider)
+	}
+
+	return loadPrompts(cfg.Prompts)
+}
+
+// Chat 使用全局模型服务发送对话请求
+func Chat(ctx context.Context, req Request) (*Response, error) {
+	if Default == nil {
+		return nil, ErrNotConfigured
+	}
+	return Default.Chat(ctx, req)
+}
+
+// ChatStream 使用全局模型服务发送流式对话请求，服务不支持流式回复时在收到完整回复后一次性传给 onDelta
+func ChatStream(ctx context.Context, req Request, onDelta func(delta string) error) (*Response, error) {
+	if Default == nil {
+		return nil, ErrNotConfigured
+	}
+	if sp, ok := Default.(StreamProvider); ok {
+		return sp.ChatStream(ctx, req, onDelta)
+	}
+
+	resp, err := Default.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := onDelta(resp.Content); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
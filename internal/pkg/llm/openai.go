@@ -0,0 +1,339 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"exam-system/internal/config"
+)
+
+// maxRetryDelay 重试等待时间上限
+const maxRetryDelay = 30 * time.Second
+
+// OpenAIProvider OpenAI兼容的 Chat Completions 接口，客户端在所有请求间复用
+type OpenAIProvider struct {
+	cfg    config.OpenAIConfig
+	client *http.Client
+	// streamClient 流式请求不限制总时长，按两次收到内容的间隔判断超时
+	streamClient *http.Client
+}
+
+// NewOpenAI 创建OpenAI兼容接口的模型服务
+func NewOpenAI(cfg config.OpenAIConfig) *OpenAIProvider {
+	return &OpenAIProvider{
+		cfg:          cfg,
+		client:       &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		streamClient: &http.Client{},
+	}
+}
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []Message            `json:"messages"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIStreamChunk 流式回复的一个片段，用量只在最后一个片段中返回
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+// Name 服务名称
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+// Chat 发送对话请求，网络错误、限流和服务端错误按配置重试
+func (p *OpenAIProvider) Chat(ctx context.Context, req Request) (*Response, error) {
+	jsonBody, err := p.encode(req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *Response
+	err = p.retry(ctx, func() (retryAfter time.Duration, err error) {
+		resp, retryAfter, err = p.do(ctx, jsonBody)
+		return retryAfter, err
+	})
+	return resp, err
+}
+
+// ChatStream 发送流式对话请求，开始接收回复前的错误按配置重试，接收过程中出错不再重试
+// 超过 timeout 秒未收到新内容时视为超时；接收过程中出错时同时返回已收到的部分回复，
+// 接口未返回用量时按字符数估算
+func (p *OpenAIProvider) ChatStream(ctx context.Context, req Request, onDelta func(delta string) error) (*Response, error) {
+	jsonBody, err := p.encode(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *Response
+	err = p.retry(ctx, func() (retryAfter time.Duration, err error) {
+		resp, retryAfter, err = p.doStream(ctx, jsonBody, onDelta)
+		return retryAfter, err
+	})
+	if err != nil && resp != nil && resp.Usage == (Usage{}) {
+		resp.Usage = estimateUsage(req, resp.Content)
+	}
+	return resp, err
+}
+
+// encode 生成请求体，未指定的模型参数使用配置的默认值
+func (p *OpenAIProvider) encode(req Request, stream bool) ([]byte, error) {
+	if p.cfg.APIKey == "" {
+		return nil, errors.New("未配置AI API密钥")
+	}
+
+	body := openAIRequest{
+		Model:       req.Model,
+		Messages:    req.Messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Stream:      stream,
+	}
+	if stream {
+		// 要求在最后一个片段中返回 token 用量
+		body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	if body.Model == "" {
+		body.Model = p.cfg.Model
+	}
+	if body.Temperature == nil {
+		body.Temperature = p.cfg.Temperature
+	}
+	if body.MaxTokens == 0 {
+		body.MaxTokens = p.cfg.MaxTokens
+	}
+	return json.Marshal(body)
+}
+
+// retry 执行请求，attempt 返回的 retryAfter 为负表示错误不可重试
+func (p *OpenAIProvider) retry(ctx context.Context, attempt func() (time.Duration, error)) error {
+	var lastErr error
+	for i := 0; i <= p.cfg.MaxRetries; i++ {
+		retryAfter, err := attempt()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if retryAfter < 0 || i == p.cfg.MaxRetries {
+			break
+		}
+
+		// 指数退避：1s、2s、4s……，服务端指定 Retry-After 时以其为准
+		delay := time.Second << i
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return lastErr
+}
+
+// do 发送一次请求；retryAfter 为负表示错误不可重试，为正表示服务端要求的等待时间
+func (p *OpenAIProvider) do(ctx context.Context, jsonBody []byte) (resp *Response, retryAfter time.Duration, err error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.APIURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, -1, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, ctx.Err()
+		}
+		return nil, 0, fmt.Errorf("调用AI接口失败: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取AI接口响应失败: %v", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("AI接口返回错误: %s, %s", httpResp.Status, truncate(string(body), 500))
+		if httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode >= 500 {
+			return nil, parseRetryAfter(httpResp.Header.Get("Retry-After")), err
+		}
+		return nil, -1, err
+	}
+
+	var aiResp openAIResponse
+	if err := json.Unmarshal(body, &aiResp); err != nil {
+		return nil, -1, fmt.Errorf("解析AI接口响应失败: %v", err)
+	}
+	if len(aiResp.Choices) == 0 {
+		return nil, -1, errors.New("AI未返回有效内容")
+	}
+
+	return &Response{
+		Content: aiResp.Choices[0].Message.Content,
+		Model:   aiResp.Model,
+		Usage:   aiResp.Usage,
+	}, 0, nil
+}
+
+// doStream 发送一次流式请求，开始接收回复后出错时不可重试，并返回已收到的部分回复
+func (p *OpenAIProvider) doStream(ctx context.Context, jsonBody []byte, onDelta func(delta string) error) (resp *Response, retryAfter time.Duration, err error) {
+	idle := time.Duration(p.cfg.Timeout) * time.Second
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.AfterFunc(idle, cancel)
+	defer timer.Stop()
+
+	// streamErr 区分调用方取消和等待超时
+	streamErr := func(err error, format string) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if streamCtx.Err() != nil {
+			return errors.New("AI接口响应超时")
+		}
+		return fmt.Errorf(format, err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(streamCtx, http.MethodPost, p.cfg.APIURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, -1, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+
+	httpResp, err := p.streamClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, ctx.Err()
+		}
+		return nil, 0, streamErr(err, "调用AI接口失败: %v")
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+		err := fmt.Errorf("AI接口返回错误: %s, %s", httpResp.Status, truncate(string(body), 500))
+		if httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode >= 500 {
+			return nil, parseRetryAfter(httpResp.Header.Get("Retry-After")), err
+		}
+		return nil, -1, err
+	}
+
+	var (
+		content  strings.Builder
+		result   Response
+		finished bool
+	)
+	// partial 已收到的回复，接口已开始返回内容，出错时也已产生费用
+	partial := func() *Response {
+		result.Content = content.String()
+		return &result
+	}
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		timer.Reset(idle)
+
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			finished = true
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return partial(), -1, fmt.Errorf("解析AI接口响应失败: %v", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finished = true
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return partial(), -1, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return partial(), -1, streamErr(err, "读取AI接口响应失败: %v")
+	}
+	if !finished {
+		return partial(), -1, streamErr(io.ErrUnexpectedEOF, "AI接口响应不完整: %v")
+	}
+	if content.Len() == 0 {
+		return partial(), -1, errors.New("AI未返回有效内容")
+	}
+
+	return partial(), 0, nil
+}
+
+// parseRetryAfter 解析以秒为单位的 Retry-After 头
+func parseRetryAfter(v string) time.Duration {
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// truncate 截断过长的错误响应
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "..."
+}
@@ -0,0 +1,24 @@
+package llm
+
+import (
+	"exam-system/internal/config"
+	"unicode/utf8"
+)
+
+// Cost 按配置的模型价格计算费用（元），未配置价格的模型返回0
+func Cost(model string, usage Usage) float64 {
+	price, ok := config.GlobalConfig.AI.Pricing[model]
+	if !ok {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
+
+// estimateUsage 按字符数估算 token 用量，用于接口没有返回用量的回复
+func estimateUsage(req Request, content string) Usage {
+	usage := Usage{CompletionTokens: utf8.RuneCountInString(content)}
+	for _, m := range req.Messages {
+		usage.PromptTokens += utf8.RuneCountInString(m.Content)
+	}
+	return usage
+}
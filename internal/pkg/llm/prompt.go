@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"exam-system/internal/config"
+)
+
+// prompt 解析后的提示词模板
+type prompt struct {
+	system      *template.Template
+	user        *template.Template
+	version     string
+	model       string
+	temperature *float64
+}
+
+var (
+	promptsMu sync.RWMutex
+	prompts   map[string]*prompt
+)
+
+// loadPrompts 解析配置中的提示词模板，模板语法错误时启动失败
+func loadPrompts(cfg map[string]config.PromptConfig) error {
+	parsed := make(map[string]*prompt, len(cfg))
+	for name, pc := range cfg {
+		if strings.TrimSpace(pc.User) == "" {
+			return fmt.Errorf("提示词模板 %s 缺少 user", name)
+		}
+		p := &prompt{version: name + "@" + pc.Version, model: pc.Model, temperature: pc.Temperature}
+
+		var err error
+		if p.system, err = template.New(name + ".system").Option("missingkey=error").Parse(pc.System); err != nil {
+			return fmt.Errorf("解析提示词模板 %s 失败: %v", name, err)
+		}
+		if p.user, err = template.New(name + ".user").Option("missingkey=error").Parse(pc.User); err != nil {
+			return fmt.Errorf("解析提示词模板 %s 失败: %v", name, err)
+		}
+		parsed[name] = p
+	}
+
+	promptsMu.Lock()
+	prompts = parsed
+	promptsMu.Unlock()
+	return nil
+}
+
+// Prompt 使用指定名称的模板生成对话请求，模板覆盖的模型和温度一并带入请求
+func Prompt(name string, data interface{}) (Request, error) {
+	promptsMu.RLock()
+	p := prompts[name]
+	promptsMu.RUnlock()
+	if p == nil {
+		return Request{}, fmt.Errorf("未配置提示词模板: %s", name)
+	}
+
+	var system, user strings.Builder
+	if err := p.system.Execute(&system, data); err != nil {
+		return Request{}, fmt.Errorf("生成提示词失败: %v", err)
+	}
+	if err := p.user.Execute(&user, data); err != nil {
+		return Request{}, fmt.Errorf("生成提示词失败: %v", err)
+	}
+
+	req := Request{Model: p.model, Temperature: p.temperature, PromptVersion: p.version}
+	if s := strings.TrimSpace(system.String()); s != "" {
+		req.Messages = append(req.Messages, Message{Role: "system", Content: s})
+	}
+	req.Messages = append(req.Messages, Message{Role: "user", Content: strings.TrimSpace(user.String())})
+	return req, nil
+}
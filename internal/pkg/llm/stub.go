@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// StubProvider 本地测试用的模型服务，不调用外部接口，相同请求总是返回相同回复
+type StubProvider struct {
+	reply string
+}
+
+// NewStub 创建本地测试模型服务，reply 为空时根据请求内容生成回复
+func NewStub(reply string) *StubProvider {
+	return &StubProvider{reply: reply}
+}
+
+// Name 服务名称
+func (p *StubProvider) Name() string {
+	return "stub"
+}
+
+// Chat 返回固定回复，token 用量按字符数估算
+func (p *StubProvider) Chat(ctx context.Context, req Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	h := fnv.New32a()
+	for _, m := range req.Messages {
+		h.Write([]byte(m.Role))
+		h.Write([]byte(m.Content))
+	}
+
+	content := p.reply
+	if content == "" {
+		content = fmt.Sprintf("（本地测试回复 %08x）", h.Sum32())
+	}
+
+	model := req.Model
+	if model == "" {
+		model = "stub"
+	}
+	return &Response{
+		Content: content,
+		Model:   model,
+		Usage:   estimateUsage(req, content),
+	}, nil
+}
+
+// stubStreamChunk 流式回复时每个片段的字符数
+const stubStreamChunk = 4
+
+// ChatStream 将固定回复按片段依次返回，中断时返回已发送的部分回复
+func (p *StubProvider) ChatStream(ctx context.Context, req Request, onDelta func(delta string) error) (*Response, error) {
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(resp.Content)
+	// partial 截至第 end 个字符的部分回复
+	partial := func(end int) *Response {
+		content := string(runes[:end])
+		return &Response{Content: content, Model: resp.Model, Usage: estimateUsage(req, content)}
+	}
+	for i := 0; i < len(runes); i += stubStreamChunk {
+		if err := ctx.Err(); err != nil {
+			return partial(i), err
+		}
+		end := i + stubStreamChunk
+		if end > len(runes) {
+			end = len(runes)
+		}
+		if err := onDelta(string(runes[i:end])); err != nil {
+			return partial(end), err
+		}
+	}
+	return resp, nil
+}
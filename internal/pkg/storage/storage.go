@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"exam-system/internal/config"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Storage 文件存储后端接口，key 为存储内的相对路径（使用 / 分隔）
+type Storage interface {
+	// Save 保存文件，返回写入的字节数
+	Save(key string, r io.Reader) (int64, error)
+	// Open 打开文件用于读取，文件不存在时返回 ErrNotFound
+	Open(key string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(key string) error
+}
+
+// Store 全局存储后端
+var Store Storage
+
+// Setup 根据配置初始化存储后端
+func Setup() error {
+	cfg := config.GlobalConfig.Media
+
+	switch cfg.Storage {
+	case "", "local":
+		local, err := NewLocal(cfg.LocalDir)
+		if err != nil {
+			return err
+		}
+		Store = local
+	default:
+		return fmt.Errorf("不支持的存储后端: %s", cfg.Storage)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"exam-system/internal/api"
 	"exam-system/internal/api/admin"
 	"exam-system/internal/middleware"
+	"exam-system/internal/service"
 	"io"
 	"net/http"
 	"strings"
@@ -72,6 +73,9 @@ func setupAPIRoutes(r *gin.Engine) {
 	// 退款回调（不需要认证）
 	apiGroup.POST("/payments/refund/notify", api.RefundNotify)
 
+	// 题目媒体文件（不需要认证，供img/audio标签直接引用）
+	apiGroup.GET("/media/:id", api.GetMediaFile)
+
 	// 需要认证的路由
 	authorized := apiGroup.Group("/")
 	authorized.Use(middleware.JWT())
@@ -96,6 +100,12 @@ func setupAPIRoutes(r *gin.Engine) {
 			// 用户反馈
 			user.GET("/feedback", api.GetUserFeedbacks)    // 获取用户的反馈列表
 			user.POST("/feedback", api.CreateUserFeedback) // 提交反馈
+
+			// 题目纠错与站内通知
+			user.GET("/question-reports", api.GetMyQuestionReports)                 // 获取我的题目纠错
+			user.GET("/notifications", api.GetNotifications)                        // 获取通知列表
+			user.GET("/notifications/unread-count", api.GetUnreadNotificationCount) // 获取未读通知数量
+			user.POST("/notifications/read", api.MarkNotificationsRead)             // 标记通知已读
 		}
 
 		// 课程相关
@@ -104,24 +114,38 @@ func setupAPIRoutes(r *gin.Engine) {
 			course.GET("", api.GetCourseCategories)
 			course.GET("/category/:id", api.GetCategoryDetail)
 			course.GET("/:id", api.GetCourseDetail)
-			course.GET("/:id/exam", api.GetCourseExam)
-			course.POST("/:id/exam/submit", api.SubmitCourseExam)
+			course.GET("/:id/exam", courseAccess("id"), api.GetCourseExam)
+			course.POST("/:id/exam/submit", courseAccess("id"), api.SubmitCourseExam)
+		}
+
+		// 课程套餐
+		bundle := authorized.Group("/bundles")
+		{
+			bundle.GET("", api.GetBundles)
+			bundle.GET("/:id", api.GetBundleDetail)
 		}
 
 		// 题目相关
 		question := authorized.Group("/questions")
 		{
-			question.GET("/:course_id", api.GetCourseQuestions)
+			question.GET("/search", api.SearchQuestions) // 全文检索已购课程的题目
+			question.GET("/:course_id", courseAccess("course_id"), api.GetCourseQuestions)
+			question.GET("/:course_id/trial", api.GetTrialQuestions) // 未购买的用户练习试用题目
 		}
 
 		// 练习相关
 		practice := authorized.Group("/practice")
 		{
 			practice.GET("/wrong-questions", api.GetWrongQuestionsStats)
-			practice.GET("/wrong-questions/:course_id", api.GetWrongQuestionsByCourse)
+			practice.GET("/wrong-questions/:course_id", courseAccess("course_id"), api.GetWrongQuestionsByCourse)
 			practice.DELETE("/wrong-questions", api.ClearWrongQuestions)
 			practice.POST("/submit", api.SubmitPractice)
 			practice.POST("/question/:id/explanation", api.GenerateExplanation)
+			practice.POST("/question/:id/explanation/stream", api.StreamExplanation)
+			practice.GET("/question/:id/tutor", api.GetTutorHistory)
+			practice.POST("/question/:id/tutor", api.AskTutor)
+			practice.DELETE("/question/:id/tutor", api.ResetTutor)
+			practice.POST("/question/:id/report", api.ReportQuestion)
 		}
 
 		// 考试相关
@@ -174,6 +198,7 @@ func setupAdminAPIRoutes(r *gin.Engine) {
 		{
 			system.GET("/login-logs", admin.GetLoginLogs)             // 获取登录日志
 			system.GET("/sales-statistics", admin.GetSalesStatistics) // 获取销售统计数据
+			system.GET("/trial-statistics", admin.GetTrialStatistics) // 获取试用转化统计
 			system.GET("/system-info", admin.GetSystemInfo)           // 获取系统信息统计数据
 
 			// 管理员个人信息
@@ -212,14 +237,60 @@ func setupAdminAPIRoutes(r *gin.Engine) {
 			orders.GET("/refund/:order_no", api.QueryRefund) // 查询退款状态
 		}
 
+		// 课程权限管理
+		entitlements := authorized.Group("/entitlements")
+		{
+			entitlements.GET("", admin.GetEntitlements)               // 获取课程权限列表
+			entitlements.POST("", admin.GrantEntitlement)             // 赠送课程权限
+			entitlements.POST("/:id/revoke", admin.RevokeEntitlement) // 撤销赠送的课程权限
+		}
+
 		// 课程管理
 		courses := authorized.Group("/courses")
 		{
-			courses.GET("", admin.GetCourses)          // 获取课程列表
-			courses.GET("/:id", admin.GetCourse)       // 获取单个课程
-			courses.POST("", admin.CreateCourse)       // 创建课程
-			courses.PUT("/:id", admin.UpdateCourse)    // 更新课程
-			courses.DELETE("/:id", admin.DeleteCourse) // 删除课程
+			courses.GET("", admin.GetCourses)                      // 获取课程列表
+			courses.GET("/:id", admin.GetCourse)                   // 获取单个课程
+			courses.POST("", admin.CreateCourse)                   // 创建课程
+			courses.PUT("/:id", admin.UpdateCourse)                // 更新课程
+			courses.DELETE("/:id", admin.DeleteCourse)             // 删除课程
+			courses.GET("/:id/plans", admin.GetCoursePlans)        // 获取课程购买方案
+			courses.PUT("/:id/plans", admin.SaveCoursePlans)       // 保存课程购买方案
+			courses.GET("/:id/chapters", admin.GetCourseChapters)  // 获取课程章节
+			courses.POST("/:id/chapters", admin.CreateChapter)     // 创建章
+			courses.GET("/:id/archive", admin.ExportCourseArchive) // 导出课程归档包
+			courses.POST("/import", admin.ImportCourseArchive)     // 导入课程归档包
+		}
+
+		// 课程章节管理
+		chapters := authorized.Group("/chapters")
+		{
+			chapters.PUT("/:id", admin.UpdateChapter)           // 修改章
+			chapters.DELETE("/:id", admin.DeleteChapter)        // 删除章及其下的小节
+			chapters.POST("/:id/sections", admin.CreateSection) // 创建小节
+		}
+		sections := authorized.Group("/sections")
+		{
+			sections.PUT("/:id", admin.UpdateSection)    // 修改小节
+			sections.DELETE("/:id", admin.DeleteSection) // 删除小节
+		}
+
+		// 课程分类管理
+		categories := authorized.Group("/categories")
+		{
+			categories.GET("", admin.GetCategories)         // 获取课程分类树
+			categories.POST("", admin.CreateCategory)       // 创建课程分类
+			categories.PUT("/:id", admin.UpdateCategory)    // 修改课程分类
+			categories.DELETE("/:id", admin.DeleteCategory) // 删除课程分类
+		}
+
+		// 课程套餐管理
+		bundles := authorized.Group("/bundles")
+		{
+			bundles.GET("", admin.GetBundles)          // 获取套餐列表
+			bundles.GET("/:id", admin.GetBundle)       // 获取单个套餐
+			bundles.POST("", admin.CreateBundle)       // 创建套餐
+			bundles.PUT("/:id", admin.UpdateBundle)    // 修改套餐
+			bundles.DELETE("/:id", admin.DeleteBundle) // 删除套餐
 		}
 
 		// 题库管理
@@ -231,11 +302,64 @@ func setupAdminAPIRoutes(r *gin.Engine) {
 			questions.PUT("/:id", admin.UpdateQuestion)                 // 更新题目
 			questions.DELETE("/:id", admin.DeleteQuestion)              // 删除题目
 			questions.POST("/batch-delete", admin.BatchDeleteQuestions) // 批量删除题目
+			questions.POST("/batch-move", admin.BatchMoveQuestions)     // 批量移动题目
+			questions.POST("/batch-copy", admin.BatchCopyQuestions)     // 批量复制题目
+			questions.POST("/batch-update", admin.BatchUpdateQuestions) // 批量修改题目
+			questions.POST("/stats/refresh", admin.RefreshQuestionStats) // 重新计算题目分析结果
+			questions.GET("/search", admin.SearchQuestions)             // 全文检索题目
+			questions.POST("/search/rebuild", admin.RebuildSearchIndex) // 重建题目检索索引
 			questions.DELETE("/clear-by-course/:course_id", admin.ClearQuestionsByCourse) // 一键清空指定课程的全部题目
 			questions.GET("/export", admin.ExportQuestions)             // 导出题库
 			questions.POST("/import", admin.ImportQuestions)            // 导入题库
 		}
 
+		// 题目纠错管理
+		reports := authorized.Group("/question-reports")
+		{
+			reports.GET("", admin.GetQuestionReportQueue)                       // 获取按题目汇总的纠错队列
+			reports.GET("/:question_id", admin.GetQuestionReports)              // 获取题目的纠错详情
+			reports.POST("/:question_id/resolve", admin.ResolveQuestionReports) // 处理题目的纠错
+		}
+
+		// AI辅助功能
+		ai := authorized.Group("/ai")
+		{
+			ai.POST("/explanations", admin.SubmitAIExplanations)            // 批量生成课程题目解析
+			ai.GET("/explanations/:id/items", admin.GetAIExplanationItems) // 获取批量生成任务的题目状态
+			ai.GET("/explanation-drafts", admin.GetExplanationDrafts)                 // 获取AI解析审核队列
+			ai.POST("/explanation-drafts/:id/approve", admin.ApproveExplanationDraft) // 采用AI解析（可修改后采用）
+			ai.POST("/explanation-drafts/:id/reject", admin.RejectExplanationDraft)   // 驳回AI解析
+			ai.POST("/question-generations", admin.SubmitQuestionGeneration)            // 根据学习资料生成题目
+			ai.GET("/question-candidates", admin.GetQuestionCandidates)                 // 获取AI生成题目审核队列
+			ai.POST("/question-candidates/:id/approve", admin.ApproveQuestionCandidate) // 采用AI生成的题目（可修改后采用）
+			ai.POST("/question-candidates/:id/reject", admin.RejectQuestionCandidate)   // 驳回AI生成的题目
+			ai.POST("/answer-verifications", admin.SubmitAnswerVerification)            // 校验课程题目答案
+			ai.GET("/answer-checks", admin.GetAnswerCheckReport)                        // 获取答案校验报告
+			ai.POST("/answer-checks/:id/resolve", admin.ResolveAnswerCheck)             // 处理疑似错误的题目
+			ai.GET("/tutor/conversations", admin.GetTutorConversations)                 // 获取学生答疑对话列表
+			ai.GET("/tutor/conversations/:id", admin.GetTutorTranscript)                // 获取答疑对话完整记录
+			ai.GET("/tutor/questions", admin.GetTutorQuestionStats)                     // 按题目汇总答疑情况
+			ai.GET("/usage", admin.GetAIUsages)                                         // 获取AI调用记录
+			ai.GET("/usage/stats", admin.GetAIUsageStats)                               // 统计AI用量和费用
+		}
+
+		// 后台任务管理
+		jobs := authorized.Group("/jobs")
+		{
+			jobs.GET("", admin.GetJobList)                       // 获取任务列表
+			jobs.GET("/:id", admin.GetJob)                       // 获取任务详情
+			jobs.POST("/:id/cancel", admin.CancelJob)            // 取消任务
+			jobs.GET("/:id/download", admin.DownloadJobArtifact) // 下载任务结果文件
+		}
+
+		// 媒体文件管理
+		media := authorized.Group("/media")
+		{
+			media.GET("", admin.GetMediaList)       // 获取媒体文件列表
+			media.POST("", admin.UploadMedia)       // 上传媒体文件
+			media.DELETE("/:id", admin.DeleteMedia) // 删除媒体文件
+		}
+
 		// 卡券管理
 		cards := authorized.Group("/cards")
 		{
@@ -331,3 +455,8 @@ func serveUserFile(c *gin.Context, path string, userFS http.FileSystem) {
 	c.Request.URL.Path = "/"
 	fileServer.ServeHTTP(c.Writer, c.Request)
 }
+
+// courseAccess 校验用户对路径参数 param 指定课程的访问权限
+func courseAccess(param string) gin.HandlerFunc {
+	return middleware.CourseAccess(param, service.Entitlement.CheckAccess)
+}
@@ -1,109 +1,121 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"exam-system/internal/config"
 	"exam-system/internal/model"
+	"exam-system/internal/pkg/llm"
 	"fmt"
-	"io"
-	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+// maxConsecutiveAIFailures 连续失败达到此数量时终止任务，通常是接口配置错误或额度用尽
+const maxConsecutiveAIFailures = 10
+
 var AI = new(AIService)
 
 type AIService struct{}
 
-type deepseekRequest struct {
-	Model    string            `json:"model"`
-	Messages []deepseekMessage `json:"messages"`
-}
-
-type deepseekMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+// explanationPromptData 题目解析提示词模板的变量
+type explanationPromptData struct {
+	Type     string
+	Question string
+	Options  string
+	Answer   string
 }
 
-type deepseekResponse struct {
-	Choices []struct {
-		Message deepseekMessage `json:"message"`
-	} `json:"choices"`
+// GeneratedText AI生成的内容及来源信息
+type GeneratedText struct {
+	Content       string
+	Model         string
+	PromptVersion string
+	Usage         llm.Usage
+	Cost          float64 // 按配置的模型价格计算的费用（元）
 }
 
-func (s *AIService) GenerateExplanation(questionText, questionType string, options []model.QuestionOption, answer string) (string, error) {
-	cfg := config.GlobalConfig.AI.Explanation
-	if cfg.APIKey == "" {
-		return "", errors.New("未配置AI API密钥")
-	}
-
-	optionsText := ""
-	for _, opt := range options {
-		optionsText += fmt.Sprintf("%s. %s\n", opt.Label, opt.Text)
-	}
-
-	typeName := typeLabel(questionType)
-
-	prompt := fmt.Sprintf(`你是一个考试题目解析助手。请为以下题目生成简短解析。
-
-题型：%s
-题目：%s
-选项：
-%s
-正确答案：%s
-
-要求：
-- 使用纯文本，禁止使用Markdown格式（禁用**加粗**、编号列表、标题等任何标记语法）
-- 解析尽量简短，控制在150字以内
-- 直接输出解析内容，不要添加任何额外说明`, typeName, questionText, optionsText, answer)
-
-	reqBody := deepseekRequest{
-		Model: "deepseek-chat",
-		Messages: []deepseekMessage{
-			{Role: "system", Content: "你是一个考试题目解析助手，擅长给出简短、准确的题目解析。请始终使用纯文本，不要使用任何Markdown格式。"},
-			{Role: "user", Content: prompt},
-		},
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
+// GenerateExplanation 为题目生成解析，返回的内容已去除首尾空白
+func (s *AIService) GenerateExplanation(ctx context.Context, question *model.Question) (*GeneratedText, error) {
+	req, err := s.explanationRequest(question)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", cfg.APIURL, bytes.NewBuffer(jsonBody))
+	resp, err := llm.Chat(ctx, req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	return newGeneratedText(req, resp, "AI未返回有效解析")
+}
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+// StreamExplanation 流式生成题目解析，生成的片段依次传给 onDelta，全部完成后返回完整解析
+// ctx 取消或 onDelta 返回错误时停止生成并返回错误；已开始生成时同时返回不完整解析的用量和费用，
+// 内容不完整，只用于记录调用
+func (s *AIService) StreamExplanation(ctx context.Context, question *model.Question, onDelta func(delta string) error) (*GeneratedText, error) {
+	req, err := s.explanationRequest(question)
 	if err != nil {
-		return "", fmt.Errorf("调用AI接口失败: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := llm.ChatStream(ctx, req, onDelta)
 	if err != nil {
-		return "", err
+		if resp != nil {
+			return partialGeneratedText(req, resp), err
+		}
+		return nil, err
 	}
+	return newGeneratedText(req, resp, "AI未返回有效解析")
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("AI接口返回错误: %s, %s", resp.Status, string(body))
+// explanationRequest 使用题目解析模板生成对话请求
+func (s *AIService) explanationRequest(question *model.Question) (llm.Request, error) {
+	optionsText := ""
+	for _, opt := range question.Options {
+		optionsText += fmt.Sprintf("%s. %s\n", opt.Label, opt.Text)
 	}
 
-	var aiResp deepseekResponse
-	if err := json.Unmarshal(body, &aiResp); err != nil {
-		return "", err
-	}
+	return llm.Prompt("explanation", explanationPromptData{
+		Type:     typeLabel(question.Type),
+		Question: question.Question,
+		Options:  strings.TrimRight(optionsText, "\n"),
+		Answer:   question.Answer,
+	})
+}
 
-	if len(aiResp.Choices) == 0 {
-		return "", errors.New("AI未返回有效解析")
+// newGeneratedText 整理模型回复，内容为空时返回 emptyMsg 错误
+func newGeneratedText(req llm.Request, resp *llm.Response, emptyMsg string) (*GeneratedText, error) {
+	content := strings.TrimSpace(resp.Content)
+	if content == "" {
+		return nil, errors.New(emptyMsg)
 	}
+	modelName := resp.Model
+	if modelName == "" {
+		modelName = req.Model
+	}
+	return &GeneratedText{
+		Content:       content,
+		Model:         modelName,
+		PromptVersion: req.PromptVersion,
+		Usage:         resp.Usage,
+		Cost:          llm.Cost(modelName, resp.Usage),
+	}, nil
+}
 
-	return aiResp.Choices[0].Message.Content, nil
+// partialGeneratedText 中断的回复，记录已产生的用量和费用
+func partialGeneratedText(req llm.Request, resp *llm.Response) *GeneratedText {
+	modelName := resp.Model
+	if modelName == "" {
+		modelName = req.Model
+	}
+	return &GeneratedText{
+		Content:       resp.Content,
+		Model:         modelName,
+		PromptVersion: req.PromptVersion,
+		Usage:         resp.Usage,
+		Cost:          llm.Cost(modelName, resp.Usage),
+	}
 }
 
 func typeLabel(questionType string) string {
@@ -118,3 +130,82 @@ func typeLabel(questionType string) string {
 		return questionType
 	}
 }
+
+// runAIBatch 按配置的并发数和速率处理批量任务中的题目，连续失败过多时终止任务
+// total 为任务的题目总数，n 为本次需要处理的题目数，handle 处理第 i 道题目并返回是否失败
+// 任务取消或服务停止时 handle 返回错误的题目不计入进度，由 handle 保证其保持等待状态，下次执行时重新处理
+func runAIBatch(jc *JobContext, total int64, n int, handle func(ctx context.Context, i int) (bool, error)) error {
+	if n == 0 {
+		return nil
+	}
+
+	cfg := config.GlobalConfig.AI.Batch
+	ctx, cancel := context.WithCancel(jc)
+	defer cancel()
+
+	var (
+		mu          sync.Mutex
+		done        = total - int64(n)
+		consecutive int
+		abortErr    error
+	)
+	// report 记录一道题目的处理结果并更新进度，连续失败过多时终止任务
+	report := func(failed bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if failed {
+			consecutive++
+			if consecutive >= maxConsecutiveAIFailures && abortErr == nil {
+				abortErr = fmt.Errorf("连续%d道题目处理失败，任务终止: %v", consecutive, err)
+				cancel()
+			}
+		} else {
+			consecutive = 0
+		}
+		jc.SetProgress(int(done * 100 / total))
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				failed, err := handle(ctx, i)
+				if ctx.Err() != nil && err != nil {
+					// 中断的题目保持等待状态，下次执行时重新处理
+					continue
+				}
+				report(failed, err)
+			}
+		}()
+	}
+
+	// 按每分钟请求数限速派发
+	limiter := time.NewTicker(time.Minute / time.Duration(cfg.RequestsPerMinute))
+	defer limiter.Stop()
+dispatch:
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			select {
+			case <-limiter.C:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if abortErr != nil {
+		return abortErr
+	}
+	return jc.Err()
+}
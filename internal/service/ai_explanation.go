@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// JobTypeAIExplanation 批量生成课程题目解析的任务类型
+const JobTypeAIExplanation = "ai_explanation"
+
+var AIExplanation = new(AIExplanationService)
+
+// AIExplanationService 批量生成课程题目解析，生成结果保存为解析草稿
+// 任务开始时记录需要处理的题目，每道题目的结果单独保存，服务重启后任务只处理尚未完成的题目
+type AIExplanationService struct{}
+
+// AIExplanationParams 批量生成解析的任务参数
+type AIExplanationParams struct {
+	CourseID    uint `json:"course_id"`
+	OverwriteAI bool `json:"overwrite_ai"` // 是否重新生成AI生成的解析及待审核的草稿，人工编写的解析始终保留
+	AutoApprove bool `json:"auto_approve"` // 是否直接采用生成的解析，否则保存为草稿等待审核
+}
+
+// AIExplanationResult 批量生成解析的任务结果
+type AIExplanationResult struct {
+	Total            int     `json:"total"`
+	Succeeded        int     `json:"succeeded"`
+	Failed           int     `json:"failed"`
+	Skipped          int     `json:"skipped"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// SubmitBatch 提交批量生成解析任务，同一课程同时只能有一个任务
+func (s *AIExplanationService) SubmitBatch(params AIExplanationParams, creatorID uint) (*model.Job, error) {
+	var course model.Course
+	if err := database.DB.First(&course, params.CourseID).Error; err != nil {
+		return nil, errors.New("课程不存在")
+	}
+
+	var active []model.Job
+	if err := database.DB.Where("type = ? AND status IN ?", JobTypeAIExplanation,
+		[]string{model.JobStatusPending, model.JobStatusRunning}).Find(&active).Error; err != nil {
+		return nil, fmt.Errorf("查询任务失败: %v", err)
+	}
+	for i := range active {
+		jc := &JobContext{Job: &active[i]}
+		var p AIExplanationParams
+		if jc.Bind(&p) == nil && p.CourseID == params.CourseID {
+			return nil, fmt.Errorf("该课程已有进行中的生成任务（任务ID %d）", active[i].ID)
+		}
+	}
+
+	var count int64
+	if err := s.targetQuery(params).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询题目失败: %v", err)
+	}
+	if count == 0 {
+		return nil, errors.New("该课程没有需要生成解析的题目")
+	}
+
+	return Job.Submit(JobTypeAIExplanation, params, creatorID)
+}
+
+// targetQuery 需要生成解析的题目：解析为空，或允许覆盖时解析由AI生成
+func (s *AIExplanationService) targetQuery(params AIExplanationParams) *gorm.DB {
+	db := database.DB.Model(&model.Question{}).Where("course_id = ?", params.CourseID)
+	if params.OverwriteAI {
+		return db.Where("(explanation IS NULL OR TRIM(explanation) = '' OR explanation_source = ?)", model.ExplanationSourceAI)
+	}
+	return db.Where("(explanation IS NULL OR TRIM(explanation) = '')")
+}
+
+// ListItems 获取任务中每道题目的处理状态
+func (s *AIExplanationService) ListItems(jobID uint, status string, page, size int) ([]model.AIExplanationItem, int64, error) {
+	db := database.DB.Model(&model.AIExplanationItem{}).Where("job_id = ?", jobID)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var items []model.AIExplanationItem
+	if err := db.Order("id").Offset((page - 1) * size).Limit(size).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// runBatchJob 执行批量生成解析任务
+func (s *AIExplanationService) runBatchJob(jc *JobContext) error {
+	var params AIExplanationParams
+	if err := jc.Bind(&params); err != nil {
+		return err
+	}
+
+	if err := s.prepareItems(jc.Job.ID, params); err != nil {
+		return err
+	}
+
+	var pending []model.AIExplanationItem
+	if err := database.DB.Where("job_id = ? AND status = ?", jc.Job.ID, model.AIItemStatusPending).
+		Order("id").Find(&pending).Error; err != nil {
+		return fmt.Errorf("查询待处理题目失败: %v", err)
+	}
+	var total int64
+	if err := database.DB.Model(&model.AIExplanationItem{}).Where("job_id = ?", jc.Job.ID).Count(&total).Error; err != nil {
+		return fmt.Errorf("查询待处理题目失败: %v", err)
+	}
+
+	if err := s.process(jc, params, pending, total); err != nil {
+		return err
+	}
+
+	result, err := s.summarize(jc.Job.ID)
+	if err != nil {
+		return err
+	}
+	return jc.SetResult(result)
+}
+
+// prepareItems 首次执行时记录需要处理的题目，重新执行时沿用已有记录
+func (s *AIExplanationService) prepareItems(jobID uint, params AIExplanationParams) error {
+	var count int64
+	if err := database.DB.Model(&model.AIExplanationItem{}).Where("job_id = ?", jobID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询任务题目失败: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var ids []uint
+	if err := s.targetQuery(params).Order("id").Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("查询题目失败: %v", err)
+	}
+
+	items := make([]model.AIExplanationItem, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, model.AIExplanationItem{
+			JobID:      jobID,
+			QuestionID: id,
+			Status:     model.AIItemStatusPending,
+		})
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if err := database.DB.CreateInBatches(items, 500).Error; err != nil {
+		return fmt.Errorf("保存任务题目失败: %v", err)
+	}
+	return nil
+}
+
+// process 处理待生成的题目，任务取消或服务停止时未处理的题目保持等待状态
+func (s *AIExplanationService) process(jc *JobContext, params AIExplanationParams, pending []model.AIExplanationItem, total int64) error {
+	return runAIBatch(jc, total, len(pending), func(ctx context.Context, i int) (bool, error) {
+		return s.processItem(ctx, jc.Job, params, &pending[i])
+	})
+}
+
+// processItem 为一道题目生成解析草稿，允许直接采用时写入题目解析，返回是否失败
+func (s *AIExplanationService) processItem(ctx context.Context, job *model.Job, params AIExplanationParams, item *model.AIExplanationItem) (bool, error) {
+	var question model.Question
+	if err := database.DB.First(&question, item.QuestionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.finishItem(item, model.AIItemStatusSkipped, "题目已删除")
+			return false, nil
+		}
+		s.finishItem(item, model.AIItemStatusFailed, "查询题目失败")
+		return true, err
+	}
+	if !s.needsExplanation(&question, params) {
+		s.finishItem(item, model.AIItemStatusSkipped, "题目已有解析")
+		return false, nil
+	}
+	if !params.OverwriteAI {
+		if draft, err := ExplanationDraft.Pending(question.ID); err == nil && draft != nil {
+			s.finishItem(item, model.AIItemStatusSkipped, "题目已有待审核的AI解析")
+			return false, nil
+		}
+	}
+
+	gen, err := AI.GenerateExplanation(ctx, &question)
+	if err != nil && ctx.Err() != nil {
+		return true, err
+	}
+	AIUsage.Record(AIUsageMeta{
+		UserID:     job.CreatorID,
+		Feature:    model.AIFeatureExplanationBatch,
+		CourseID:   question.CourseID,
+		QuestionID: question.ID,
+		JobID:      job.ID,
+	}, gen, err)
+	if err != nil {
+		s.finishItem(item, model.AIItemStatusFailed, err.Error())
+		return true, err
+	}
+
+	item.Model = gen.Model
+	item.PromptTokens = gen.Usage.PromptTokens
+	item.CompletionTokens = gen.Usage.CompletionTokens
+	item.Cost = gen.Cost
+
+	// 直接采用时，生成期间管理员可能已手动填写解析，写入时不覆盖人工解析，草稿留待审核
+	applied := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		draft, err := ExplanationDraft.SaveTx(tx, question.ID, gen, model.DraftOriginBatch, job.CreatorID, job.ID)
+		if err != nil {
+			return err
+		}
+		item.DraftID = draft.ID
+		if params.AutoApprove {
+			applied, err = ExplanationDraft.approveTx(tx, draft, job.CreatorID, draft.Content, false, true)
+		}
+		return err
+	})
+	switch {
+	case err != nil:
+		item.DraftID = 0
+		s.finishItem(item, model.AIItemStatusFailed, fmt.Sprintf("保存解析失败: %v", err))
+		return true, err
+	case params.AutoApprove && !applied:
+		s.finishItem(item, model.AIItemStatusSucceeded, "生成期间题目已有人工解析，草稿待审核")
+	default:
+		s.finishItem(item, model.AIItemStatusSucceeded, "")
+	}
+	return false, nil
+}
+
+// needsExplanation 题目当前是否需要生成解析
+func (s *AIExplanationService) needsExplanation(question *model.Question, params AIExplanationParams) bool {
+	if strings.TrimSpace(question.Explanation) == "" {
+		return true
+	}
+	return params.OverwriteAI && question.ExplanationSource == model.ExplanationSourceAI
+}
+
+// finishItem 保存题目的处理结果
+func (s *AIExplanationService) finishItem(item *model.AIExplanationItem, status, message string) {
+	item.Status = status
+	item.Message = truncateMessage(message, 500)
+	database.DB.Model(&model.AIExplanationItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"status":            item.Status,
+		"message":           item.Message,
+		"draft_id":          item.DraftID,
+		"model":             item.Model,
+		"prompt_tokens":     item.PromptTokens,
+		"completion_tokens": item.CompletionTokens,
+		"cost":              item.Cost,
+	})
+}
+
+// summarize 汇总任务中所有题目的处理结果和用量
+func (s *AIExplanationService) summarize(jobID uint) (*AIExplanationResult, error) {
+	var rows []struct {
+		Status           string
+		Count            int
+		PromptTokens     int64
+		CompletionTokens int64
+		Cost             float64
+	}
+	if err := database.DB.Model(&model.AIExplanationItem{}).
+		Select("status, COUNT(*) AS count, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, SUM(cost) AS cost").
+		Where("job_id = ?", jobID).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总任务结果失败: %v", err)
+	}
+
+	result := &AIExplanationResult{}
+	for _, row := range rows {
+		result.Total += row.Count
+		result.PromptTokens += row.PromptTokens
+		result.CompletionTokens += row.CompletionTokens
+		result.Cost += row.Cost
+		switch row.Status {
+		case model.AIItemStatusSucceeded:
+			result.Succeeded += row.Count
+		case model.AIItemStatusFailed:
+			result.Failed += row.Count
+		case model.AIItemStatusSkipped:
+			result.Skipped += row.Count
+		}
+	}
+	return result, nil
+}
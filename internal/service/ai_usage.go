@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exam-system/internal/config"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/logger"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var AIUsage = new(AIUsageService)
+
+// AIUsageService 记录每次调用模型服务的用户、功能、token 用量和费用，控制学生端的使用额度
+// 学生端功能调用前通过 Reserve 检查额度并占用一次，后台任务不受额度限制，调用后通过 Record 记录
+type AIUsageService struct{}
+
+// ErrAIQuotaExceeded AI使用额度已用完
+var ErrAIQuotaExceeded = errors.New("AI使用额度已用完")
+
+// AIUsageMeta 一次调用的来源
+type AIUsageMeta struct {
+	UserID     uint
+	Feature    string
+	CourseID   uint
+	QuestionID uint
+	JobID      uint
+}
+
+// AIUsageStats AI用量统计
+type AIUsageStats struct {
+	Requests         int64                `json:"requests"`
+	PromptTokens     int64                `json:"prompt_tokens"`
+	CompletionTokens int64                `json:"completion_tokens"`
+	Cost             float64              `json:"cost"`
+	Daily            []AIUsageStatsPoint  `json:"daily"`    // 按天统计
+	Courses          []AIUsageCourseStats `json:"courses"`  // 按课程统计，费用高的在前
+	Features         []AIUsageStatsPoint  `json:"features"` // 按功能统计
+	TopUsers         []AIUsageUserStats   `json:"top_users"`
+}
+
+// AIUsageStatsPoint 按天或按功能统计的用量，Key 为日期或功能名称
+type AIUsageStatsPoint struct {
+	Key              string  `json:"key"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// AIUsageCourseStats 按课程统计的用量
+type AIUsageCourseStats struct {
+	CourseID         uint    `json:"course_id"`
+	CourseName       string  `json:"course_name"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// AIUsageUserStats 按用户统计的用量，用于发现异常使用
+type AIUsageUserStats struct {
+	UserID   uint    `json:"user_id"`
+	Username string  `json:"username"`
+	Nickname string  `json:"nickname"`
+	Requests int64   `json:"requests"`
+	Cost     float64 `json:"cost"`
+}
+
+// topUsersLimit 统计中返回的用量最高的用户数
+const topUsersLimit = 10
+
+// Reserve 检查学生的使用额度，通过后记录一次进行中的调用；调用结束后必须通过 Finish 记录结果
+// 锁定用户记录，同一用户并发请求时额度统计准确
+func (s *AIUsageService) Reserve(meta AIUsageMeta) (*model.AIUsage, error) {
+	cfg := config.GlobalConfig.AI.Quota
+	today := startOfDay(time.Now())
+
+	usage := &model.AIUsage{
+		UserID:     meta.UserID,
+		Feature:    meta.Feature,
+		CourseID:   meta.CourseID,
+		QuestionID: meta.QuestionID,
+		JobID:      meta.JobID,
+		Status:     model.AIUsageStatusPending,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&model.User{}, meta.UserID).Error; err != nil {
+			return errors.New("用户不存在")
+		}
+
+		var used struct {
+			Requests int64
+			Cost     float64
+		}
+		if err := tx.Model(&model.AIUsage{}).
+			Select("COUNT(*) AS requests, COALESCE(SUM(cost), 0) AS cost").
+			Where("user_id = ? AND created_at >= ? AND status <> ?", meta.UserID, today, model.AIUsageStatusFailed).
+			Scan(&used).Error; err != nil {
+			return err
+		}
+		if cfg.UserDailyRequests > 0 && used.Requests >= int64(cfg.UserDailyRequests) {
+			return fmt.Errorf("%w：每天最多使用%d次，请明天再试", ErrAIQuotaExceeded, cfg.UserDailyRequests)
+		}
+		if cfg.UserDailyCost > 0 && used.Cost >= cfg.UserDailyCost {
+			return fmt.Errorf("%w：今日额度已用完，请明天再试", ErrAIQuotaExceeded)
+		}
+
+		if cfg.DailyBudget > 0 {
+			var spent float64
+			if err := tx.Model(&model.AIUsage{}).
+				Select("COALESCE(SUM(cost), 0)").
+				Where("created_at >= ?", today).
+				Scan(&spent).Error; err != nil {
+				return err
+			}
+			if spent >= cfg.DailyBudget {
+				return fmt.Errorf("%w：今日AI服务已达使用上限，请明天再试", ErrAIQuotaExceeded)
+			}
+		}
+
+		return tx.Create(usage).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// Finish 记录 Reserve 占用的调用结果，失败的调用不计入次数额度，调用方取消的调用照常计入
+// 中断的调用 gen 不为空时记录已产生的用量和费用
+func (s *AIUsageService) Finish(usage *model.AIUsage, gen *GeneratedText, err error) {
+	updates := map[string]interface{}{"status": usageStatus(err)}
+	if err != nil {
+		updates["message"] = truncateMessage(err.Error(), 500)
+	}
+	if gen != nil {
+		updates["model"] = gen.Model
+		updates["prompt_version"] = gen.PromptVersion
+		updates["prompt_tokens"] = gen.Usage.PromptTokens
+		updates["completion_tokens"] = gen.Usage.CompletionTokens
+		updates["cost"] = gen.Cost
+	}
+	if dbErr := database.DB.Model(usage).Updates(updates).Error; dbErr != nil {
+		logger.Errorf("记录AI调用失败: %v", dbErr)
+	}
+}
+
+// Record 记录一次不受额度限制的调用，用于后台任务
+func (s *AIUsageService) Record(meta AIUsageMeta, gen *GeneratedText, err error) {
+	usage := &model.AIUsage{
+		UserID:     meta.UserID,
+		Feature:    meta.Feature,
+		CourseID:   meta.CourseID,
+		QuestionID: meta.QuestionID,
+		JobID:      meta.JobID,
+		Status:     usageStatus(err),
+	}
+	if err != nil {
+		usage.Message = truncateMessage(err.Error(), 500)
+	}
+	if gen != nil {
+		usage.Model = gen.Model
+		usage.PromptVersion = gen.PromptVersion
+		usage.PromptTokens = gen.Usage.PromptTokens
+		usage.CompletionTokens = gen.Usage.CompletionTokens
+		usage.Cost = gen.Cost
+	}
+	if dbErr := database.DB.Create(usage).Error; dbErr != nil {
+		logger.Errorf("记录AI调用失败: %v", dbErr)
+	}
+}
+
+// usageStatus 调用结果对应的记录状态
+func usageStatus(err error) string {
+	switch {
+	case err == nil:
+		return model.AIUsageStatusSucceeded
+	case errors.Is(err, context.Canceled):
+		return model.AIUsageStatusCanceled
+	default:
+		return model.AIUsageStatusFailed
+	}
+}
+
+// List 获取AI调用记录，最新的在前
+func (s *AIUsageService) List(userId, courseId uint, feature, status string, page, size int) ([]model.AIUsage, int64, error) {
+	db := database.DB.Model(&model.AIUsage{})
+	if userId > 0 {
+		db = db.Where("user_id = ?", userId)
+	}
+	if courseId > 0 {
+		db = db.Where("course_id = ?", courseId)
+	}
+	if feature != "" {
+		db = db.Where("feature = ?", feature)
+	}
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var usages []model.AIUsage
+	if err := db.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&usages).Error; err != nil {
+		return nil, 0, err
+	}
+	return usages, total, nil
+}
+
+// Stats 统计时间范围内的AI用量和费用，按天、课程、功能汇总，并列出用量最高的用户
+func (s *AIUsageService) Stats(startTime, endTime time.Time, courseId uint, feature string) (*AIUsageStats, error) {
+	base := func() *gorm.DB {
+		db := database.DB.Model(&model.AIUsage{}).
+			Where("ai_usages.created_at BETWEEN ? AND ?", startTime, endTime)
+		if courseId > 0 {
+			db = db.Where("ai_usages.course_id = ?", courseId)
+		}
+		if feature != "" {
+			db = db.Where("ai_usages.feature = ?", feature)
+		}
+		return db
+	}
+	const sums = "COUNT(*) AS requests, COALESCE(SUM(ai_usages.prompt_tokens), 0) AS prompt_tokens, " +
+		"COALESCE(SUM(ai_usages.completion_tokens), 0) AS completion_tokens, COALESCE(SUM(ai_usages.cost), 0) AS cost"
+
+	stats := &AIUsageStats{
+		Daily:    []AIUsageStatsPoint{},
+		Courses:  []AIUsageCourseStats{},
+		Features: []AIUsageStatsPoint{},
+		TopUsers: []AIUsageUserStats{},
+	}
+	var totals AIUsageStatsPoint
+	if err := base().Select(sums).Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	stats.Requests = totals.Requests
+	stats.PromptTokens = totals.PromptTokens
+	stats.CompletionTokens = totals.CompletionTokens
+	stats.Cost = totals.Cost
+	if err := base().Select("DATE_FORMAT(ai_usages.created_at, '%Y-%m-%d') AS `key`, " + sums).
+		Group("`key`").
+		Order("`key` ASC").
+		Scan(&stats.Daily).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("ai_usages.course_id, MAX(courses.name) AS course_name, " + sums).
+		Joins("LEFT JOIN courses ON courses.id = ai_usages.course_id").
+		Group("ai_usages.course_id").
+		Order("cost DESC").
+		Scan(&stats.Courses).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("ai_usages.feature AS `key`, " + sums).
+		Group("ai_usages.feature").
+		Order("cost DESC").
+		Scan(&stats.Features).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("ai_usages.user_id, MAX(users.username) AS username, MAX(users.nickname) AS nickname, " +
+		"COUNT(*) AS requests, COALESCE(SUM(ai_usages.cost), 0) AS cost").
+		Joins("LEFT JOIN users ON users.id = ai_usages.user_id").
+		Group("ai_usages.user_id").
+		Order("cost DESC, requests DESC").
+		Limit(topUsersLimit).
+		Scan(&stats.TopUsers).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// startOfDay 当天零点
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
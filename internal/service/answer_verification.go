@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/llm"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobTypeAnswerVerification 校验课程题目答案的任务类型
+const JobTypeAnswerVerification = "answer_verification"
+
+var AnswerVerification = new(AnswerVerificationService)
+
+// AnswerVerificationService 由模型独立解答课程中的题目，与题目答案比对后记录疑似错误，供编辑逐一核对
+// 任务开始时记录需要校验的题目，每道题目的结果单独保存，服务重启后任务只处理尚未完成的题目
+type AnswerVerificationService struct{}
+
+// ErrAnswerCheckNotFound 校验记录不存在
+var ErrAnswerCheckNotFound = errors.New("校验记录不存在")
+
+// AnswerVerificationParams 答案校验的任务参数
+type AnswerVerificationParams struct {
+	CourseID uint `json:"course_id"`
+}
+
+// AnswerVerificationResult 答案校验的任务结果
+type AnswerVerificationResult struct {
+	Total            int     `json:"total"`
+	Agreed           int     `json:"agreed"`
+	Disagreed        int     `json:"disagreed"`
+	Failed           int     `json:"failed"`
+	Skipped          int     `json:"skipped"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// AnswerCheckItem 疑似错误报告中的一条记录，附带题目当前内容
+type AnswerCheckItem struct {
+	model.AnswerCheck
+	Question ReviewQuestion `json:"question"`
+}
+
+// AnswerCheckQuery 疑似错误报告的查询条件
+type AnswerCheckQuery struct {
+	CourseID   uint
+	JobID      uint   // 为0时使用该课程最近一次校验任务
+	Status     string // 为空时返回全部
+	Resolution string // open 表示待处理，为空时返回全部
+	Sort       string // confidence 或 id
+	Desc       bool
+	Page       int
+	Size       int
+}
+
+// answerVerificationPromptData 校验答案提示词模板的变量
+type answerVerificationPromptData struct {
+	Type     string
+	Question string
+	Options  string
+}
+
+// verifiedAnswer 模型返回的作答结果
+type verifiedAnswer struct {
+	Answer     string  `json:"answer"`
+	Confidence float64 `json:"confidence"`
+	Reasoning  string  `json:"reasoning"`
+}
+
+// SubmitSweep 提交课程答案校验任务，同一课程同时只能有一个任务
+func (s *AnswerVerificationService) SubmitSweep(params AnswerVerificationParams, creatorID uint) (*model.Job, error) {
+	var course model.Course
+	if err := database.DB.First(&course, params.CourseID).Error; err != nil {
+		return nil, errors.New("课程不存在")
+	}
+
+	var active []model.Job
+	if err := database.DB.Where("type = ? AND status IN ?", JobTypeAnswerVerification,
+		[]string{model.JobStatusPending, model.JobStatusRunning}).Find(&active).Error; err != nil {
+		return nil, fmt.Errorf("查询任务失败: %v", err)
+	}
+	for i := range active {
+		jc := &JobContext{Job: &active[i]}
+		var p AnswerVerificationParams
+		if jc.Bind(&p) == nil && p.CourseID == params.CourseID {
+			return nil, fmt.Errorf("该课程已有进行中的校验任务（任务ID %d）", active[i].ID)
+		}
+	}
+
+	var count int64
+	if err := database.DB.Model(&model.Question{}).Where("course_id = ?", params.CourseID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询题目失败: %v", err)
+	}
+	if count == 0 {
+		return nil, errors.New("该课程没有题目")
+	}
+
+	return Job.Submit(JobTypeAnswerVerification, params, creatorID)
+}
+
+// runVerificationJob 执行答案校验任务
+func (s *AnswerVerificationService) runVerificationJob(jc *JobContext) error {
+	var params AnswerVerificationParams
+	if err := jc.Bind(&params); err != nil {
+		return err
+	}
+
+	if err := s.prepareChecks(jc.Job.ID, params); err != nil {
+		return err
+	}
+
+	var pending []model.AnswerCheck
+	if err := database.DB.Where("job_id = ? AND status = ?", jc.Job.ID, model.AnswerCheckPending).
+		Order("id").Find(&pending).Error; err != nil {
+		return fmt.Errorf("查询待校验题目失败: %v", err)
+	}
+	var total int64
+	if err := database.DB.Model(&model.AnswerCheck{}).Where("job_id = ?", jc.Job.ID).Count(&total).Error; err != nil {
+		return fmt.Errorf("查询待校验题目失败: %v", err)
+	}
+
+	if err := runAIBatch(jc, total, len(pending), func(ctx context.Context, i int) (bool, error) {
+		return s.checkQuestion(ctx, jc.Job, &pending[i])
+	}); err != nil {
+		return err
+	}
+
+	result, err := s.summarize(jc.Job.ID)
+	if err != nil {
+		return err
+	}
+	return jc.SetResult(result)
+}
+
+// prepareChecks 首次执行时记录课程中的全部题目，重新执行时沿用已有记录
+func (s *AnswerVerificationService) prepareChecks(jobID uint, params AnswerVerificationParams) error {
+	var count int64
+	if err := database.DB.Model(&model.AnswerCheck{}).Where("job_id = ?", jobID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询任务题目失败: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var ids []uint
+	if err := database.DB.Model(&model.Question{}).Where("course_id = ?", params.CourseID).
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("查询题目失败: %v", err)
+	}
+
+	checks := make([]model.AnswerCheck, 0, len(ids))
+	for _, id := range ids {
+		checks = append(checks, model.AnswerCheck{
+			JobID:      jobID,
+			QuestionID: id,
+			CourseID:   params.CourseID,
+			Status:     model.AnswerCheckPending,
+		})
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+	if err := database.DB.CreateInBatches(checks, 500).Error; err != nil {
+		return fmt.Errorf("保存任务题目失败: %v", err)
+	}
+	return nil
+}
+
+// checkQuestion 由模型独立解答一道题目并与题目答案比对，返回是否失败
+func (s *AnswerVerificationService) checkQuestion(ctx context.Context, job *model.Job, check *model.AnswerCheck) (bool, error) {
+	var question model.Question
+	if err := database.DB.First(&question, check.QuestionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.finishCheck(check, model.AnswerCheckSkipped, "题目已删除")
+			return false, nil
+		}
+		s.finishCheck(check, model.AnswerCheckFailed, "查询题目失败")
+		return true, err
+	}
+	check.StoredAnswer = question.Answer
+	if hasQuestionMedia(&question) {
+		s.finishCheck(check, model.AnswerCheckSkipped, "题目包含图片等媒体，模型无法查看")
+		return false, nil
+	}
+
+	optionsText := ""
+	for _, opt := range question.Options {
+		optionsText += fmt.Sprintf("%s. %s\n", opt.Label, opt.Text)
+	}
+	req, err := llm.Prompt("answer_verification", answerVerificationPromptData{
+		Type:     typeLabel(question.Type),
+		Question: question.Question,
+		Options:  strings.TrimRight(optionsText, "\n"),
+	})
+	if err != nil {
+		s.finishCheck(check, model.AnswerCheckFailed, err.Error())
+		return true, err
+	}
+
+	var gen *GeneratedText
+	resp, err := llm.Chat(ctx, req)
+	if err == nil {
+		gen, err = newGeneratedText(req, resp, "AI未返回有效内容")
+	}
+	if err != nil && ctx.Err() != nil {
+		return true, err
+	}
+	AIUsage.Record(AIUsageMeta{
+		UserID:     job.CreatorID,
+		Feature:    model.AIFeatureAnswerVerification,
+		CourseID:   question.CourseID,
+		QuestionID: question.ID,
+		JobID:      job.ID,
+	}, gen, err)
+	if err != nil {
+		s.finishCheck(check, model.AnswerCheckFailed, err.Error())
+		return true, err
+	}
+	check.Model = gen.Model
+	check.PromptVersion = gen.PromptVersion
+	check.PromptTokens = gen.Usage.PromptTokens
+	check.CompletionTokens = gen.Usage.CompletionTokens
+	check.Cost = gen.Cost
+
+	verified, err := parseVerifiedAnswer(gen.Content)
+	if err != nil {
+		s.finishCheck(check, model.AnswerCheckFailed, err.Error())
+		return true, err
+	}
+	check.ModelAnswer = normalizeGeneratedAnswer(question.Type, verified.Answer)
+	check.Confidence = verified.Confidence
+	check.Reasoning = strings.TrimSpace(verified.Reasoning)
+	if check.ModelAnswer == "" {
+		s.finishCheck(check, model.AnswerCheckFailed, "AI未给出有效答案")
+		return true, errors.New("AI未给出有效答案")
+	}
+
+	status := model.AnswerCheckAgreed
+	if check.ModelAnswer != normalizeGeneratedAnswer(question.Type, question.Answer) {
+		status = model.AnswerCheckDisagreed
+	}
+	s.finishCheck(check, status, "")
+	return false, nil
+}
+
+// hasQuestionMedia 题干或选项是否包含媒体
+func hasQuestionMedia(question *model.Question) bool {
+	if len(question.StemMedia) > 0 {
+		return true
+	}
+	for _, opt := range question.Options {
+		if len(opt.Media) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVerifiedAnswer 从模型回复中解析作答结果，把握按0-1记录，兼容百分制
+func parseVerifiedAnswer(content string) (*verifiedAnswer, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("AI返回的内容不是JSON对象")
+	}
+
+	var verified verifiedAnswer
+	if err := json.Unmarshal([]byte(content[start:end+1]), &verified); err != nil {
+		return nil, fmt.Errorf("解析AI返回的答案失败: %v", err)
+	}
+	if verified.Confidence > 1 {
+		verified.Confidence /= 100
+	}
+	if verified.Confidence < 0 {
+		verified.Confidence = 0
+	}
+	if verified.Confidence > 1 {
+		verified.Confidence = 1
+	}
+	return &verified, nil
+}
+
+// finishCheck 保存题目的校验结果
+func (s *AnswerVerificationService) finishCheck(check *model.AnswerCheck, status, message string) {
+	check.Status = status
+	check.Message = truncateMessage(message, 500)
+	database.DB.Model(&model.AnswerCheck{}).Where("id = ?", check.ID).Updates(map[string]interface{}{
+		"status":            check.Status,
+		"message":           check.Message,
+		"stored_answer":     check.StoredAnswer,
+		"model_answer":      truncateMessage(check.ModelAnswer, 255),
+		"confidence":        check.Confidence,
+		"reasoning":         check.Reasoning,
+		"model":             check.Model,
+		"prompt_version":    check.PromptVersion,
+		"prompt_tokens":     check.PromptTokens,
+		"completion_tokens": check.CompletionTokens,
+		"cost":              check.Cost,
+	})
+}
+
+// summarize 汇总任务中所有题目的校验结果和用量
+func (s *AnswerVerificationService) summarize(jobID uint) (*AnswerVerificationResult, error) {
+	var rows []struct {
+		Status           string
+		Count            int
+		PromptTokens     int64
+		CompletionTokens int64
+		Cost             float64
+	}
+	if err := database.DB.Model(&model.AnswerCheck{}).
+		Select("status, COUNT(*) AS count, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, SUM(cost) AS cost").
+		Where("job_id = ?", jobID).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总任务结果失败: %v", err)
+	}
+
+	result := &AnswerVerificationResult{}
+	for _, row := range rows {
+		result.Total += row.Count
+		result.PromptTokens += row.PromptTokens
+		result.CompletionTokens += row.CompletionTokens
+		result.Cost += row.Cost
+		switch row.Status {
+		case model.AnswerCheckAgreed:
+			result.Agreed += row.Count
+		case model.AnswerCheckDisagreed:
+			result.Disagreed += row.Count
+		case model.AnswerCheckFailed:
+			result.Failed += row.Count
+		case model.AnswerCheckSkipped:
+			result.Skipped += row.Count
+		}
+	}
+	return result, nil
+}
+
+// Report 获取课程的校验报告，返回使用的任务ID；未指定任务时使用该课程最近一次校验任务
+func (s *AnswerVerificationService) Report(query AnswerCheckQuery) ([]AnswerCheckItem, int64, uint, error) {
+	jobID := query.JobID
+	if jobID == 0 {
+		var latest model.AnswerCheck
+		err := database.DB.Where("course_id = ?", query.CourseID).Order("job_id DESC").First(&latest).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []AnswerCheckItem{}, 0, 0, nil
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		jobID = latest.JobID
+	}
+
+	db := database.DB.Model(&model.AnswerCheck{}).Where("job_id = ?", jobID)
+	if query.CourseID > 0 {
+		db = db.Where("course_id = ?", query.CourseID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	switch query.Resolution {
+	case "":
+	case "open":
+		db = db.Where("resolution = ?", model.AnswerCheckResolutionOpen)
+	default:
+		db = db.Where("resolution = ?", query.Resolution)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	direction := "ASC"
+	if query.Desc {
+		direction = "DESC"
+	}
+	order := "id " + direction
+	if query.Sort == "confidence" {
+		order = "confidence " + direction + ", id"
+	}
+	var checks []model.AnswerCheck
+	if err := db.Order(order).Offset((query.Page - 1) * query.Size).Limit(query.Size).Find(&checks).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	questionIDs := make([]uint, 0, len(checks))
+	for _, c := range checks {
+		questionIDs = append(questionIDs, c.QuestionID)
+	}
+	questionByID, err := loadReviewQuestions(questionIDs)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	items := make([]AnswerCheckItem, 0, len(checks))
+	for _, c := range checks {
+		items = append(items, AnswerCheckItem{
+			AnswerCheck: c,
+			Question:    questionByID[c.QuestionID],
+		})
+	}
+	return items, total, jobID, nil
+}
+
+// Resolve 处理疑似错误：accepted 将题目答案改为模型答案，corrected 表示已人工修正，dismissed 表示题目答案无误
+func (s *AnswerVerificationService) Resolve(id, reviewerID uint, resolution, note string) error {
+	switch resolution {
+	case model.AnswerCheckResolutionAccepted, model.AnswerCheckResolutionCorrected, model.AnswerCheckResolutionDismissed:
+	default:
+		return errors.New("无效的处理结果")
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var check model.AnswerCheck
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&check, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAnswerCheckNotFound
+			}
+			return err
+		}
+		if check.Status != model.AnswerCheckDisagreed {
+			return errors.New("只能处理答案不一致的题目")
+		}
+		if check.Resolution != model.AnswerCheckResolutionOpen {
+			return errors.New("该题目已处理")
+		}
+
+		if resolution == model.AnswerCheckResolutionAccepted {
+			if err := s.applyModelAnswer(tx, &check); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(&check).Updates(map[string]interface{}{
+			"resolution":      resolution,
+			"resolution_note": truncateMessage(strings.TrimSpace(note), 500),
+			"resolved_by":     reviewerID,
+			"resolved_at":     now,
+		}).Error
+	})
+}
+
+// applyModelAnswer 将题目答案改为模型答案，校验后题目答案已被修改时不覆盖
+func (s *AnswerVerificationService) applyModelAnswer(tx *gorm.DB, check *model.AnswerCheck) error {
+	var question model.Question
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&question, check.QuestionID).Error; err != nil {
+		return errors.New("题目不存在")
+	}
+	if question.Answer != check.StoredAnswer {
+		return errors.New("校验后题目答案已被修改，请核对后标记为已人工修正")
+	}
+	if err := ValidateQuestionOptions(question.Type, question.Options, check.ModelAnswer); err != nil {
+		return fmt.Errorf("模型答案无效: %v", err)
+	}
+
+	if err := tx.Model(&model.Question{}).Where("id = ?", question.ID).Update("answer", check.ModelAnswer).Error; err != nil {
+		return fmt.Errorf("更新题目答案失败: %v", err)
+	}
+	return QuestionSearch.IndexTx(tx, question.ID)
+}
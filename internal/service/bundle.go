@@ -0,0 +1,436 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var Bundle = new(BundleService)
+
+// BundleService 课程套餐，一个套餐包含多门课程，使用现有支付流程整体购买
+// 套餐订单支付后为每门课程生成金额为0的子订单，子订单同步为来源为套餐的课程权限
+type BundleService struct{}
+
+// 套餐子订单的支付方式
+const PaymentTypeBundle = "bundle"
+
+// BundleInput 创建或修改套餐的参数
+type BundleInput struct {
+	Name        string
+	Cover       string
+	Description string
+	Price       float64
+	ExpireDays  int
+	Sort        int
+	OnSale      bool
+	CourseIDs   []uint // 包含的课程，按顺序排列
+}
+
+// BundleCourseItem 套餐中的课程
+type BundleCourseItem struct {
+	ID    uint    `json:"id"`
+	Name  string  `json:"name"` // 所属分类名称 + "-" + 课程名称
+	Cover string  `json:"cover"`
+	Price float64 `json:"price"`
+}
+
+// BundleDetail 套餐详情
+type BundleDetail struct {
+	model.Bundle
+	OriginalPrice float64            `json:"original_price"` // 所含课程单独购买的总价
+	Courses       []BundleCourseItem `json:"courses"`
+	Purchased     bool               `json:"purchased"` // 当前用户是否已购买且在有效期内
+}
+
+// BundleBrief 课程所属套餐的简要信息
+type BundleBrief struct {
+	ID    uint    `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// List 获取套餐列表，onSaleOnly 为 true 时只返回上架的套餐
+func (s *BundleService) List(userId uint, onSaleOnly bool) ([]BundleDetail, error) {
+	db := database.DB.Order("sort, id")
+	if onSaleOnly {
+		db = db.Where("on_sale = ?", true)
+	}
+	var bundles []model.Bundle
+	if err := db.Find(&bundles).Error; err != nil {
+		return nil, err
+	}
+	return s.details(userId, bundles)
+}
+
+// Get 获取套餐详情，onSaleOnly 为 true 时下架的套餐视为不存在
+func (s *BundleService) Get(userId, id uint, onSaleOnly bool) (*BundleDetail, error) {
+	var bundle model.Bundle
+	if err := database.DB.First(&bundle, id).Error; err != nil || (onSaleOnly && !bundle.OnSale) {
+		return nil, errors.New("套餐不存在")
+	}
+	details, err := s.details(userId, []model.Bundle{bundle})
+	if err != nil {
+		return nil, err
+	}
+	return &details[0], nil
+}
+
+// details 批量补充套餐的课程列表和购买状态
+func (s *BundleService) details(userId uint, bundles []model.Bundle) ([]BundleDetail, error) {
+	result := make([]BundleDetail, 0, len(bundles))
+	if len(bundles) == 0 {
+		return result, nil
+	}
+
+	bundleIDs := make([]uint, 0, len(bundles))
+	for _, bundle := range bundles {
+		bundleIDs = append(bundleIDs, bundle.ID)
+	}
+
+	var links []model.BundleCourse
+	if err := database.DB.Where("bundle_id IN ?", bundleIDs).Order("bundle_id, sort, id").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	courseIDs := make([]uint, 0, len(links))
+	for _, link := range links {
+		courseIDs = append(courseIDs, link.CourseID)
+	}
+
+	var courses []model.Course
+	if len(courseIDs) > 0 {
+		if err := database.DB.Where("id IN ?", courseIDs).Find(&courses).Error; err != nil {
+			return nil, err
+		}
+	}
+	courseMap := make(map[uint]model.Course, len(courses))
+	for _, course := range courses {
+		courseMap[course.ID] = course
+	}
+	names := CourseCategory.CourseDisplayNames(courseIDs)
+
+	items := make(map[uint][]BundleCourseItem)
+	for _, link := range links {
+		course, ok := courseMap[link.CourseID]
+		if !ok {
+			continue // 课程已删除
+		}
+		items[link.BundleID] = append(items[link.BundleID], BundleCourseItem{
+			ID:    course.ID,
+			Name:  names[course.ID],
+			Cover: course.Cover,
+			Price: course.Price,
+		})
+	}
+
+	purchased := s.purchased(userId, bundleIDs)
+	for _, bundle := range bundles {
+		detail := BundleDetail{
+			Bundle:    bundle,
+			Courses:   items[bundle.ID],
+			Purchased: purchased[bundle.ID],
+		}
+		if detail.Courses == nil {
+			detail.Courses = make([]BundleCourseItem, 0)
+		}
+		for _, course := range detail.Courses {
+			detail.OriginalPrice += course.Price
+		}
+		result = append(result, detail)
+	}
+	return result, nil
+}
+
+// purchased 查询用户已购买且在有效期内的套餐
+func (s *BundleService) purchased(userId uint, bundleIDs []uint) map[uint]bool {
+	result := make(map[uint]bool)
+	if userId == 0 || len(bundleIDs) == 0 {
+		return result
+	}
+	var ids []uint
+	database.DB.Model(&model.Order{}).
+		Where("user_id = ? AND bundle_id IN ? AND status = ?", userId, bundleIDs, "paid").
+		Where("(expire_time IS NULL OR expire_time > ?)", time.Now()).
+		Distinct().Pluck("bundle_id", &ids)
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result
+}
+
+// ForCourses 查询包含指定课程的上架套餐，返回 课程ID -> 套餐列表
+func (s *BundleService) ForCourses(courseIDs []uint) map[uint][]BundleBrief {
+	result := make(map[uint][]BundleBrief)
+	if len(courseIDs) == 0 {
+		return result
+	}
+
+	var rows []struct {
+		CourseID uint
+		ID       uint
+		Name     string
+		Price    float64
+	}
+	if err := database.DB.Table("bundle_courses").
+		Select("bundle_courses.course_id, bundles.id, bundles.name, bundles.price").
+		Joins("JOIN bundles ON bundles.id = bundle_courses.bundle_id AND bundles.deleted_at IS NULL").
+		Where("bundle_courses.course_id IN ? AND bundles.on_sale = ?", courseIDs, true).
+		Order("bundles.sort, bundles.id").
+		Scan(&rows).Error; err != nil {
+		return result
+	}
+	for _, row := range rows {
+		result[row.CourseID] = append(result[row.CourseID], BundleBrief{ID: row.ID, Name: row.Name, Price: row.Price})
+	}
+	return result
+}
+
+// Create 创建套餐
+func (s *BundleService) Create(input BundleInput) (*model.Bundle, error) {
+	if err := s.validate(&input); err != nil {
+		return nil, err
+	}
+
+	bundle := &model.Bundle{}
+	s.apply(bundle, input)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bundle).Error; err != nil {
+			return err
+		}
+		return s.saveCourses(tx, bundle.ID, input.CourseIDs)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建套餐失败: %v", err)
+	}
+	return bundle, nil
+}
+
+// Update 修改套餐，包含的课程整体替换；已购买用户的课程权限不受影响
+func (s *BundleService) Update(id uint, input BundleInput) (*model.Bundle, error) {
+	var bundle model.Bundle
+	if err := database.DB.First(&bundle, id).Error; err != nil {
+		return nil, errors.New("套餐不存在")
+	}
+	if err := s.validate(&input); err != nil {
+		return nil, err
+	}
+
+	s.apply(&bundle, input)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("name", "cover", "description", "price", "expire_days", "sort", "on_sale").
+			Save(&bundle).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("bundle_id = ?", id).Delete(&model.BundleCourse{}).Error; err != nil {
+			return err
+		}
+		return s.saveCourses(tx, id, input.CourseIDs)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("修改套餐失败: %v", err)
+	}
+	return &bundle, nil
+}
+
+// Delete 删除套餐，已有订单的套餐只能下架
+func (s *BundleService) Delete(id uint) error {
+	var count int64
+	if err := database.DB.Model(&model.Order{}).Where("bundle_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查关联订单失败: %v", err)
+	}
+	if count > 0 {
+		return errors.New("该套餐已有订单关联，无法删除，请改为下架")
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.Bundle{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("套餐不存在")
+		}
+		return tx.Where("bundle_id = ?", id).Delete(&model.BundleCourse{}).Error
+	})
+}
+
+// validate 校验套餐参数，去除重复的课程
+func (s *BundleService) validate(input *BundleInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	if input.Price < 0 {
+		return errors.New("套餐价格不能为负数")
+	}
+	if input.ExpireDays < 0 {
+		return errors.New("套餐有效期不能为负数")
+	}
+
+	courseIDs := make([]uint, 0, len(input.CourseIDs))
+	seen := make(map[uint]bool)
+	for _, id := range input.CourseIDs {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			courseIDs = append(courseIDs, id)
+		}
+	}
+	if len(courseIDs) == 0 {
+		return errors.New("套餐至少包含一门课程")
+	}
+
+	var count int64
+	if err := database.DB.Model(&model.Course{}).Where("id IN ?", courseIDs).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询课程失败: %v", err)
+	}
+	if int(count) != len(courseIDs) {
+		return errors.New("套餐中包含不存在的课程")
+	}
+	input.CourseIDs = courseIDs
+	return nil
+}
+
+func (s *BundleService) apply(bundle *model.Bundle, input BundleInput) {
+	bundle.Name = input.Name
+	bundle.Cover = input.Cover
+	bundle.Description = input.Description
+	bundle.Price = input.Price
+	bundle.ExpireDays = input.ExpireDays
+	bundle.Sort = input.Sort
+	bundle.OnSale = input.OnSale
+}
+
+func (s *BundleService) saveCourses(tx *gorm.DB, bundleID uint, courseIDs []uint) error {
+	links := make([]model.BundleCourse, 0, len(courseIDs))
+	for i, courseID := range courseIDs {
+		links = append(links, model.BundleCourse{BundleID: bundleID, CourseID: courseID, Sort: i})
+	}
+	return tx.Create(&links).Error
+}
+
+// CheckPurchasable 检查套餐是否可以购买，返回套餐信息
+func (s *BundleService) CheckPurchasable(userId, bundleId uint) (*model.Bundle, error) {
+	var bundle model.Bundle
+	if err := database.DB.First(&bundle, bundleId).Error; err != nil || !bundle.OnSale {
+		return nil, errors.New("套餐不存在或已下架")
+	}
+	if s.purchased(userId, []uint{bundleId})[bundleId] {
+		return nil, errors.New("已购买该套餐")
+	}
+	return &bundle, nil
+}
+
+// Fulfill 将套餐订单标记为已支付，并为套餐中的每门课程生成子订单，重复调用时不会重复开通
+func (s *BundleService) Fulfill(orderID uint, paymentType string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return errors.New("订单不存在")
+		}
+		if order.Status == "paid" {
+			return nil
+		}
+
+		// 订单创建后套餐可能已下架或删除，已支付的订单仍然开通
+		var bundle model.Bundle
+		if err := tx.Unscoped().First(&bundle, order.BundleID).Error; err != nil {
+			return fmt.Errorf("套餐不存在: %v", err)
+		}
+		var courseIDs []uint
+		if err := tx.Model(&model.BundleCourse{}).Where("bundle_id = ?", bundle.ID).
+			Order("sort, id").Pluck("course_id", &courseIDs).Error; err != nil {
+			return fmt.Errorf("查询套餐课程失败: %v", err)
+		}
+
+		now := time.Now()
+		var expireTime *time.Time
+		if bundle.ExpireDays > 0 {
+			t := now.AddDate(0, 0, bundle.ExpireDays)
+			expireTime = &t
+		}
+
+		if err := tx.Model(&order).Updates(map[string]interface{}{
+			"status":       "paid",
+			"pay_time":     &now,
+			"payment_type": paymentType,
+			"expire_time":  expireTime,
+		}).Error; err != nil {
+			return fmt.Errorf("更新订单状态失败: %v", err)
+		}
+
+		// 每门课程分别叠加到用户该课程当前的到期时间上
+		children := make([]model.Order, 0, len(courseIDs))
+		for _, courseID := range courseIDs {
+			childExpireTime, err := Entitlement.RenewalExpireTime(tx, order.UserID, courseID, bundle.ExpireDays)
+			if err != nil {
+				return err
+			}
+			children = append(children, model.Order{
+				OrderNo:       fmt.Sprintf("%s-%d", order.OrderNo, courseID),
+				UserID:        order.UserID,
+				CourseID:      courseID,
+				BundleID:      bundle.ID,
+				ParentOrderID: order.ID,
+				Amount:        0,
+				Status:        "paid",
+				PaymentType:   PaymentTypeBundle,
+				PayTime:       &now,
+				ExpireTime:    childExpireTime,
+			})
+		}
+		if len(children) == 0 {
+			return nil
+		}
+		if err := tx.Create(&children).Error; err != nil {
+			return fmt.Errorf("开通套餐课程失败: %v", err)
+		}
+		if err := Entitlement.SyncOrder(tx, order.ID); err != nil {
+			return fmt.Errorf("开通课程权限失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// SyncChildren 套餐订单的状态或有效期变化（如退款）时同步更新其子订单，子订单不再是已支付状态后课程权限随之失效
+// 子订单的有效期在开通时按各课程已有的有效期分别续期，修改套餐有效期时各子订单按相同的时长调整，不直接覆盖
+// previousExpire 为修改前套餐订单的有效期
+func (s *BundleService) SyncChildren(order *model.Order, previousExpire *time.Time, updates map[string]interface{}) error {
+	if order.BundleID == 0 || order.ParentOrderID != 0 {
+		return nil
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if status, ok := updates["status"]; ok {
+			if err := tx.Model(&model.Order{}).Where("parent_order_id = ?", order.ID).
+				Update("status", status).Error; err != nil {
+				return err
+			}
+		}
+
+		expireTime, ok := updates["expire_time"].(time.Time)
+		if !ok {
+			return nil
+		}
+		var children []model.Order
+		if err := tx.Where("parent_order_id = ?", order.ID).Find(&children).Error; err != nil {
+			return err
+		}
+		for _, child := range children {
+			childExpire := expireTime
+			// 原套餐为永久有效时子订单没有可参照的续期时长，直接使用新的有效期
+			if previousExpire != nil && child.ExpireTime != nil {
+				childExpire = child.ExpireTime.Add(expireTime.Sub(*previousExpire))
+			}
+			if err := tx.Model(&model.Order{}).Where("id = ?", child.ID).
+				Update("expire_time", childExpire).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
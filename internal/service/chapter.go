@@ -0,0 +1,408 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var Chapter = new(ChapterService)
+
+// ChapterService 课程章节管理，课程下分章、章下分小节，题目归属到小节
+type ChapterService struct{}
+
+// ChapterInput 创建或修改章、小节的参数
+type ChapterInput struct {
+	Name string
+	Sort int
+}
+
+// ChapterFilter 按章或小节筛选题目，都为0时不筛选，同时指定时以小节为准
+type ChapterFilter struct {
+	ChapterID uint
+	SectionID uint
+}
+
+// IsEmpty 是否未指定章节
+func (f ChapterFilter) IsEmpty() bool {
+	return f.ChapterID == 0 && f.SectionID == 0
+}
+
+// Tree 获取课程的章节树，章和小节按排序值、ID排列，包含各章节的题目数
+func (s *ChapterService) Tree(courseID uint) ([]ChapterDetail, error) {
+	var chapters []model.CourseChapter
+	if err := database.DB.Where("course_id = ?", courseID).Order("sort, id").Find(&chapters).Error; err != nil {
+		return nil, err
+	}
+	var sections []model.CourseSection
+	if err := database.DB.Where("course_id = ?", courseID).Order("sort, id").Find(&sections).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		SectionID uint
+		Count     int
+	}
+	if err := database.DB.Model(&model.Question{}).
+		Select("section_id, COUNT(*) AS count").
+		Where("course_id = ? AND section_id > 0", courseID).
+		Group("section_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	countMap := make(map[uint]int, len(counts))
+	for _, c := range counts {
+		countMap[c.SectionID] = c.Count
+	}
+
+	bySection := make(map[uint][]SectionDetail)
+	for _, section := range sections {
+		bySection[section.ChapterID] = append(bySection[section.ChapterID], SectionDetail{
+			ID:            section.ID,
+			Name:          section.Name,
+			Sort:          section.Sort,
+			QuestionCount: countMap[section.ID],
+		})
+	}
+
+	result := make([]ChapterDetail, 0, len(chapters))
+	for _, chapter := range chapters {
+		detail := ChapterDetail{
+			ID:       chapter.ID,
+			Name:     chapter.Name,
+			Sort:     chapter.Sort,
+			Sections: bySection[chapter.ID],
+		}
+		if detail.Sections == nil {
+			detail.Sections = make([]SectionDetail, 0)
+		}
+		for _, section := range detail.Sections {
+			detail.QuestionCount += section.QuestionCount
+		}
+		result = append(result, detail)
+	}
+	return result, nil
+}
+
+// CreateChapter 在课程下创建章
+func (s *ChapterService) CreateChapter(courseID uint, input ChapterInput) (*model.CourseChapter, error) {
+	chapter := &model.CourseChapter{
+		CourseID: courseID,
+		Name:     strings.TrimSpace(input.Name),
+		Sort:     input.Sort,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&model.Course{}, courseID).Error; err != nil {
+			return errors.New("课程不存在")
+		}
+		if err := s.checkChapterName(tx, 0, courseID, chapter.Name); err != nil {
+			return err
+		}
+		return tx.Create(chapter).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// UpdateChapter 修改章的名称和排序
+func (s *ChapterService) UpdateChapter(id uint, input ChapterInput) (*model.CourseChapter, error) {
+	var chapter model.CourseChapter
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&chapter, id).Error; err != nil {
+			return errors.New("章不存在")
+		}
+		name := strings.TrimSpace(input.Name)
+		if err := s.checkChapterName(tx, id, chapter.CourseID, name); err != nil {
+			return err
+		}
+		chapter.Name = name
+		chapter.Sort = input.Sort
+		return tx.Model(&chapter).Updates(map[string]interface{}{"name": chapter.Name, "sort": chapter.Sort}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &chapter, nil
+}
+
+// DeleteChapter 删除章及其下的小节，原属于这些小节的题目变为未分配章节
+func (s *ChapterService) DeleteChapter(id uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var chapter model.CourseChapter
+		if err := tx.First(&chapter, id).Error; err != nil {
+			return errors.New("章不存在")
+		}
+		if err := tx.Model(&model.Question{}).
+			Where("section_id IN (?)", tx.Model(&model.CourseSection{}).Select("id").Where("chapter_id = ?", id)).
+			UpdateColumn("section_id", 0).Error; err != nil {
+			return fmt.Errorf("删除章失败: %v", err)
+		}
+		if err := tx.Where("chapter_id = ?", id).Delete(&model.CourseSection{}).Error; err != nil {
+			return fmt.Errorf("删除章失败: %v", err)
+		}
+		return tx.Delete(&chapter).Error
+	})
+}
+
+// CreateSection 在章下创建小节
+func (s *ChapterService) CreateSection(chapterID uint, input ChapterInput) (*model.CourseSection, error) {
+	section := &model.CourseSection{
+		ChapterID: chapterID,
+		Name:      strings.TrimSpace(input.Name),
+		Sort:      input.Sort,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var chapter model.CourseChapter
+		if err := tx.First(&chapter, chapterID).Error; err != nil {
+			return errors.New("章不存在")
+		}
+		if err := s.checkSectionName(tx, 0, chapterID, section.Name); err != nil {
+			return err
+		}
+		section.CourseID = chapter.CourseID
+		return tx.Create(section).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return section, nil
+}
+
+// UpdateSection 修改小节的名称和排序，chapterID 不为0时移动到同一课程的其他章下
+func (s *ChapterService) UpdateSection(id, chapterID uint, input ChapterInput) (*model.CourseSection, error) {
+	var section model.CourseSection
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&section, id).Error; err != nil {
+			return errors.New("小节不存在")
+		}
+		if chapterID > 0 && chapterID != section.ChapterID {
+			var chapter model.CourseChapter
+			if err := tx.Where("id = ? AND course_id = ?", chapterID, section.CourseID).First(&chapter).Error; err != nil {
+				return errors.New("章不存在")
+			}
+			section.ChapterID = chapterID
+		}
+		name := strings.TrimSpace(input.Name)
+		if err := s.checkSectionName(tx, id, section.ChapterID, name); err != nil {
+			return err
+		}
+		section.Name = name
+		section.Sort = input.Sort
+		return tx.Model(&section).Updates(map[string]interface{}{
+			"chapter_id": section.ChapterID,
+			"name":       section.Name,
+			"sort":       section.Sort,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &section, nil
+}
+
+// DeleteSection 删除小节，原属于该小节的题目变为未分配章节
+func (s *ChapterService) DeleteSection(id uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var section model.CourseSection
+		if err := tx.First(&section, id).Error; err != nil {
+			return errors.New("小节不存在")
+		}
+		if err := tx.Model(&model.Question{}).Where("section_id = ?", id).
+			UpdateColumn("section_id", 0).Error; err != nil {
+			return fmt.Errorf("删除小节失败: %v", err)
+		}
+		return tx.Delete(&section).Error
+	})
+}
+
+// checkChapterName 校验章名称不为空且在课程内不重复，名称中不能包含 /（导入时用于分隔章和小节）
+func (s *ChapterService) checkChapterName(tx *gorm.DB, id, courseID uint, name string) error {
+	if name == "" {
+		return errors.New("章名称不能为空")
+	}
+	if strings.Contains(name, "/") {
+		return errors.New("章名称不能包含 /")
+	}
+	var count int64
+	if err := tx.Model(&model.CourseChapter{}).
+		Where("course_id = ? AND name = ? AND id <> ?", courseID, name, id).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("章名称重复: %s", name)
+	}
+	return nil
+}
+
+// checkSectionName 校验小节名称不为空且在章内不重复
+func (s *ChapterService) checkSectionName(tx *gorm.DB, id, chapterID uint, name string) error {
+	if name == "" {
+		return errors.New("小节名称不能为空")
+	}
+	var count int64
+	if err := tx.Model(&model.CourseSection{}).
+		Where("chapter_id = ? AND name = ? AND id <> ?", chapterID, name, id).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("小节名称重复: %s", name)
+	}
+	return nil
+}
+
+// CheckSection 校验小节属于指定课程，sectionID 为0表示不分配章节
+func (s *ChapterService) CheckSection(courseID, sectionID uint) error {
+	if sectionID == 0 {
+		return nil
+	}
+	var count int64
+	if err := database.DB.Model(&model.CourseSection{}).
+		Where("id = ? AND course_id = ?", sectionID, courseID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("小节不存在或不属于该课程")
+	}
+	return nil
+}
+
+// CheckExamConfig 校验考试配置中指定的章属于该课程
+func (s *ChapterService) CheckExamConfig(courseID uint, items []model.ExamConfigItem) error {
+	for _, item := range items {
+		if item.ChapterID == 0 {
+			continue
+		}
+		var count int64
+		if err := database.DB.Model(&model.CourseChapter{}).
+			Where("id = ? AND course_id = ?", item.ChapterID, courseID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("考试配置中的章 %d 不存在或不属于该课程", item.ChapterID)
+		}
+	}
+	return nil
+}
+
+// chapterSections 章下小节ID的子查询
+func chapterSections(chapterID uint) *gorm.DB {
+	return database.DB.Model(&model.CourseSection{}).Select("id").Where("chapter_id = ?", chapterID)
+}
+
+// Apply 将章节筛选条件应用到题目查询，courseID 不为0时校验章节属于该课程
+func (s *ChapterService) Apply(db *gorm.DB, courseID uint, filter ChapterFilter) (*gorm.DB, error) {
+	if filter.SectionID > 0 {
+		query := database.DB.Model(&model.CourseSection{}).Where("id = ?", filter.SectionID)
+		if courseID > 0 {
+			query = query.Where("course_id = ?", courseID)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, errors.New("小节不存在")
+		}
+		return db.Where("section_id = ?", filter.SectionID), nil
+	}
+
+	if filter.ChapterID > 0 {
+		query := database.DB.Model(&model.CourseChapter{}).Where("id = ?", filter.ChapterID)
+		if courseID > 0 {
+			query = query.Where("course_id = ?", courseID)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, errors.New("章不存在")
+		}
+		return db.Where("section_id IN (?)", chapterSections(filter.ChapterID)), nil
+	}
+
+	return db, nil
+}
+
+// sectionPaths 获取全部小节的 "章名称/小节名称"，用于导出
+func (s *ChapterService) sectionPaths() (map[uint]string, error) {
+	var chapters []model.CourseChapter
+	if err := database.DB.Select("id, name").Find(&chapters).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(chapters))
+	for _, chapter := range chapters {
+		names[chapter.ID] = chapter.Name
+	}
+
+	var sections []model.CourseSection
+	if err := database.DB.Select("id, chapter_id, name").Find(&sections).Error; err != nil {
+		return nil, err
+	}
+	paths := make(map[uint]string, len(sections))
+	for _, section := range sections {
+		paths[section.ID] = names[section.ChapterID] + "/" + section.Name
+	}
+	return paths, nil
+}
+
+// sectionResolver 导入时按 "章名称/小节名称" 查找课程下的小节，不存在时创建
+type sectionResolver struct {
+	tx    *gorm.DB
+	cache map[string]uint
+}
+
+func newSectionResolver(tx *gorm.DB) *sectionResolver {
+	return &sectionResolver{tx: tx, cache: make(map[string]uint)}
+}
+
+// resolve 返回小节ID，path 为空时返回0
+func (r *sectionResolver) resolve(courseID uint, path string) (uint, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return 0, nil
+	}
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+		return 0, errors.New("章节格式应为 章名称/小节名称")
+	}
+	chapterName, sectionName := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+	key := fmt.Sprintf("%d/%s/%s", courseID, chapterName, sectionName)
+	if id, ok := r.cache[key]; ok {
+		return id, nil
+	}
+
+	var chapter model.CourseChapter
+	err := r.tx.Where("course_id = ? AND name = ?", courseID, chapterName).First(&chapter).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		chapter = model.CourseChapter{CourseID: courseID, Name: chapterName}
+		err = r.tx.Create(&chapter).Error
+	}
+	if err != nil {
+		return 0, fmt.Errorf("创建章失败: %v", err)
+	}
+
+	var section model.CourseSection
+	err = r.tx.Where("chapter_id = ? AND name = ?", chapter.ID, sectionName).First(&section).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		section = model.CourseSection{CourseID: courseID, ChapterID: chapter.ID, Name: sectionName}
+		err = r.tx.Create(&section).Error
+	}
+	if err != nil {
+		return 0, fmt.Errorf("创建小节失败: %v", err)
+	}
+
+	r.cache[key] = section.ID
+	return section.ID, nil
+}
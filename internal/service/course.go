@@ -5,9 +5,7 @@ import (
 	"errors"
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
-	"fmt"
-	"sort"
-	"strings"
+	"exam-system/internal/pkg/logger"
 	"time"
 
 	"gorm.io/gorm"
@@ -17,26 +15,37 @@ var Course = new(CourseService)
 
 type CourseService struct{}
 
-// 分类树节点
+// 分类树节点类型
+const (
+	CategoryNodeCategory = "category" // 分类
+	CategoryNodeCourse   = "course"   // 课程
+)
+
+// 分类树节点，分类节点的 ID 为分类ID，课程节点的 ID 为课程ID
 type CategoryNode struct {
 	ID       uint           `json:"id"`
+	Type     string         `json:"type"` // 节点类型：category 或 course
 	Name     string         `json:"name"`
+	Icon     string         `json:"icon,omitempty"` // 分类图标
 	Level    int            `json:"level"`
 	Sort     int            `json:"sort"`
-	Price    float64        `json:"price,omitempty"` // 只在三级分类中显示价格
+	Price    float64        `json:"price,omitempty"` // 只在课程节点中显示价格
 	Children []CategoryNode `json:"children,omitempty"`
-	Courses  []CourseInfo   `json:"courses,omitempty"` // 只在三级分类中包含课程
+	Courses  []CourseInfo   `json:"courses,omitempty"` // 只在课程节点中包含课程
 }
 
 // 课程信息
 type CourseInfo struct {
-	ID          uint    `json:"id"`
-	Name        string  `json:"name"`
-	Cover       string  `json:"cover"`
-	Price       float64 `json:"price"`
-	Description string  `json:"description"`
-	Purchased   bool    `json:"purchased"`   // 是否已购买
-	ExpireDays  int     `json:"expire_days"` // 剩余有效期（天）
+	ID          uint               `json:"id"`
+	Name        string             `json:"name"`
+	Cover       string             `json:"cover"`
+	Price       float64            `json:"price"`
+	Description string             `json:"description"`
+	Purchased   bool               `json:"purchased"`         // 是否已购买
+	ExpireDays  int                `json:"expire_days"`       // 剩余有效期（天）
+	ExpireTime  *time.Time         `json:"expire_time"`       // 到期时间，多次购买时为续费叠加后的时间，永久有效时为空
+	Plans       []model.CoursePlan `json:"plans,omitempty"`   // 购买方案，未设置时按 price 和课程有效期购买
+	Bundles     []BundleBrief      `json:"bundles,omitempty"` // 包含该课程的在售套餐
 }
 
 // 课程列表项
@@ -50,9 +59,10 @@ type CourseListItem struct {
 
 // 考试配置项
 type ExamConfigItem struct {
-	Type  string  `json:"type"`
-	Count int     `json:"count"`
-	Score float64 `json:"score"`
+	Type      string  `json:"type"`
+	Count     int     `json:"count"`
+	Score     float64 `json:"score"`
+	ChapterID uint    `json:"chapter_id,omitempty"` // 只从指定章抽题，0表示从整门课程抽题
 }
 
 // 模拟考试全局配置
@@ -64,201 +74,186 @@ type MockExamConfig struct {
 
 // 课程详情
 type CourseDetail struct {
-	ID             uint             `json:"id"`
-	Name           string           `json:"name"` // 修改为 Name + "-" + CategoryLevel2
-	Cover          string           `json:"cover"`
-	Price          float64          `json:"price"`
-	Description    string           `json:"description"`
-	QuestionStats  map[string]int   `json:"question_stats"`   // 各题型的数量
-	ExamConfig     []ExamConfigItem `json:"exam_config"`      // 模拟考试配置
-	MockExamConfig *MockExamConfig  `json:"mock_exam_config"` // 模拟考试全局配置
+	ID             uint               `json:"id"`
+	Name           string             `json:"name"` // 所属分类名称 + "-" + Name
+	Cover          string             `json:"cover"`
+	Price          float64            `json:"price"`
+	Description    string             `json:"description"`
+	QuestionStats  map[string]int     `json:"question_stats"`   // 各题型的数量
+	ExamConfig     []ExamConfigItem   `json:"exam_config"`      // 模拟考试配置
+	MockExamConfig *MockExamConfig    `json:"mock_exam_config"` // 模拟考试全局配置
+	Plans          []model.CoursePlan `json:"plans"`            // 购买方案，为空时按 price 购买
+	Bundles        []BundleBrief      `json:"bundles"`          // 包含该课程的在售套餐
+	Chapters       []ChapterDetail    `json:"chapters"`         // 章节目录
+	Purchased      bool               `json:"purchased"`        // 是否已购买
+	ExpireDays     int                `json:"expire_days"`      // 剩余有效期（天）
+	ExpireTime     *time.Time         `json:"expire_time"`      // 到期时间，多次购买时为续费叠加后的时间，永久有效时为空
+	TrialQuestions int                `json:"trial_questions"`  // 未购买时可以免费练习的试用题目数
 }
 
 // 章节详情
 type ChapterDetail struct {
-	ID       uint            `json:"id"`
-	Name     string          `json:"name"`
-	Sections []SectionDetail `json:"sections"`
+	ID            uint            `json:"id"`
+	Name          string          `json:"name"`
+	Sort          int             `json:"sort"`
+	QuestionCount int             `json:"question_count"` // 章下各小节的题目总数
+	Sections      []SectionDetail `json:"sections"`
 }
 
 // 小节详情
 type SectionDetail struct {
-	ID   uint   `json:"id"`
-	Name string `json:"name"`
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Sort          int    `json:"sort"`
+	QuestionCount int    `json:"question_count"`
 }
 
 // 模拟考试题目响应结构体（包含答案、解释和分数）
 type ExamQuestionResponse struct {
-	ID          uint                   `json:"id"`
-	Type        string                 `json:"type"`
-	Question    string                 `json:"question"`
-	Options     []model.QuestionOption `json:"options"`
-	Answer      string                 `json:"answer"`
-	Explanation string                 `json:"explanation"`
-	Score       float64                `json:"score"` // 添加分数字段
-	CourseID    uint                   `json:"course_id"`
+	ID               uint                   `json:"id"`
+	Type             string                 `json:"type"`
+	Question         string                 `json:"question"`
+	StemMedia        model.MediaRefs        `json:"stem_media"`
+	Options          []model.QuestionOption `json:"options"`
+	Answer           string                 `json:"answer"`
+	Explanation      string                 `json:"explanation"`
+	ExplanationMedia model.MediaRefs        `json:"explanation_media"`
+	AIExplanation    *AIExplanationView     `json:"ai_explanation,omitempty"` // 没有正式解析时返回待审核的AI解析
+	Score            float64                `json:"score"`                    // 添加分数字段
+	CourseID         uint                   `json:"course_id"`
 }
 
-// 获取课程分类树
+// 获取课程分类树，只包含有课程的分类，未分类的课程归入"未分类"节点
 func (s *CourseService) GetCategoryTree(userId uint) ([]CategoryNode, error) {
-	// 从课程表中获取所有课程，按sort字段排序
-	var courses []model.Course
-	if err := database.DB.Order("sort").Find(&courses).Error; err != nil {
+	tree, err := s.loadCategoryTree(userId)
+	if err != nil {
 		return nil, err
 	}
 
-	// 预处理分类排序值
-	level1Sorts := make(map[string]int)
-	level2Sorts := make(map[string]map[string]int)
-
-	for _, course := range courses {
-		// 处理一级分类排序
-		if _, exists := level1Sorts[course.CategoryLevel1]; !exists {
-			level1Sorts[course.CategoryLevel1] = course.CategorySort1
-		}
-
-		// 处理二级分类排序
-		if _, exists := level2Sorts[course.CategoryLevel1]; !exists {
-			level2Sorts[course.CategoryLevel1] = make(map[string]int)
-		}
-		if _, exists := level2Sorts[course.CategoryLevel1][course.CategoryLevel2]; !exists {
-			level2Sorts[course.CategoryLevel1][course.CategoryLevel2] = course.CategorySort2
+	result := make([]CategoryNode, 0)
+	for _, category := range tree.children[0] {
+		if node, count := tree.build(category, 1); count > 0 {
+			result = append(result, node)
 		}
 	}
+	if len(tree.uncategorized) > 0 {
+		result = append(result, CategoryNode{
+			ID:       0,
+			Type:     CategoryNodeCategory,
+			Name:     uncategorizedName,
+			Level:    1,
+			Children: tree.courseNodes(tree.uncategorized, 2),
+		})
+	}
+	return result, nil
+}
 
-	// 构建分类映射
-	level1Map := make(map[string]*CategoryNode)            // 一级分类映射
-	level2Map := make(map[string]map[string]*CategoryNode) // 二级分类映射
+// 获取分类详情，包括子分类和课程
+func (s *CourseService) GetCategoryDetail(userId, categoryId uint) (*CategoryNode, error) {
+	var category model.CourseCategory
+	if err := database.DB.First(&category, categoryId).Error; err != nil {
+		return nil, errors.New("分类不存在")
+	}
 
-	// 遍历所有课程，构建分类树
-	for _, course := range courses {
-		// 处理一级分类
-		if _, exists := level1Map[course.CategoryLevel1]; !exists {
-			level1Map[course.CategoryLevel1] = &CategoryNode{
-				ID:       0, // 这里没有ID，可以用索引代替
-				Name:     course.CategoryLevel1,
-				Level:    1,
-				Sort:     level1Sorts[course.CategoryLevel1], // 使用预处理的排序值
-				Children: make([]CategoryNode, 0),
-			}
-		}
+	tree, err := s.loadCategoryTree(userId)
+	if err != nil {
+		return nil, err
+	}
+	node, _ := tree.build(category, category.Level)
+	return &node, nil
+}
 
-		// 处理二级分类
-		if _, exists := level2Map[course.CategoryLevel1]; !exists {
-			level2Map[course.CategoryLevel1] = make(map[string]*CategoryNode)
-		}
+// categoryTree 构建学生端分类树所需的数据
+type categoryTree struct {
+	children      map[uint][]model.CourseCategory // 父分类ID -> 子分类
+	courses       map[uint][]model.Course         // 分类ID -> 直接属于该分类的课程
+	uncategorized []model.Course                  // 未分类或所属分类已删除的课程
+	purchases     map[uint]coursePurchase
+	plans         map[uint][]model.CoursePlan // 课程ID -> 购买方案
+	bundles       map[uint][]BundleBrief      // 课程ID -> 包含该课程的在售套餐
+}
 
-		if _, exists := level2Map[course.CategoryLevel1][course.CategoryLevel2]; !exists {
-			level2Node := &CategoryNode{
-				ID:       0, // 这里没有ID，可以用索引代替
-				Name:     course.CategoryLevel2,
-				Level:    2,
-				Sort:     level2Sorts[course.CategoryLevel1][course.CategoryLevel2], // 使用预处理的排序值
-				Children: make([]CategoryNode, 0),
-			}
-			level2Map[course.CategoryLevel1][course.CategoryLevel2] = level2Node
-		}
+// loadCategoryTree 查询全部分类、用户可见的课程和购买状态
+func (s *CourseService) loadCategoryTree(userId uint) (*categoryTree, error) {
+	categories, err := CourseCategory.all()
+	if err != nil {
+		return nil, err
 	}
+	var candidates []model.Course
+	if err := s.visibleQuery().Order("sort, name").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	candidateIDs := make([]uint, 0, len(candidates))
+	for _, course := range candidates {
+		candidateIDs = append(candidateIDs, course.ID)
+	}
+	purchases := coursePurchases(userId, candidateIDs)
 
-	// 将二级分类添加到一级分类的子节点中
-	for level1Name, level1Node := range level1Map {
-		for _, level2Node := range level2Map[level1Name] {
-			level1Node.Children = append(level1Node.Children, *level2Node)
+	// 已归档的课程只对已购买的用户显示
+	courses := make([]model.Course, 0, len(candidates))
+	for _, course := range candidates {
+		if visibleTo(&course, purchases[course.ID]) {
+			courses = append(courses, course)
 		}
 	}
 
-	// 将课程添加到二级分类的子节点中
-	// 课程ID收集，用于后续批量查询订单
-	courseIDs := make([]uint, 0, len(courses))
-	courseMap := make(map[uint]model.Course)
-	for _, c := range courses {
-		courseIDs = append(courseIDs, c.ID)
-		courseMap[c.ID] = c
+	exists := make(map[uint]bool, len(categories))
+	for _, category := range categories {
+		exists[category.ID] = true
 	}
 
-	// 批量查询所有课程的订单状态
-	purchaseStatusMap := make(map[uint]struct {
-		Purchased  bool
-		ExpireDays int
-	})
-
-	// 默认所有课程未购买
-	for _, cid := range courseIDs {
-		purchaseStatusMap[cid] = struct {
-			Purchased  bool
-			ExpireDays int
-		}{
-			Purchased:  false,
-			ExpireDays: 0,
-		}
+	tree := &categoryTree{
+		children: childrenOf(categories),
+		courses:  make(map[uint][]model.Course),
 	}
-
-	// 只有登录用户才查询订单
-	if userId > 0 {
-		var orders []model.Order
-		// 一次性查询该用户所有已支付的课程订单
-		if err := database.DB.Where("user_id = ? AND course_id IN (?) AND status = ?",
-			userId, courseIDs, "paid").
-			Order("course_id, expire_time DESC").
-			Find(&orders).Error; err != nil {
-			// 查询出错，记录日志但继续处理
-			fmt.Printf("查询用户订单失败: %v\n", err)
+	courseIDs := make([]uint, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.ID)
+		if exists[course.CategoryID] {
+			tree.courses[course.CategoryID] = append(tree.courses[course.CategoryID], course)
 		} else {
-			// 处理订单数据，为每个课程找到最新的有效订单
-			courseOrderMap := make(map[uint]model.Order)
-			for _, order := range orders {
-				// 如果该课程还没有记录订单，或者当前订单比已记录的更新
-				if _, exists := courseOrderMap[order.CourseID]; !exists {
-					courseOrderMap[order.CourseID] = order
-				}
-			}
+			tree.uncategorized = append(tree.uncategorized, course)
+		}
+	}
+	tree.purchases = purchases
+	tree.plans = CoursePlan.ForCourses(courseIDs)
+	tree.bundles = Bundle.ForCourses(courseIDs)
+	return tree, nil
+}
 
-			// 计算每个课程的购买状态和剩余天数
-			for courseID, order := range courseOrderMap {
-				status := struct {
-					Purchased  bool
-					ExpireDays int
-				}{
-					Purchased:  false,
-					ExpireDays: 0,
-				}
-
-				// 如果订单未过期，标记为已购买
-				if order.ExpireTime != nil && order.ExpireTime.After(time.Now()) {
-					status.Purchased = true
-					// 计算剩余天数（向上取整）
-					duration := order.ExpireTime.Sub(time.Now())
-					durationDays := duration.Hours() / 24
-
-					// 向上取整：如果有小数部分，就加1天
-					status.ExpireDays = int(durationDays)
-					if durationDays > float64(status.ExpireDays) {
-						status.ExpireDays++
-					}
-
-					if status.ExpireDays < 0 {
-						status.ExpireDays = 0
-					}
-				} else if order.ExpireTime == nil {
-					// 如果订单没有过期时间，视为永久有效
-					status.Purchased = true
-					status.ExpireDays = 999999 // 表示永久有效
-				}
-
-				purchaseStatusMap[courseID] = status
-			}
+// build 构建分类节点，子节点为有课程的子分类和直接属于该分类的课程，返回节点及其下的课程总数
+func (t *categoryTree) build(category model.CourseCategory, level int) (CategoryNode, int) {
+	node := CategoryNode{
+		ID:       category.ID,
+		Type:     CategoryNodeCategory,
+		Name:     category.Name,
+		Icon:     category.Icon,
+		Level:    level,
+		Sort:     category.Sort,
+		Children: make([]CategoryNode, 0),
+	}
+
+	count := len(t.courses[category.ID])
+	for _, child := range t.children[category.ID] {
+		if childNode, childCount := t.build(child, level+1); childCount > 0 {
+			node.Children = append(node.Children, childNode)
+			count += childCount
 		}
 	}
+	node.Children = append(node.Children, t.courseNodes(t.courses[category.ID], level+1)...)
+	return node, count
+}
 
-	// 使用已查询的状态信息
+// courseNodes 构建课程节点，课程已按排序值、名称排列
+func (t *categoryTree) courseNodes(courses []model.Course, level int) []CategoryNode {
+	nodes := make([]CategoryNode, 0, len(courses))
 	for _, course := range courses {
-		status := purchaseStatusMap[course.ID]
-
-		// 创建课程节点
-		courseNode := CategoryNode{
+		purchase := t.purchases[course.ID]
+		nodes = append(nodes, CategoryNode{
 			ID:    course.ID,
+			Type:  CategoryNodeCourse,
 			Name:  course.Name,
-			Level: 3,
+			Level: level,
 			Sort:  course.Sort,
 			Price: course.Price,
 			Courses: []CourseInfo{
@@ -268,136 +263,63 @@ func (s *CourseService) GetCategoryTree(userId uint) ([]CategoryNode, error) {
 					Cover:       course.Cover,
 					Price:       course.Price,
 					Description: course.Description,
-					Purchased:   status.Purchased,
-					ExpireDays:  status.ExpireDays,
+					Purchased:   purchase.Purchased,
+					ExpireDays:  purchase.ExpireDays,
+					ExpireTime:  purchase.ExpireTime,
+					Plans:       t.plans[course.ID],
+					Bundles:     t.bundles[course.ID],
 				},
 			},
-		}
-
-		// 找到对应的二级分类节点
-		for i := range level1Map[course.CategoryLevel1].Children {
-			if level1Map[course.CategoryLevel1].Children[i].Name == course.CategoryLevel2 {
-				// 添加课程到二级分类的子节点
-				level1Map[course.CategoryLevel1].Children[i].Children = append(
-					level1Map[course.CategoryLevel1].Children[i].Children,
-					courseNode,
-				)
-				break
-			}
-		}
-	}
-
-	// 构建结果数组
-	var result []CategoryNode
-	for _, node := range level1Map {
-		result = append(result, *node)
-	}
-
-	// 按排序字段排序一级分类
-	sort.Slice(result, func(i, j int) bool {
-		if result[i].Sort != result[j].Sort {
-			return result[i].Sort < result[j].Sort
-		}
-		return result[i].Name < result[j].Name // 排序值相同时按名称排序
-	})
-
-	// 对每个一级分类的子节点排序
-	for i := range result {
-		// 对二级分类按排序字段排序
-		sort.Slice(result[i].Children, func(j, k int) bool {
-			if result[i].Children[j].Sort != result[i].Children[k].Sort {
-				return result[i].Children[j].Sort < result[i].Children[k].Sort
-			}
-			return result[i].Children[j].Name < result[i].Children[k].Name // 排序值相同时按名称排序
 		})
-
-		// 对每个二级分类的子节点(课程)按sort字段排序
-		for j := range result[i].Children {
-			sort.Slice(result[i].Children[j].Children, func(k, l int) bool {
-				// 优先按sort排序，sort相同时按名称排序
-				if result[i].Children[j].Children[k].Sort != result[i].Children[j].Children[l].Sort {
-					return result[i].Children[j].Children[k].Sort < result[i].Children[j].Children[l].Sort
-				}
-				return result[i].Children[j].Children[k].Name < result[i].Children[j].Children[l].Name
-			})
-		}
 	}
+	return nodes
+}
 
-	return result, nil
+// coursePurchase 用户对课程的购买状态
+type coursePurchase struct {
+	Purchased  bool
+	ExpireDays int        // 剩余有效期（天），永久有效时为 999999
+	ExpireTime *time.Time // 到期时间，永久有效时为 nil
 }
 
-// 获取分类详情
-func (s *CourseService) GetCategoryDetail(userId, categoryId uint) (*CategoryNode, error) {
-	// 由于没有分类表，我们直接查询课程
-	var course model.Course
-	if err := database.DB.First(&course, categoryId).Error; err != nil {
-		return nil, errors.New("课程不存在")
+// coursePurchases 批量查询用户对课程的购买状态，未登录用户均为未购买
+func coursePurchases(userId uint, courseIDs []uint) map[uint]coursePurchase {
+	result := make(map[uint]coursePurchase, len(courseIDs))
+	if userId == 0 || len(courseIDs) == 0 {
+		return result
 	}
 
-	// 检查课程是否已购买
-	purchased := false
-	expireDays := 0
-
-	// 只有登录用户才查询订单
-	if userId > 0 {
-		var order model.Order
-		// 查询用户最近的已支付订单
-		if err := database.DB.Where("user_id = ? AND course_id = ? AND status = ?",
-			userId, course.ID, "paid").
-			Order("expire_time DESC").
-			First(&order).Error; err == nil {
-
-			// 如果订单未过期，标记为已购买
-			if order.ExpireTime != nil && order.ExpireTime.After(time.Now()) {
-				purchased = true
-				// 计算剩余天数（向上取整）
-				duration := order.ExpireTime.Sub(time.Now())
-				durationDays := duration.Hours() / 24
-
-				// 向上取整：如果有小数部分，就加1天
-				expireDays = int(durationDays)
-				if durationDays > float64(expireDays) {
-					expireDays++
-				}
-
-				if expireDays < 0 {
-					expireDays = 0
-				}
-			} else if order.ExpireTime == nil {
-				// 如果订单没有过期时间，视为永久有效
-				purchased = true
-				expireDays = 999999 // 表示永久有效
-			}
-		}
+	// 一次性查询该用户对这些课程的有效权限
+	access, err := Entitlement.Access(userId, courseIDs)
+	if err != nil {
+		// 查询出错，记录日志但继续处理
+		logger.Errorf("查询用户课程权限失败: %v", err)
+		return result
 	}
 
-	// 构建分类节点
-	node := &CategoryNode{
-		ID:    course.ID,
-		Name:  course.Name,
-		Level: 3, // 课程作为三级分类
-		Price: course.Price,
-		Courses: []CourseInfo{
-			{
-				ID:          course.ID,
-				Name:        course.Name,
-				Cover:       course.Cover,
-				Price:       course.Price,
-				Description: course.Description,
-				Purchased:   purchased,  // 设置是否已购买
-				ExpireDays:  expireDays, // 设置剩余有效期
-			},
-		},
+	for courseID, endAt := range access {
+		if endAt == nil {
+			// 如果权限没有到期时间，视为永久有效
+			result[courseID] = coursePurchase{Purchased: true, ExpireDays: 999999}
+			continue
+		}
+		// 计算剩余天数（向上取整）
+		durationDays := time.Until(*endAt).Hours() / 24
+		expireDays := int(durationDays)
+		if durationDays > float64(expireDays) {
+			expireDays++
+		}
+		result[courseID] = coursePurchase{Purchased: true, ExpireDays: expireDays, ExpireTime: endAt}
 	}
-
-	return node, nil
+	return result
 }
 
 func (s *CourseService) GetList(page, size int, courseType string) ([]CourseListItem, int64, error) {
 	var courses []model.Course
 	var total int64
 
-	query := database.DB.Model(&model.Course{})
+	// 课程列表只包含已发布的课程
+	query := database.DB.Model(&model.Course{}).Where("status = ?", model.CourseStatusPublished)
 	if courseType != "" {
 		query = query.Where("type = ?", courseType)
 	}
@@ -426,19 +348,21 @@ func (s *CourseService) GetList(page, size int, courseType string) ([]CourseList
 	return items, total, nil
 }
 
-func (s *CourseService) GetDetail(courseId uint) (*CourseDetail, error) {
+func (s *CourseService) GetDetail(courseId, userId uint) (*CourseDetail, error) {
 	var course model.Course
 	err := database.DB.First(&course, courseId).Error
 	if err != nil {
 		return nil, errors.New("课程不存在")
 	}
-
-	// 1. 拼接课程名称: Name + "-" + CategoryLevel2
-	courseName := course.Name
-	if course.CategoryLevel2 != "" {
-		courseName = course.CategoryLevel2 + "-" + course.Name
+	// 草稿不可见，已归档的课程只对已购买的用户可见
+	purchase := coursePurchases(userId, []uint{course.ID})[course.ID]
+	if !visibleTo(&course, purchase) {
+		return nil, errors.New("课程不存在")
 	}
 
+	// 1. 拼接课程名称: 分类名称 + "-" + Name
+	courseName := CourseCategory.CourseDisplayName(&course)
+
 	// 2. 统计各题型的数量
 	type QuestionCount struct {
 		Type  string
@@ -476,9 +400,10 @@ func (s *CourseService) GetDetail(courseId uint) (*CourseDetail, error) {
 		// 将 model.ExamConfigItem 转换为 service.ExamConfigItem
 		for _, item := range courseExamConfig {
 			examConfig = append(examConfig, ExamConfigItem{
-				Type:  item.Type,
-				Count: item.Count,
-				Score: float64(item.Score),
+				Type:      item.Type,
+				Count:     item.Count,
+				Score:     float64(item.Score),
+				ChapterID: item.ChapterID,
 			})
 		}
 	}
@@ -510,6 +435,20 @@ func (s *CourseService) GetDetail(courseId uint) (*CourseDetail, error) {
 		QuestionStats:  questionStats,
 		ExamConfig:     examConfig,
 		MockExamConfig: mockExamConfig,
+		Bundles:        Bundle.ForCourses([]uint{course.ID})[course.ID],
+		Purchased:      purchase.Purchased,
+		ExpireDays:     purchase.ExpireDays,
+		ExpireTime:     purchase.ExpireTime,
+		TrialQuestions: Trial.Count(&course),
+	}
+	if detail.Plans, err = CoursePlan.List(course.ID); err != nil {
+		return nil, err
+	}
+	if detail.Chapters, err = Chapter.Tree(course.ID); err != nil {
+		return nil, err
+	}
+	if detail.Bundles == nil {
+		detail.Bundles = make([]BundleBrief, 0)
 	}
 
 	return detail, nil
@@ -517,21 +456,14 @@ func (s *CourseService) GetDetail(courseId uint) (*CourseDetail, error) {
 
 // 随机生成模拟考试题目
 func (s *CourseService) GenerateExamQuestions(courseId, userId uint) ([]ExamQuestionResponse, int, float64, float64, error) {
-	// 1. 检查用户是否购买了该课程且未过期
-	var order model.Order
-	err := database.DB.Where("user_id = ? AND course_id = ? AND status = ?",
-		userId, courseId, "paid").
-		Where("expire_time IS NULL OR expire_time > ?", time.Now()).
-		Order("expire_time DESC").
-		First(&order).Error
-
-	if err != nil {
-		return nil, 0, 0, 0, errors.New("您尚未购买该课程或课程已过期")
+	// 1. 检查用户是否有该课程的有效权限
+	if err := Entitlement.CheckAccess(userId, courseId); err != nil {
+		return nil, 0, 0, 0, err
 	}
 
 	// 2. 获取课程信息，特别是考试配置
 	var course model.Course
-	err = database.DB.First(&course, courseId).Error
+	err := database.DB.First(&course, courseId).Error
 	if err != nil {
 		return nil, 0, 0, 0, errors.New("课程不存在")
 	}
@@ -550,9 +482,10 @@ func (s *CourseService) GenerateExamQuestions(courseId, userId uint) ([]ExamQues
 		// 将 model.ExamConfigItem 转换为 service.ExamConfigItem
 		for _, item := range courseExamConfig {
 			examConfig = append(examConfig, ExamConfigItem{
-				Type:  item.Type,
-				Count: item.Count,
-				Score: float64(item.Score),
+				Type:      item.Type,
+				Count:     item.Count,
+				Score:     float64(item.Score),
+				ChapterID: item.ChapterID,
 			})
 		}
 	}
@@ -574,111 +507,86 @@ func (s *CourseService) GenerateExamQuestions(courseId, userId uint) ([]ExamQues
 	}
 
 	// 5. 根据配置，从每种题型中随机抽取题目
+	// 先按指定了章的配置抽题，再从整门课程中抽取，已抽到的题目不会重复抽取，结果按配置顺序排列
 	var allQuestions []ExamQuestionResponse
 	var totalScore float64
 
-	for _, config := range examConfig {
-		// 使用临时结构体接收数据，以便手动处理 options 字段
-		type RawQuestion struct {
-			ID          uint   `json:"id"`
-			Type        string `json:"type"`
-			Question    string `json:"question"`
-			Options     string `json:"options"` // options 作为字符串
-			Answer      string `json:"answer"`
-			Explanation string `json:"explanation"`
-			CourseID    uint   `json:"course_id"`
-			CreatedAt   time.Time
-			UpdatedAt   time.Time
-			DeletedAt   gorm.DeletedAt
-		}
-
-		var rawQuestions []RawQuestion
-		// 查询该类型的所有题目
-		err := database.DB.Table("questions").
-			Where("course_id = ? AND type = ?", courseId, config.Type).
-			Where("deleted_at IS NULL").
-			Order("RAND()"). // MySQL特有的随机排序函数
-			Limit(config.Count).
-			Find(&rawQuestions).Error
-
-		if err != nil {
-			continue // 如果查询失败，跳过该题型
-		}
+	// 使用临时结构体接收数据，以便手动处理 options 字段
+	type RawQuestion struct {
+		ID               uint            `json:"id"`
+		Type             string          `json:"type"`
+		Question         string          `json:"question"`
+		StemMedia        model.MediaRefs `json:"stem_media"`
+		Options          string          `json:"options"` // options 作为字符串
+		Answer           string          `json:"answer"`
+		Explanation      string          `json:"explanation"`
+		ExplanationMedia model.MediaRefs `json:"explanation_media"`
+		CourseID         uint            `json:"course_id"`
+		CreatedAt        time.Time
+		UpdatedAt        time.Time
+		DeletedAt        gorm.DeletedAt
+	}
+
+	groups := make([][]ExamQuestionResponse, len(examConfig))
+	var pickedIDs []uint
+	for _, chapterPass := range []bool{true, false} {
+		for i, config := range examConfig {
+			if (config.ChapterID > 0) != chapterPass {
+				continue
+			}
 
-		// 处理每个题目
-		for _, q := range rawQuestions {
-			// 手动解析 options 字段
-			var options []model.QuestionOption
-			if q.Options != "" {
-				// 首先尝试解析为字符串数组，这是数据库中实际存储的格式
-				var textOptions []string
-				if err := json.Unmarshal([]byte(q.Options), &textOptions); err == nil {
-					// 成功解析为字符串数组
-					for _, optText := range textOptions {
-						// 处理选项文本中的标签前缀，如 "A.选项内容"
-						parts := strings.SplitN(optText, ".", 2)
-						if len(parts) == 2 {
-							// 正确分割出标签和文本
-							options = append(options, model.QuestionOption{
-								Label: parts[0],
-								Text:  parts[1],
-							})
-						} else {
-							// 如果没有分隔符，使用整个字符串作为文本
-							options = append(options, model.QuestionOption{
-								Label: "", // 空标签
-								Text:  optText,
-							})
-						}
-					}
-				} else {
-					// 如果解析失败，尝试其他格式
-					// 尝试解析为 QuestionOption 数组
-					if err := json.Unmarshal([]byte(q.Options), &options); err != nil {
-						// 如果仍然失败，使用默认选项
-						for i := 0; i < 4; i++ {
-							label := string(rune('A' + i))
-							options = append(options, model.QuestionOption{
-								Label: label,
-								Text:  "选项" + label,
-							})
-						}
-					}
-				}
-			} else {
-				// 如果选项为空，根据题目类型生成默认选项
-				if q.Type == "judge" {
-					// 判断题默认有两个选项
-					options = append(options, model.QuestionOption{Label: "A", Text: "正确"})
-					options = append(options, model.QuestionOption{Label: "B", Text: "错误"})
-				} else {
-					// 其他类型默认有4个选项
-					for i := 0; i < 4; i++ {
-						label := string(rune('A' + i))
-						options = append(options, model.QuestionOption{
-							Label: label,
-							Text:  "选项" + label,
-						})
-					}
-				}
+			// 查询该类型的题目
+			query := database.DB.Table("questions").
+				Where("course_id = ? AND type = ?", courseId, config.Type).
+				Where("deleted_at IS NULL")
+			if config.ChapterID > 0 {
+				query = query.Where("section_id IN (?)", chapterSections(config.ChapterID))
+			}
+			if len(pickedIDs) > 0 {
+				query = query.Where("id NOT IN ?", pickedIDs)
 			}
 
-			// 处理每个题目时，需要包含答案、解释和分数
-			allQuestions = append(allQuestions, ExamQuestionResponse{
-				ID:          q.ID,
-				Type:        q.Type,
-				Question:    q.Question,
-				Options:     options,
-				Answer:      q.Answer,
-				Explanation: q.Explanation,
-				Score:       config.Score, // 设置题目分数
-				CourseID:    q.CourseID,
-			})
+			var rawQuestions []RawQuestion
+			// MySQL特有的随机排序函数
+			err := query.Order("RAND()").Limit(config.Count).Find(&rawQuestions).Error
+			if err != nil {
+				continue // 如果查询失败，跳过该配置项
+			}
 
-			// 累加分数
-			totalScore += config.Score
+			// 处理每个题目
+			for _, q := range rawQuestions {
+				// 解析选项，无法解析的题目由 normalize-options 命令报告
+				options, _ := model.DecodeQuestionOptions(q.Type, q.Options)
+
+				// 处理每个题目时，需要包含答案、解释和分数
+				groups[i] = append(groups[i], ExamQuestionResponse{
+					ID:               q.ID,
+					Type:             q.Type,
+					Question:         q.Question,
+					StemMedia:        q.StemMedia,
+					Options:          options,
+					Answer:           q.Answer,
+					Explanation:      q.Explanation,
+					ExplanationMedia: q.ExplanationMedia,
+					Score:            config.Score, // 设置题目分数
+					CourseID:         q.CourseID,
+				})
+				pickedIDs = append(pickedIDs, q.ID)
+
+				// 累加分数
+				totalScore += config.Score
+			}
 		}
 	}
+	for _, group := range groups {
+		allQuestions = append(allQuestions, group...)
+	}
+
+	attachAIExplanations(len(allQuestions), func(i int) (uint, string) {
+		return allQuestions[i].ID, allQuestions[i].Explanation
+	}, func(i int, view *AIExplanationView) {
+		allQuestions[i].AIExplanation = view
+	})
 
 	// 如果没有从配置中读取到及格分数，则设置为总分的60%
 	if passScore <= 0 {
@@ -689,17 +597,11 @@ func (s *CourseService) GenerateExamQuestions(courseId, userId uint) ([]ExamQues
 }
 
 // 记录模拟考试结果
-func (s *CourseService) SubmitExamAnswers(userId, courseId uint, score float64, wrongAnswers []uint) (*model.ExamRecord, error) {
-	// 检查用户是否购买了该课程且未过期
-	var order model.Order
-	err := database.DB.Where("user_id = ? AND course_id = ? AND status = ?",
-		userId, courseId, "paid").
-		Where("expire_time IS NULL OR expire_time > ?", time.Now()).
-		Order("expire_time DESC").
-		First(&order).Error
-
-	if err != nil {
-		return nil, errors.New("您尚未购买该课程或课程已过期")
+// answers 为题目ID到所选选项的映射（可选），用于题目分析
+func (s *CourseService) SubmitExamAnswers(userId, courseId uint, score float64, wrongAnswers []uint, answers map[uint][]string) (*model.ExamRecord, error) {
+	// 检查用户是否有该课程的有效权限
+	if err := Entitlement.CheckAccess(userId, courseId); err != nil {
+		return nil, err
 	}
 
 	// 将错题ID数组转换为JSON
@@ -723,5 +625,7 @@ func (s *CourseService) SubmitExamAnswers(userId, courseId uint, score float64,
 		return nil, errors.New("保存考试记录失败")
 	}
 
+	ItemAnalysis.RecordExamAnswers(userId, courseId, record.ID, answers)
+
 	return record, nil
 }
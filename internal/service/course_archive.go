@@ -0,0 +1,803 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var CourseArchive = new(CourseArchiveService)
+
+// CourseArchiveService 课程归档包导入导出，用于在不同部署之间迁移整门课程
+// 归档包为zip压缩包，包含课程信息、考试配置、分类路径、购买方案、章节、题目和题目引用的媒体文件
+// 导入时课程、章节、题目和媒体文件重新生成ID，归档包中的引用按映射替换
+type CourseArchiveService struct{}
+
+// courseArchiveVersion 归档包格式版本，只能导入不高于当前版本的归档包
+const courseArchiveVersion = 1
+
+// 归档包内的文件路径，媒体文件沿用题库压缩包的 media/<ID>/<文件名>
+const (
+	courseArchiveManifest  = "course.json"
+	courseArchiveQuestions = "questions.jsonl" // 每行一道题目的JSON
+)
+
+// 课程归档包导入导出任务类型
+const (
+	JobTypeCourseExport = "course_export"
+	JobTypeCourseImport = "course_import"
+)
+
+// 导入时目标分类下已有同名课程的处理方式
+const (
+	CourseConflictError     = "error"     // 导入失败
+	CourseConflictRename    = "rename"    // 以 "名称 (2)" 这样的新名称导入为新课程
+	CourseConflictOverwrite = "overwrite" // 覆盖已有课程的信息、购买方案、章节和题目，课程ID及订单、权限、学习记录保留
+)
+
+// ValidConflict 是否为支持的同名课程处理方式
+func (s *CourseArchiveService) ValidConflict(conflict string) bool {
+	switch conflict {
+	case CourseConflictError, CourseConflictRename, CourseConflictOverwrite:
+		return true
+	}
+	return false
+}
+
+// CourseExportParams 课程归档包导出任务参数
+type CourseExportParams struct {
+	CourseID uint `json:"course_id"`
+}
+
+// CourseImportParams 课程归档包导入任务参数
+type CourseImportParams struct {
+	Size       int64  `json:"size"` // 上传文件大小
+	UploaderID uint   `json:"uploader_id"`
+	CategoryID uint   `json:"category_id"` // 导入的目标分类，0表示按归档包中的分类路径查找，不存在的分类自动创建
+	Conflict   string `json:"conflict"`    // 同名课程的处理方式：error、rename、overwrite，默认 error
+}
+
+// CourseImportResult 课程归档包导入结果，题目的导入数和错误沿用题库导入结果
+type CourseImportResult struct {
+	CourseID     uint   `json:"course_id"`
+	CourseName   string `json:"course_name"`
+	Overwritten  bool   `json:"overwritten"` // 是否覆盖了已有课程
+	PlanCount    int    `json:"plan_count"`
+	ChapterCount int    `json:"chapter_count"`
+	SectionCount int    `json:"section_count"`
+	MediaCount   int    `json:"media_count"`
+	ImportResult
+}
+
+// courseArchive 归档包中 course.json 的内容
+type courseArchive struct {
+	Version    int                     `json:"version"`
+	ExportedAt time.Time               `json:"exported_at"`
+	Course     courseArchiveCourse     `json:"course"`
+	Categories []courseArchiveCategory `json:"categories"` // 课程所属分类的路径，从一级分类开始
+	Plans      []courseArchivePlan     `json:"plans"`
+	Chapters   []courseArchiveChapter  `json:"chapters"`
+	Media      []archiveMediaEntry     `json:"media"`
+}
+
+// courseArchiveCourse 课程信息，发布状态和定时发布时间不导出
+type courseArchiveCourse struct {
+	Name           string                 `json:"name"`
+	Cover          string                 `json:"cover"`
+	CoverMedia     uint                   `json:"cover_media,omitempty"` // 封面为本系统媒体文件时的媒体ID
+	Price          float64                `json:"price"`
+	Description    string                 `json:"description"`
+	ExpireDays     int                    `json:"expire_days"`
+	Sort           int                    `json:"sort"`
+	TrialCount     int                    `json:"trial_count"`
+	ExamConfig     []model.ExamConfigItem `json:"exam_config"` // 章ID为归档包中的章ID
+	MockExamConfig model.MockExamConfig   `json:"mock_exam_config"`
+}
+
+type courseArchiveCategory struct {
+	Name string `json:"name"`
+	Icon string `json:"icon"`
+	Sort int    `json:"sort"`
+}
+
+type courseArchivePlan struct {
+	Name       string  `json:"name"`
+	Price      float64 `json:"price"`
+	ExpireDays int     `json:"expire_days"`
+	Sort       int     `json:"sort"`
+}
+
+type courseArchiveChapter struct {
+	ID       uint                   `json:"id"`
+	Name     string                 `json:"name"`
+	Sort     int                    `json:"sort"`
+	Sections []courseArchiveSection `json:"sections"`
+}
+
+type courseArchiveSection struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Sort int    `json:"sort"`
+}
+
+// courseArchiveQuestion 归档包中的题目，小节ID和媒体ID为归档包中的ID
+type courseArchiveQuestion struct {
+	ID                uint                  `json:"id"`
+	Type              string                `json:"type"`
+	Question          string                `json:"question"`
+	StemMedia         model.MediaRefs       `json:"stem_media,omitempty"`
+	Options           model.QuestionOptions `json:"options"`
+	Answer            string                `json:"answer"`
+	Explanation       string                `json:"explanation"`
+	ExplanationMedia  model.MediaRefs       `json:"explanation_media,omitempty"`
+	ExplanationSource string                `json:"explanation_source,omitempty"`
+	SectionID         uint                  `json:"section_id,omitempty"`
+	IsTrial           bool                  `json:"is_trial,omitempty"`
+}
+
+// Export 导出课程归档包，progress 可为nil
+func (s *CourseArchiveService) Export(ctx context.Context, w io.Writer, courseID uint, progress func(percent int)) error {
+	var course model.Course
+	if err := database.DB.First(&course, courseID).Error; err != nil {
+		return errors.New("课程不存在")
+	}
+
+	archive, err := s.buildArchive(&course)
+	if err != nil {
+		return err
+	}
+
+	// 收集封面、题干、选项、解析中引用的媒体
+	var mediaIDs []uint
+	seen := make(map[uint]bool)
+	collect := func(refs []model.MediaRef) {
+		for _, ref := range refs {
+			if !seen[ref.ID] {
+				seen[ref.ID] = true
+				mediaIDs = append(mediaIDs, ref.ID)
+			}
+		}
+	}
+	if archive.Course.CoverMedia > 0 {
+		collect([]model.MediaRef{{ID: archive.Course.CoverMedia}})
+	}
+
+	zw := zip.NewWriter(w)
+
+	questionWriter, err := zw.Create(courseArchiveQuestions)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(questionWriter)
+	// 题目占前80%进度，媒体文件占剩余部分
+	err = forEachExportQuestion(ctx, course.ID, scaleProgress(progress, 0, 80), func(q *exportQuestion) error {
+		collect(q.StemMedia)
+		collect(q.ExplanationMedia)
+		for _, opt := range q.Options {
+			collect(opt.Media)
+		}
+		return encoder.Encode(courseArchiveQuestion{
+			ID:                q.ID,
+			Type:              q.Type,
+			Question:          q.Question,
+			StemMedia:         q.StemMedia,
+			Options:           q.Options,
+			Answer:            q.Answer,
+			Explanation:       q.Explanation,
+			ExplanationMedia:  q.ExplanationMedia,
+			ExplanationSource: q.ExplanationSource,
+			SectionID:         q.SectionID,
+			IsTrial:           q.IsTrial,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	archive.Media, err = writeArchiveMedia(ctx, zw, mediaIDs, scaleProgress(progress, 80, 100))
+	if err != nil {
+		return err
+	}
+
+	manifestWriter, err := zw.Create(courseArchiveManifest)
+	if err != nil {
+		return err
+	}
+	encoder = json.NewEncoder(manifestWriter)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// buildArchive 读取课程信息、分类路径、购买方案和章节
+func (s *CourseArchiveService) buildArchive(course *model.Course) (*courseArchive, error) {
+	examConfig, err := course.GetExamConfig()
+	if err != nil {
+		return nil, errors.New("考试配置格式错误")
+	}
+	mockExamConfig, err := course.GetMockExamConfig()
+	if err != nil {
+		return nil, errors.New("模拟考试配置格式错误")
+	}
+
+	archive := &courseArchive{
+		Version:    courseArchiveVersion,
+		ExportedAt: time.Now(),
+		Course: courseArchiveCourse{
+			Name:           course.Name,
+			Cover:          course.Cover,
+			Price:          course.Price,
+			Description:    course.Description,
+			ExpireDays:     course.ExpireDays,
+			Sort:           course.Sort,
+			TrialCount:     course.TrialCount,
+			ExamConfig:     examConfig,
+			MockExamConfig: mockExamConfig,
+		},
+		Categories: make([]courseArchiveCategory, 0),
+		Plans:      make([]courseArchivePlan, 0),
+		Chapters:   make([]courseArchiveChapter, 0),
+	}
+
+	// 封面为本系统上传的媒体文件时随归档包导出
+	if strings.HasPrefix(course.Cover, mediaURLPrefix) {
+		if id, err := strconv.ParseUint(strings.TrimPrefix(course.Cover, mediaURLPrefix), 10, 32); err == nil {
+			archive.Course.CoverMedia = uint(id)
+		}
+	}
+
+	// 分类路径，从课程所属分类向上查找到一级分类
+	for id, depth := course.CategoryID, 0; id > 0 && depth < 32; depth++ {
+		var category model.CourseCategory
+		if err := database.DB.First(&category, id).Error; err != nil {
+			break
+		}
+		archive.Categories = append([]courseArchiveCategory{{
+			Name: category.Name,
+			Icon: category.Icon,
+			Sort: category.Sort,
+		}}, archive.Categories...)
+		id = category.ParentID
+	}
+
+	plans, err := CoursePlan.List(course.ID)
+	if err != nil {
+		return nil, errors.New("获取购买方案失败")
+	}
+	for _, plan := range plans {
+		archive.Plans = append(archive.Plans, courseArchivePlan{
+			Name:       plan.Name,
+			Price:      plan.Price,
+			ExpireDays: plan.ExpireDays,
+			Sort:       plan.Sort,
+		})
+	}
+
+	var chapters []model.CourseChapter
+	if err := database.DB.Where("course_id = ?", course.ID).Order("sort, id").Find(&chapters).Error; err != nil {
+		return nil, errors.New("获取章节失败")
+	}
+	var sections []model.CourseSection
+	if err := database.DB.Where("course_id = ?", course.ID).Order("sort, id").Find(&sections).Error; err != nil {
+		return nil, errors.New("获取章节失败")
+	}
+	bySection := make(map[uint][]courseArchiveSection)
+	for _, section := range sections {
+		bySection[section.ChapterID] = append(bySection[section.ChapterID], courseArchiveSection{
+			ID:   section.ID,
+			Name: section.Name,
+			Sort: section.Sort,
+		})
+	}
+	for _, chapter := range chapters {
+		archive.Chapters = append(archive.Chapters, courseArchiveChapter{
+			ID:       chapter.ID,
+			Name:     chapter.Name,
+			Sort:     chapter.Sort,
+			Sections: bySection[chapter.ID],
+		})
+	}
+
+	return archive, nil
+}
+
+// Import 导入课程归档包，progress 可为nil
+// 新导入的课程为草稿状态；覆盖已有课程时保留其发布状态，原有的购买方案、章节和题目被删除后重新导入，
+// 覆盖时任何一道题目导入失败都回滚全部导入
+func (s *CourseArchiveService) Import(ctx context.Context, r io.ReaderAt, size int64, params CourseImportParams, progress func(percent int)) (*CourseImportResult, error) {
+	if params.Conflict == "" {
+		params.Conflict = CourseConflictError
+	}
+	if !s.ValidConflict(params.Conflict) {
+		return nil, fmt.Errorf("不支持的同名课程处理方式: %s", params.Conflict)
+	}
+	if params.CategoryID > 0 {
+		if err := CourseCategory.Exists(params.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("压缩包格式不正确")
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	archive, err := readCourseArchive(files)
+	if err != nil {
+		return nil, err
+	}
+	questionFile, exists := files[courseArchiveQuestions]
+	if !exists {
+		return nil, fmt.Errorf("压缩包中缺少 %s", courseArchiveQuestions)
+	}
+
+	// 媒体记录与课程在同一事务中保存，回滚时删除本次新写入的媒体文件
+	result := &CourseImportResult{}
+	var savedKeys []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		course, err := s.resolveCourse(tx, archive, params, result)
+		if err != nil {
+			return err
+		}
+
+		// 媒体文件占前20%进度，题目占剩余部分
+		mediaMap, keys, err := saveArchiveMedia(ctx, tx, files, archive.Media, params.UploaderID, scaleProgress(progress, 0, 20))
+		savedKeys = keys
+		if err != nil {
+			return err
+		}
+		result.MediaCount = len(mediaMap)
+
+		for _, plan := range archive.Plans {
+			if strings.TrimSpace(plan.Name) == "" || plan.Price < 0 || plan.ExpireDays < 0 {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("购买方案 %q 的名称、价格或有效期无效，已跳过", plan.Name))
+				continue
+			}
+			if err := tx.Create(&model.CoursePlan{
+				CourseID:   course.ID,
+				Name:       strings.TrimSpace(plan.Name),
+				Price:      plan.Price,
+				ExpireDays: plan.ExpireDays,
+				Sort:       plan.Sort,
+			}).Error; err != nil {
+				return fmt.Errorf("创建购买方案失败: %v", err)
+			}
+			result.PlanCount++
+		}
+
+		chapterMap, sectionMap, err := s.importChapters(tx, course.ID, archive.Chapters, result)
+		if err != nil {
+			return err
+		}
+
+		if err := s.updateCourse(tx, course, archive, chapterMap, mediaMap, result); err != nil {
+			return err
+		}
+
+		rc, err := questionFile.Open()
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %v", courseArchiveQuestions, err)
+		}
+		defer rc.Close()
+
+		if err := s.importQuestions(ctx, tx, course.ID, &progressReader{
+			r:        rc,
+			total:    int64(questionFile.UncompressedSize64),
+			progress: scaleProgress(progress, 20, 100),
+		}, sectionMap, mediaMap, result); err != nil {
+			return err
+		}
+
+		// 覆盖时原有题目已删除，有题目导入失败则整体回滚，避免用不完整的归档包替换线上课程
+		if result.Overwritten && result.ErrorCount > 0 {
+			return fmt.Errorf("覆盖导入时有%d道题目导入失败，已取消导入，原课程未修改。%s",
+				result.ErrorCount, strings.Join(result.Errors, "；"))
+		}
+		return nil
+	})
+	if err != nil {
+		discardMediaFiles(savedKeys)
+		return nil, err
+	}
+	return result, nil
+}
+
+// readCourseArchive 读取并校验 course.json
+func readCourseArchive(files map[string]*zip.File) (*courseArchive, error) {
+	manifestFile, exists := files[courseArchiveManifest]
+	if !exists {
+		return nil, fmt.Errorf("压缩包中缺少 %s，不是课程归档包", courseArchiveManifest)
+	}
+	rc, err := manifestFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", courseArchiveManifest, err)
+	}
+	defer rc.Close()
+
+	var archive courseArchive
+	if err := json.NewDecoder(rc).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("%s 格式不正确", courseArchiveManifest)
+	}
+	if archive.Version < 1 || archive.Version > courseArchiveVersion {
+		return nil, fmt.Errorf("不支持的归档包版本: %d", archive.Version)
+	}
+	archive.Course.Name = strings.TrimSpace(archive.Course.Name)
+	if archive.Course.Name == "" {
+		return nil, errors.New("归档包中的课程名称为空")
+	}
+	return &archive, nil
+}
+
+// resolveCourse 确定导入的分类，按同名课程的处理方式创建新课程或清空已有课程
+func (s *CourseArchiveService) resolveCourse(tx *gorm.DB, archive *courseArchive, params CourseImportParams, result *CourseImportResult) (*model.Course, error) {
+	categoryID := params.CategoryID
+	if categoryID == 0 {
+		var err error
+		if categoryID, err = resolveCategoryPath(tx, archive.Categories); err != nil {
+			return nil, err
+		}
+	}
+
+	name := archive.Course.Name
+	var existing model.Course
+	err := tx.Where("category_id = ? AND name = ?", categoryID, name).Order("id").First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		switch params.Conflict {
+		case CourseConflictOverwrite:
+			if err := s.clearCourse(tx, existing.ID); err != nil {
+				return nil, err
+			}
+			result.Overwritten = true
+			return &existing, nil
+		case CourseConflictRename:
+			if name, err = availableCourseName(tx, categoryID, name); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("分类下已存在同名课程「%s」(ID %d)", name, existing.ID)
+		}
+	}
+
+	// 新导入的课程为草稿，检查无误后再发布
+	course := &model.Course{
+		Name:       name,
+		CategoryID: categoryID,
+		Status:     model.CourseStatusDraft,
+	}
+	// 考试配置引用的章导入后才有新ID，先保存为空配置
+	course.SetExamConfig([]model.ExamConfigItem{})
+	course.SetMockExamConfig(model.MockExamConfig{})
+	if err := tx.Create(course).Error; err != nil {
+		return nil, fmt.Errorf("创建课程失败: %v", err)
+	}
+	return course, nil
+}
+
+// resolveCategoryPath 按名称逐级查找分类，不存在的分类自动创建，路径为空时返回0（未分类）
+func resolveCategoryPath(tx *gorm.DB, path []courseArchiveCategory) (uint, error) {
+	var parentID uint
+	for i, item := range path {
+		name := strings.TrimSpace(item.Name)
+		if name == "" {
+			return 0, errors.New("归档包中的分类名称为空")
+		}
+
+		var category model.CourseCategory
+		err := tx.Where("parent_id = ? AND name = ?", parentID, name).First(&category).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			category = model.CourseCategory{
+				ParentID: parentID,
+				Name:     name,
+				Icon:     strings.TrimSpace(item.Icon),
+				Sort:     item.Sort,
+				Level:    i + 1,
+			}
+			err = tx.Create(&category).Error
+		}
+		if err != nil {
+			return 0, fmt.Errorf("创建课程分类失败: %v", err)
+		}
+		parentID = category.ID
+	}
+	return parentID, nil
+}
+
+// availableCourseName 为同名课程生成 "名称 (2)" 这样未被使用的名称，名称不超过64个字
+func availableCourseName(tx *gorm.DB, categoryID uint, name string) (string, error) {
+	for i := 2; i < 1000; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		base := []rune(name)
+		if limit := 64 - len([]rune(suffix)); len(base) > limit {
+			base = base[:limit]
+		}
+		candidate := string(base) + suffix
+
+		var count int64
+		if err := tx.Model(&model.Course{}).
+			Where("category_id = ? AND name = ?", categoryID, candidate).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("无法为课程「%s」生成不重复的名称", name)
+}
+
+// clearCourse 覆盖导入前删除课程原有的购买方案、章节和题目
+func (s *CourseArchiveService) clearCourse(tx *gorm.DB, courseID uint) error {
+	var questionIDs []uint
+	if err := tx.Model(&model.Question{}).Where("course_id = ?", courseID).Pluck("id", &questionIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("course_id = ?", courseID).Delete(&model.Question{}).Error; err != nil {
+		return fmt.Errorf("删除原有题目失败: %v", err)
+	}
+	// 删除已删除题目的检索文档
+	if err := QuestionSearch.IndexTx(tx, questionIDs...); err != nil {
+		return err
+	}
+	if err := tx.Where("course_id = ?", courseID).Delete(&model.CourseSection{}).Error; err != nil {
+		return fmt.Errorf("删除原有章节失败: %v", err)
+	}
+	if err := tx.Where("course_id = ?", courseID).Delete(&model.CourseChapter{}).Error; err != nil {
+		return fmt.Errorf("删除原有章节失败: %v", err)
+	}
+	if err := tx.Where("course_id = ?", courseID).Delete(&model.CoursePlan{}).Error; err != nil {
+		return fmt.Errorf("删除原有购买方案失败: %v", err)
+	}
+	return nil
+}
+
+// importChapters 创建章和小节，返回归档包中的章ID、小节ID到新ID的映射
+func (s *CourseArchiveService) importChapters(tx *gorm.DB, courseID uint, chapters []courseArchiveChapter, result *CourseImportResult) (map[uint]uint, map[uint]uint, error) {
+	chapterMap := make(map[uint]uint)
+	sectionMap := make(map[uint]uint)
+	for _, item := range chapters {
+		chapter := model.CourseChapter{CourseID: courseID, Name: strings.TrimSpace(item.Name), Sort: item.Sort}
+		if err := tx.Create(&chapter).Error; err != nil {
+			return nil, nil, fmt.Errorf("创建章失败: %v", err)
+		}
+		chapterMap[item.ID] = chapter.ID
+		result.ChapterCount++
+
+		for _, sectionItem := range item.Sections {
+			section := model.CourseSection{
+				CourseID:  courseID,
+				ChapterID: chapter.ID,
+				Name:      strings.TrimSpace(sectionItem.Name),
+				Sort:      sectionItem.Sort,
+			}
+			if err := tx.Create(&section).Error; err != nil {
+				return nil, nil, fmt.Errorf("创建小节失败: %v", err)
+			}
+			sectionMap[sectionItem.ID] = section.ID
+			result.SectionCount++
+		}
+	}
+	return chapterMap, sectionMap, nil
+}
+
+// updateCourse 写入课程信息，考试配置中的章和封面媒体按映射替换为新ID
+func (s *CourseArchiveService) updateCourse(tx *gorm.DB, course *model.Course, archive *courseArchive, chapterMap, mediaMap map[uint]uint, result *CourseImportResult) error {
+	info := archive.Course
+
+	examConfig := make([]model.ExamConfigItem, 0, len(info.ExamConfig))
+	for _, item := range info.ExamConfig {
+		if item.ChapterID > 0 {
+			newID, exists := chapterMap[item.ChapterID]
+			if !exists {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("考试配置中的章 %d 不在归档包中，已改为从整门课程抽题", item.ChapterID))
+			}
+			item.ChapterID = newID
+		}
+		examConfig = append(examConfig, item)
+	}
+	if err := course.SetExamConfig(examConfig); err != nil {
+		return errors.New("考试配置格式错误")
+	}
+	if err := course.SetMockExamConfig(info.MockExamConfig); err != nil {
+		return errors.New("模拟考试配置格式错误")
+	}
+
+	cover := info.Cover
+	if info.CoverMedia > 0 {
+		if newID, exists := mediaMap[info.CoverMedia]; exists {
+			cover = MediaURL(newID)
+		} else {
+			result.Warnings = append(result.Warnings, "归档包中缺少课程封面文件，已保留原封面地址")
+		}
+	}
+
+	if info.TrialCount < 0 {
+		info.TrialCount = 0
+	}
+	if err := tx.Model(&model.Course{}).Where("id = ?", course.ID).Updates(map[string]interface{}{
+		"cover":            cover,
+		"price":            info.Price,
+		"description":      info.Description,
+		"expire_days":      info.ExpireDays,
+		"sort":             info.Sort,
+		"trial_count":      info.TrialCount,
+		"exam_config":      course.ExamConfig,
+		"mock_exam_config": course.MockExamConfig,
+	}).Error; err != nil {
+		return fmt.Errorf("保存课程信息失败: %v", err)
+	}
+
+	result.CourseID = course.ID
+	result.CourseName = course.Name
+	return nil
+}
+
+// importQuestions 逐题导入，出错的题目跳过并记录原因，取消时回滚全部导入
+func (s *CourseArchiveService) importQuestions(ctx context.Context, tx *gorm.DB, courseID uint, r io.Reader, sectionMap, mediaMap map[uint]uint, result *CourseImportResult) error {
+	addError := func(num int, format string, args ...interface{}) {
+		result.ErrorCount++
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, fmt.Sprintf("第%d题: ", num)+fmt.Sprintf(format, args...))
+		}
+	}
+
+	decoder := json.NewDecoder(r)
+	for num := 1; ; num++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var q courseArchiveQuestion
+		if err := decoder.Decode(&q); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s 第%d题格式不正确", courseArchiveQuestions, num)
+		}
+
+		if q.Type != "single" && q.Type != "multiple" && q.Type != "judge" {
+			addError(num, "题目类型错误: %s", q.Type)
+			continue
+		}
+
+		options := q.Options
+		if q.Type != "judge" && len(options) == 0 {
+			addError(num, "选项为空")
+			continue
+		}
+		if err := remapOptionMedia(options, mediaMap); err != nil {
+			addError(num, "选项媒体: %s", err.Error())
+			continue
+		}
+		if err := Media.normalizeOptionRefs(tx, options); err != nil {
+			addError(num, "%s", err.Error())
+			continue
+		}
+		optionsJSON, err := EncodeQuestionOptions(q.Type, options)
+		if err != nil {
+			addError(num, "选项格式化失败")
+			continue
+		}
+
+		answer := cleanAnswer(q.Answer)
+		if answer == "" {
+			addError(num, "答案为空")
+			continue
+		}
+		if msg := checkImportAnswer(q.Type, answer, len(model.CanonicalOptions(q.Type, options))); msg != "" {
+			addError(num, "%s", msg)
+			continue
+		}
+
+		if err := remapMediaRefs(q.StemMedia, mediaMap); err != nil {
+			addError(num, "题干媒体: %s", err.Error())
+			continue
+		}
+		stemMedia, err := Media.normalizeRefs(tx, q.StemMedia)
+		if err != nil {
+			addError(num, "题干媒体: %s", err.Error())
+			continue
+		}
+		if err := remapMediaRefs(q.ExplanationMedia, mediaMap); err != nil {
+			addError(num, "解析媒体: %s", err.Error())
+			continue
+		}
+		explanationMedia, err := Media.normalizeRefs(tx, q.ExplanationMedia)
+		if err != nil {
+			addError(num, "解析媒体: %s", err.Error())
+			continue
+		}
+
+		question := model.Question{
+			Type:              q.Type,
+			Question:          q.Question,
+			StemMedia:         stemMedia,
+			Answer:            answer,
+			Explanation:       q.Explanation,
+			ExplanationMedia:  explanationMedia,
+			ExplanationSource: q.ExplanationSource,
+			CourseID:          courseID,
+			SectionID:         sectionMap[q.SectionID],
+			IsTrial:           q.IsTrial,
+		}
+		if err := createImportedQuestion(tx, &question, optionsJSON); err != nil {
+			addError(num, "%s", err.Error())
+			continue
+		}
+		result.ImportCount++
+	}
+	return nil
+}
+
+// remapOptionMedia 按映射替换各选项引用的媒体ID
+func remapOptionMedia(options model.QuestionOptions, mediaMap map[uint]uint) error {
+	for i := range options {
+		if err := remapMediaRefs(options[i].Media, mediaMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runExportJob 执行课程归档包导出任务，结果文件供下载
+func (s *CourseArchiveService) runExportJob(jc *JobContext) error {
+	var params CourseExportParams
+	if err := jc.Bind(&params); err != nil {
+		return err
+	}
+	return jc.WriteArtifact(fmt.Sprintf("course-%d.zip", params.CourseID), func(w io.Writer) error {
+		return s.Export(jc, w, params.CourseID, jc.SetProgress)
+	})
+}
+
+// runImportJob 执行课程归档包导入任务
+func (s *CourseArchiveService) runImportJob(jc *JobContext) error {
+	var params CourseImportParams
+	if err := jc.Bind(&params); err != nil {
+		return err
+	}
+
+	input, err := jc.OpenInput()
+	if err != nil {
+		return fmt.Errorf("读取上传文件失败: %v", err)
+	}
+	defer input.Close()
+
+	// 读取zip需要随机访问，先复制到临时文件
+	tmp, err := os.CreateTemp("", "course-import-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, input)
+	if err != nil {
+		return fmt.Errorf("读取上传文件失败: %v", err)
+	}
+
+	result, err := s.Import(jc, tmp, size, params, jc.SetProgress)
+	if err != nil {
+		return err
+	}
+	return jc.SetResult(result)
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var CourseCategory = new(CourseCategoryService)
+
+// CourseCategoryService 课程分类管理，分类层级不限，课程通过 CategoryID 关联分类
+type CourseCategoryService struct{}
+
+// CategoryInput 创建或修改分类的参数
+type CategoryInput struct {
+	ParentID uint   // 父分类ID，0表示一级分类
+	Name     string // 分类名称，同一父分类下不能重复
+	Icon     string // 图标地址
+	Sort     int    // 排序
+}
+
+// CategoryTreeItem 管理端分类树节点
+type CategoryTreeItem struct {
+	model.CourseCategory
+	CourseCount int64              `json:"course_count"` // 直接属于该分类的课程数，不含子分类
+	Children    []CategoryTreeItem `json:"children"`
+}
+
+// uncategorizedName 未分类课程在分类树中的名称
+const uncategorizedName = "未分类"
+
+// all 获取全部分类，按排序值、名称排列
+func (s *CourseCategoryService) all() ([]model.CourseCategory, error) {
+	var categories []model.CourseCategory
+	if err := database.DB.Order("sort, name, id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// childrenOf 按父分类ID分组，组内保持 all 的顺序
+func childrenOf(categories []model.CourseCategory) map[uint][]model.CourseCategory {
+	children := make(map[uint][]model.CourseCategory)
+	for _, category := range categories {
+		children[category.ParentID] = append(children[category.ParentID], category)
+	}
+	return children
+}
+
+// Tree 获取管理端分类树，包含没有课程的分类
+func (s *CourseCategoryService) Tree() ([]CategoryTreeItem, error) {
+	categories, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		CategoryID uint
+		Count      int64
+	}
+	if err := database.DB.Model(&model.Course{}).
+		Select("category_id, COUNT(*) AS count").
+		Group("category_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	countMap := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		countMap[c.CategoryID] = c.Count
+	}
+
+	children := childrenOf(categories)
+	var build func(parentID uint) []CategoryTreeItem
+	build = func(parentID uint) []CategoryTreeItem {
+		items := make([]CategoryTreeItem, 0, len(children[parentID]))
+		for _, category := range children[parentID] {
+			items = append(items, CategoryTreeItem{
+				CourseCategory: category,
+				CourseCount:    countMap[category.ID],
+				Children:       build(category.ID),
+			})
+		}
+		return items
+	}
+	return build(0), nil
+}
+
+// Create 创建分类
+func (s *CourseCategoryService) Create(input CategoryInput) (*model.CourseCategory, error) {
+	category := &model.CourseCategory{
+		ParentID: input.ParentID,
+		Name:     strings.TrimSpace(input.Name),
+		Icon:     strings.TrimSpace(input.Icon),
+		Sort:     input.Sort,
+		Level:    1,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkName(tx, 0, category.ParentID, category.Name); err != nil {
+			return err
+		}
+		if category.ParentID > 0 {
+			var parent model.CourseCategory
+			if err := tx.First(&parent, category.ParentID).Error; err != nil {
+				return errors.New("父分类不存在")
+			}
+			category.Level = parent.Level + 1
+		}
+		return tx.Create(category).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// Update 修改分类的名称、图标、排序和父分类，移动分类时子分类随之移动
+func (s *CourseCategoryService) Update(id uint, input CategoryInput) (*model.CourseCategory, error) {
+	var category model.CourseCategory
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&category, id).Error; err != nil {
+			return errors.New("分类不存在")
+		}
+
+		name := strings.TrimSpace(input.Name)
+		if err := s.checkName(tx, id, input.ParentID, name); err != nil {
+			return err
+		}
+
+		var categories []model.CourseCategory
+		if err := tx.Find(&categories).Error; err != nil {
+			return err
+		}
+		descendants := descendantIDs(childrenOf(categories), id)
+
+		level := 1
+		if input.ParentID > 0 {
+			if input.ParentID == id || containsID(descendants, input.ParentID) {
+				return errors.New("不能将分类移动到自身或其子分类下")
+			}
+			var parent model.CourseCategory
+			if err := tx.First(&parent, input.ParentID).Error; err != nil {
+				return errors.New("父分类不存在")
+			}
+			level = parent.Level + 1
+		}
+
+		// 移动后子分类的层级随之调整
+		if delta := level - category.Level; delta != 0 && len(descendants) > 0 {
+			if err := tx.Model(&model.CourseCategory{}).Where("id IN ?", descendants).
+				Update("level", gorm.Expr("level + ?", delta)).Error; err != nil {
+				return err
+			}
+		}
+
+		category.ParentID = input.ParentID
+		category.Name = name
+		category.Icon = strings.TrimSpace(input.Icon)
+		category.Sort = input.Sort
+		category.Level = level
+		return tx.Select("parent_id", "name", "icon", "sort", "level").Save(&category).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Delete 删除分类，分类下还有子分类或课程时不能删除
+func (s *CourseCategoryService) Delete(id uint) error {
+	var category model.CourseCategory
+	if err := database.DB.First(&category, id).Error; err != nil {
+		return errors.New("分类不存在")
+	}
+
+	var count int64
+	if err := database.DB.Model(&model.CourseCategory{}).Where("parent_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该分类下还有子分类，无法删除")
+	}
+	if err := database.DB.Model(&model.Course{}).Where("category_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("该分类下还有%d门课程，无法删除", count)
+	}
+
+	return database.DB.Delete(&category).Error
+}
+
+// checkName 检查分类名称不为空且同一父分类下不重复，excludeID 为正在修改的分类
+func (s *CourseCategoryService) checkName(tx *gorm.DB, excludeID, parentID uint, name string) error {
+	if name == "" {
+		return errors.New("分类名称不能为空")
+	}
+	if len([]rune(name)) > 64 {
+		return errors.New("分类名称不能超过64个字")
+	}
+	var count int64
+	if err := tx.Model(&model.CourseCategory{}).
+		Where("parent_id = ? AND name = ? AND id <> ?", parentID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("同一分类下已存在同名分类")
+	}
+	return nil
+}
+
+// Exists 检查分类是否存在，用于设置课程分类
+func (s *CourseCategoryService) Exists(id uint) error {
+	if id == 0 {
+		return errors.New("请选择课程分类")
+	}
+	var count int64
+	if err := database.DB.Model(&model.CourseCategory{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("课程分类不存在")
+	}
+	return nil
+}
+
+// SubtreeIDs 分类及其全部子分类的ID，用于按分类筛选课程
+func (s *CourseCategoryService) SubtreeIDs(id uint) ([]uint, error) {
+	categories, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	return append([]uint{id}, descendantIDs(childrenOf(categories), id)...), nil
+}
+
+// Paths 各分类从一级分类开始的名称路径，如 "职业资格/会计"
+func (s *CourseCategoryService) Paths() (map[uint]string, error) {
+	categories, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.CourseCategory, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	paths := make(map[uint]string, len(categories))
+	for _, category := range categories {
+		names := []string{category.Name}
+		// 层级不会超过分类总数，防止数据异常形成环时死循环
+		for parentID, depth := category.ParentID, 0; parentID > 0 && depth < len(categories); depth++ {
+			parent, ok := byID[parentID]
+			if !ok {
+				break
+			}
+			names = append([]string{parent.Name}, names...)
+			parentID = parent.ParentID
+		}
+		paths[category.ID] = strings.Join(names, "/")
+	}
+	return paths, nil
+}
+
+// descendantIDs 分类的全部子孙分类ID
+func descendantIDs(children map[uint][]model.CourseCategory, id uint) []uint {
+	var ids []uint
+	visited := map[uint]bool{id: true}
+	queue := []uint{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if visited[child.ID] {
+				continue
+			}
+			visited[child.ID] = true
+			ids = append(ids, child.ID)
+			queue = append(queue, child.ID)
+		}
+	}
+	return ids
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// CourseDisplayName 课程的展示名称：所属分类名称-课程名称，未分类时为课程名称
+func (s *CourseCategoryService) CourseDisplayName(course *model.Course) string {
+	if course.CategoryID == 0 {
+		return course.Name
+	}
+	var category model.CourseCategory
+	if err := database.DB.Select("name").First(&category, course.CategoryID).Error; err != nil {
+		return course.Name
+	}
+	return courseDisplayName(category.Name, course.Name)
+}
+
+// CourseDisplayNames 批量获取课程的展示名称
+func (s *CourseCategoryService) CourseDisplayNames(courseIds []uint) map[uint]string {
+	names := make(map[uint]string, len(courseIds))
+	if len(courseIds) == 0 {
+		return names
+	}
+
+	var rows []struct {
+		ID           uint
+		Name         string
+		CategoryName string
+	}
+	database.DB.Table("courses").
+		Select("courses.id, courses.name, course_categories.name AS category_name").
+		Joins("LEFT JOIN course_categories ON course_categories.id = courses.category_id AND course_categories.deleted_at IS NULL").
+		Where("courses.id IN ?", courseIds).
+		Scan(&rows)
+	for _, row := range rows {
+		names[row.ID] = courseDisplayName(row.CategoryName, row.Name)
+	}
+	return names
+}
+
+func courseDisplayName(categoryName, courseName string) string {
+	if categoryName == "" {
+		return courseName
+	}
+	return categoryName + "-" + courseName
+}
+
+// MigrateLegacy 将旧版课程中的一级、二级分类名称转换为分类记录并关联到课程
+// 只处理尚未关联分类的课程，可重复执行；同名分类合并，排序取排序值最小的课程中记录的值
+func (s *CourseCategoryService) MigrateLegacy() (int, error) {
+	var courses []model.Course
+	if err := database.DB.Where("category_id = 0 AND category_level1 <> ''").
+		Order("sort, id").Find(&courses).Error; err != nil {
+		return 0, fmt.Errorf("查询课程失败: %v", err)
+	}
+	if len(courses) == 0 {
+		return 0, nil
+	}
+
+	migrated := 0
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// 已存在的同名分类直接沿用，便于重复执行
+		find := func(parentID uint, name string, level, sort int) (uint, error) {
+			// 一级分类的 parent_id 为 0，不能用结构体作为查询条件，否则零值字段会被忽略
+			category := model.CourseCategory{ParentID: parentID, Name: name}
+			if err := tx.Where("parent_id = ? AND name = ?", parentID, name).
+				Attrs(model.CourseCategory{Level: level, Sort: sort}).
+				FirstOrCreate(&category).Error; err != nil {
+				return 0, err
+			}
+			return category.ID, nil
+		}
+
+		// 排序值取各课程中记录的最小值
+		level1Sorts := make(map[string]int)
+		level2Sorts := make(map[[2]string]int)
+		for _, course := range courses {
+			name1 := strings.TrimSpace(course.CategoryLevel1)
+			if v, ok := level1Sorts[name1]; !ok || course.CategorySort1 < v {
+				level1Sorts[name1] = course.CategorySort1
+			}
+			key := [2]string{name1, strings.TrimSpace(course.CategoryLevel2)}
+			if v, ok := level2Sorts[key]; !ok || course.CategorySort2 < v {
+				level2Sorts[key] = course.CategorySort2
+			}
+		}
+
+		names1 := make([]string, 0, len(level1Sorts))
+		for name := range level1Sorts {
+			names1 = append(names1, name)
+		}
+		sort.Strings(names1)
+		level1IDs := make(map[string]uint, len(names1))
+		for _, name := range names1 {
+			id, err := find(0, name, 1, level1Sorts[name])
+			if err != nil {
+				return err
+			}
+			level1IDs[name] = id
+		}
+
+		for _, course := range courses {
+			name1 := strings.TrimSpace(course.CategoryLevel1)
+			name2 := strings.TrimSpace(course.CategoryLevel2)
+			categoryID := level1IDs[name1]
+			if name2 != "" {
+				id, err := find(categoryID, name2, 2, level2Sorts[[2]string{name1, name2}])
+				if err != nil {
+					return err
+				}
+				categoryID = id
+			}
+			if err := tx.Model(&model.Course{}).Where("id = ?", course.ID).
+				Update("category_id", categoryID).Error; err != nil {
+				return err
+			}
+			migrated++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("迁移课程分类失败: %v", err)
+	}
+	return migrated, nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var CoursePlan = new(CoursePlanService)
+
+// CoursePlanService 课程购买方案，一门课程可以按不同有效期设置不同价格
+// 课程设置了方案时必须选择方案购买，未设置方案时仍按课程的价格和有效期购买
+type CoursePlanService struct{}
+
+// CoursePlanInput 保存购买方案的参数，ID 为0时新建方案
+type CoursePlanInput struct {
+	ID         uint
+	Name       string
+	Price      float64
+	ExpireDays int
+	Sort       int
+}
+
+// PurchaseTerms 购买课程的价格和有效期
+type PurchaseTerms struct {
+	PlanID     uint    // 购买方案ID，0表示按课程价格和有效期购买
+	Price      float64 // 价格（元）
+	ExpireDays int     // 有效期（天），0表示永久有效
+}
+
+// List 获取课程的购买方案
+func (s *CoursePlanService) List(courseID uint) ([]model.CoursePlan, error) {
+	plans := make([]model.CoursePlan, 0)
+	err := database.DB.Where("course_id = ?", courseID).Order("sort, price, id").Find(&plans).Error
+	return plans, err
+}
+
+// ForCourses 批量查询课程的购买方案，返回 课程ID -> 方案列表
+func (s *CoursePlanService) ForCourses(courseIDs []uint) map[uint][]model.CoursePlan {
+	result := make(map[uint][]model.CoursePlan)
+	if len(courseIDs) == 0 {
+		return result
+	}
+	var plans []model.CoursePlan
+	if err := database.DB.Where("course_id IN ?", courseIDs).Order("sort, price, id").Find(&plans).Error; err != nil {
+		return result
+	}
+	for _, plan := range plans {
+		result[plan.CourseID] = append(result[plan.CourseID], plan)
+	}
+	return result
+}
+
+// Save 整体保存课程的购买方案：带ID的方案修改，不带ID的新建，未包含的方案删除
+// 已支付订单的金额和到期时间不受影响，删除的方案仍保留记录用于未支付订单的开通
+func (s *CoursePlanService) Save(courseID uint, inputs []CoursePlanInput) ([]model.CoursePlan, error) {
+	var course model.Course
+	if err := database.DB.First(&course, courseID).Error; err != nil {
+		return nil, errors.New("课程不存在")
+	}
+
+	names := make(map[string]bool)
+	for i := range inputs {
+		inputs[i].Name = strings.TrimSpace(inputs[i].Name)
+		input := inputs[i]
+		if input.Name == "" {
+			return nil, errors.New("方案名称不能为空")
+		}
+		if names[input.Name] {
+			return nil, fmt.Errorf("方案名称重复: %s", input.Name)
+		}
+		names[input.Name] = true
+		if input.Price < 0 {
+			return nil, errors.New("方案价格不能为负数")
+		}
+		if input.ExpireDays < 0 {
+			return nil, errors.New("方案有效期不能为负数")
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var existing []model.CoursePlan
+		if err := tx.Where("course_id = ?", courseID).Find(&existing).Error; err != nil {
+			return err
+		}
+		kept := make(map[uint]bool)
+		for _, plan := range existing {
+			kept[plan.ID] = false
+		}
+
+		for _, input := range inputs {
+			plan := model.CoursePlan{
+				ID:         input.ID,
+				CourseID:   courseID,
+				Name:       input.Name,
+				Price:      input.Price,
+				ExpireDays: input.ExpireDays,
+				Sort:       input.Sort,
+			}
+			if input.ID == 0 {
+				if err := tx.Create(&plan).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if _, ok := kept[input.ID]; !ok {
+				return fmt.Errorf("方案不存在: %d", input.ID)
+			}
+			kept[input.ID] = true
+			if err := tx.Model(&model.CoursePlan{}).Where("id = ?", input.ID).Updates(map[string]interface{}{
+				"name":        plan.Name,
+				"price":       plan.Price,
+				"expire_days": plan.ExpireDays,
+				"sort":        plan.Sort,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		var removed []uint
+		for id, keep := range kept {
+			if !keep {
+				removed = append(removed, id)
+			}
+		}
+		if len(removed) > 0 {
+			return tx.Delete(&model.CoursePlan{}, removed).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存购买方案失败: %v", err)
+	}
+	return s.List(courseID)
+}
+
+// Resolve 确定新订单的价格和有效期：课程必须已发布，设置了方案时必须选择其中一个方案
+func (s *CoursePlanService) Resolve(courseID, planID uint) (*PurchaseTerms, error) {
+	var course model.Course
+	if err := database.DB.First(&course, courseID).Error; err != nil {
+		return nil, errors.New("课程不存在")
+	}
+	if err := Course.CheckPurchasable(&course); err != nil {
+		return nil, err
+	}
+
+	if planID > 0 {
+		var plan model.CoursePlan
+		if err := database.DB.Where("id = ? AND course_id = ?", planID, courseID).First(&plan).Error; err != nil {
+			return nil, errors.New("购买方案不存在")
+		}
+		return &PurchaseTerms{PlanID: plan.ID, Price: plan.Price, ExpireDays: plan.ExpireDays}, nil
+	}
+
+	var count int64
+	if err := database.DB.Model(&model.CoursePlan{}).Where("course_id = ?", courseID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询购买方案失败: %v", err)
+	}
+	if count > 0 {
+		return nil, errors.New("请选择购买方案")
+	}
+	return &PurchaseTerms{Price: course.Price, ExpireDays: course.ExpireDays}, nil
+}
+
+// OrderExpireDays 订单支付时开通的有效期（天），按下单时选择的方案计算，方案已删除时仍然有效
+func (s *CoursePlanService) OrderExpireDays(order *model.Order) (int, error) {
+	if order.PlanID > 0 {
+		var plan model.CoursePlan
+		if err := database.DB.Unscoped().First(&plan, order.PlanID).Error; err != nil {
+			return 0, fmt.Errorf("购买方案不存在: %v", err)
+		}
+		return plan.ExpireDays, nil
+	}
+
+	var course model.Course
+	if err := database.DB.First(&course, order.CourseID).Error; err != nil {
+		return 0, fmt.Errorf("课程不存在: %v", err)
+	}
+	return course.ExpireDays, nil
+}
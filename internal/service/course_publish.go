@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ValidStatus 是否为有效的课程发布状态
+func (s *CourseService) ValidStatus(status string) bool {
+	switch status {
+	case model.CourseStatusDraft, model.CourseStatusPublished, model.CourseStatusArchived:
+		return true
+	}
+	return false
+}
+
+// CheckSchedule 校验定时发布和定时归档时间，两者都设置时归档时间必须晚于发布时间
+func (s *CourseService) CheckSchedule(publishAt, unpublishAt *time.Time) error {
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return errors.New("定时归档时间必须晚于定时发布时间")
+	}
+	return nil
+}
+
+// CheckPurchasable 只有已发布的课程可以购买或兑换，草稿和已归档的课程不再出售
+func (s *CourseService) CheckPurchasable(course *model.Course) error {
+	if course.Status != model.CourseStatusPublished {
+		return errors.New("课程未发布或已下架")
+	}
+	return nil
+}
+
+// visibleQuery 学生端可见的课程：已发布的课程，以及用户已购买的已归档课程
+// 已归档课程是否已购买由调用方根据购买状态过滤
+func (s *CourseService) visibleQuery() *gorm.DB {
+	return database.DB.Model(&model.Course{}).
+		Where("status IN ?", []string{model.CourseStatusPublished, model.CourseStatusArchived})
+}
+
+// visibleTo 课程对用户是否可见：已归档的课程只对购买且在有效期内的用户可见
+func visibleTo(course *model.Course, purchase coursePurchase) bool {
+	switch course.Status {
+	case model.CourseStatusPublished:
+		return true
+	case model.CourseStatusArchived:
+		return purchase.Purchased
+	}
+	return false
+}
+
+// ApplySchedules 处理到期的定时发布和定时归档，返回发布和归档的课程数
+func (s *CourseService) ApplySchedules(now time.Time) (int64, int64, error) {
+	published := database.DB.Model(&model.Course{}).
+		Where("publish_at IS NOT NULL AND publish_at <= ?", now).
+		Updates(map[string]interface{}{"status": model.CourseStatusPublished, "publish_at": nil})
+	if published.Error != nil {
+		return 0, 0, published.Error
+	}
+
+	archived := database.DB.Model(&model.Course{}).
+		Where("unpublish_at IS NOT NULL AND unpublish_at <= ? AND status = ?", now, model.CourseStatusPublished).
+		Updates(map[string]interface{}{"status": model.CourseStatusArchived, "unpublish_at": nil})
+	if archived.Error != nil {
+		return published.RowsAffected, 0, archived.Error
+	}
+	return published.RowsAffected, archived.RowsAffected, nil
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/logger"
 	"fmt"
 	"time"
 )
@@ -19,6 +21,8 @@ var Cron = &CronService{
 // Start 启动定时任务
 func (s *CronService) Start() {
 	go s.handleExpiredOrders()
+	go s.refreshItemAnalysis()
+	go s.publishScheduledCourses()
 }
 
 // Stop 停止定时任务
@@ -58,3 +62,44 @@ func (s *CronService) handleExpiredOrders() {
 		}
 	}
 }
+
+// refreshItemAnalysis 定期重新计算题目分析结果
+func (s *CronService) refreshItemAnalysis() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ItemAnalysis.Recompute(context.Background(), nil); err != nil {
+				logger.Errorf("计算题目分析结果失败: %v", err)
+			}
+
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// publishScheduledCourses 处理课程的定时发布和定时归档
+func (s *CronService) publishScheduledCourses() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			published, archived, err := Course.ApplySchedules(time.Now())
+			if err != nil {
+				logger.Errorf("处理课程定时发布失败: %v", err)
+				continue
+			}
+			if published > 0 || archived > 0 {
+				logger.Infof("课程定时发布 %d 门，定时归档 %d 门", published, archived)
+			}
+
+		case <-s.stopChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var Entitlement = new(EntitlementService)
+
+// EntitlementService 课程权限，统一判断用户能否访问课程
+// 购买、卡券兑换、套餐开通产生的权限随订单同步，管理员也可以直接赠送
+type EntitlementService struct{}
+
+// ErrNoCourseAccess 用户没有课程的有效权限
+var ErrNoCourseAccess = errors.New("您尚未购买该课程或课程已过期")
+
+// ErrPermanentAccess 用户已永久拥有课程，无需再次购买
+var ErrPermanentAccess = errors.New("您已永久拥有该课程，无需续费")
+
+// EntitlementQuery 权限列表查询条件
+type EntitlementQuery struct {
+	UserID   uint
+	CourseID uint
+	Source   string
+	Active   bool // 只返回生效中的权限
+	Page     int
+	PageSize int
+}
+
+// EntitlementItem 权限列表项
+type EntitlementItem struct {
+	model.Entitlement
+	Username   string `json:"username"`
+	CourseName string `json:"course_name"`
+	OrderNo    string `json:"order_no"`
+	Active     bool   `json:"active"`
+}
+
+// active 生效中的权限：未撤销、已开始且未到期，到期时间为空表示永久有效
+func (s *EntitlementService) active(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+	return db.Where("revoked_at IS NULL AND start_at <= ?", now).
+		Where("(end_at IS NULL OR end_at > ?)", now)
+}
+
+// HasAccess 用户是否有课程的有效权限
+func (s *EntitlementService) HasAccess(userId, courseId uint) bool {
+	if userId == 0 || courseId == 0 {
+		return false
+	}
+	var count int64
+	s.active(database.DB.Model(&model.Entitlement{})).
+		Where("user_id = ? AND course_id = ?", userId, courseId).
+		Count(&count)
+	return count > 0
+}
+
+// CheckAccess 检查用户是否有课程的有效权限，没有时返回 ErrNoCourseAccess
+func (s *EntitlementService) CheckAccess(userId, courseId uint) error {
+	if !s.HasAccess(userId, courseId) {
+		return ErrNoCourseAccess
+	}
+	return nil
+}
+
+// Courses 用户有有效权限的课程ID子查询，用于 course_id IN (?) 条件
+func (s *EntitlementService) Courses(userId uint) *gorm.DB {
+	return s.active(database.DB.Model(&model.Entitlement{})).
+		Select("DISTINCT course_id").
+		Where("user_id = ?", userId)
+}
+
+// CourseIDs 用户有有效权限的课程ID
+func (s *EntitlementService) CourseIDs(userId uint) ([]uint, error) {
+	var courseIDs []uint
+	err := s.active(database.DB.Model(&model.Entitlement{})).
+		Where("user_id = ?", userId).
+		Distinct().
+		Pluck("course_id", &courseIDs).Error
+	return courseIDs, err
+}
+
+// Access 批量查询用户对课程的有效权限，返回课程ID到最晚到期时间的映射
+// 不在映射中表示没有权限，值为 nil 表示永久有效
+func (s *EntitlementService) Access(userId uint, courseIDs []uint) (map[uint]*time.Time, error) {
+	return s.access(database.DB, userId, courseIDs)
+}
+
+func (s *EntitlementService) access(db *gorm.DB, userId uint, courseIDs []uint) (map[uint]*time.Time, error) {
+	result := make(map[uint]*time.Time, len(courseIDs))
+	if userId == 0 || len(courseIDs) == 0 {
+		return result, nil
+	}
+
+	var entitlements []model.Entitlement
+	if err := s.active(db).
+		Where("user_id = ? AND course_id IN (?)", userId, courseIDs).
+		Find(&entitlements).Error; err != nil {
+		return nil, err
+	}
+
+	for _, e := range entitlements {
+		endAt, exists := result[e.CourseID]
+		switch {
+		case !exists:
+			result[e.CourseID] = e.EndAt
+		case endAt == nil:
+			// 已有永久权限
+		case e.EndAt == nil || e.EndAt.After(*endAt):
+			result[e.CourseID] = e.EndAt
+		}
+	}
+	return result, nil
+}
+
+// CheckRenewable 检查用户能否购买或续费课程，已有永久有效的权限时返回 ErrPermanentAccess
+func (s *EntitlementService) CheckRenewable(userId, courseId uint) error {
+	access, err := s.Access(userId, []uint{courseId})
+	if err != nil {
+		return fmt.Errorf("查询课程权限失败: %v", err)
+	}
+	if endAt, ok := access[courseId]; ok && endAt == nil {
+		return ErrPermanentAccess
+	}
+	return nil
+}
+
+// RenewalExpireTime 新开通课程权限的到期时间，days 为开通的有效天数，0表示永久有效（返回 nil）
+// 用户已有未到期的权限时在其最晚到期时间上叠加，剩余天数不会丢失；没有有效权限或已永久有效时从现在开始计算
+// 购买、免费开通、卡券兑换和套餐开通都按此规则计算，db 可以是事务
+func (s *EntitlementService) RenewalExpireTime(db *gorm.DB, userId, courseId uint, days int) (*time.Time, error) {
+	if days <= 0 {
+		return nil, nil
+	}
+	access, err := s.access(db, userId, []uint{courseId})
+	if err != nil {
+		return nil, fmt.Errorf("查询课程权限失败: %v", err)
+	}
+
+	start := time.Now()
+	if endAt := access[courseId]; endAt != nil && endAt.After(start) {
+		start = *endAt
+	}
+	expireTime := start.AddDate(0, 0, days)
+	return &expireTime, nil
+}
+
+// SyncOrder 按订单当前状态同步其产生的课程权限，订单状态、有效期变化或删除后调用
+// 已支付且未删除的订单权限生效，其他状态（退款、取消等）撤销；套餐订单同步其所有子订单
+func (s *EntitlementService) SyncOrder(db *gorm.DB, orderId uint) error {
+	var order model.Order
+	if err := db.Unscoped().First(&order, orderId).Error; err != nil {
+		return fmt.Errorf("订单不存在: %v", err)
+	}
+
+	if order.CourseID == 0 {
+		if order.BundleID == 0 {
+			return nil
+		}
+		var children []model.Order
+		if err := db.Unscoped().Where("parent_order_id = ?", order.ID).Find(&children).Error; err != nil {
+			return err
+		}
+		for i := range children {
+			if err := s.syncOrder(db, &children[i], order.DeletedAt.Valid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	parentDeleted := false
+	if order.ParentOrderID > 0 {
+		var parent model.Order
+		if err := db.Unscoped().Select("id, deleted_at").First(&parent, order.ParentOrderID).Error; err == nil {
+			parentDeleted = parent.DeletedAt.Valid
+		}
+	}
+	return s.syncOrder(db, &order, parentDeleted)
+}
+
+// syncOrder 创建或更新课程订单对应的权限，每个订单对应一条权限
+func (s *EntitlementService) syncOrder(db *gorm.DB, order *model.Order, parentDeleted bool) error {
+	var entitlement model.Entitlement
+	err := db.Where("order_id = ?", order.ID).First(&entitlement).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	exists := err == nil
+
+	active := order.Status == "paid" && !order.DeletedAt.Valid && !parentDeleted
+	if !active {
+		// 从未生效的订单无需记录
+		if !exists || entitlement.RevokedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		return db.Model(&entitlement).Update("revoked_at", &now).Error
+	}
+
+	startAt := order.CreatedAt
+	if order.PayTime != nil {
+		startAt = *order.PayTime
+	}
+	if !exists {
+		entitlement = model.Entitlement{
+			UserID:   order.UserID,
+			CourseID: order.CourseID,
+			Source:   s.orderSource(order),
+			OrderID:  order.ID,
+			StartAt:  startAt,
+			EndAt:    order.ExpireTime,
+		}
+		return db.Create(&entitlement).Error
+	}
+	return db.Model(&entitlement).Updates(map[string]interface{}{
+		"user_id":    order.UserID,
+		"course_id":  order.CourseID,
+		"start_at":   startAt,
+		"end_at":     order.ExpireTime,
+		"revoked_at": nil,
+	}).Error
+}
+
+// orderSource 订单产生的权限来源
+func (s *EntitlementService) orderSource(order *model.Order) string {
+	switch {
+	case order.ParentOrderID > 0:
+		return model.EntitlementSourceBundle
+	case order.PaymentType == "card":
+		return model.EntitlementSourceCard
+	case order.PaymentType == "admin":
+		return model.EntitlementSourceAdmin
+	default:
+		return model.EntitlementSourcePurchase
+	}
+}
+
+// Backfill 为已支付但还没有权限记录的课程订单生成权限，启动时执行，返回生成的数量
+func (s *EntitlementService) Backfill() (int, error) {
+	var orderIDs []uint
+	if err := database.DB.Model(&model.Order{}).
+		Where("status = ? AND course_id > 0", "paid").
+		Where("id NOT IN (?)", database.DB.Model(&model.Entitlement{}).Select("order_id").Where("order_id > 0")).
+		Pluck("id", &orderIDs).Error; err != nil {
+		return 0, err
+	}
+
+	for _, id := range orderIDs {
+		if err := s.SyncOrder(database.DB, id); err != nil {
+			return 0, fmt.Errorf("同步订单 %d 的课程权限失败: %v", id, err)
+		}
+	}
+	return len(orderIDs), nil
+}
+
+// Grant 管理员向用户赠送课程权限，days 为有效天数，0表示永久有效，与购买相同在当前到期时间上叠加
+func (s *EntitlementService) Grant(adminId, userId, courseId uint, days int, remark string) (*model.Entitlement, error) {
+	if days < 0 {
+		return nil, errors.New("有效天数不能为负数")
+	}
+	if err := database.DB.Select("id").First(&model.User{}, userId).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if err := database.DB.Select("id").First(&model.Course{}, courseId).Error; err != nil {
+		return nil, errors.New("课程不存在")
+	}
+
+	endAt, err := s.RenewalExpireTime(database.DB, userId, courseId, days)
+	if err != nil {
+		return nil, err
+	}
+	entitlement := &model.Entitlement{
+		UserID:    userId,
+		CourseID:  courseId,
+		Source:    model.EntitlementSourceAdmin,
+		GrantedBy: adminId,
+		Remark:    remark,
+		StartAt:   time.Now(),
+		EndAt:     endAt,
+	}
+	if err := database.DB.Create(entitlement).Error; err != nil {
+		return nil, errors.New("赠送课程失败")
+	}
+	return entitlement, nil
+}
+
+// Revoke 撤销管理员赠送的权限，订单产生的权限需通过退款或修改订单处理
+func (s *EntitlementService) Revoke(id uint) error {
+	var entitlement model.Entitlement
+	if err := database.DB.First(&entitlement, id).Error; err != nil {
+		return errors.New("权限记录不存在")
+	}
+	if entitlement.OrderID > 0 {
+		return errors.New("订单产生的权限请通过订单退款或修改订单撤销")
+	}
+	if entitlement.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	return database.DB.Model(&entitlement).Update("revoked_at", &now).Error
+}
+
+// List 获取权限列表，按创建时间倒序
+func (s *EntitlementService) List(q EntitlementQuery) ([]EntitlementItem, int64, error) {
+	db := database.DB.Model(&model.Entitlement{})
+	if q.UserID > 0 {
+		db = db.Where("user_id = ?", q.UserID)
+	}
+	if q.CourseID > 0 {
+		db = db.Where("course_id = ?", q.CourseID)
+	}
+	if q.Source != "" {
+		db = db.Where("source = ?", q.Source)
+	}
+	if q.Active {
+		db = s.active(db)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entitlements []model.Entitlement
+	if err := db.Order("id DESC").Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Find(&entitlements).Error; err != nil {
+		return nil, 0, err
+	}
+
+	userIDs := make([]uint, 0, len(entitlements))
+	courseIDs := make([]uint, 0, len(entitlements))
+	orderIDs := make([]uint, 0, len(entitlements))
+	for _, e := range entitlements {
+		userIDs = append(userIDs, e.UserID)
+		courseIDs = append(courseIDs, e.CourseID)
+		if e.OrderID > 0 {
+			orderIDs = append(orderIDs, e.OrderID)
+		}
+	}
+
+	var users []model.User
+	database.DB.Select("id, username").Where("id IN ?", userIDs).Find(&users)
+	usernames := make(map[uint]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	var courses []model.Course
+	database.DB.Unscoped().Select("id, name").Where("id IN ?", courseIDs).Find(&courses)
+	courseNames := make(map[uint]string, len(courses))
+	for _, c := range courses {
+		courseNames[c.ID] = c.Name
+	}
+
+	orderNos := make(map[uint]string)
+	if len(orderIDs) > 0 {
+		var orders []model.Order
+		database.DB.Unscoped().Select("id, order_no").Where("id IN ?", orderIDs).Find(&orders)
+		for _, o := range orders {
+			orderNos[o.ID] = o.OrderNo
+		}
+	}
+
+	now := time.Now()
+	items := make([]EntitlementItem, 0, len(entitlements))
+	for _, e := range entitlements {
+		items = append(items, EntitlementItem{
+			Entitlement: e,
+			Username:    usernames[e.UserID],
+			CourseName:  courseNames[e.CourseID],
+			OrderNo:     orderNos[e.OrderID],
+			Active:      e.RevokedAt == nil && !e.StartAt.After(now) && (e.EndAt == nil || e.EndAt.After(now)),
+		})
+	}
+	return items, total, nil
+}
@@ -24,19 +24,18 @@ type ExamResultItem struct {
 func (s *ExamService) GetAllResults(userId uint) ([]ExamResultItem, error) {
 	// 定义一个临时结构体用于查询结果
 	type QueryResult struct {
-		ID             uint      `json:"id"`
-		Score          float64   `json:"score"`
-		CourseID       uint      `json:"course_id"`
-		CategoryLevel2 string    `json:"category_level2"`
-		Name           string    `json:"name"`
-		CreatedAt      time.Time `json:"created_at"`
+		ID        uint      `json:"id"`
+		Score     float64   `json:"score"`
+		CourseID  uint      `json:"course_id"`
+		Name      string    `json:"name"`
+		CreatedAt time.Time `json:"created_at"`
 	}
 
 	var queryResults []QueryResult
 
 	// 查询用户的考试记录，联表查询课程信息
 	err := database.DB.Table("exam_records").
-		Select("exam_records.id, exam_records.score, exam_records.course_id, courses.category_level2 AS category_level2, courses.name, exam_records.created_at").
+		Select("exam_records.id, exam_records.score, exam_records.course_id, courses.name, exam_records.created_at").
 		Joins("LEFT JOIN courses ON exam_records.course_id = courses.id").
 		Where("exam_records.user_id = ? AND exam_records.deleted_at IS NULL", userId).
 		Order("exam_records.created_at DESC").
@@ -46,15 +45,19 @@ func (s *ExamService) GetAllResults(userId uint) ([]ExamResultItem, error) {
 		return nil, errors.New("获取考试记录失败: " + err.Error())
 	}
 
+	// 课程名称：分类名称-课程名称
+	courseIds := make([]uint, 0, len(queryResults))
+	for _, result := range queryResults {
+		courseIds = append(courseIds, result.CourseID)
+	}
+	courseNameMap := CourseCategory.CourseDisplayNames(courseIds)
+
 	// 组装返回数据
 	var results []ExamResultItem
 	for _, result := range queryResults {
-		// 拼接课程名称：category_level2-name
-		var courseName string
-		if result.CategoryLevel2 != "" {
-			courseName = result.CategoryLevel2 + "-" + result.Name
-		} else {
-			courseName = result.Name // 如果二级分类为空，则只使用课程名称
+		courseName, ok := courseNameMap[result.CourseID]
+		if !ok {
+			courseName = result.Name
 		}
 
 		results = append(results, ExamResultItem{
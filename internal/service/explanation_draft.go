@@ -0,0 +1,334 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ExplanationDraft = new(ExplanationDraftService)
+
+// ExplanationDraftService AI解析草稿：保存生成结果、学生端展示及管理端审核
+// AI生成的内容不直接写入题目解析，管理员采用后才成为正式解析
+type ExplanationDraftService struct{}
+
+// ErrDraftNotFound 草稿不存在
+var ErrDraftNotFound = errors.New("草稿不存在")
+
+// aiDraftLabel 学生端展示待审核草稿时的提示
+const aiDraftLabel = "AI生成，未经审核，仅供参考"
+
+// AIExplanationView 学生端展示的待审核AI解析，题目没有正式解析时返回
+type AIExplanationView struct {
+	Content     string    `json:"content"`
+	Status      string    `json:"status"`
+	Label       string    `json:"label"`
+	Model       string    `json:"model"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+func newAIExplanationView(draft *model.ExplanationDraft) *AIExplanationView {
+	return &AIExplanationView{
+		Content:     draft.Content,
+		Status:      draft.Status,
+		Label:       aiDraftLabel,
+		Model:       draft.Model,
+		GeneratedAt: draft.GeneratedAt,
+	}
+}
+
+// ExplanationDraftItem 审核队列中的草稿，附带题目当前内容便于对照
+type ExplanationDraftItem struct {
+	model.ExplanationDraft
+	Question ReviewQuestion `json:"question"`
+}
+
+// ReviewQuestion 审核列表中附带的题目当前内容
+type ReviewQuestion struct {
+	ID                uint                   `json:"id"`
+	Type              string                 `json:"type"`
+	Question          string                 `json:"question"`
+	Options           []model.QuestionOption `json:"options"`
+	Answer            string                 `json:"answer"`
+	Explanation       string                 `json:"explanation"`
+	ExplanationSource string                 `json:"explanation_source"`
+	CourseID          uint                   `json:"course_id"`
+	CourseName        string                 `json:"course_name"`
+}
+
+// SaveTx 保存AI生成的解析为草稿，题目已有待审核草稿时覆盖该草稿
+func (s *ExplanationDraftService) SaveTx(tx *gorm.DB, questionID uint, gen *GeneratedText, origin string, requestedBy, jobID uint) (*model.ExplanationDraft, error) {
+	var draft model.ExplanationDraft
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("question_id = ? AND status = ?", questionID, model.DraftStatusDraft).
+		Order("id DESC").
+		First(&draft).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	draft.QuestionID = questionID
+	draft.Content = gen.Content
+	draft.Status = model.DraftStatusDraft
+	draft.Origin = origin
+	draft.Model = gen.Model
+	draft.PromptVersion = gen.PromptVersion
+	draft.PromptTokens = gen.Usage.PromptTokens
+	draft.CompletionTokens = gen.Usage.CompletionTokens
+	draft.Cost = gen.Cost
+	draft.RequestedBy = requestedBy
+	draft.JobID = jobID
+	draft.GeneratedAt = time.Now()
+
+	if err := tx.Save(&draft).Error; err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// Pending 获取题目待审核的草稿，没有时返回nil
+func (s *ExplanationDraftService) Pending(questionID uint) (*model.ExplanationDraft, error) {
+	var draft model.ExplanationDraft
+	err := database.DB.Where("question_id = ? AND status = ?", questionID, model.DraftStatusDraft).
+		Order("id DESC").
+		First(&draft).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// Views 批量获取题目待审核草稿的学生端展示内容
+func (s *ExplanationDraftService) Views(questionIDs []uint) map[uint]*AIExplanationView {
+	views := make(map[uint]*AIExplanationView)
+	if len(questionIDs) == 0 {
+		return views
+	}
+
+	var drafts []model.ExplanationDraft
+	if err := database.DB.Where("question_id IN ? AND status = ?", questionIDs, model.DraftStatusDraft).
+		Order("id").
+		Find(&drafts).Error; err != nil {
+		return views
+	}
+	for i := range drafts {
+		views[drafts[i].QuestionID] = newAIExplanationView(&drafts[i])
+	}
+	return views
+}
+
+// attachAIExplanations 为没有正式解析的题目附加待审核的AI解析
+// get 返回第i道题目的ID和正式解析，set 设置第i道题目的AI解析
+func attachAIExplanations(n int, get func(i int) (uint, string), set func(i int, view *AIExplanationView)) {
+	var ids []uint
+	for i := 0; i < n; i++ {
+		if id, explanation := get(i); strings.TrimSpace(explanation) == "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	views := ExplanationDraft.Views(ids)
+	for i := 0; i < n; i++ {
+		if id, explanation := get(i); strings.TrimSpace(explanation) == "" {
+			if view, ok := views[id]; ok {
+				set(i, view)
+			}
+		}
+	}
+}
+
+// Queue 获取审核队列，待审核的草稿先生成的在前，其他状态最新的在前
+func (s *ExplanationDraftService) Queue(status string, courseID uint, page, size int) ([]ExplanationDraftItem, int64, error) {
+	db := database.DB.Model(&model.ExplanationDraft{}).
+		Joins("JOIN questions ON questions.id = explanation_drafts.question_id AND questions.deleted_at IS NULL")
+	if status != "" {
+		db = db.Where("explanation_drafts.status = ?", status)
+	}
+	if courseID > 0 {
+		db = db.Where("questions.course_id = ?", courseID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "explanation_drafts.id DESC"
+	if status == model.DraftStatusDraft {
+		order = "explanation_drafts.id"
+	}
+	var drafts []model.ExplanationDraft
+	if err := db.Select("explanation_drafts.*").
+		Order(order).
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&drafts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	questionIDs := make([]uint, 0, len(drafts))
+	for _, d := range drafts {
+		questionIDs = append(questionIDs, d.QuestionID)
+	}
+	questionByID, err := loadReviewQuestions(questionIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]ExplanationDraftItem, 0, len(drafts))
+	for _, d := range drafts {
+		items = append(items, ExplanationDraftItem{
+			ExplanationDraft: d,
+			Question:         questionByID[d.QuestionID],
+		})
+	}
+	return items, total, nil
+}
+
+// Approve 采用草稿，content 不为空时使用修改后的内容；修改过的解析视为人工解析
+func (s *ExplanationDraftService) Approve(id, reviewerID uint, content string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		draft, err := s.lockDraft(tx, id)
+		if err != nil {
+			return err
+		}
+
+		text := strings.TrimSpace(content)
+		edited := text != "" && text != draft.Content
+		if text == "" {
+			text = draft.Content
+		}
+
+		_, err = s.approveTx(tx, draft, reviewerID, text, edited, false)
+		return err
+	})
+}
+
+// approveTx 将草稿写入题目解析并标记为已采用；keepManual 为true时不覆盖人工编写的解析，返回是否已写入
+func (s *ExplanationDraftService) approveTx(tx *gorm.DB, draft *model.ExplanationDraft, reviewerID uint, text string, edited, keepManual bool) (bool, error) {
+	source := model.ExplanationSourceAI
+	if edited {
+		source = model.ExplanationSourceManual
+	}
+
+	db := tx.Model(&model.Question{}).Where("id = ?", draft.QuestionID)
+	if keepManual {
+		db = db.Where("(explanation IS NULL OR TRIM(explanation) = '' OR explanation_source = ?)", model.ExplanationSourceAI)
+	}
+	result := db.Updates(map[string]interface{}{
+		"explanation":        text,
+		"explanation_source": source,
+	})
+	if result.Error != nil {
+		return false, fmt.Errorf("保存解析失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if keepManual {
+			return false, nil
+		}
+		return false, errors.New("题目不存在")
+	}
+	if err := QuestionSearch.IndexTx(tx, draft.QuestionID); err != nil {
+		return false, fmt.Errorf("更新检索索引失败: %v", err)
+	}
+
+	now := time.Now()
+	if err := tx.Model(draft).Updates(map[string]interface{}{
+		"status":      model.DraftStatusApproved,
+		"edited":      edited,
+		"reviewed_by": reviewerID,
+		"reviewed_at": now,
+	}).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reject 驳回草稿，学生端不再展示
+func (s *ExplanationDraftService) Reject(id, reviewerID uint, note string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		draft, err := s.lockDraft(tx, id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(draft).Updates(map[string]interface{}{
+			"status":      model.DraftStatusRejected,
+			"review_note": truncateMessage(strings.TrimSpace(note), 500),
+			"reviewed_by": reviewerID,
+			"reviewed_at": now,
+		}).Error
+	})
+}
+
+// lockDraft 锁定待审核的草稿，已处理的草稿不能再次审核
+func (s *ExplanationDraftService) lockDraft(tx *gorm.DB, id uint) (*model.ExplanationDraft, error) {
+	var draft model.ExplanationDraft
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&draft, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDraftNotFound
+		}
+		return nil, err
+	}
+	if draft.Status != model.DraftStatusDraft {
+		return nil, errors.New("草稿已处理")
+	}
+	return &draft, nil
+}
+
+// loadReviewQuestions 批量读取题目当前内容，选项按原始字符串读取，个别题目选项无法解析时不影响整个列表
+func loadReviewQuestions(questionIDs []uint) (map[uint]ReviewQuestion, error) {
+	questionByID := make(map[uint]ReviewQuestion, len(questionIDs))
+	if len(questionIDs) == 0 {
+		return questionByID, nil
+	}
+
+	var questions []struct {
+		ID                uint
+		Type              string
+		Question          string
+		Options           string
+		Answer            string
+		Explanation       string
+		ExplanationSource string
+		CourseID          uint
+		CourseName        string
+	}
+	if err := database.DB.Table("questions").
+		Select("questions.id, questions.type, questions.question, questions.options, questions.answer, "+
+			"questions.explanation, questions.explanation_source, questions.course_id, courses.name AS course_name").
+		Joins("LEFT JOIN courses ON courses.id = questions.course_id").
+		Where("questions.id IN ?", questionIDs).
+		Scan(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, q := range questions {
+		options, _ := model.DecodeQuestionOptions(q.Type, q.Options)
+		questionByID[q.ID] = ReviewQuestion{
+			ID:                q.ID,
+			Type:              q.Type,
+			Question:          q.Question,
+			Options:           options,
+			Answer:            q.Answer,
+			Explanation:       q.Explanation,
+			ExplanationSource: q.ExplanationSource,
+			CourseID:          q.CourseID,
+			CourseName:        q.CourseName,
+		}
+	}
+	return questionByID, nil
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/logger"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+var ItemAnalysis = new(ItemAnalysisService)
+
+// ItemAnalysisService 题目分析：正确率、选项分布、区分度及答案疑似错误检测
+type ItemAnalysisService struct{}
+
+// JobTypeItemAnalysis 重新计算题目分析的任务类型
+const JobTypeItemAnalysis = "item_analysis"
+
+const (
+	discriminationGroupRatio = 0.27 // 区分度取成绩最高和最低各27%的作答
+	minDiscriminationSamples = 20   // 计算区分度所需的最少考试作答数
+	minSuspectAttempts       = 20   // 判断答案疑似错误所需的最少作答数
+)
+
+// normalizeAnswer 将所选选项去重、排序后拼接，如 ["C","a"] 转为 "AC"
+func normalizeAnswer(answer []string) string {
+	seen := make(map[rune]bool)
+	var letters []string
+	for _, a := range answer {
+		for _, ch := range strings.ToUpper(a) {
+			if ch >= 'A' && ch <= 'Z' && !seen[ch] {
+				seen[ch] = true
+				letters = append(letters, string(ch))
+			}
+		}
+	}
+	sort.Strings(letters)
+	return strings.Join(letters, "")
+}
+
+// RecordPracticeAnswer 记录练习作答，失败只记录日志，不影响答题
+func (s *ItemAnalysisService) RecordPracticeAnswer(userId uint, question *model.Question, answer []string, correct bool) {
+	log := model.AnswerLog{
+		UserID:     userId,
+		QuestionID: question.ID,
+		CourseID:   question.CourseID,
+		Source:     model.AnswerSourcePractice,
+		Answer:     normalizeAnswer(answer),
+		Correct:    correct,
+	}
+	if err := database.DB.Create(&log).Error; err != nil {
+		logger.Errorf("记录练习作答失败: %v", err)
+	}
+}
+
+// RecordExamAnswers 记录模拟考试的全部作答，是否正确由服务端根据答案判断
+func (s *ItemAnalysisService) RecordExamAnswers(userId, courseId, recordId uint, answers map[uint][]string) {
+	if len(answers) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(answers))
+	for id := range answers {
+		ids = append(ids, id)
+	}
+
+	var questions []model.Question
+	if err := database.DB.Select("id, answer, course_id").
+		Where("id IN ? AND course_id = ?", ids, courseId).
+		Find(&questions).Error; err != nil {
+		logger.Errorf("记录考试作答失败: %v", err)
+		return
+	}
+
+	logs := make([]model.AnswerLog, 0, len(questions))
+	for _, q := range questions {
+		answer := answers[q.ID]
+		logs = append(logs, model.AnswerLog{
+			UserID:       userId,
+			QuestionID:   q.ID,
+			CourseID:     courseId,
+			Source:       model.AnswerSourceExam,
+			ExamRecordID: recordId,
+			Answer:       normalizeAnswer(answer),
+			Correct:      compareAnswers(q.Answer, answer),
+		})
+	}
+	if len(logs) == 0 {
+		return
+	}
+
+	if err := database.DB.CreateInBatches(logs, 200).Error; err != nil {
+		logger.Errorf("记录考试作答失败: %v", err)
+	}
+}
+
+// GetStats 批量获取题目分析结果
+func (s *ItemAnalysisService) GetStats(questionIds []uint) (map[uint]model.QuestionStat, error) {
+	result := make(map[uint]model.QuestionStat, len(questionIds))
+	if len(questionIds) == 0 {
+		return result, nil
+	}
+
+	var stats []model.QuestionStat
+	if err := database.DB.Where("question_id IN ?", questionIds).Find(&stats).Error; err != nil {
+		return nil, err
+	}
+	for _, stat := range stats {
+		result[stat.QuestionID] = stat
+	}
+	return result, nil
+}
+
+// Recompute 根据全部作答记录重新计算题目分析结果，progress 可为nil
+func (s *ItemAnalysisService) Recompute(ctx context.Context, progress func(percent int)) error {
+	if progress == nil {
+		progress = func(int) {}
+	}
+	// 取整到秒，避免数据库时间精度导致刚保存的结果被当作过期清除
+	now := time.Now().Truncate(time.Second)
+
+	// 1. 作答次数、答对次数和选项分布
+	type answerCount struct {
+		QuestionID uint
+		Answer     string
+		Correct    bool
+		Total      int
+	}
+	var counts []answerCount
+	if err := database.DB.Model(&model.AnswerLog{}).
+		Select("question_id, answer, correct, COUNT(*) AS total").
+		Group("question_id, answer, correct").
+		Scan(&counts).Error; err != nil {
+		return fmt.Errorf("统计作答记录失败: %v", err)
+	}
+
+	stats := make(map[uint]*model.QuestionStat)
+	for _, c := range counts {
+		stat, exists := stats[c.QuestionID]
+		if !exists {
+			stat = &model.QuestionStat{QuestionID: c.QuestionID, OptionCounts: model.OptionCounts{}}
+			stats[c.QuestionID] = stat
+		}
+		stat.Attempts += c.Total
+		if c.Correct {
+			stat.CorrectCount += c.Total
+		}
+		for _, ch := range c.Answer {
+			stat.OptionCounts[string(ch)] += c.Total
+		}
+	}
+	progress(30)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 2. 区分度：按考试成绩排序，高分组与低分组正确率之差
+	if err := s.computeDiscrimination(ctx, stats); err != nil {
+		return err
+	}
+	progress(60)
+
+	// 3. 对照正确答案检测疑似错误的答案
+	ids := make([]uint, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	valid := make(map[uint]bool, len(ids))
+	for start := 0; start < len(ids); start += 1000 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		end := start + 1000
+		if end > len(ids) {
+			end = len(ids)
+		}
+		var questions []model.Question
+		if err := database.DB.Select("id, type, answer").
+			Where("id IN ?", ids[start:end]).
+			Find(&questions).Error; err != nil {
+			return fmt.Errorf("查询题目失败: %v", err)
+		}
+		for _, q := range questions {
+			valid[q.ID] = true
+			stat := stats[q.ID]
+			stat.SuspectKey, stat.SuspectReason = detectSuspectKey(q.Answer, stat)
+		}
+	}
+	progress(80)
+
+	// 4. 保存结果，已删除题目的统计一并清除
+	list := make([]model.QuestionStat, 0, len(valid))
+	for id, stat := range stats {
+		if !valid[id] {
+			continue
+		}
+		if stat.Attempts > 0 {
+			stat.CorrectRate = math.Round(float64(stat.CorrectCount)/float64(stat.Attempts)*10000) / 10000
+		}
+		stat.UpdatedAt = now
+		list = append(list, *stat)
+	}
+
+	if len(list) > 0 {
+		if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).
+			CreateInBatches(list, 500).Error; err != nil {
+			return fmt.Errorf("保存题目分析结果失败: %v", err)
+		}
+	}
+	if err := database.DB.Where("updated_at < ?", now).Delete(&model.QuestionStat{}).Error; err != nil {
+		return fmt.Errorf("清理题目分析结果失败: %v", err)
+	}
+
+	return nil
+}
+
+// computeDiscrimination 根据模拟考试作答计算区分度
+func (s *ItemAnalysisService) computeDiscrimination(ctx context.Context, stats map[uint]*model.QuestionStat) error {
+	rows, err := database.DB.Table("answer_logs").
+		Select("answer_logs.question_id, answer_logs.correct, exam_records.score").
+		Joins("JOIN exam_records ON exam_records.id = answer_logs.exam_record_id").
+		Where("answer_logs.source = ?", model.AnswerSourceExam).
+		Order("answer_logs.question_id").
+		Rows()
+	if err != nil {
+		return fmt.Errorf("查询考试作答失败: %v", err)
+	}
+	defer rows.Close()
+
+	var currentID uint
+	var samples []examSample
+	flush := func() {
+		if stat, exists := stats[currentID]; exists {
+			stat.Discrimination = discriminationIndex(samples)
+		}
+		samples = samples[:0]
+	}
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var questionID uint
+		var item examSample
+		if err := rows.Scan(&questionID, &item.correct, &item.score); err != nil {
+			return fmt.Errorf("读取考试作答失败: %v", err)
+		}
+		if questionID != currentID && len(samples) > 0 {
+			flush()
+		}
+		currentID = questionID
+		samples = append(samples, item)
+	}
+	if len(samples) > 0 {
+		flush()
+	}
+
+	return rows.Err()
+}
+
+// examSample 一次考试作答：是否答对及该次考试成绩
+type examSample struct {
+	correct bool
+	score   float64
+}
+
+// discriminationIndex 计算区分度 D = 高分组正确率 - 低分组正确率，样本不足时返回nil
+func discriminationIndex(samples []examSample) *float64 {
+	n := len(samples)
+	if n < minDiscriminationSamples {
+		return nil
+	}
+	group := int(math.Round(float64(n) * discriminationGroupRatio))
+
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].score > samples[j].score })
+
+	upper, lower := 0, 0
+	for i := 0; i < group; i++ {
+		if samples[i].correct {
+			upper++
+		}
+		if samples[n-1-i].correct {
+			lower++
+		}
+	}
+
+	d := math.Round(float64(upper-lower)/float64(group)*10000) / 10000
+	return &d
+}
+
+// detectSuspectKey 若有干扰项被选次数多于某个正确选项，则认为答案可能有误
+func detectSuspectKey(answer string, stat *model.QuestionStat) (bool, string) {
+	if stat.Attempts < minSuspectAttempts || answer == "" {
+		return false, ""
+	}
+
+	// 找出被选次数最少的正确选项
+	keyLabel, keyCount := "", -1
+	for _, ch := range answer {
+		count := stat.OptionCounts[string(ch)]
+		if keyCount < 0 || count < keyCount {
+			keyLabel, keyCount = string(ch), count
+		}
+	}
+
+	// 按标签顺序检查干扰项，保证结果稳定
+	labels := make([]string, 0, len(stat.OptionCounts))
+	for label := range stat.OptionCounts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		if strings.Contains(answer, label) {
+			continue
+		}
+		if count := stat.OptionCounts[label]; count > keyCount {
+			return true, fmt.Sprintf("干扰项%s被选%d次，多于正确选项%s的%d次", label, count, keyLabel, keyCount)
+		}
+	}
+
+	return false, ""
+}
+
+// runItemAnalysisJob 执行题目分析任务
+func (s *ItemAnalysisService) runItemAnalysisJob(jc *JobContext) error {
+	return s.Recompute(jc, jc.SetProgress)
+}
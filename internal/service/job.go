@@ -0,0 +1,605 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"exam-system/internal/config"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/logger"
+	"exam-system/internal/pkg/storage"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrJobNotFound 任务不存在
+var ErrJobNotFound = errors.New("任务不存在")
+
+// JobHandler 任务处理函数，返回错误时任务标记为失败
+type JobHandler func(jc *JobContext) error
+
+// JobService 后台任务服务
+// 任务持久化在数据库中，由若干工作协程轮询领取执行；多个实例可以共用同一个数据库，
+// 执行中的任务由所在实例定期更新心跳，心跳超时的任务视为所在实例已退出，重新排队
+type JobService struct {
+	instanceID string // 本实例的ID，记录在领取的任务上
+
+	mu       sync.Mutex
+	handlers map[string]JobHandler
+	running  map[uint]context.CancelFunc
+	notify   chan struct{}
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+var Job = &JobService{
+	handlers: make(map[string]JobHandler),
+	running:  make(map[uint]context.CancelFunc),
+	notify:   make(chan struct{}, 1),
+	stopChan: make(chan struct{}),
+}
+
+// 执行中任务的心跳间隔，超过 jobStaleAfter 没有心跳的任务重新排队
+const (
+	jobHeartbeatInterval = 30 * time.Second
+	jobStaleAfter        = 2 * time.Minute
+)
+
+// registerJobHandlers 注册内置任务类型
+func (s *JobService) registerJobHandlers() {
+	s.Register(JobTypeQuestionImport, QuestionIO.runImportJob)
+	s.Register(JobTypeQuestionExport, QuestionIO.runExportJob)
+	s.Register(JobTypeItemAnalysis, ItemAnalysis.runItemAnalysisJob)
+	s.Register(JobTypeSearchReindex, QuestionSearch.runReindexJob)
+	s.Register(JobTypeAIExplanation, AIExplanation.runBatchJob)
+	s.Register(JobTypeQuestionGeneration, QuestionGeneration.runGenerationJob)
+	s.Register(JobTypeAnswerVerification, AnswerVerification.runVerificationJob)
+	s.Register(JobTypeCourseExport, CourseArchive.runExportJob)
+	s.Register(JobTypeCourseImport, CourseArchive.runImportJob)
+}
+
+// Register 注册任务处理函数
+func (s *JobService) Register(jobType string, handler JobHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[jobType] = handler
+}
+
+func (s *JobService) handler(jobType string) (JobHandler, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	handler, exists := s.handlers[jobType]
+	return handler, exists
+}
+
+// Start 启动任务工作协程
+func (s *JobService) Start() {
+	s.registerJobHandlers()
+	s.instanceID = uuid.New().String()
+
+	// 异常退出的实例（包括本实例上次运行）留下的执行中任务重新排队，其他实例正在执行的任务不受影响
+	s.requeueStaleJobs()
+
+	workers := config.GlobalConfig.Job.Workers
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+
+	go s.heartbeat()
+	go s.cleanupExpiredJobs()
+}
+
+// Stop 停止任务工作协程，执行中的任务会在下次启动时重新执行
+func (s *JobService) Stop() {
+	close(s.stopChan)
+
+	s.mu.Lock()
+	for _, cancel := range s.running {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *JobService) stopping() bool {
+	select {
+	case <-s.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// Submit 提交任务
+func (s *JobService) Submit(jobType string, params interface{}, creatorID uint) (*model.Job, error) {
+	return s.submit(jobType, params, creatorID, "", "")
+}
+
+// SubmitWithUpload 保存上传文件后提交任务，任务中通过 JobContext.OpenInput 读取该文件
+func (s *JobService) SubmitWithUpload(jobType string, params interface{}, file *multipart.FileHeader, creatorID uint) (*model.Job, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, errors.New("无法打开文件")
+	}
+	defer src.Close()
+
+	return s.SubmitWithInput(jobType, params, file.Filename, src, creatorID)
+}
+
+// SubmitWithInput 将输入内容保存为任务输入文件后提交任务，name 为文件名，用于识别格式
+func (s *JobService) SubmitWithInput(jobType string, params interface{}, name string, r io.Reader, creatorID uint) (*model.Job, error) {
+	inputKey := fmt.Sprintf("jobs/uploads/%s/%s", time.Now().Format("200601"), uuid.New().String()+path.Ext(name))
+	if _, err := storage.Store.Save(inputKey, r); err != nil {
+		return nil, fmt.Errorf("保存上传文件失败: %v", err)
+	}
+
+	job, err := s.submit(jobType, params, creatorID, inputKey, name)
+	if err != nil {
+		storage.Store.Delete(inputKey)
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *JobService) submit(jobType string, params interface{}, creatorID uint, inputKey, inputName string) (*model.Job, error) {
+	if _, exists := s.handler(jobType); !exists {
+		return nil, fmt.Errorf("不支持的任务类型: %s", jobType)
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("任务参数格式错误: %v", err)
+	}
+
+	job := &model.Job{
+		Type:      jobType,
+		Status:    model.JobStatusPending,
+		Params:    string(data),
+		InputKey:  inputKey,
+		InputName: inputName,
+		CreatorID: creatorID,
+	}
+	if err := database.DB.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("创建任务失败: %v", err)
+	}
+
+	// 唤醒空闲的工作协程
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// Get 获取任务
+func (s *JobService) Get(id uint) (*model.Job, error) {
+	var job model.Job
+	if err := database.DB.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Cancel 取消任务，等待中的任务直接取消，执行中的任务在下一个检查点停止
+func (s *JobService) Cancel(id uint) error {
+	job, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if job.IsFinished() {
+		return errors.New("任务已结束，无法取消")
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&model.Job{}).
+		Where("id = ? AND status = ?", id, model.JobStatusPending).
+		Updates(map[string]interface{}{
+			"status":           model.JobStatusCanceled,
+			"cancel_requested": true,
+			"message":          "任务已取消",
+			"finished_at":      now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		if job.InputKey != "" {
+			storage.Store.Delete(job.InputKey)
+		}
+		return nil
+	}
+
+	// 任务已开始执行，标记取消请求
+	if err := database.DB.Model(&model.Job{}).Where("id = ?", id).
+		Update("cancel_requested", true).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if cancel, exists := s.running[id]; exists {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// OpenArtifact 打开任务结果文件
+func (s *JobService) OpenArtifact(id uint) (*model.Job, io.ReadCloser, error) {
+	job, err := s.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != model.JobStatusSucceeded || job.ArtifactKey == "" {
+		return nil, nil, errors.New("任务没有可下载的结果文件")
+	}
+
+	reader, err := storage.Store.Open(job.ArtifactKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, errors.New("结果文件已过期")
+		}
+		return nil, nil, err
+	}
+	return job, reader, nil
+}
+
+// worker 循环领取并执行任务
+func (s *JobService) worker() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		// 队列中有任务时连续执行
+		for !s.stopping() && s.runNext() {
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.notify:
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// runNext 领取并执行一个等待中的任务，队列为空时返回false
+func (s *JobService) runNext() bool {
+	var job model.Job
+	err := database.DB.Where("status = ?", model.JobStatusPending).Order("id").First(&job).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Errorf("查询待执行任务失败: %v", err)
+		}
+		return false
+	}
+
+	// 通过状态条件更新抢占任务，避免多个协程重复执行
+	now := time.Now()
+	result := database.DB.Model(&model.Job{}).
+		Where("id = ? AND status = ?", job.ID, model.JobStatusPending).
+		Updates(map[string]interface{}{
+			"status":       model.JobStatusRunning,
+			"started_at":   now,
+			"message":      "",
+			"worker_id":    s.instanceID,
+			"heartbeat_at": now,
+		})
+	if result.Error != nil {
+		logger.Errorf("领取任务 %d 失败: %v", job.ID, result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		// 已被其他协程领取，继续查找下一个
+		return true
+	}
+
+	job.Status = model.JobStatusRunning
+	job.StartedAt = &now
+	s.run(&job)
+	return true
+}
+
+// run 执行任务并记录结果
+func (s *JobService) run(job *model.Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.running[job.ID] = cancel
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	jc := &JobContext{Context: ctx, Job: job, cancel: cancel}
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("任务执行异常: %v", r)
+			}
+		}()
+
+		handler, exists := s.handler(job.Type)
+		if !exists {
+			return fmt.Errorf("不支持的任务类型: %s", job.Type)
+		}
+		return handler(jc)
+	}()
+
+	s.finish(jc, err)
+}
+
+// finish 根据执行结果更新任务状态
+func (s *JobService) finish(jc *JobContext, err error) {
+	job := jc.Job
+	now := time.Now()
+
+	updates := map[string]interface{}{"finished_at": now}
+	switch {
+	case err == nil:
+		updates["status"] = model.JobStatusSucceeded
+		updates["progress"] = 100
+		updates["message"] = "任务完成"
+		updates["result"] = jc.result
+		updates["artifact_key"] = jc.artifactKey
+		updates["artifact_name"] = jc.artifactName
+	case jc.Err() != nil && s.stopping() && !jc.cancelRequested():
+		// 服务停止导致的中断，重新排队由其他实例或下次启动执行
+		jc.discardArtifact()
+		if err := database.DB.Model(&model.Job{}).
+			Where("id = ? AND worker_id = ?", job.ID, s.instanceID).
+			Updates(map[string]interface{}{
+				"status":       model.JobStatusPending,
+				"progress":     0,
+				"started_at":   nil,
+				"worker_id":    "",
+				"heartbeat_at": nil,
+			}).Error; err != nil {
+			logger.Errorf("任务 %d 重新排队失败: %v", job.ID, err)
+		}
+		return
+	case jc.Err() != nil:
+		jc.discardArtifact()
+		updates["status"] = model.JobStatusCanceled
+		updates["message"] = "任务已取消"
+	default:
+		jc.discardArtifact()
+		updates["status"] = model.JobStatusFailed
+		updates["message"] = truncateMessage(err.Error(), 500)
+		logger.Errorf("任务 %d(%s) 执行失败: %v", job.ID, job.Type, err)
+	}
+
+	// 只更新仍由本实例执行的任务，心跳超时后已被重新排队的任务以新的执行结果为准
+	result := database.DB.Model(&model.Job{}).
+		Where("id = ? AND worker_id = ?", job.ID, s.instanceID).
+		Updates(updates)
+	if result.Error != nil {
+		logger.Errorf("更新任务 %d 状态失败: %v", job.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		if err == nil {
+			jc.discardArtifact()
+		}
+		logger.Infof("任务 %d 已被重新排队，丢弃本次执行结果", job.ID)
+		return
+	}
+
+	// 任务结束后不再需要上传的输入文件
+	if job.InputKey != "" {
+		storage.Store.Delete(job.InputKey)
+	}
+}
+
+// heartbeat 定期更新本实例执行中任务的心跳，并将心跳超时的任务重新排队
+func (s *JobService) heartbeat() {
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := database.DB.Model(&model.Job{}).
+				Where("worker_id = ? AND status = ?", s.instanceID, model.JobStatusRunning).
+				Update("heartbeat_at", time.Now()).Error; err != nil {
+				logger.Errorf("更新任务心跳失败: %v", err)
+			}
+			s.requeueStaleJobs()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// requeueStaleJobs 将心跳超时的执行中任务重新排队
+func (s *JobService) requeueStaleJobs() {
+	result := database.DB.Model(&model.Job{}).
+		Where("status = ? AND COALESCE(heartbeat_at, started_at) < ?", model.JobStatusRunning, time.Now().Add(-jobStaleAfter)).
+		Updates(map[string]interface{}{
+			"status":       model.JobStatusPending,
+			"progress":     0,
+			"started_at":   nil,
+			"worker_id":    "",
+			"heartbeat_at": nil,
+		})
+	if result.Error != nil {
+		logger.Errorf("重置超时任务失败: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.Infof("%d 个心跳超时的任务已重新排队", result.RowsAffected)
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// cleanupExpiredJobs 定期删除过期的已结束任务及其结果文件
+func (s *JobService) cleanupExpiredJobs() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			retention := time.Duration(config.GlobalConfig.Job.RetentionDays) * 24 * time.Hour
+
+			var jobs []model.Job
+			if err := database.DB.Where("status IN ? AND finished_at < ?",
+				[]string{model.JobStatusSucceeded, model.JobStatusFailed, model.JobStatusCanceled},
+				time.Now().Add(-retention)).
+				Find(&jobs).Error; err != nil {
+				logger.Errorf("查询过期任务失败: %v", err)
+				continue
+			}
+
+			for _, job := range jobs {
+				if job.ArtifactKey != "" {
+					if err := storage.Store.Delete(job.ArtifactKey); err != nil {
+						logger.Errorf("删除任务 %d 结果文件失败: %v", job.ID, err)
+						continue
+					}
+				}
+				database.DB.Delete(&job)
+			}
+
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// truncateMessage 按字符截断过长的信息
+func truncateMessage(msg string, max int) string {
+	runes := []rune(msg)
+	if len(runes) <= max {
+		return msg
+	}
+	return string(runes[:max])
+}
+
+// JobContext 任务执行上下文，任务被取消时 Context 结束
+type JobContext struct {
+	context.Context
+	Job *model.Job
+
+	cancel       context.CancelFunc
+	progress     int
+	result       string
+	artifactKey  string
+	artifactName string
+}
+
+// Bind 解析任务参数
+func (jc *JobContext) Bind(v interface{}) error {
+	if err := json.Unmarshal([]byte(jc.Job.Params), v); err != nil {
+		return fmt.Errorf("任务参数格式错误: %v", err)
+	}
+	return nil
+}
+
+// SetProgress 更新任务进度（0-99），同时检查是否有其他实例发起的取消请求
+func (jc *JobContext) SetProgress(percent int) {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 99 {
+		percent = 99
+	}
+	if percent == jc.progress {
+		return
+	}
+	jc.progress = percent
+
+	database.DB.Model(&model.Job{}).Where("id = ?", jc.Job.ID).Update("progress", percent)
+
+	if jc.cancelRequested() {
+		jc.cancel()
+	}
+}
+
+// SetResult 设置任务结果，任务成功后保存
+func (jc *JobContext) SetResult(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	jc.result = string(data)
+	return nil
+}
+
+// OpenInput 打开提交任务时上传的文件
+func (jc *JobContext) OpenInput() (io.ReadCloser, error) {
+	if jc.Job.InputKey == "" {
+		return nil, errors.New("任务没有上传文件")
+	}
+	return storage.Store.Open(jc.Job.InputKey)
+}
+
+// WriteArtifact 生成任务结果文件，write 写入的内容直接保存到存储中
+func (jc *JobContext) WriteArtifact(name string, write func(w io.Writer) error) error {
+	key := fmt.Sprintf("jobs/%d/%s", jc.Job.ID, path.Base(name))
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		err := write(pw)
+		pw.CloseWithError(err)
+		errCh <- err
+	}()
+
+	_, saveErr := storage.Store.Save(key, pr)
+	// 保存失败时让写入方退出
+	pr.CloseWithError(saveErr)
+
+	if err := <-errCh; err != nil {
+		storage.Store.Delete(key)
+		return err
+	}
+	if saveErr != nil {
+		storage.Store.Delete(key)
+		return fmt.Errorf("保存结果文件失败: %v", saveErr)
+	}
+
+	jc.artifactKey = key
+	jc.artifactName = name
+	return nil
+}
+
+// cancelRequested 查询任务是否已被请求取消
+func (jc *JobContext) cancelRequested() bool {
+	var job model.Job
+	if err := database.DB.Select("cancel_requested").First(&job, jc.Job.ID).Error; err != nil {
+		return false
+	}
+	return job.CancelRequested
+}
+
+// discardArtifact 删除未完成任务已生成的结果文件
+func (jc *JobContext) discardArtifact() {
+	if jc.artifactKey != "" {
+		storage.Store.Delete(jc.artifactKey)
+		jc.artifactKey = ""
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"exam-system/internal/config"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/storage"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var Media = new(MediaService)
+
+type MediaService struct{}
+
+// 媒体文件访问路径前缀，对应路由 GET /api/v1/media/:id
+const mediaURLPrefix = "/api/v1/media/"
+
+// MediaURL 获取媒体文件的访问地址
+func MediaURL(id uint) string {
+	return fmt.Sprintf("%s%d", mediaURLPrefix, id)
+}
+
+// mediaKind 根据Content-Type获取媒体类别
+func mediaKind(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return "image"
+	case strings.HasPrefix(contentType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(contentType, "video/"):
+		return "video"
+	default:
+		return "file"
+	}
+}
+
+// detectContentType 根据文件内容识别类型，内容无法识别时参考扩展名
+func detectContentType(head []byte, fileName string) string {
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); byExt != "" {
+			contentType = byExt
+		}
+	}
+	if idx := strings.Index(contentType, ";"); idx >= 0 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+	return contentType
+}
+
+// isAllowedType 检查文件类型是否在允许列表中
+func isAllowedType(contentType string) bool {
+	for _, allowed := range config.GlobalConfig.Media.AllowedTypes {
+		if strings.EqualFold(allowed, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Upload 保存管理员上传的媒体文件
+func (s *MediaService) Upload(file *multipart.FileHeader, uploaderID uint) (*model.Media, error) {
+	maxBytes := config.GlobalConfig.Media.MaxSize * 1024 * 1024
+	if file.Size > maxBytes {
+		return nil, fmt.Errorf("文件大小不能超过%dMB", config.GlobalConfig.Media.MaxSize)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, errors.New("无法打开文件")
+	}
+	defer src.Close()
+
+	return s.Save(file.Filename, src, uploaderID)
+}
+
+// Save 校验文件类型和大小后写入存储后端，内容相同的文件只保存一份
+func (s *MediaService) Save(fileName string, r io.Reader, uploaderID uint) (*model.Media, error) {
+	media, _, err := s.save(database.DB, fileName, r, uploaderID)
+	return media, err
+}
+
+// save 在 db（可为事务）中保存媒体记录，created 表示是否新写入了文件，复用已有媒体时为false
+// 事务回滚后媒体记录随之撤销，调用方需删除新写入的文件
+func (s *MediaService) save(db *gorm.DB, fileName string, r io.Reader, uploaderID uint) (*model.Media, bool, error) {
+	cfg := config.GlobalConfig.Media
+	maxBytes := cfg.MaxSize * 1024 * 1024
+
+	// 读取文件头用于识别类型
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return nil, false, errors.New("文件内容为空")
+		}
+		return nil, false, fmt.Errorf("读取文件失败: %v", err)
+	}
+	head = head[:n]
+
+	contentType := detectContentType(head, fileName)
+	if !isAllowedType(contentType) {
+		return nil, false, fmt.Errorf("不支持的文件类型: %s", contentType)
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if exts, _ := mime.ExtensionsByType(contentType); ext == "" && len(exts) > 0 {
+		ext = exts[0]
+	}
+	key := fmt.Sprintf("media/%s/%s%s", time.Now().Format("200601"), uuid.New().String(), ext)
+
+	// 多读一个字节用于判断是否超出大小限制
+	hasher := sha256.New()
+	reader := io.TeeReader(io.LimitReader(io.MultiReader(bytes.NewReader(head), r), maxBytes+1), hasher)
+	size, err := storage.Store.Save(key, reader)
+	if err != nil {
+		return nil, false, err
+	}
+	if size > maxBytes {
+		storage.Store.Delete(key)
+		return nil, false, fmt.Errorf("文件大小不能超过%dMB", cfg.MaxSize)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	// 已存在相同内容的文件时直接复用
+	var existing model.Media
+	if err := db.Where("sha256 = ? AND content_type = ?", hash, contentType).First(&existing).Error; err == nil {
+		storage.Store.Delete(key)
+		return &existing, false, nil
+	}
+
+	media := &model.Media{
+		FileName:    filepath.Base(fileName),
+		StorageKey:  key,
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hash,
+		UploaderID:  uploaderID,
+	}
+	if err := db.Create(media).Error; err != nil {
+		storage.Store.Delete(key)
+		return nil, false, fmt.Errorf("保存媒体记录失败: %v", err)
+	}
+
+	return media, true, nil
+}
+
+// Open 打开媒体文件
+func (s *MediaService) Open(id uint) (*model.Media, io.ReadCloser, error) {
+	var media model.Media
+	if err := database.DB.First(&media, id).Error; err != nil {
+		return nil, nil, errors.New("媒体文件不存在")
+	}
+
+	reader, err := storage.Store.Open(media.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &media, reader, nil
+}
+
+// Delete 删除媒体文件，仍被题目引用或用作课程封面时不允许删除
+func (s *MediaService) Delete(id uint) error {
+	var media model.Media
+	if err := database.DB.First(&media, id).Error; err != nil {
+		return errors.New("媒体文件不存在")
+	}
+
+	var refCount int64
+	err := database.DB.Model(&model.Question{}).
+		Where("JSON_CONTAINS(stem_media, JSON_OBJECT('id', ?)) OR JSON_CONTAINS(explanation_media, JSON_OBJECT('id', ?)) OR JSON_CONTAINS(options, JSON_OBJECT('media', JSON_ARRAY(JSON_OBJECT('id', ?))))", id, id, id).
+		Count(&refCount).Error
+	if err != nil {
+		return fmt.Errorf("检查媒体引用失败: %v", err)
+	}
+	if refCount > 0 {
+		return fmt.Errorf("该媒体文件仍被%d道题目引用，无法删除", refCount)
+	}
+
+	// 课程封面以访问地址引用媒体文件
+	var coverCount int64
+	if err := database.DB.Model(&model.Course{}).Where("cover = ?", MediaURL(id)).Count(&coverCount).Error; err != nil {
+		return fmt.Errorf("检查媒体引用失败: %v", err)
+	}
+	if coverCount > 0 {
+		return fmt.Errorf("该媒体文件仍被%d门课程用作封面，无法删除", coverCount)
+	}
+
+	if err := database.DB.Delete(&media).Error; err != nil {
+		return fmt.Errorf("删除媒体记录失败: %v", err)
+	}
+	if err := storage.Store.Delete(media.StorageKey); err != nil {
+		return fmt.Errorf("删除媒体文件失败: %v", err)
+	}
+
+	return nil
+}
+
+// NormalizeRefs 校验引用的媒体是否存在，并根据媒体记录补全类型和访问地址
+func (s *MediaService) NormalizeRefs(refs model.MediaRefs) (model.MediaRefs, error) {
+	return s.normalizeRefs(database.DB, refs)
+}
+
+// normalizeRefs 在 db（可为事务）中查询媒体记录，导入时媒体与题目在同一事务中保存
+func (s *MediaService) normalizeRefs(db *gorm.DB, refs model.MediaRefs) (model.MediaRefs, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, 0, len(refs))
+	for _, ref := range refs {
+		ids = append(ids, ref.ID)
+	}
+
+	var medias []model.Media
+	if err := db.Where("id IN ?", ids).Find(&medias).Error; err != nil {
+		return nil, fmt.Errorf("查询媒体文件失败: %v", err)
+	}
+	mediaMap := make(map[uint]model.Media, len(medias))
+	for _, m := range medias {
+		mediaMap[m.ID] = m
+	}
+
+	result := make(model.MediaRefs, 0, len(refs))
+	for _, ref := range refs {
+		media, exists := mediaMap[ref.ID]
+		if !exists {
+			return nil, fmt.Errorf("媒体文件 %d 不存在", ref.ID)
+		}
+		result = append(result, model.MediaRef{
+			ID:   media.ID,
+			Type: mediaKind(media.ContentType),
+			URL:  MediaURL(media.ID),
+			Alt:  ref.Alt,
+		})
+	}
+
+	return result, nil
+}
+
+// NormalizeOptionRefs 校验并补全各选项中的媒体引用
+func (s *MediaService) NormalizeOptionRefs(options model.QuestionOptions) error {
+	return s.normalizeOptionRefs(database.DB, options)
+}
+
+func (s *MediaService) normalizeOptionRefs(db *gorm.DB, options model.QuestionOptions) error {
+	for i := range options {
+		if len(options[i].Media) == 0 {
+			continue
+		}
+		refs, err := s.normalizeRefs(db, options[i].Media)
+		if err != nil {
+			return fmt.Errorf("选项%s: %v", options[i].Label, err)
+		}
+		options[i].Media = refs
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var Notification = new(NotificationService)
+
+// NotificationService 用户站内通知
+type NotificationService struct{}
+
+// notifyTx 在事务中创建通知
+func (s *NotificationService) notifyTx(tx *gorm.DB, notifications []model.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(notifications, 200).Error
+}
+
+// List 获取用户通知列表，按时间倒序
+func (s *NotificationService) List(userId uint, unreadOnly bool, page, size int) ([]model.Notification, int64, error) {
+	db := database.DB.Model(&model.Notification{}).Where("user_id = ?", userId)
+	if unreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	notifications := make([]model.Notification, 0)
+	if err := db.Order("id DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&notifications).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, total, nil
+}
+
+// UnreadCount 获取未读通知数量
+func (s *NotificationService) UnreadCount(userId uint) (int64, error) {
+	var count int64
+	err := database.DB.Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userId).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkRead 将通知标记为已读，ids 为空时标记全部
+func (s *NotificationService) MarkRead(userId uint, ids []uint) (int64, error) {
+	db := database.DB.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userId)
+	if len(ids) > 0 {
+		db = db.Where("id IN ?", ids)
+	}
+
+	result := db.Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -18,6 +18,7 @@ type OrderListItem struct {
 	ID         uint      `json:"id"`
 	OrderNo    string    `json:"order_no"`
 	CourseID   uint      `json:"course_id"`
+	BundleID   uint      `json:"bundle_id"` // 套餐ID，课程订单为0
 	CourseName string    `json:"course_name"`
 	Price      float64   `json:"price"`
 	Status     string    `json:"status"`
@@ -29,6 +30,7 @@ type OrderDetail struct {
 	ID          uint       `json:"id"`
 	OrderNo     string     `json:"order_no"`
 	CourseID    uint       `json:"course_id"`
+	BundleID    uint       `json:"bundle_id"` // 套餐ID，课程订单为0
 	CourseName  string     `json:"course_name"`
 	Price       float64    `json:"price"`
 	Status      string     `json:"status"`
@@ -38,29 +40,22 @@ type OrderDetail struct {
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
-func (s *OrderService) Create(userId, courseId uint) (*model.Order, error) {
-	// 检查课程是否存在
-	var course model.Course
-	err := database.DB.First(&course, courseId).Error
+func (s *OrderService) Create(userId, courseId, planId uint) (*model.Order, error) {
+	// 检查课程是否存在，并按所选购买方案确定价格和有效期
+	terms, err := CoursePlan.Resolve(courseId, planId)
 	if err != nil {
-		return nil, errors.New("课程不存在")
+		return nil, err
 	}
 
-	// 检查是否已购买且未过期
-	var count int64
-	database.DB.Model(&model.Order{}).
-		Where("user_id = ? AND course_id = ? AND status = ?", userId, courseId, "paid").
-		Where("(expire_time IS NULL OR expire_time > ?)", time.Now()).
-		Count(&count)
-	if count > 0 {
-		return nil, errors.New("已购买该课程")
+	// 已永久拥有时无需再次购买，未到期时作为续费
+	if err := Entitlement.CheckRenewable(userId, courseId); err != nil {
+		return nil, err
 	}
 
-	// 计算过期时间
-	var expireTime *time.Time
-	if course.ExpireDays > 0 {
-		t := time.Now().AddDate(0, 0, course.ExpireDays)
-		expireTime = &t
+	// 计算过期时间，续费时在当前到期时间上叠加，支付时按支付时间重新计算
+	expireTime, err := Entitlement.RenewalExpireTime(database.DB, userId, courseId, terms.ExpireDays)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建订单时只设置必要的字段，不设置 pay_time
@@ -68,7 +63,8 @@ func (s *OrderService) Create(userId, courseId uint) (*model.Order, error) {
 		OrderNo:    generateOrderNo(),
 		UserID:     userId,
 		CourseID:   courseId,
-		Amount:     course.Price,
+		PlanID:     terms.PlanID,
+		Amount:     terms.Price,
 		Status:     "pending",
 		ExpireTime: expireTime, // 设置过期时间
 		// 不设置 PaymentType 和 PayTime，让它们保持 NULL
@@ -84,20 +80,16 @@ func (s *OrderService) Create(userId, courseId uint) (*model.Order, error) {
 
 func (s *OrderService) GetList(userId uint) ([]map[string]interface{}, error) {
 	var orders []model.Order
-	err := database.DB.Where("user_id = ?", userId).Find(&orders).Error
+	// 套餐子订单不单独展示
+	err := database.DB.Where("user_id = ? AND parent_order_id = 0", userId).Find(&orders).Error
 	if err != nil {
 		return nil, err
 	}
 
 	var result []map[string]interface{}
 	for _, order := range orders {
-		// 查询课程信息
-		var course model.Course
-		courseName := "未知课程" // 默认值
-		if err := database.DB.First(&course, order.CourseID).Error; err == nil {
-			// 拼接课程名称：CategoryLevel2-Name
-			courseName = course.CategoryLevel2 + "-" + course.Name
-		}
+		// 课程名称：分类名称-课程名称，套餐订单为套餐名称
+		courseName := s.ProductName(&order)
 
 		// 构建包含 CourseName 的响应，使用小写加下划线的字段名
 		result = append(result, map[string]interface{}{
@@ -105,11 +97,13 @@ func (s *OrderService) GetList(userId uint) ([]map[string]interface{}, error) {
 			"order_no":     order.OrderNo,
 			"user_id":      order.UserID,
 			"course_id":    order.CourseID,
+			"bundle_id":    order.BundleID,
 			"course_name":  courseName, // 添加课程名称
 			"amount":       order.Amount,
 			"status":       order.Status,
 			"payment_type": order.PaymentType,
 			"pay_time":     order.PayTime,
+			"expire_time":  order.ExpireTime, // 到期时间，续费时为叠加后的时间
 			"created_at":   order.CreatedAt,
 			"updated_at":   order.UpdatedAt,
 			"deleted_at":   order.DeletedAt,
@@ -126,13 +120,8 @@ func (s *OrderService) GetDetail(userId, orderId uint) (map[string]interface{},
 		return nil, errors.New("订单不存在")
 	}
 
-	// 查询课程信息
-	var course model.Course
-	courseName := "未知课程" // 默认值
-	if err := database.DB.First(&course, order.CourseID).Error; err == nil {
-		// 拼接课程名称：CategoryLevel2-Name
-		courseName = course.CategoryLevel2 + "-" + course.Name
-	}
+	// 课程名称：分类名称-课程名称，套餐订单为套餐名称
+	courseName := s.ProductName(&order)
 
 	// 构建包含 CourseName 的响应，使用小写加下划线的字段名
 	result := map[string]interface{}{
@@ -140,11 +129,13 @@ func (s *OrderService) GetDetail(userId, orderId uint) (map[string]interface{},
 		"order_no":     order.OrderNo,
 		"user_id":      order.UserID,
 		"course_id":    order.CourseID,
+		"bundle_id":    order.BundleID,
 		"course_name":  courseName, // 添加课程名称
 		"amount":       order.Amount,
 		"status":       order.Status,
 		"payment_type": order.PaymentType,
 		"pay_time":     order.PayTime,
+		"expire_time":  order.ExpireTime, // 到期时间，续费时为叠加后的时间
 		"created_at":   order.CreatedAt,
 		"updated_at":   order.UpdatedAt,
 		"deleted_at":   order.DeletedAt,
@@ -153,54 +144,60 @@ func (s *OrderService) GetDetail(userId, orderId uint) (map[string]interface{},
 	return result, nil
 }
 
+// ProductName 订单商品名称：套餐订单为套餐名称，课程订单为 分类名称-课程名称
+func (s *OrderService) ProductName(order *model.Order) string {
+	if order.BundleID > 0 && order.ParentOrderID == 0 {
+		var bundle model.Bundle
+		if err := database.DB.Unscoped().First(&bundle, order.BundleID).Error; err == nil {
+			return bundle.Name
+		}
+		return "未知套餐"
+	}
+	var course model.Course
+	if err := database.DB.First(&course, order.CourseID).Error; err == nil {
+		return CourseCategory.CourseDisplayName(&course)
+	}
+	return "未知课程"
+}
+
 // 生成订单号
 func generateOrderNo() string {
 	return time.Now().Format("20060102150405") + fmt.Sprintf("%06d", rand.Intn(1000000))
 }
 
+// GenerateOrderNo 生成订单号，接口层直接创建订单（如卡券兑换）时使用
+func (s *OrderService) GenerateOrderNo() string {
+	return generateOrderNo()
+}
+
 // 获取订单列表
 func (s *OrderService) GetOrderList(userId uint, page, size int) ([]OrderListItem, int64, error) {
 	var orders []model.Order
 	var total int64
 
 	offset := (page - 1) * size
-	if err := database.DB.Model(&model.Order{}).Where("user_id = ?", userId).Count(&total).Error; err != nil {
+	// 套餐子订单不单独展示
+	query := database.DB.Model(&model.Order{}).Where("user_id = ? AND parent_order_id = 0", userId)
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	if err := database.DB.Where("user_id = ?", userId).Order("created_at desc").Offset(offset).Limit(size).Find(&orders).Error; err != nil {
+	if err := query.Order("created_at desc").Offset(offset).Limit(size).Find(&orders).Error; err != nil {
 		return nil, 0, err
 	}
 
 	var result []OrderListItem
 	for _, order := range orders {
-		// 查询课程信息
-		var course model.Course
-		if err := database.DB.First(&course, order.CourseID).Error; err == nil {
-			// 拼接课程名称：CategoryLevel2-Name
-			courseName := course.CategoryLevel2 + "-" + course.Name
-
-			result = append(result, OrderListItem{
-				ID:         order.ID,
-				OrderNo:    order.OrderNo,
-				CourseID:   order.CourseID,
-				CourseName: courseName,   // 设置拼接后的课程名称
-				Price:      order.Amount, // 使用 Amount 字段
-				Status:     order.Status, // Status 已经是 string 类型
-				CreatedAt:  order.CreatedAt,
-			})
-		} else {
-			// 如果课程不存在，仍然添加订单，但课程名称为空
-			result = append(result, OrderListItem{
-				ID:         order.ID,
-				OrderNo:    order.OrderNo,
-				CourseID:   order.CourseID,
-				CourseName: "未知课程",       // 课程不存在时的默认值
-				Price:      order.Amount, // 使用 Amount 字段
-				Status:     order.Status, // Status 已经是 string 类型
-				CreatedAt:  order.CreatedAt,
-			})
-		}
+		result = append(result, OrderListItem{
+			ID:         order.ID,
+			OrderNo:    order.OrderNo,
+			CourseID:   order.CourseID,
+			BundleID:   order.BundleID,
+			CourseName: s.ProductName(&order), // 分类名称-课程名称，套餐订单为套餐名称
+			Price:      order.Amount,          // 使用 Amount 字段
+			Status:     order.Status,          // Status 已经是 string 类型
+			CreatedAt:  order.CreatedAt,
+		})
 	}
 
 	return result, total, nil
@@ -213,18 +210,14 @@ func (s *OrderService) GetOrderDetail(userId uint, orderId uint) (*OrderDetail,
 		return nil, errors.New("订单不存在")
 	}
 
-	// 查询课程信息
-	var course model.Course
-	courseName := "未知课程" // 默认值
-	if err := database.DB.First(&course, order.CourseID).Error; err == nil {
-		// 拼接课程名称：CategoryLevel2-Name
-		courseName = course.CategoryLevel2 + "-" + course.Name
-	}
+	// 课程名称：分类名称-课程名称，套餐订单为套餐名称
+	courseName := s.ProductName(&order)
 
 	return &OrderDetail{
 		ID:          order.ID,
 		OrderNo:     order.OrderNo,
 		CourseID:    order.CourseID,
+		BundleID:    order.BundleID,
 		CourseName:  courseName,   // 设置拼接后的课程名称
 		Price:       order.Amount, // 使用 Amount 字段
 		Status:      order.Status, // Status 已经是 string 类型
@@ -12,9 +12,11 @@ import (
 	"exam-system/internal/config"
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/logger"
 	"exam-system/internal/pkg/payment"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -24,6 +26,8 @@ import (
 	"time"
 
 	"golang.org/x/crypto/pkcs12"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var Payment = new(PaymentService)
@@ -40,8 +44,8 @@ type WXPayParams struct {
 	PaySign   string `json:"paySign"`
 }
 
-// 统一下单
-func (s *PaymentService) Create(userID uint, courseID string, totalFee int, openID string, existingOrderNo string) (*payment.WXPayParams, *model.Order, error) {
+// 统一下单，金额以所选购买方案的价格为准；价格为0时直接开通，此时返回的支付参数为 nil
+func (s *PaymentService) Create(userID uint, courseID string, planID uint, openID string, existingOrderNo string) (*payment.WXPayParams, *model.Order, error) {
 	// 获取配置
 	if config.GlobalConfig == nil {
 		return nil, nil, errors.New("配置未初始化")
@@ -65,7 +69,7 @@ func (s *PaymentService) Create(userID uint, courseID string, totalFee int, open
 		order = &existingOrder
 	} else {
 		// 生成新订单号
-		orderNo := fmt.Sprintf("%s%d", time.Now().Format("20060102150405"), userID)
+		orderNo := generateOrderNo()
 
 		// 将课程ID转换为uint
 		courseIDUint, err := strconv.ParseUint(courseID, 10, 32)
@@ -73,12 +77,24 @@ func (s *PaymentService) Create(userID uint, courseID string, totalFee int, open
 			return nil, nil, fmt.Errorf("课程ID格式错误: %v", err)
 		}
 
+		// 按所选购买方案确定价格
+		terms, err := CoursePlan.Resolve(uint(courseIDUint), planID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// 已永久拥有时无需再次购买，未到期时作为续费
+		if err := Entitlement.CheckRenewable(userID, uint(courseIDUint)); err != nil {
+			return nil, nil, err
+		}
+
 		// 创建新订单
 		order = &model.Order{
 			OrderNo:  orderNo,
 			UserID:   userID,
 			CourseID: uint(courseIDUint),
-			Amount:   float64(totalFee) / 100, // 转换为元
+			PlanID:   terms.PlanID,
+			Amount:   terms.Price,
 			Status:   "pending",
 		}
 
@@ -87,6 +103,13 @@ func (s *PaymentService) Create(userID uint, courseID string, totalFee int, open
 		}
 	}
 
+	// 免费方案直接开通
+	totalFee := int(math.Round(order.Amount * 100)) // 转换为分
+	if totalFee == 0 {
+		order, err := s.CreateFreeOrder(userID, "", 0, order.OrderNo)
+		return nil, order, err
+	}
+
 	// 生成支付参数
 	params, err := WeChat.GeneratePayParams(order.OrderNo, totalFee, openID)
 	if err != nil {
@@ -170,45 +193,76 @@ func (s *PaymentService) HandleNotify(notify *payment.WXPayNotify) error {
 	}
 	fmt.Printf("找到订单: %+v\n", order)
 
-	// 查找课程信息以获取有效期
-	var course model.Course
-	fmt.Printf("查找课程: CourseID=%d\n", order.CourseID)
-	if err := database.DB.First(&course, order.CourseID).Error; err != nil {
-		fmt.Printf("错误: 课程不存在，err=%v\n", err)
-		return fmt.Errorf("课程不存在: %v", err)
-	}
-	fmt.Printf("找到课程: %+v\n", course)
-
-	// 计算过期时间
-	var expireTime *time.Time
-	if course.ExpireDays > 0 {
-		t := time.Now().AddDate(0, 0, course.ExpireDays)
-		expireTime = &t
-		fmt.Printf("设置过期时间: %v\n", *expireTime)
-	} else {
-		fmt.Println("课程无过期时间")
+	// 重复通知不再处理，避免续费时重复叠加有效期；并发的重复通知由 fulfill 中的行锁排除
+	if order.Status == "paid" {
+		fmt.Println("订单已支付，忽略重复通知")
+		return nil
 	}
 
-	// 更新订单状态
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":       "paid",
-		"pay_time":     &now,
-		"payment_type": "wechat",
-		"expire_time":  expireTime,
+	// 套餐订单：按套餐有效期开通其中的所有课程
+	if order.BundleID > 0 {
+		if err := Bundle.Fulfill(order.ID, "wechat"); err != nil {
+			logger.Errorf("订单 %s 开通套餐失败: %v", order.OrderNo, err)
+			return err
+		}
+		return nil
 	}
-	fmt.Printf("更新订单数据: %+v\n", updates)
 
-	if err := database.DB.Model(&order).Updates(updates).Error; err != nil {
-		fmt.Printf("错误: 更新订单状态失败，err=%v\n", err)
-		return errors.New("更新订单状态失败")
+	if _, err := s.fulfill(order.ID, "wechat"); err != nil {
+		logger.Errorf("订单 %s 开通课程失败: %v", order.OrderNo, err)
+		return err
 	}
-	fmt.Println("订单更新成功")
 
 	fmt.Println("=== 支付回调处理完成 ===")
 	return nil
 }
 
+// fulfill 将课程订单标记为已支付并开通课程权限，返回更新后的订单
+// 锁定订单记录，已支付的订单不再处理，并发的重复通知不会重复叠加有效期
+func (s *PaymentService) fulfill(orderID uint, paymentType string) (*model.Order, error) {
+	var order model.Order
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return errors.New("订单不存在")
+		}
+		if order.Status == "paid" {
+			return nil
+		}
+
+		// 按下单时选择的购买方案获取有效期
+		expireDays, err := CoursePlan.OrderExpireDays(&order)
+		if err != nil {
+			return err
+		}
+
+		// 计算过期时间，用户仍有未到期的权限时在其到期时间上叠加
+		expireTime, err := Entitlement.RenewalExpireTime(tx, order.UserID, order.CourseID, expireDays)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&order).Updates(map[string]interface{}{
+			"status":       "paid",
+			"pay_time":     &now,
+			"payment_type": paymentType,
+			"expire_time":  expireTime,
+		}).Error; err != nil {
+			return errors.New("更新订单状态失败")
+		}
+
+		// 开通课程权限
+		if err := Entitlement.SyncOrder(tx, order.ID); err != nil {
+			return fmt.Errorf("开通课程权限失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
 // 查询支付结果
 func (s *PaymentService) Query(userID uint, orderNo string) (map[string]interface{}, error) {
 	var order model.Order
@@ -278,8 +332,79 @@ func (s *PaymentService) CancelPayment(userID uint, orderNo string) error {
 	return nil
 }
 
-// CreateFreeOrder 创建免费订单并直接标记为已支付
-func (s *PaymentService) CreateFreeOrder(userID uint, courseID string, existingOrderNo string) (*model.Order, error) {
+// CreateBundle 购买套餐，金额以套餐价格为准；免费套餐直接开通，此时返回的支付参数为 nil
+func (s *PaymentService) CreateBundle(userID, bundleID uint, openID string, existingOrderNo string) (*payment.WXPayParams, *model.Order, error) {
+	var order *model.Order
+
+	// 如果提供了订单号，尝试查找已有订单
+	if existingOrderNo != "" {
+		var existingOrder model.Order
+		if err := database.DB.Where("order_no = ? AND user_id = ? AND bundle_id = ?", existingOrderNo, userID, bundleID).
+			First(&existingOrder).Error; err != nil {
+			return nil, nil, errors.New("订单不存在")
+		}
+
+		// 检查订单状态是否允许重新支付
+		if existingOrder.Status != "unpaid" && existingOrder.Status != "pending" {
+			return nil, nil, errors.New("订单状态不允许重新支付")
+		}
+
+		// 使用已有订单
+		order = &existingOrder
+	} else {
+		bundle, err := Bundle.CheckPurchasable(userID, bundleID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// 创建新订单
+		order = &model.Order{
+			OrderNo:  generateOrderNo(),
+			UserID:   userID,
+			BundleID: bundle.ID,
+			Amount:   bundle.Price,
+			Status:   "pending",
+		}
+
+		if err := database.DB.Create(order).Error; err != nil {
+			return nil, nil, fmt.Errorf("保存订单失败: %v", err)
+		}
+	}
+
+	// 免费套餐直接开通
+	totalFee := int(math.Round(order.Amount * 100))
+	if totalFee == 0 {
+		order, err := s.CreateFreeOrder(userID, "", 0, order.OrderNo)
+		return nil, order, err
+	}
+
+	// 获取配置
+	if config.GlobalConfig == nil {
+		return nil, nil, errors.New("配置未初始化")
+	}
+
+	// 生成支付参数
+	params, err := WeChat.GeneratePayParams(order.OrderNo, totalFee, openID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成支付参数失败: %v", err)
+	}
+
+	return params, order, nil
+}
+
+// updateStatus 更新订单状态，套餐订单同时更新其子订单，并同步课程权限
+func (s *PaymentService) updateStatus(order *model.Order, status string) error {
+	if err := database.DB.Model(order).Update("status", status).Error; err != nil {
+		return err
+	}
+	if err := Bundle.SyncChildren(order, order.ExpireTime, map[string]interface{}{"status": status}); err != nil {
+		return err
+	}
+	return Entitlement.SyncOrder(database.DB, order.ID)
+}
+
+// CreateFreeOrder 创建免费订单并直接标记为已支付，所选购买方案的价格必须为0
+func (s *PaymentService) CreateFreeOrder(userID uint, courseID string, planID uint, existingOrderNo string) (*model.Order, error) {
 	var order *model.Order
 
 	// 如果提供了订单号，尝试查找已有订单
@@ -298,7 +423,7 @@ func (s *PaymentService) CreateFreeOrder(userID uint, courseID string, existingO
 		order = &existingOrder
 	} else {
 		// 生成新订单号
-		orderNo := fmt.Sprintf("%s%d", time.Now().Format("20060102150405"), userID)
+		orderNo := generateOrderNo()
 
 		// 将课程ID转换为uint
 		courseIDUint, err := strconv.ParseUint(courseID, 10, 32)
@@ -306,11 +431,26 @@ func (s *PaymentService) CreateFreeOrder(userID uint, courseID string, existingO
 			return nil, fmt.Errorf("课程ID格式错误: %v", err)
 		}
 
+		// 按所选购买方案确定价格
+		terms, err := CoursePlan.Resolve(uint(courseIDUint), planID)
+		if err != nil {
+			return nil, err
+		}
+		if terms.Price > 0 {
+			return nil, errors.New("该课程需要支付后开通")
+		}
+
+		// 已永久拥有时无需再次开通，未到期时作为续费
+		if err := Entitlement.CheckRenewable(userID, uint(courseIDUint)); err != nil {
+			return nil, err
+		}
+
 		// 创建新订单
 		order = &model.Order{
 			OrderNo:  orderNo,
 			UserID:   userID,
 			CourseID: uint(courseIDUint),
+			PlanID:   terms.PlanID,
 			Amount:   0, // 免费课程金额为0
 			Status:   "pending",
 		}
@@ -320,33 +460,21 @@ func (s *PaymentService) CreateFreeOrder(userID uint, courseID string, existingO
 		}
 	}
 
-	// 查找课程信息以获取有效期
-	var course model.Course
-	if err := database.DB.First(&course, order.CourseID).Error; err != nil {
-		return nil, fmt.Errorf("课程不存在: %v", err)
+	if order.Amount > 0 {
+		return nil, errors.New("该订单需要支付后开通")
 	}
 
-	// 计算过期时间
-	var expireTime *time.Time
-	if course.ExpireDays > 0 {
-		t := time.Now().AddDate(0, 0, course.ExpireDays)
-		expireTime = &t
-	}
-
-	// 更新订单状态为已支付
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":       "paid",
-		"pay_time":     &now,
-		"payment_type": "free", // 标记为免费支付
-		"expire_time":  expireTime,
-	}
-
-	if err := database.DB.Model(order).Updates(updates).Error; err != nil {
-		return nil, errors.New("更新订单状态失败")
+	// 套餐订单：按套餐有效期开通其中的所有课程
+	if order.BundleID > 0 {
+		if err := Bundle.Fulfill(order.ID, "free"); err != nil {
+			return nil, err
+		}
+		order.Status = "paid"
+		return order, nil
 	}
 
-	return order, nil
+	// 标记为免费支付并开通课程权限
+	return s.fulfill(order.ID, "free")
 }
 
 // Refund 申请退款
@@ -472,7 +600,7 @@ func (s *PaymentService) Refund(adminID uint, orderNo string, refundFee float64,
 	}
 
 	// 更新订单状态，只更新status字段
-	if err := database.DB.Model(&order).Update("status", "refunding").Error; err != nil {
+	if err := s.updateStatus(&order, "refunding"); err != nil {
 		return nil, errors.New("更新订单状态失败")
 	}
 
@@ -577,7 +705,7 @@ func (s *PaymentService) HandleRefundNotify(notify *payment.WXRefundNotify) erro
 	}
 
 	// 只更新status字段
-	if err := database.DB.Model(&order).Update("status", status).Error; err != nil {
+	if err := s.updateStatus(&order, status); err != nil {
 		return errors.New("更新订单状态失败")
 	}
 
@@ -671,11 +799,11 @@ func (s *PaymentService) QueryRefund(adminID uint, orderNo string) (map[string]i
 
 	// 更新订单状态，只更新status字段
 	if queryResp.RefundStatus0 == "SUCCESS" && order.Status != "refunded" {
-		if err := database.DB.Model(&order).Update("status", "refunded").Error; err != nil {
+		if err := s.updateStatus(&order, "refunded"); err != nil {
 			return nil, errors.New("更新订单状态失败")
 		}
 	} else if queryResp.RefundStatus0 == "FAIL" && order.Status != "refund_failed" {
-		if err := database.DB.Model(&order).Update("status", "refund_failed").Error; err != nil {
+		if err := s.updateStatus(&order, "refund_failed"); err != nil {
 			return nil, errors.New("更新订单状态失败")
 		}
 	}
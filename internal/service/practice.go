@@ -1,14 +1,16 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"exam-system/internal/config"
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
 	"fmt"
-	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 var Practice = new(PracticeService)
@@ -17,15 +19,18 @@ type PracticeService struct{}
 
 // 错题详情
 type WrongQuestionDetail struct {
-	ID          uint                   `json:"id"`
-	Type        string                 `json:"type"` // 题目类型
-	Question    string                 `json:"question"`
-	Options     []model.QuestionOption `json:"options"`
-	Answer      string                 `json:"answer"`
-	Explanation string                 `json:"explanation"`
-	UpdatedAt   time.Time              `json:"updated_at"`
-	CourseID    uint                   `json:"course_id"`
-	CourseName  string                 `json:"course_name"` // 新增字段：课程名称
+	ID               uint                   `json:"id"`
+	Type             string                 `json:"type"` // 题目类型
+	Question         string                 `json:"question"`
+	StemMedia        model.MediaRefs        `json:"stem_media"`
+	Options          []model.QuestionOption `json:"options"`
+	Answer           string                 `json:"answer"`
+	Explanation      string                 `json:"explanation"`
+	ExplanationMedia model.MediaRefs        `json:"explanation_media"`
+	AIExplanation    *AIExplanationView     `json:"ai_explanation,omitempty"` // 没有正式解析时返回待审核的AI解析
+	UpdatedAt        time.Time              `json:"updated_at"`
+	CourseID         uint                   `json:"course_id"`
+	CourseName       string                 `json:"course_name"` // 新增字段：课程名称
 }
 
 // 错题统计信息（课程维度）
@@ -38,15 +43,13 @@ type WrongQuestionCourse struct {
 	Total      int    `json:"total"`
 }
 
-// 获取错题列表 - 重构版本
-func (s *PracticeService) GetWrongQuestions(userId uint, courseId int, page, pageSize int) ([]WrongQuestionDetail, int64, error) {
-	// 1. 查询用户的所有考试记录，并关联订单表检查过期时间
+// 获取错题列表 - 重构版本，可按章或小节筛选
+func (s *PracticeService) GetWrongQuestions(userId uint, courseId int, chapter ChapterFilter, page, pageSize int) ([]WrongQuestionDetail, int64, error) {
+	// 1. 查询用户的所有考试记录，只保留仍有课程权限的课程
 	var records []model.ExamRecord
 	query := database.DB.Table("exam_records").
-		Joins("LEFT JOIN orders ON exam_records.course_id = orders.course_id AND exam_records.user_id = orders.user_id").
 		Where("exam_records.user_id = ?", userId).
-		Where("orders.status = ?", "paid").
-		Where("(orders.expire_time IS NULL OR orders.expire_time > ?)", time.Now())
+		Where("exam_records.course_id IN (?)", Entitlement.Courses(userId))
 
 	// 如果指定了课程ID，则过滤特定课程的记录
 	if courseId > 0 {
@@ -82,6 +85,12 @@ func (s *PracticeService) GetWrongQuestions(userId uint, courseId int, page, pag
 		wrongQuestionIds = append(wrongQuestionIds, id)
 	}
 
+	// 按章节筛选
+	wrongQuestionIds, err = filterByChapter(wrongQuestionIds, uint(courseId), chapter)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	// 4. 计算总数
 	total := int64(len(wrongQuestionIds))
 
@@ -112,19 +121,21 @@ func (s *PracticeService) GetWrongQuestions(userId uint, courseId int, page, pag
 
 	// 6. 使用自定义查询获取题目信息
 	type RawQuestion struct {
-		ID          uint      `json:"id"`
-		Type        string    `json:"type"`
-		Question    string    `json:"question"`
-		Options     string    `json:"options"` // 先保留为原始字符串
-		Answer      string    `json:"answer"`
-		Explanation string    `json:"explanation"`
-		CourseID    uint      `json:"course_id"`
-		UpdatedAt   time.Time `json:"updated_at"`
+		ID               uint            `json:"id"`
+		Type             string          `json:"type"`
+		Question         string          `json:"question"`
+		StemMedia        model.MediaRefs `json:"stem_media"`
+		Options          string          `json:"options"` // 先保留为原始字符串
+		Answer           string          `json:"answer"`
+		Explanation      string          `json:"explanation"`
+		ExplanationMedia model.MediaRefs `json:"explanation_media"`
+		CourseID         uint            `json:"course_id"`
+		UpdatedAt        time.Time       `json:"updated_at"`
 	}
 
 	var rawQuestions []RawQuestion
 	err = database.DB.Table("questions").
-		Select("id, type, question, options, answer, explanation, course_id, updated_at").
+		Select("id, type, question, stem_media, options, answer, explanation, explanation_media, course_id, updated_at").
 		Where("id IN ?", pageIds).
 		Where("deleted_at IS NULL").
 		Find(&rawQuestions).Error
@@ -133,13 +144,6 @@ func (s *PracticeService) GetWrongQuestions(userId uint, courseId int, page, pag
 		return nil, 0, err
 	}
 
-	// 获取课程信息用于构建课程名称
-	type CourseInfo struct {
-		ID             uint   `json:"id"`
-		CategoryLevel2 string `json:"category_level2"`
-		Name           string `json:"name"`
-	}
-
 	// 收集所有需要的课程ID
 	courseIdsMap := make(map[uint]bool)
 	for _, q := range rawQuestions {
@@ -152,129 +156,14 @@ func (s *PracticeService) GetWrongQuestions(userId uint, courseId int, page, pag
 		courseIds = append(courseIds, id)
 	}
 
-	// 查询课程信息
-	var courses []CourseInfo
-	database.DB.Table("courses").
-		Select("id, category_level2, name").
-		Where("id IN ?", courseIds).
-		Find(&courses)
-
-	// 构建课程ID到课程名称的映射
-	courseNameMap := make(map[uint]string)
-	for _, course := range courses {
-		// 拼接课程名称：CategoryLevel2-Name
-		courseName := course.Name
-		if course.CategoryLevel2 != "" {
-			courseName = course.CategoryLevel2 + "-" + course.Name
-		}
-		courseNameMap[course.ID] = courseName
-	}
+	// 课程ID到课程名称（分类名称-课程名称）的映射
+	courseNameMap := CourseCategory.CourseDisplayNames(courseIds)
 
-	// 7. 转换为响应格式，手动处理options字段并过滤空选项
+	// 7. 转换为响应格式
 	var result []WrongQuestionDetail
 	for _, q := range rawQuestions {
-		// 处理options字段
-		var options []model.QuestionOption
-
-		// 尝试不同的方式解析options
-		if q.Options != "" {
-			// 1. 先尝试直接解析为QuestionOption数组
-			err := json.Unmarshal([]byte(q.Options), &options)
-
-			// 2. 如果失败，尝试解析为字符串数组
-			if err != nil {
-				var optStrings []string
-				if err = json.Unmarshal([]byte(q.Options), &optStrings); err == nil {
-					// 清空options以确保不会添加空选项
-					options = []model.QuestionOption{}
-
-					for i, optText := range optStrings {
-						// 跳过空字符串
-						if strings.TrimSpace(optText) == "" {
-							continue
-						}
-
-						// 处理格式为 "A.选项文本" 的情况
-						parts := strings.SplitN(optText, ".", 2)
-						if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
-							options = append(options, model.QuestionOption{
-								Label: parts[0],
-								Text:  strings.TrimSpace(parts[1]),
-							})
-						} else {
-							// 如果没有分隔符，使用索引创建标签
-							label := string(rune('A' + i))
-							options = append(options, model.QuestionOption{
-								Label: label,
-								Text:  strings.TrimSpace(optText),
-							})
-						}
-					}
-				} else {
-					// 3. 如果仍然失败，提供默认选项
-					options = []model.QuestionOption{} // 重置选项数组
-
-					if q.Type == "judge" {
-						// 判断题默认有两个选项
-						options = append(options, model.QuestionOption{Label: "A", Text: "正确"})
-						options = append(options, model.QuestionOption{Label: "B", Text: "错误"})
-					} else {
-						// 其他题型提供4个默认选项
-						for i := 0; i < 4; i++ {
-							label := string(rune('A' + i))
-							options = append(options, model.QuestionOption{
-								Label: label,
-								Text:  "选项" + label,
-							})
-						}
-					}
-				}
-			} else {
-				// 解析成功，现在过滤掉空选项
-				filteredOptions := []model.QuestionOption{}
-				for _, opt := range options {
-					if opt.Label != "" && strings.TrimSpace(opt.Text) != "" {
-						filteredOptions = append(filteredOptions, model.QuestionOption{
-							Label: opt.Label,
-							Text:  strings.TrimSpace(opt.Text),
-						})
-					}
-				}
-				options = filteredOptions
-			}
-		} else {
-			// 如果选项为空，提供默认选项
-			options = []model.QuestionOption{} // 重置确保干净
-
-			if q.Type == "judge" {
-				options = append(options, model.QuestionOption{Label: "A", Text: "正确"})
-				options = append(options, model.QuestionOption{Label: "B", Text: "错误"})
-			} else {
-				for i := 0; i < 4; i++ {
-					label := string(rune('A' + i))
-					options = append(options, model.QuestionOption{
-						Label: label,
-						Text:  "选项" + label,
-					})
-				}
-			}
-		}
-
-		// 如果经过处理后没有有效选项，则添加默认选项
-		if len(options) == 0 {
-			if q.Type == "judge" {
-				options = append(options, model.QuestionOption{Label: "A", Text: "正确"})
-				options = append(options, model.QuestionOption{Label: "B", Text: "错误"})
-			} else {
-				for i := 0; i < 4; i++ {
-					label := string(rune('A' + i))
-					options = append(options, model.QuestionOption{
-						Label: label,
-						Text:  "选项" + label,
-					})
-				}
-			}
-		}
+		// 解析选项，无法解析的题目由 normalize-options 命令报告
+		options, _ := model.DecodeQuestionOptions(q.Type, q.Options)
 
 		// 获取课程名称，如果找不到则使用默认值
 		courseName := "未知课程"
@@ -283,30 +172,51 @@ func (s *PracticeService) GetWrongQuestions(userId uint, courseId int, page, pag
 		}
 
 		result = append(result, WrongQuestionDetail{
-			ID:          q.ID,
-			Type:        q.Type,
-			Question:    q.Question,
-			Options:     options,
-			Answer:      q.Answer,
-			Explanation: q.Explanation,
-			UpdatedAt:   q.UpdatedAt,
-			CourseID:    q.CourseID,
-			CourseName:  courseName, // 添加课程名称
+			ID:               q.ID,
+			Type:             q.Type,
+			Question:         q.Question,
+			StemMedia:        q.StemMedia,
+			Options:          options,
+			Answer:           q.Answer,
+			Explanation:      q.Explanation,
+			ExplanationMedia: q.ExplanationMedia,
+			UpdatedAt:        q.UpdatedAt,
+			CourseID:         q.CourseID,
+			CourseName:       courseName, // 添加课程名称
 		})
 	}
+	attachAIExplanations(len(result), func(i int) (uint, string) {
+		return result[i].ID, result[i].Explanation
+	}, func(i int, view *AIExplanationView) {
+		result[i].AIExplanation = view
+	})
 
 	return result, total, nil
 }
 
+// filterByChapter 只保留属于指定章或小节的错题，未指定章节时原样返回
+func filterByChapter(questionIds []uint, courseId uint, chapter ChapterFilter) ([]uint, error) {
+	if chapter.IsEmpty() || len(questionIds) == 0 {
+		return questionIds, nil
+	}
+	query, err := Chapter.Apply(database.DB.Model(&model.Question{}).Where("id IN ?", questionIds), courseId, chapter)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []uint
+	if err := query.Pluck("id", &filtered).Error; err != nil {
+		return nil, err
+	}
+	return filtered, nil
+}
+
 // 获取错题统计信息
 func (s *PracticeService) GetWrongQuestionsStats(userId uint) ([]WrongQuestionCourse, int64, error) {
-	// 1. 查询用户的所有考试记录，并关联订单表检查过期时间
+	// 1. 查询用户的所有考试记录，只保留仍有课程权限的课程
 	var records []model.ExamRecord
 	err := database.DB.Table("exam_records").
-		Joins("LEFT JOIN orders ON exam_records.course_id = orders.course_id AND exam_records.user_id = orders.user_id").
 		Where("exam_records.user_id = ?", userId).
-		Where("orders.status = ?", "paid").
-		Where("(orders.expire_time IS NULL OR orders.expire_time > ?)", time.Now()).
+		Where("exam_records.course_id IN (?)", Entitlement.Courses(userId)).
 		Find(&records).Error
 	if err != nil {
 		return nil, 0, err
@@ -347,28 +257,8 @@ func (s *PracticeService) GetWrongQuestionsStats(userId uint) ([]WrongQuestionCo
 		courseIds = append(courseIds, courseId)
 	}
 
-	// 4. 查询课程信息
-	type CourseInfo struct {
-		ID             uint   `json:"id"`
-		CategoryLevel2 string `json:"category_level2"`
-		Name           string `json:"name"`
-	}
-
-	var courses []CourseInfo
-	database.DB.Table("courses").
-		Select("id, category_level2, name").
-		Where("id IN ?", courseIds).
-		Find(&courses)
-
-	// 构建课程ID到课程名称的映射
-	courseNameMap := make(map[uint]string)
-	for _, course := range courses {
-		courseName := course.Name
-		if course.CategoryLevel2 != "" {
-			courseName = course.CategoryLevel2 + "-" + course.Name
-		}
-		courseNameMap[course.ID] = courseName
-	}
+	// 4. 课程ID到课程名称（分类名称-课程名称）的映射
+	courseNameMap := CourseCategory.CourseDisplayNames(courseIds)
 
 	// 5. 为每个课程查询错题类型统计
 	var result []WrongQuestionCourse
@@ -439,14 +329,17 @@ func (s *PracticeService) GetWrongQuestionsStats(userId uint) ([]WrongQuestionCo
 
 // 提交练习答案
 func (s *PracticeService) Submit(userId uint, questionId uint, answer []string) (bool, error) {
-	var question model.Question
-	err := database.DB.First(&question, questionId).Error
+	question, err := s.accessibleQuestion(userId, questionId)
 	if err != nil {
 		return false, err
 	}
 
 	// 检查答案是否正确
-	if !compareAnswers(question.Answer, answer) {
+	correct := compareAnswers(question.Answer, answer)
+	ItemAnalysis.RecordPracticeAnswer(userId, question, answer, correct)
+	Trial.RecordAnswer(userId, question)
+
+	if !correct {
 		// 答案错误，记录到当前用户的最近一次考试记录中
 		var latestRecord model.ExamRecord
 		err := database.DB.Where("user_id = ?", userId).
@@ -502,15 +395,13 @@ func (s *PracticeService) ClearWrongQuestions(userId uint) error {
 		Update("wrong_answers", "[]").Error
 }
 
-// 获取特定课程的所有错题（不分页）
-func (s *PracticeService) GetAllWrongQuestionsByCourse(userId uint, courseId int) ([]WrongQuestionDetail, int64, error) {
-	// 1. 查询用户的指定课程的所有考试记录，并关联订单表检查过期时间
+// 获取特定课程的所有错题（不分页），可按章或小节筛选
+func (s *PracticeService) GetAllWrongQuestionsByCourse(userId uint, courseId int, chapter ChapterFilter) ([]WrongQuestionDetail, int64, error) {
+	// 1. 查询用户的指定课程的所有考试记录，只保留仍有课程权限的课程
 	var records []model.ExamRecord
 	query := database.DB.Table("exam_records").
-		Joins("LEFT JOIN orders ON exam_records.course_id = orders.course_id AND exam_records.user_id = orders.user_id").
 		Where("exam_records.user_id = ? AND exam_records.course_id = ?", userId, courseId).
-		Where("orders.status = ?", "paid").
-		Where("(orders.expire_time IS NULL OR orders.expire_time > ?)", time.Now())
+		Where("exam_records.course_id IN (?)", Entitlement.Courses(userId))
 
 	err := query.Find(&records).Error
 	if err != nil {
@@ -541,6 +432,12 @@ func (s *PracticeService) GetAllWrongQuestionsByCourse(userId uint, courseId int
 		wrongQuestionIds = append(wrongQuestionIds, id)
 	}
 
+	// 按章节筛选
+	wrongQuestionIds, err = filterByChapter(wrongQuestionIds, uint(courseId), chapter)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	// 4. 计算总数
 	total := int64(len(wrongQuestionIds))
 
@@ -551,19 +448,21 @@ func (s *PracticeService) GetAllWrongQuestionsByCourse(userId uint, courseId int
 
 	// 5. 使用自定义查询获取题目信息
 	type RawQuestion struct {
-		ID          uint      `json:"id"`
-		Type        string    `json:"type"`
-		Question    string    `json:"question"`
-		Options     string    `json:"options"`
-		Answer      string    `json:"answer"`
-		Explanation string    `json:"explanation"`
-		CourseID    uint      `json:"course_id"`
-		UpdatedAt   time.Time `json:"updated_at"`
+		ID               uint            `json:"id"`
+		Type             string          `json:"type"`
+		Question         string          `json:"question"`
+		StemMedia        model.MediaRefs `json:"stem_media"`
+		Options          string          `json:"options"`
+		Answer           string          `json:"answer"`
+		Explanation      string          `json:"explanation"`
+		ExplanationMedia model.MediaRefs `json:"explanation_media"`
+		CourseID         uint            `json:"course_id"`
+		UpdatedAt        time.Time       `json:"updated_at"`
 	}
 
 	var rawQuestions []RawQuestion
 	err = database.DB.Table("questions").
-		Select("id, type, question, options, answer, explanation, course_id, updated_at").
+		Select("id, type, question, stem_media, options, answer, explanation, explanation_media, course_id, updated_at").
 		Where("id IN ?", wrongQuestionIds).
 		Where("deleted_at IS NULL").
 		Find(&rawQuestions).Error
@@ -582,87 +481,14 @@ func (s *PracticeService) GetAllWrongQuestionsByCourse(userId uint, courseId int
 		}
 	}
 
-	type CourseInfo struct {
-		ID             uint   `json:"id"`
-		CategoryLevel2 string `json:"category_level2"`
-		Name           string `json:"name"`
-	}
-
-	var courses []CourseInfo
-	database.DB.Table("courses").
-		Select("id, category_level2, name").
-		Where("id IN ?", courseIds).
-		Find(&courses)
+	// 课程ID到课程名称（分类名称-课程名称）的映射
+	courseNameMap := CourseCategory.CourseDisplayNames(courseIds)
 
-	// 构建课程ID到课程名称的映射
-	courseNameMap := make(map[uint]string)
-	for _, course := range courses {
-		courseName := course.Name
-		if course.CategoryLevel2 != "" {
-			courseName = course.CategoryLevel2 + "-" + course.Name
-		}
-		courseNameMap[course.ID] = courseName
-	}
-
-	// 7. 转换为响应格式，手动处理options字段并过滤空选项
+	// 7. 转换为响应格式
 	var result []WrongQuestionDetail
 	for _, q := range rawQuestions {
-		// 解析options字段
-		var options []model.QuestionOption
-		if q.Options != "" {
-			// 尝试解析为QuestionOption数组
-			err := json.Unmarshal([]byte(q.Options), &options)
-			if err != nil {
-				// 如果解析失败，尝试解析为字符串数组
-				var optStrings []string
-				if err := json.Unmarshal([]byte(q.Options), &optStrings); err == nil {
-					for i, optStr := range optStrings {
-						// 尝试从字符串中提取标签和文本
-						parts := strings.SplitN(optStr, ".", 2)
-						if len(parts) == 2 {
-							options = append(options, model.QuestionOption{
-								Label: parts[0],
-								Text:  strings.TrimSpace(parts[1]),
-							})
-						} else {
-							// 如果没有标签格式，使用默认标签
-							label := string(rune('A' + i))
-							options = append(options, model.QuestionOption{
-								Label: label,
-								Text:  strings.TrimSpace(optStr),
-							})
-						}
-					}
-				}
-			}
-		}
-
-		// 过滤空选项
-		var filteredOptions []model.QuestionOption
-		for _, opt := range options {
-			if opt.Label != "" || opt.Text != "" {
-				// 确保选项的文本前后没有空格
-				opt.Text = strings.TrimSpace(opt.Text)
-				filteredOptions = append(filteredOptions, opt)
-			}
-		}
-
-		// 确保至少有一个选项
-		if len(filteredOptions) == 0 && (q.Type == "single" || q.Type == "multiple" || q.Type == "judge") {
-			// 根据题目类型添加默认选项
-			if q.Type == "judge" {
-				filteredOptions = append(filteredOptions, model.QuestionOption{Label: "A", Text: "正确"})
-				filteredOptions = append(filteredOptions, model.QuestionOption{Label: "B", Text: "错误"})
-			} else {
-				for i := 0; i < 4; i++ {
-					label := string(rune('A' + i))
-					filteredOptions = append(filteredOptions, model.QuestionOption{
-						Label: label,
-						Text:  "选项" + label,
-					})
-				}
-			}
-		}
+		// 解析选项，无法解析的题目由 normalize-options 命令报告
+		options, _ := model.DecodeQuestionOptions(q.Type, q.Options)
 
 		// 获取课程名称
 		courseName := "未知课程"
@@ -671,41 +497,112 @@ func (s *PracticeService) GetAllWrongQuestionsByCourse(userId uint, courseId int
 		}
 
 		result = append(result, WrongQuestionDetail{
-			ID:          q.ID,
-			Type:        q.Type,
-			Question:    q.Question,
-			Options:     filteredOptions,
-			Answer:      q.Answer,
-			Explanation: q.Explanation,
-			UpdatedAt:   q.UpdatedAt,
-			CourseID:    q.CourseID,
-			CourseName:  courseName,
+			ID:               q.ID,
+			Type:             q.Type,
+			Question:         q.Question,
+			StemMedia:        q.StemMedia,
+			Options:          options,
+			Answer:           q.Answer,
+			Explanation:      q.Explanation,
+			ExplanationMedia: q.ExplanationMedia,
+			UpdatedAt:        q.UpdatedAt,
+			CourseID:         q.CourseID,
+			CourseName:       courseName,
 		})
 	}
+	attachAIExplanations(len(result), func(i int) (uint, string) {
+		return result[i].ID, result[i].Explanation
+	}, func(i int, view *AIExplanationView) {
+		result[i].AIExplanation = view
+	})
 
 	return result, total, nil
 }
 
-func (s *PracticeService) GenerateQuestionExplanation(questionId uint, force bool) (string, error) {
-	var question model.Question
-	if err := database.DB.First(&question, questionId).Error; err != nil {
-		return "", errors.New("题目不存在")
+// GenerateQuestionExplanation 学生请求AI解析，生成结果保存为待审核草稿，不修改题目的正式解析
+// 题目已有待审核草稿时直接返回，force 为true时重新生成该草稿
+func (s *PracticeService) GenerateQuestionExplanation(ctx context.Context, userId, questionId uint, force bool) (*AIExplanationView, error) {
+	view, _, err := s.generateExplanation(userId, questionId, force, func(question *model.Question) (*GeneratedText, error) {
+		return AI.GenerateExplanation(ctx, question)
+	})
+	return view, err
+}
+
+// StreamQuestionExplanation 流式生成AI解析，生成的片段依次传给 onDelta，完成后保存为待审核草稿
+// 题目已有待审核草稿且 force 为false时，将草稿内容一次性传给 onDelta；ctx 取消时停止生成，不保存不完整的解析
+func (s *PracticeService) StreamQuestionExplanation(ctx context.Context, userId, questionId uint, force bool, onDelta func(delta string) error) (*AIExplanationView, error) {
+	view, generated, err := s.generateExplanation(userId, questionId, force, func(question *model.Question) (*GeneratedText, error) {
+		return AI.StreamExplanation(ctx, question, onDelta)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !generated {
+		if err := onDelta(view.Content); err != nil {
+			return nil, err
+		}
+	}
+	return view, nil
+}
+
+// generateExplanation 检查题目后调用 generate 生成解析并保存为草稿，返回是否重新生成
+func (s *PracticeService) generateExplanation(userId, questionId uint, force bool, generate func(question *model.Question) (*GeneratedText, error)) (*AIExplanationView, bool, error) {
+	question, err := s.accessibleQuestion(userId, questionId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if question.Explanation != "" && !config.GlobalConfig.AI.Explanation.AllowOverride {
+		return nil, false, errors.New("题目已有解析")
 	}
 
-	if question.Explanation != "" {
-		if !force && !config.GlobalConfig.AI.Explanation.AllowOverride {
-			return "", errors.New("题目已有解析，不允许覆盖")
+	if !force {
+		draft, err := ExplanationDraft.Pending(question.ID)
+		if err != nil {
+			return nil, false, fmt.Errorf("查询AI解析失败: %v", err)
+		}
+		if draft != nil {
+			return newAIExplanationView(draft), false, nil
 		}
 	}
 
-	explanation, err := AI.GenerateExplanation(question.Question, question.Type, question.Options, question.Answer)
+	usage, err := AIUsage.Reserve(AIUsageMeta{
+		UserID:     userId,
+		Feature:    model.AIFeatureExplanation,
+		CourseID:   question.CourseID,
+		QuestionID: question.ID,
+	})
 	if err != nil {
-		return "", err
+		return nil, false, err
+	}
+	gen, err := generate(question)
+	AIUsage.Finish(usage, gen, err)
+	if err != nil {
+		return nil, false, err
 	}
 
-	if err := database.DB.Model(&question).Update("explanation", explanation).Error; err != nil {
-		return "", fmt.Errorf("保存解析失败: %v", err)
+	var draft *model.ExplanationDraft
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		draft, err = ExplanationDraft.SaveTx(tx, question.ID, gen, model.DraftOriginStudent, userId, 0)
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("保存解析失败: %v", err)
 	}
 
-	return explanation, nil
+	return newAIExplanationView(draft), true, nil
+}
+
+// accessibleQuestion 获取用户可以练习的题目：有课程权限，或题目为课程的试用题目
+func (s *PracticeService) accessibleQuestion(userId, questionId uint) (*model.Question, error) {
+	var question model.Question
+	if err := database.DB.First(&question, questionId).Error; err != nil {
+		return nil, errors.New("题目不存在")
+	}
+
+	if err := Entitlement.CheckAccess(userId, question.CourseID); err != nil && !Trial.IsTrialQuestion(&question) {
+		return nil, err
+	}
+	return &question, nil
 }
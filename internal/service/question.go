@@ -5,7 +5,8 @@ import (
 	"errors"
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
-	"strings"
+	"fmt"
+	"reflect"
 	"time"
 
 	"gorm.io/gorm"
@@ -17,52 +18,59 @@ type QuestionService struct{}
 
 // 问题返回结构
 type QuestionResponse struct {
-	ID          uint                   `json:"id"`
-	Type        string                 `json:"type"`
-	Question    string                 `json:"question"`
-	Options     []model.QuestionOption `json:"options"`
-	Answer      string                 `json:"answer"`
-	Explanation string                 `json:"explanation"`
-	CourseID    uint                   `json:"course_id"`
+	ID               uint                   `json:"id"`
+	Type             string                 `json:"type"`
+	Question         string                 `json:"question"`
+	StemMedia        model.MediaRefs        `json:"stem_media"`
+	Options          []model.QuestionOption `json:"options"`
+	Answer           string                 `json:"answer"`
+	Explanation      string                 `json:"explanation"`
+	ExplanationMedia model.MediaRefs        `json:"explanation_media"`
+	AIExplanation    *AIExplanationView     `json:"ai_explanation,omitempty"` // 没有正式解析时返回待审核的AI解析
+	CourseID         uint                   `json:"course_id"`
 }
 
-// 获取课程题目
-func (s *QuestionService) GetQuestionsByCourse(userId, courseId uint, questionType string) ([]QuestionResponse, error) {
-	// 1. 检查用户是否购买了该课程且未过期
-	var order model.Order
-	err := database.DB.Where("user_id = ? AND course_id = ? AND status = ?",
-		userId, courseId, "paid").
-		Where("expire_time IS NULL OR expire_time > ?", time.Now()).
-		Order("expire_time DESC").
-		First(&order).Error
-
-	if err != nil {
-		return nil, errors.New("您尚未购买该课程或课程已过期")
+// 获取课程题目，可按章或小节筛选
+func (s *QuestionService) GetQuestionsByCourse(userId, courseId uint, questionType string, chapter ChapterFilter) ([]QuestionResponse, error) {
+	// 1. 检查用户是否有该课程的有效权限
+	if err := Entitlement.CheckAccess(userId, courseId); err != nil {
+		return nil, err
 	}
 
 	// 2. 查询指定类型的题目
 	query := database.DB.Table("questions").Where("course_id = ?", courseId)
 
+	var err error
 	if questionType != "" && questionType != "all" {
 		query = query.Where("type = ?", questionType)
 	}
+	if query, err = Chapter.Apply(query, courseId, chapter); err != nil {
+		return nil, err
+	}
+
+	return s.list(query)
+}
 
+// list 查询题目并转换为练习用的响应格式，包含答案和解析
+func (s *QuestionService) list(query *gorm.DB) ([]QuestionResponse, error) {
 	// 使用临时结构体接收数据
 	type RawQuestion struct {
-		ID          uint   `json:"id"`
-		Type        string `json:"type"`
-		Question    string `json:"question"`
-		Options     string `json:"options"`
-		Answer      string `json:"answer"`
-		Explanation string `json:"explanation"`
-		CourseID    uint   `json:"course_id"`
-		CreatedAt   time.Time
-		UpdatedAt   time.Time
-		DeletedAt   gorm.DeletedAt
+		ID               uint            `json:"id"`
+		Type             string          `json:"type"`
+		Question         string          `json:"question"`
+		StemMedia        model.MediaRefs `json:"stem_media"`
+		Options          string          `json:"options"`
+		Answer           string          `json:"answer"`
+		Explanation      string          `json:"explanation"`
+		ExplanationMedia model.MediaRefs `json:"explanation_media"`
+		CourseID         uint            `json:"course_id"`
+		CreatedAt        time.Time
+		UpdatedAt        time.Time
+		DeletedAt        gorm.DeletedAt
 	}
 
 	var rawQuestions []RawQuestion
-	err = query.Find(&rawQuestions).Error
+	err := query.Find(&rawQuestions).Error
 	if err != nil {
 		return nil, err
 	}
@@ -70,73 +78,157 @@ func (s *QuestionService) GetQuestionsByCourse(userId, courseId uint, questionTy
 	// 3. 转换为标准响应格式
 	var response []QuestionResponse
 	for _, q := range rawQuestions {
-		// 手动解析options字段
-		var options []model.QuestionOption
-		if q.Options != "" {
-			// 首先尝试解析为字符串数组，这是数据库中实际存储的格式
-			var textOptions []string
-			if err := json.Unmarshal([]byte(q.Options), &textOptions); err == nil {
-				// 成功解析为字符串数组
-				for _, optText := range textOptions {
-					// 处理选项文本中的标签前缀，如 "A.选项内容"
-					parts := strings.SplitN(optText, ".", 2)
-					if len(parts) == 2 {
-						// 正确分割出标签和文本
-						options = append(options, model.QuestionOption{
-							Label: parts[0],
-							Text:  parts[1],
-						})
-					} else {
-						// 如果没有分隔符，使用整个字符串作为文本
-						options = append(options, model.QuestionOption{
-							Label: "", // 空标签
-							Text:  optText,
-						})
-					}
-				}
-			} else {
-				// 如果解析失败，尝试其他格式
-				// 尝试解析为 QuestionOption 数组
-				if err := json.Unmarshal([]byte(q.Options), &options); err != nil {
-					// 如果仍然失败，使用默认选项
-					for i := 0; i < 4; i++ {
-						label := string(rune('A' + i))
-						options = append(options, model.QuestionOption{
-							Label: label,
-							Text:  "选项" + label,
-						})
-					}
-				}
-			}
-		} else {
-			// 如果选项为空，根据题目类型生成默认选项
-			if q.Type == "judge" {
-				// 判断题默认有两个选项
-				options = append(options, model.QuestionOption{Label: "A", Text: "正确"})
-				options = append(options, model.QuestionOption{Label: "B", Text: "错误"})
-			} else {
-				// 其他类型默认有4个选项
-				for i := 0; i < 4; i++ {
-					label := string(rune('A' + i))
-					options = append(options, model.QuestionOption{
-						Label: label,
-						Text:  "选项" + label,
-					})
-				}
-			}
-		}
+		// 解析选项，无法解析的题目由 normalize-options 命令报告
+		options, _ := model.DecodeQuestionOptions(q.Type, q.Options)
 
 		// 创建响应结构
 		response = append(response, QuestionResponse{
-			ID:          q.ID,
-			Type:        q.Type,
-			Question:    q.Question,
-			Options:     options,
-			Answer:      q.Answer,
-			Explanation: q.Explanation,
-			CourseID:    q.CourseID,
+			ID:               q.ID,
+			Type:             q.Type,
+			Question:         q.Question,
+			StemMedia:        q.StemMedia,
+			Options:          options,
+			Answer:           q.Answer,
+			Explanation:      q.Explanation,
+			ExplanationMedia: q.ExplanationMedia,
+			CourseID:         q.CourseID,
 		})
 	}
+	attachAIExplanations(len(response), func(i int) (uint, string) {
+		return response[i].ID, response[i].Explanation
+	}, func(i int, view *AIExplanationView) {
+		response[i].AIExplanation = view
+	})
 
 	return response, nil
 }
+
+// NormalizeOptionsResult 选项格式规范化结果
+type NormalizeOptionsResult struct {
+	Total     int                       // 检查的题目数
+	Converted int                       // 改写（或预览模式下需改写）为规范格式的题目数
+	Failures  []NormalizeOptionsFailure // 无法解析而未改写的题目
+}
+
+// NormalizeOptionsFailure 无法规范化的题目
+type NormalizeOptionsFailure struct {
+	QuestionID uint
+	Reason     string
+	Raw        string
+}
+
+// NormalizeOptions 将全部题目（含已删除）的选项改写为规范格式，dryRun 时只统计不修改
+// 无法解析或标签与位置不一致的题目不改写，记录在结果中供人工处理
+func (s *QuestionService) NormalizeOptions(dryRun bool) (*NormalizeOptionsResult, error) {
+	result := &NormalizeOptionsResult{}
+
+	type rawOptions struct {
+		ID      uint
+		Type    string
+		Options *string
+	}
+
+	var lastID uint
+	for {
+		var rows []rawOptions
+		if err := database.DB.Table("questions").
+			Select("id, type, options").
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(500).
+			Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		lastID = rows[len(rows)-1].ID
+
+		for _, row := range rows {
+			result.Total++
+
+			raw := ""
+			if row.Options != nil {
+				raw = *row.Options
+			}
+
+			options, err := model.DecodeQuestionOptions(row.Type, raw)
+			if err != nil {
+				result.Failures = append(result.Failures, NormalizeOptionsFailure{
+					QuestionID: row.ID,
+					Reason:     err.Error(),
+					Raw:        raw,
+				})
+				continue
+			}
+
+			// 数据库返回的JSON文本会被重新格式化，按结构比较是否已是规范格式
+			canonicalOptions := model.CanonicalOptions(row.Type, options)
+			var stored model.QuestionOptions
+			if err := json.Unmarshal([]byte(raw), (*[]model.QuestionOption)(&stored)); err == nil &&
+				reflect.DeepEqual(stored, canonicalOptions) {
+				continue
+			}
+
+			canonical, err := EncodeQuestionOptions(row.Type, options)
+			if err != nil {
+				return nil, err
+			}
+
+			result.Converted++
+			if dryRun {
+				continue
+			}
+			// 只改写存储格式，不更新 updated_at
+			if err := database.DB.Exec("UPDATE questions SET options = ? WHERE id = ?", canonical, row.ID).Error; err != nil {
+				return nil, fmt.Errorf("改写题目 %d 的选项失败: %v", row.ID, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ValidateQuestionOptions 验证题目选项和答案格式，管理端编辑题目和采用AI生成的题目使用同一规则
+func ValidateQuestionOptions(qType string, options model.QuestionOptions, answer string) error {
+	switch qType {
+	case "judge":
+		// 判断题必须只有两个选项：A.正确、B.错误
+		if len(options) != 2 {
+			return errors.New("判断题必须有且只有两个选项")
+		}
+		if options[0].Text != "正确" || options[1].Text != "错误" {
+			return errors.New("判断题选项必须为：正确、错误")
+		}
+		if answer != "A" && answer != "B" {
+			return errors.New("判断题答案必须为A或B")
+		}
+	case "single":
+		// 单选题选项必须是A-D，答案必须是其中之一
+		if len(options) < 2 || len(options) > 26 {
+			return errors.New("单选题选项数量必须在2-26之间")
+		}
+		if len(answer) != 1 || answer < "A" || answer > string(rune('A'+len(options)-1)) {
+			return errors.New("单选题答案必须是选项中的一个字母")
+		}
+	case "multiple":
+		// 多选题选项必须是A-Z，答案必须是其中的一个或多个
+		if len(options) < 2 || len(options) > 26 {
+			return errors.New("多选题选项数量必须在2-26之间")
+		}
+		// 验证答案格式（必须是大写字母组合，如"ABC"）
+		answerMap := make(map[rune]bool)
+		for _, a := range answer {
+			if a < 'A' || a > rune('A'+len(options)-1) {
+				return errors.New("多选题答案必须是选项标签的组合")
+			}
+			answerMap[a] = true
+		}
+		if len(answer) == 0 {
+			return errors.New("多选题答案不能为空")
+		}
+	default:
+		return errors.New("不支持的题目类型")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Aiken 格式，参见 https://docs.moodle.org/en/Aiken_format
+// 每题为一行题干、若干 "A. 选项" 行和一行 "ANSWER: A"，只支持单选题，不包含解析
+
+var (
+	aikenOptionPattern = regexp.MustCompile(`^([A-Z])\s*[.)．、]\s*(.*)$`)
+	aikenAnswerPattern = regexp.MustCompile(`(?i)^ANSWER\s*[:：]\s*(.*)$`)
+)
+
+// parseAiken 解析Aiken文本；题干可跨多行，选项按字母顺序识别
+func parseAiken(text string) ([]exchangeQuestion, []exchangeSkip) {
+	var questions []exchangeQuestion
+	var skipped []exchangeSkip
+
+	var stem []string
+	var options []string
+	startLine := 0
+
+	reset := func() {
+		stem = stem[:0]
+		options = options[:0]
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if len(stem) == 0 && len(options) == 0 {
+			startLine = i + 1
+		}
+		ref := fmt.Sprintf("第%d行", startLine)
+
+		if m := aikenAnswerPattern.FindStringSubmatch(trimmed); m != nil && len(options) > 0 {
+			answer := cleanAnswer(m[1])
+			q := exchangeQuestion{
+				Ref:      ref,
+				Type:     "single",
+				Question: strings.Join(stem, "\n"),
+				Options:  append([]string(nil), options...),
+				Answer:   answer,
+			}
+			if len(answer) > 1 {
+				q.Type = "multiple"
+				q.Warnings = append(q.Warnings, "答案包含多个选项，不符合Aiken格式，已按多选题导入")
+			}
+			detectJudge(&q)
+			questions = append(questions, q)
+			reset()
+			continue
+		}
+
+		// 只有下一个应出现的字母才视为选项，避免以 "A." 开头的题干被误判
+		if m := aikenOptionPattern.FindStringSubmatch(trimmed); m != nil && len(stem) > 0 &&
+			m[1] == string(rune('A'+len(options))) {
+			options = append(options, strings.TrimSpace(m[2]))
+			continue
+		}
+
+		if len(options) > 0 {
+			// 选项之后出现其他内容，上一题缺少答案
+			skipped = append(skipped, exchangeSkip{Ref: ref, Reason: "缺少 ANSWER 行"})
+			reset()
+			startLine = i + 1
+		}
+		stem = append(stem, trimmed)
+	}
+
+	if len(stem) > 0 {
+		skipped = append(skipped, exchangeSkip{Ref: fmt.Sprintf("第%d行", startLine), Reason: "缺少选项或 ANSWER 行"})
+	}
+
+	return questions, skipped
+}
+
+// aikenLine Aiken每项只能占一行，换行替换为空格
+func aikenLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// ExportAiken 导出题库为Aiken格式，多选题无法表示而跳过，progress 可为nil
+func (s *QuestionIOService) ExportAiken(ctx context.Context, w io.Writer, courseID uint, progress func(percent int)) (*ExportResult, error) {
+	result := &ExportResult{}
+	var limits exportLimits
+	bw := bufio.NewWriter(w)
+
+	err := forEachExportQuestion(ctx, courseID, scaleProgress(progress, 0, 100), func(q *exportQuestion) error {
+		if q.Type == "multiple" {
+			result.skip(q.ID, "Aiken格式不支持多选题")
+			return nil
+		}
+		limits.check(q, false)
+
+		bw.WriteString(aikenLine(q.Question) + "\n")
+		for i, text := range exportOptionTexts(q) {
+			fmt.Fprintf(bw, "%c. %s\n", 'A'+i, aikenLine(text))
+		}
+		fmt.Fprintf(bw, "ANSWER: %s\n\n", q.Answer)
+
+		result.ExportCount++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	limits.report(result, "Aiken")
+	return result, bw.Flush()
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var QuestionBulk = new(QuestionBulkService)
+
+// QuestionBulkService 题目批量移动、复制、修改
+type QuestionBulkService struct{}
+
+// bulkMaxItems 单次批量操作的题目数量上限
+const bulkMaxItems = 5000
+
+// QuestionFilter 题目筛选条件，与管理端题目列表的查询参数一致
+type QuestionFilter struct {
+	Type      string `json:"type" form:"type"`
+	Question  string `json:"question" form:"question"`
+	CourseID  uint   `json:"course_id" form:"course_id"`
+	ChapterID uint   `json:"chapter_id" form:"chapter_id"` // 按章筛选
+	SectionID uint   `json:"section_id" form:"section_id"` // 按小节筛选
+}
+
+// IsEmpty 是否未设置任何筛选条件
+func (f QuestionFilter) IsEmpty() bool {
+	return f.Type == "" && f.Question == "" && f.CourseID == 0 && f.ChapterID == 0 && f.SectionID == 0
+}
+
+// Apply 将筛选条件应用到查询
+func (f QuestionFilter) Apply(db *gorm.DB) *gorm.DB {
+	if f.Type != "" {
+		db = db.Where("type = ?", f.Type)
+	}
+	if f.Question != "" {
+		db = db.Where("question LIKE ?", "%"+f.Question+"%")
+	}
+	if f.CourseID > 0 {
+		db = db.Where("course_id = ?", f.CourseID)
+	}
+	if f.SectionID > 0 {
+		db = db.Where("section_id = ?", f.SectionID)
+	} else if f.ChapterID > 0 {
+		db = db.Where("section_id IN (?)", chapterSections(f.ChapterID))
+	}
+	return db
+}
+
+// QuestionSelector 批量操作的题目范围，指定ID列表或筛选条件（二选一）
+type QuestionSelector struct {
+	IDs    []uint          `json:"ids"`
+	Filter *QuestionFilter `json:"filter"`
+}
+
+// BulkItemResult 单个题目的处理结果
+type BulkItemResult struct {
+	ID      uint   `json:"id"`
+	NewID   uint   `json:"new_id,omitempty"` // 复制生成的新题目ID
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkResult 批量操作结果
+type BulkResult struct {
+	Total        int              `json:"total"`
+	SuccessCount int              `json:"success_count"`
+	FailedCount  int              `json:"failed_count"`
+	Items        []BulkItemResult `json:"items"`
+}
+
+func (r *BulkResult) add(item BulkItemResult) {
+	r.Total++
+	if item.Success {
+		r.SuccessCount++
+	} else {
+		r.FailedCount++
+	}
+	r.Items = append(r.Items, item)
+}
+
+// BulkUpdateFields 批量修改的字段，为nil的字段不修改
+type BulkUpdateFields struct {
+	Type        *string `json:"type"`
+	Explanation *string `json:"explanation"`
+	SectionID   *uint   `json:"section_id"` // 分配到小节，0表示取消分配
+	IsTrial     *bool   `json:"is_trial"`   // 标记或取消标记为试用题目
+}
+
+// loadSelected 在事务中加载选中的题目，ID列表中不存在的题目记为失败
+func loadSelected(tx *gorm.DB, sel QuestionSelector, result *BulkResult) ([]model.Question, error) {
+	var questions []model.Question
+
+	if len(sel.IDs) > 0 {
+		if len(sel.IDs) > bulkMaxItems {
+			return nil, fmt.Errorf("单次最多操作%d道题目", bulkMaxItems)
+		}
+		if err := tx.Where("id IN ?", sel.IDs).Order("id").Find(&questions).Error; err != nil {
+			return nil, fmt.Errorf("查询题目失败: %v", err)
+		}
+
+		found := make(map[uint]bool, len(questions))
+		for _, q := range questions {
+			found[q.ID] = true
+		}
+		seen := make(map[uint]bool, len(sel.IDs))
+		for _, id := range sel.IDs {
+			if !found[id] && !seen[id] {
+				result.add(BulkItemResult{ID: id, Error: "题目不存在"})
+			}
+			seen[id] = true
+		}
+		return questions, nil
+	}
+
+	if sel.Filter == nil || sel.Filter.IsEmpty() {
+		return nil, errors.New("请选择题目或设置筛选条件")
+	}
+
+	var count int64
+	if err := sel.Filter.Apply(tx.Model(&model.Question{})).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询题目失败: %v", err)
+	}
+	if count > bulkMaxItems {
+		return nil, fmt.Errorf("筛选结果共%d道题目，单次最多操作%d道", count, bulkMaxItems)
+	}
+
+	if err := sel.Filter.Apply(tx.Model(&model.Question{})).Order("id").Find(&questions).Error; err != nil {
+		return nil, fmt.Errorf("查询题目失败: %v", err)
+	}
+	return questions, nil
+}
+
+// checkTargetCourse 验证目标课程存在
+func checkTargetCourse(tx *gorm.DB, courseID uint) error {
+	if courseID == 0 {
+		return errors.New("请选择目标课程")
+	}
+	var count int64
+	if err := tx.Model(&model.Course{}).Where("id = ?", courseID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询课程失败: %v", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("课程ID %d 不存在", courseID)
+	}
+	return nil
+}
+
+// Move 将题目移动到目标课程，章节属于原课程，移动后题目变为未分配章节
+func (s *QuestionBulkService) Move(sel QuestionSelector, targetCourseID uint) (*BulkResult, error) {
+	result := &BulkResult{Items: []BulkItemResult{}}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkTargetCourse(tx, targetCourseID); err != nil {
+			return err
+		}
+
+		questions, err := loadSelected(tx, sel, result)
+		if err != nil {
+			return err
+		}
+
+		var ids []uint
+		for _, q := range questions {
+			ids = append(ids, q.ID)
+			result.add(BulkItemResult{ID: q.ID, Success: true})
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&model.Question{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"course_id": targetCourseID, "section_id": 0, "is_trial": false}).Error; err != nil {
+			return fmt.Errorf("移动题目失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Copy 将题目复制到目标课程，媒体引用共用同一文件，复制到同一课程时保留所属小节和试用标记
+func (s *QuestionBulkService) Copy(sel QuestionSelector, targetCourseID uint) (*BulkResult, error) {
+	result := &BulkResult{Items: []BulkItemResult{}}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkTargetCourse(tx, targetCourseID); err != nil {
+			return err
+		}
+
+		questions, err := loadSelected(tx, sel, result)
+		if err != nil {
+			return err
+		}
+
+		for _, q := range questions {
+			optionsJSON, err := EncodeQuestionOptions(q.Type, q.Options)
+			if err != nil {
+				result.add(BulkItemResult{ID: q.ID, Error: "选项格式化失败"})
+				continue
+			}
+
+			var sectionID uint
+			var isTrial bool
+			if q.CourseID == targetCourseID {
+				sectionID = q.SectionID
+				isTrial = q.IsTrial
+			}
+
+			copied := model.Question{
+				Type:              q.Type,
+				Question:          q.Question,
+				StemMedia:         q.StemMedia,
+				Answer:            q.Answer,
+				Explanation:       q.Explanation,
+				ExplanationMedia:  q.ExplanationMedia,
+				ExplanationSource: q.ExplanationSource,
+				CourseID:          targetCourseID,
+				SectionID:         sectionID,
+				IsTrial:           isTrial,
+			}
+			if err := tx.Omit("Options").Create(&copied).Error; err != nil {
+				return fmt.Errorf("复制题目 %d 失败: %v", q.ID, err)
+			}
+			if err := tx.Exec("UPDATE questions SET options = ? WHERE id = ?", optionsJSON, copied.ID).Error; err != nil {
+				return fmt.Errorf("复制题目 %d 失败: %v", q.ID, err)
+			}
+
+			if err := QuestionSearch.IndexTx(tx, copied.ID); err != nil {
+				return fmt.Errorf("复制题目 %d 失败: %v", q.ID, err)
+			}
+
+			result.add(BulkItemResult{ID: q.ID, NewID: copied.ID, Success: true})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Update 批量修改题目类型、解析、所属小节或试用标记，类型与答案不匹配、小节不属于题目所在课程的题目跳过
+func (s *QuestionBulkService) Update(sel QuestionSelector, fields BulkUpdateFields) (*BulkResult, error) {
+	if fields.Type == nil && fields.Explanation == nil && fields.SectionID == nil && fields.IsTrial == nil {
+		return nil, errors.New("请指定要修改的字段")
+	}
+	if fields.Type != nil {
+		t := strings.TrimSpace(*fields.Type)
+		if t != "single" && t != "multiple" && t != "judge" {
+			return nil, errors.New("题目类型错误，只支持single(单选题)、multiple(多选题)或judge(判断题)")
+		}
+		fields.Type = &t
+	}
+
+	result := &BulkResult{Items: []BulkItemResult{}}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var section model.CourseSection
+		if fields.SectionID != nil && *fields.SectionID > 0 {
+			if err := tx.First(&section, *fields.SectionID).Error; err != nil {
+				return errors.New("小节不存在")
+			}
+		}
+
+		questions, err := loadSelected(tx, sel, result)
+		if err != nil {
+			return err
+		}
+
+		for _, q := range questions {
+			updates := map[string]interface{}{}
+
+			if section.ID > 0 && section.CourseID != q.CourseID {
+				result.add(BulkItemResult{ID: q.ID, Error: "小节不属于题目所在课程"})
+				continue
+			}
+
+			if fields.Type != nil && *fields.Type != q.Type {
+				newType := *fields.Type
+				if newType == "judge" && len(q.Options) != 2 {
+					result.add(BulkItemResult{ID: q.ID, Error: "只有两个选项的题目可以改为判断题"})
+					continue
+				}
+				if msg := checkImportAnswer(newType, q.Answer, len(q.Options)); msg != "" {
+					result.add(BulkItemResult{ID: q.ID, Error: msg})
+					continue
+				}
+
+				optionsJSON, err := EncodeQuestionOptions(newType, q.Options)
+				if err != nil {
+					result.add(BulkItemResult{ID: q.ID, Error: "选项格式化失败"})
+					continue
+				}
+				updates["type"] = newType
+				updates["options"] = optionsJSON
+			}
+			if fields.Explanation != nil {
+				updates["explanation"] = *fields.Explanation
+				updates["explanation_source"] = model.ExplanationSourceManual
+			}
+			if fields.SectionID != nil {
+				updates["section_id"] = *fields.SectionID
+			}
+			if fields.IsTrial != nil {
+				updates["is_trial"] = *fields.IsTrial
+			}
+
+			if len(updates) > 0 {
+				if err := tx.Model(&model.Question{}).Where("id = ?", q.ID).Updates(updates).Error; err != nil {
+					return fmt.Errorf("修改题目 %d 失败: %v", q.ID, err)
+				}
+				if err := QuestionSearch.IndexTx(tx, q.ID); err != nil {
+					return fmt.Errorf("修改题目 %d 失败: %v", q.ID, err)
+				}
+			}
+			result.add(BulkItemResult{ID: q.ID, Success: true})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
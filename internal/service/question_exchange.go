@@ -0,0 +1,293 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// 题库交换格式：Moodle GIFT、Aiken 以及 IMS QTI 2.1 题目包
+// 这些格式不携带课程信息，导入时需指定目标课程；无法用 single/multiple/judge 表示的题目跳过并记录原因
+
+// exchangeQuestion 从交换格式中解析出的题目
+type exchangeQuestion struct {
+	Ref         string   // 题目在源文件中的位置，用于错误提示，如 "第3行"
+	Type        string   // single、multiple 或 judge
+	Question    string   // 题干
+	Options     []string // 选项文本，按顺序对应 A、B、C……
+	Answer      string   // 正确选项标签，如 "AC"
+	Explanation string
+	Warnings    []string // 无法完整表示、已忽略的内容
+}
+
+// exchangeSkip 无法导入的题目
+type exchangeSkip struct {
+	Ref    string
+	Reason string
+}
+
+// ExportResult 题库导出结果，GIFT、Aiken、QTI 导出时记录无法表示的内容
+type ExportResult struct {
+	ExportCount  int      `json:"export_count"`
+	SkippedCount int      `json:"skipped_count"`
+	Warnings     []string `json:"warnings,omitempty"`
+}
+
+func (r *ExportResult) warn(format string, args ...interface{}) {
+	if len(r.Warnings) < maxImportErrors {
+		r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *ExportResult) skip(id uint, reason string) {
+	r.SkippedCount++
+	r.warn("题目%d: %s，已跳过", id, reason)
+}
+
+// exportLimits 统计导出格式整体不支持的内容，导出结束时汇总为一条警告
+type exportLimits struct {
+	media       int
+	explanation int
+}
+
+func (l *exportLimits) check(q *exportQuestion, keepExplanation bool) {
+	if len(q.StemMedia) > 0 || len(q.ExplanationMedia) > 0 {
+		l.media++
+	} else {
+		for _, opt := range q.Options {
+			if len(opt.Media) > 0 {
+				l.media++
+				break
+			}
+		}
+	}
+	if !keepExplanation && strings.TrimSpace(q.Explanation) != "" {
+		l.explanation++
+	}
+}
+
+func (l *exportLimits) report(r *ExportResult, format string) {
+	if l.media > 0 {
+		r.warn("%d道题目包含图片等媒体，%s格式不支持，媒体未导出", l.media, format)
+	}
+	if l.explanation > 0 {
+		r.warn("%d道题目的解析未导出，%s格式不支持解析", l.explanation, format)
+	}
+}
+
+// exportOptionTexts 导出时使用的选项文本，判断题固定为正确/错误
+func exportOptionTexts(q *exportQuestion) []string {
+	if q.Type == "judge" {
+		return []string{"正确", "错误"}
+	}
+	texts := make([]string, len(q.Options))
+	for i, opt := range q.Options {
+		texts[i] = opt.Text
+	}
+	return texts
+}
+
+// judgeOptionPairs 可识别为判断题的两个选项
+var judgeOptionPairs = [][2]string{
+	{"正确", "错误"},
+	{"对", "错"},
+	{"是", "否"},
+	{"true", "false"},
+}
+
+// detectJudge 两个选项为正确/错误时转为判断题，选项顺序相反时同时调整答案
+func detectJudge(q *exchangeQuestion) {
+	if q.Type != "single" || len(q.Options) != 2 {
+		return
+	}
+	first := strings.ToLower(strings.TrimSpace(q.Options[0]))
+	second := strings.ToLower(strings.TrimSpace(q.Options[1]))
+	for _, pair := range judgeOptionPairs {
+		switch {
+		case first == pair[0] && second == pair[1]:
+			q.Type = "judge"
+		case first == pair[1] && second == pair[0]:
+			q.Type = "judge"
+			if q.Answer == "A" {
+				q.Answer = "B"
+			} else if q.Answer == "B" {
+				q.Answer = "A"
+			}
+		default:
+			continue
+		}
+		q.Options = []string{"正确", "错误"}
+		return
+	}
+}
+
+// answerLabels 将正确选项的下标转为标签，如 [0,2] 转为 "AC"
+func answerLabels(correct []int) string {
+	var b strings.Builder
+	for _, i := range correct {
+		b.WriteRune(rune('A' + i))
+	}
+	return b.String()
+}
+
+var (
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>`)
+	htmlTagPattern   = regexp.MustCompile(`<[^>]*>`)
+	blankLinePattern = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// htmlToText 去除HTML标签并还原实体，段落转为换行
+func htmlToText(s string) string {
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = blankLinePattern.ReplaceAllString(s, "\n")
+	return strings.TrimSpace(s)
+}
+
+// readExchangeText 读取文本格式的题库文件，去除BOM并统一换行符
+func readExchangeText(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
+// ImportExchange 从 GIFT、Aiken 或 QTI 题目包导入题库到指定课程，progress 可为nil
+func (s *QuestionIOService) ImportExchange(ctx context.Context, format string, r io.ReaderAt, size int64, courseID uint, progress func(percent int)) (*ImportResult, error) {
+	if courseID == 0 {
+		return nil, errors.New("请指定导入的目标课程")
+	}
+	var courseCount int64
+	if err := database.DB.Model(&model.Course{}).Where("id = ?", courseID).Count(&courseCount).Error; err != nil || courseCount == 0 {
+		return nil, fmt.Errorf("课程ID %d 不存在", courseID)
+	}
+
+	var questions []exchangeQuestion
+	var skipped []exchangeSkip
+	switch format {
+	case "gift", "aiken":
+		text, err := readExchangeText(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, fmt.Errorf("读取上传文件失败: %v", err)
+		}
+		if format == "gift" {
+			questions, skipped = parseGIFT(text)
+		} else {
+			questions, skipped = parseAiken(text)
+		}
+	case "qti":
+		var err error
+		questions, skipped, err = parseQTIPackage(r, size)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的导入格式: %s", format)
+	}
+	if progress != nil {
+		progress(10)
+	}
+
+	return s.importExchange(ctx, questions, skipped, courseID, scaleProgress(progress, 10, 100))
+}
+
+// importExchange 保存解析出的题目，校验失败的题目跳过并记录原因，取消时回滚全部导入
+func (s *QuestionIOService) importExchange(ctx context.Context, questions []exchangeQuestion, skipped []exchangeSkip, courseID uint, progress func(done, total int64)) (*ImportResult, error) {
+	result := &ImportResult{}
+	addError := func(ref, format string, args ...interface{}) {
+		result.ErrorCount++
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, ref+": "+fmt.Sprintf(format, args...))
+		}
+	}
+	for _, skip := range skipped {
+		addError(skip.Ref, "%s", skip.Reason)
+	}
+
+	tx := database.DB.Begin()
+	for i, q := range questions {
+		if err := ctx.Err(); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if strings.TrimSpace(q.Question) == "" {
+			addError(q.Ref, "题干为空")
+			continue
+		}
+		if len(q.Options) > 26 {
+			addError(q.Ref, "选项数量超过26个")
+			continue
+		}
+
+		var options model.QuestionOptions
+		if q.Type == "judge" {
+			options = model.JudgeOptions()
+		} else {
+			if len(q.Options) < 2 {
+				addError(q.Ref, "选项少于2个")
+				continue
+			}
+			for j, text := range q.Options {
+				options = append(options, model.QuestionOption{
+					Label: string(rune('A' + j)),
+					Text:  strings.TrimSpace(text),
+				})
+			}
+		}
+
+		answer := cleanAnswer(q.Answer)
+		if answer == "" {
+			addError(q.Ref, "没有正确答案")
+			continue
+		}
+		if msg := checkImportAnswer(q.Type, answer, len(options)); msg != "" {
+			addError(q.Ref, "%s", msg)
+			continue
+		}
+
+		optionsJSON, err := EncodeQuestionOptions(q.Type, options)
+		if err != nil {
+			addError(q.Ref, "选项格式化失败")
+			continue
+		}
+
+		question := model.Question{
+			Type:        q.Type,
+			Question:    strings.TrimSpace(q.Question),
+			Answer:      answer,
+			Explanation: strings.TrimSpace(q.Explanation),
+			CourseID:    courseID,
+		}
+		if err := createImportedQuestion(tx, &question, optionsJSON); err != nil {
+			addError(q.Ref, "%s", err.Error())
+			continue
+		}
+
+		result.ImportCount++
+		for _, w := range q.Warnings {
+			if len(result.Warnings) < maxImportErrors {
+				result.Warnings = append(result.Warnings, q.Ref+": "+w)
+			}
+		}
+		if progress != nil {
+			progress(int64(i+1), int64(len(questions)))
+		}
+	}
+
+	if _, err := commitImport(tx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,620 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"exam-system/internal/config"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/llm"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobTypeQuestionGeneration 根据学习资料生成题目的任务类型
+const JobTypeQuestionGeneration = "question_generation"
+
+// maxGenerationErrors 任务结果中最多记录的失败原因数量
+const maxGenerationErrors = 20
+
+var QuestionGeneration = new(QuestionGenerationService)
+
+// QuestionGenerationService 根据学习资料生成候选题目
+// 资料按段落切分后逐段请求模型出题，结果经过与管理端编辑题目相同的校验后进入审核队列，管理员采用后才创建题目
+type QuestionGenerationService struct{}
+
+// ErrCandidateNotFound 候选题目不存在
+var ErrCandidateNotFound = errors.New("候选题目不存在")
+
+// questionTypes 支持生成的题型
+var questionTypes = []string{"single", "multiple", "judge"}
+
+// materialExts 支持的学习资料文件类型
+var materialExts = map[string]bool{".txt": true, ".md": true, ".html": true, ".htm": true}
+
+// QuestionGenerationParams 生成题目的任务参数
+type QuestionGenerationParams struct {
+	CourseID uint     `json:"course_id"`
+	Count    int      `json:"count"` // 计划生成的题目总数，按资料片段长度分配
+	Types    []string `json:"types"` // 允许的题型
+}
+
+// QuestionGenerationResult 生成题目的任务结果，用量只统计本次执行
+type QuestionGenerationResult struct {
+	Chunks           int      `json:"chunks"`
+	Generated        int      `json:"generated"`
+	WithIssues       int      `json:"with_issues"` // 未通过校验、需修改后才能采用的题目数
+	FailedChunks     int      `json:"failed_chunks"`
+	Errors           []string `json:"errors,omitempty"`
+	PromptTokens     int64    `json:"prompt_tokens"`
+	CompletionTokens int64    `json:"completion_tokens"`
+	Cost             float64  `json:"cost"`
+}
+
+// CandidateEdit 采用候选题目时的修改，为空的字段保持生成的内容
+type CandidateEdit struct {
+	Type        string                `json:"type"`
+	Question    string                `json:"question"`
+	Options     model.QuestionOptions `json:"options"`
+	Answer      string                `json:"answer"`
+	Explanation string                `json:"explanation"`
+}
+
+// questionGenerationPromptData 出题提示词模板的变量
+type questionGenerationPromptData struct {
+	Course   string
+	Count    int
+	Types    string
+	Material string
+}
+
+// generatedQuestion 模型返回的题目，options 兼容字符串数组和结构化数组
+type generatedQuestion struct {
+	Type        string          `json:"type"`
+	Question    string          `json:"question"`
+	Options     json.RawMessage `json:"options"`
+	Answer      string          `json:"answer"`
+	Explanation string          `json:"explanation"`
+	Source      string          `json:"source"`
+}
+
+// Submit 校验学习资料后提交生成任务，name 为资料文件名，粘贴的文本使用 .txt
+func (s *QuestionGenerationService) Submit(params QuestionGenerationParams, name string, r io.Reader, creatorID uint) (*model.Job, error) {
+	cfg := config.GlobalConfig.AI.Generation
+
+	var course model.Course
+	if err := database.DB.First(&course, params.CourseID).Error; err != nil {
+		return nil, errors.New("课程不存在")
+	}
+	if params.Count <= 0 || params.Count > cfg.MaxQuestions {
+		return nil, fmt.Errorf("题目数量必须在1-%d之间", cfg.MaxQuestions)
+	}
+	if len(params.Types) == 0 {
+		params.Types = questionTypes
+	}
+	for _, t := range params.Types {
+		if t != "single" && t != "multiple" && t != "judge" {
+			return nil, fmt.Errorf("不支持的题目类型: %s", t)
+		}
+	}
+	if !materialExts[strings.ToLower(path.Ext(name))] {
+		return nil, errors.New("学习资料仅支持 txt、md、html 文件")
+	}
+
+	limit := int64(cfg.MaxMaterialSize) * 1024
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取学习资料失败: %v", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("学习资料不能超过%dKB", cfg.MaxMaterialSize)
+	}
+	if !utf8.Valid(data) {
+		return nil, errors.New("学习资料必须是UTF-8编码的文本")
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return nil, errors.New("学习资料内容为空")
+	}
+
+	return Job.SubmitWithInput(JobTypeQuestionGeneration, params, name, bytes.NewReader(data), creatorID)
+}
+
+// runGenerationJob 执行生成题目任务，已生成过题目的资料片段在任务重新执行时跳过
+func (s *QuestionGenerationService) runGenerationJob(jc *JobContext) error {
+	var params QuestionGenerationParams
+	if err := jc.Bind(&params); err != nil {
+		return err
+	}
+
+	var course model.Course
+	if err := database.DB.First(&course, params.CourseID).Error; err != nil {
+		return errors.New("课程不存在")
+	}
+
+	input, err := jc.OpenInput()
+	if err != nil {
+		return fmt.Errorf("读取学习资料失败: %v", err)
+	}
+	text, err := readExchangeText(input)
+	input.Close()
+	if err != nil {
+		return fmt.Errorf("读取学习资料失败: %v", err)
+	}
+	if ext := strings.ToLower(path.Ext(jc.Job.InputName)); ext == ".html" || ext == ".htm" {
+		text = htmlToText(text)
+	}
+
+	chunks := splitMaterial(text, config.GlobalConfig.AI.Generation.ChunkSize)
+	if len(chunks) == 0 {
+		return errors.New("学习资料内容为空")
+	}
+	quotas := allocateQuestions(chunks, params.Count)
+
+	var doneChunks []int
+	if err := database.DB.Model(&model.QuestionCandidate{}).Where("job_id = ?", jc.Job.ID).
+		Distinct().Pluck("chunk_index", &doneChunks).Error; err != nil {
+		return fmt.Errorf("查询已生成的题目失败: %v", err)
+	}
+	done := make(map[int]bool, len(doneChunks))
+	for _, i := range doneChunks {
+		done[i] = true
+	}
+
+	result := &QuestionGenerationResult{Chunks: len(chunks)}
+	interval := time.Minute / time.Duration(config.GlobalConfig.AI.Batch.RequestsPerMinute)
+	requested := false
+	for i, chunk := range chunks {
+		jc.SetProgress(i * 100 / len(chunks))
+		if quotas[i] == 0 || done[i] {
+			continue
+		}
+
+		// 按每分钟请求数限速
+		if requested {
+			select {
+			case <-time.After(interval):
+			case <-jc.Done():
+			}
+		}
+		if err := jc.Err(); err != nil {
+			return err
+		}
+		requested = true
+
+		generated, withIssues, err := s.generateChunk(jc, &course, params, i, chunk, quotas[i], result)
+		if err != nil {
+			if jc.Err() != nil {
+				return jc.Err()
+			}
+			result.FailedChunks++
+			if len(result.Errors) < maxGenerationErrors {
+				result.Errors = append(result.Errors, fmt.Sprintf("第%d段: %s", i+1, truncateMessage(err.Error(), 200)))
+			}
+			continue
+		}
+		result.Generated += generated
+		result.WithIssues += withIssues
+	}
+
+	if result.FailedChunks > 0 && result.Generated == 0 && len(done) == 0 {
+		return fmt.Errorf("未能生成题目: %s", result.Errors[0])
+	}
+	return jc.SetResult(result)
+}
+
+// generateChunk 根据一段资料生成题目并保存到审核队列，返回生成的题目数和未通过校验的数量
+func (s *QuestionGenerationService) generateChunk(jc *JobContext, course *model.Course, params QuestionGenerationParams, index int, chunk string, count int, result *QuestionGenerationResult) (int, int, error) {
+	req, err := llm.Prompt("question_generation", questionGenerationPromptData{
+		Course:   course.Name,
+		Count:    count,
+		Types:    strings.Join(params.Types, "、"),
+		Material: chunk,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var gen *GeneratedText
+	resp, err := llm.Chat(jc, req)
+	if err == nil {
+		gen, err = newGeneratedText(req, resp, "AI未返回题目")
+	}
+	if err != nil && jc.Err() != nil {
+		return 0, 0, err
+	}
+	AIUsage.Record(AIUsageMeta{
+		UserID:   jc.Job.CreatorID,
+		Feature:  model.AIFeatureQuestionGeneration,
+		CourseID: course.ID,
+		JobID:    jc.Job.ID,
+	}, gen, err)
+	if err != nil {
+		return 0, 0, err
+	}
+	result.PromptTokens += int64(gen.Usage.PromptTokens)
+	result.CompletionTokens += int64(gen.Usage.CompletionTokens)
+	result.Cost += gen.Cost
+
+	items, err := parseGeneratedQuestions(gen.Content)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(items) > count {
+		items = items[:count]
+	}
+	if len(items) == 0 {
+		return 0, 0, errors.New("AI未返回题目")
+	}
+
+	candidates := make([]model.QuestionCandidate, 0, len(items))
+	withIssues := 0
+	for _, item := range items {
+		candidate := buildCandidate(item, params.Types)
+		candidate.CourseID = course.ID
+		candidate.JobID = jc.Job.ID
+		candidate.ChunkIndex = index
+		candidate.Model = gen.Model
+		candidate.PromptVersion = gen.PromptVersion
+		if candidate.Issues != "" {
+			withIssues++
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err := database.DB.Create(&candidates).Error; err != nil {
+		return 0, 0, fmt.Errorf("保存候选题目失败: %v", err)
+	}
+	return len(candidates), withIssues, nil
+}
+
+// buildCandidate 规范化模型返回的题目并校验，未通过校验的原因记录在 Issues 中
+func buildCandidate(item generatedQuestion, allowedTypes []string) model.QuestionCandidate {
+	candidate := model.QuestionCandidate{
+		Type:        normalizeGeneratedType(item.Type),
+		Question:    strings.TrimSpace(item.Question),
+		Explanation: strings.TrimSpace(item.Explanation),
+		Source:      strings.TrimSpace(item.Source),
+		Status:      model.CandidateStatusPending,
+	}
+
+	var issues []string
+	options, err := model.DecodeQuestionOptions(candidate.Type, string(item.Options))
+	if err != nil && len(options) == 0 {
+		issues = append(issues, "选项格式错误")
+	}
+	candidate.Options = model.CanonicalOptions(candidate.Type, options)
+	candidate.Answer = normalizeGeneratedAnswer(candidate.Type, item.Answer)
+
+	if candidate.Question == "" {
+		issues = append(issues, "题干为空")
+	}
+	allowed := false
+	for _, t := range allowedTypes {
+		allowed = allowed || t == candidate.Type
+	}
+	if !allowed {
+		issues = append(issues, "题型不在要求范围内")
+	}
+	if err := ValidateQuestionOptions(candidate.Type, candidate.Options, candidate.Answer); err != nil {
+		issues = append(issues, err.Error())
+	} else if issue := checkCandidateOptions(candidate.Options); issue != "" {
+		issues = append(issues, issue)
+	}
+
+	if len(candidate.Type) > 20 {
+		candidate.Type = candidate.Type[:20]
+	}
+	candidate.Issues = truncateMessage(strings.Join(issues, "；"), 500)
+	return candidate
+}
+
+// checkCandidateOptions 检查选项内容不为空且不重复
+func checkCandidateOptions(options model.QuestionOptions) string {
+	seen := make(map[string]bool, len(options))
+	for _, opt := range options {
+		if opt.Text == "" && len(opt.Media) == 0 {
+			return fmt.Sprintf("选项%s内容为空", opt.Label)
+		}
+		if opt.Text != "" && seen[opt.Text] {
+			return fmt.Sprintf("选项%s与其他选项重复", opt.Label)
+		}
+		seen[opt.Text] = true
+	}
+	return ""
+}
+
+// parseGeneratedQuestions 从模型回复中解析题目数组，兼容包裹在Markdown代码块或说明文字中的JSON
+func parseGeneratedQuestions(content string) ([]generatedQuestion, error) {
+	start := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("AI返回的内容不是题目数组")
+	}
+
+	var items []generatedQuestion
+	if err := json.Unmarshal([]byte(content[start:end+1]), &items); err != nil {
+		return nil, fmt.Errorf("解析AI返回的题目失败: %v", err)
+	}
+	return items, nil
+}
+
+// normalizeGeneratedType 将模型返回的题型转换为系统题型
+func normalizeGeneratedType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	switch t {
+	case "单选题", "单选", "single_choice":
+		return "single"
+	case "多选题", "多选", "multiple_choice":
+		return "multiple"
+	case "判断题", "判断", "true_false", "truefalse":
+		return "judge"
+	}
+	return t
+}
+
+// normalizeGeneratedAnswer 规范化答案：判断题兼容“正确/错误”，多选题字母去重并排序
+func normalizeGeneratedAnswer(qType, answer string) string {
+	answer = strings.TrimSpace(answer)
+	if qType == "judge" {
+		switch strings.ToLower(answer) {
+		case "正确", "对", "是", "true", "t", "√":
+			return "A"
+		case "错误", "错", "否", "false", "f", "×":
+			return "B"
+		}
+	}
+
+	cleaned := cleanAnswer(answer)
+	if qType != "multiple" {
+		return cleaned
+	}
+	letters := make(map[rune]bool)
+	for _, ch := range cleaned {
+		letters[ch] = true
+	}
+	sorted := make([]string, 0, len(letters))
+	for ch := range letters {
+		sorted = append(sorted, string(ch))
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, "")
+}
+
+// splitMaterial 按段落切分资料，每段不超过 size 个字符，超长段落按字符截断
+func splitMaterial(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+
+	for _, para := range strings.Split(text, "\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		runes := []rune(para)
+		if currentLen > 0 && currentLen+len(runes)+1 > size {
+			flush()
+		}
+		for len(runes) > size {
+			chunks = append(chunks, string(runes[:size]))
+			runes = runes[size:]
+		}
+		if currentLen > 0 {
+			current.WriteString("\n")
+			currentLen++
+		}
+		current.WriteString(string(runes))
+		currentLen += len(runes)
+	}
+	flush()
+	return chunks
+}
+
+// allocateQuestions 按资料片段长度分配题目数量，题目少于片段数时均匀分布在整份资料中
+func allocateQuestions(chunks []string, count int) []int {
+	total := 0
+	lengths := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		lengths[i] = utf8.RuneCountInString(chunk)
+		total += lengths[i]
+	}
+
+	quotas := make([]int, len(chunks))
+	cumulative := 0
+	for i := range chunks {
+		before := count * cumulative / total
+		cumulative += lengths[i]
+		quotas[i] = count*cumulative/total - before
+	}
+	return quotas
+}
+
+// Candidates 获取候选题目审核队列，待审核的先生成的在前，其他状态最新的在前
+func (s *QuestionGenerationService) Candidates(status string, courseID, jobID uint, page, size int) ([]model.QuestionCandidate, int64, error) {
+	db := database.DB.Model(&model.QuestionCandidate{})
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if courseID > 0 {
+		db = db.Where("course_id = ?", courseID)
+	}
+	if jobID > 0 {
+		db = db.Where("job_id = ?", jobID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "id DESC"
+	if status == model.CandidateStatusPending {
+		order = "id"
+	}
+	var candidates []model.QuestionCandidate
+	if err := db.Order(order).Offset((page - 1) * size).Limit(size).Find(&candidates).Error; err != nil {
+		return nil, 0, err
+	}
+	return candidates, total, nil
+}
+
+// Approve 采用候选题目并创建为正式题目，可先修改内容；修改后的题目需重新通过校验
+func (s *QuestionGenerationService) Approve(id, reviewerID uint, edit CandidateEdit) (*model.Question, error) {
+	var question *model.Question
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		candidate, err := s.lockCandidate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		final := CandidateEdit{
+			Type:        candidate.Type,
+			Question:    candidate.Question,
+			Options:     candidate.Options,
+			Answer:      candidate.Answer,
+			Explanation: candidate.Explanation,
+		}
+		if t := strings.TrimSpace(edit.Type); t != "" {
+			final.Type = t
+		}
+		if q := strings.TrimSpace(edit.Question); q != "" {
+			final.Question = q
+		}
+		if edit.Options != nil {
+			final.Options = edit.Options
+		}
+		if a := strings.ToUpper(strings.TrimSpace(edit.Answer)); a != "" {
+			final.Answer = a
+		}
+		if e := strings.TrimSpace(edit.Explanation); e != "" {
+			final.Explanation = e
+		}
+		final.Options = model.CanonicalOptions(final.Type, final.Options)
+		explanationEdited := final.Explanation != candidate.Explanation
+		edited := final.Type != candidate.Type || final.Question != candidate.Question ||
+			final.Answer != candidate.Answer || explanationEdited || !sameOptions(final.Options, candidate.Options)
+
+		if final.Question == "" {
+			return errors.New("题干不能为空")
+		}
+		if err := ValidateQuestionOptions(final.Type, final.Options, final.Answer); err != nil {
+			return err
+		}
+		if issue := checkCandidateOptions(final.Options); issue != "" {
+			return errors.New(issue)
+		}
+		if err := Media.NormalizeOptionRefs(final.Options); err != nil {
+			return err
+		}
+
+		var course model.Course
+		if err := tx.First(&course, candidate.CourseID).Error; err != nil {
+			return errors.New("课程不存在")
+		}
+
+		optionsJSON, err := EncodeQuestionOptions(final.Type, final.Options)
+		if err != nil {
+			return fmt.Errorf("处理选项数据失败: %v", err)
+		}
+		source := model.ExplanationSourceAI
+		if explanationEdited {
+			source = model.ExplanationSourceManual
+		}
+		question = &model.Question{
+			Type:              final.Type,
+			Question:          final.Question,
+			Answer:            final.Answer,
+			Explanation:       final.Explanation,
+			ExplanationSource: source,
+			CourseID:          candidate.CourseID,
+		}
+		if err := createImportedQuestion(tx, question, optionsJSON); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(candidate).Updates(map[string]interface{}{
+			"type":        final.Type,
+			"question":    final.Question,
+			"options":     final.Options,
+			"answer":      final.Answer,
+			"explanation": final.Explanation,
+			"issues":      "",
+			"status":      model.CandidateStatusApproved,
+			"question_id": question.ID,
+			"edited":      edited,
+			"reviewed_by": reviewerID,
+			"reviewed_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return question, nil
+}
+
+// Reject 驳回候选题目
+func (s *QuestionGenerationService) Reject(id, reviewerID uint, note string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		candidate, err := s.lockCandidate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(candidate).Updates(map[string]interface{}{
+			"status":      model.CandidateStatusRejected,
+			"review_note": truncateMessage(strings.TrimSpace(note), 500),
+			"reviewed_by": reviewerID,
+			"reviewed_at": now,
+		}).Error
+	})
+}
+
+// lockCandidate 锁定待审核的候选题目，已处理的不能再次审核
+func (s *QuestionGenerationService) lockCandidate(tx *gorm.DB, id uint) (*model.QuestionCandidate, error) {
+	var candidate model.QuestionCandidate
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&candidate, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCandidateNotFound
+		}
+		return nil, err
+	}
+	if candidate.Status != model.CandidateStatusPending {
+		return nil, errors.New("候选题目已处理")
+	}
+	return &candidate, nil
+}
+
+// sameOptions 比较两组选项的内容和媒体
+func sameOptions(a, b model.QuestionOptions) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Text != b[i].Text || len(a[i].Media) != len(b[i].Media) {
+			return false
+		}
+		for j := range a[i].Media {
+			if a[i].Media[j] != b[i].Media[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Moodle GIFT 格式，参见 https://docs.moodle.org/en/GIFT_format
+// 支持选择题（= 正确、~ 错误，带 %权重% 的多选）、判断题（{T}/{F}）、填空式选择题（题干在答案前后）及 #### 总体反馈（作为解析）
+// 简答题、匹配题、数值题、问答题和描述不支持
+
+// giftSpecialChars GIFT中需要转义的字符
+const giftSpecialChars = "~=#{}:"
+
+// parseGIFT 解析GIFT文本，题目之间以空行分隔
+func parseGIFT(text string) ([]exchangeQuestion, []exchangeSkip) {
+	var questions []exchangeQuestion
+	var skipped []exchangeSkip
+
+	lines := strings.Split(text, "\n")
+	var block []string
+	startLine := 0
+
+	flush := func() {
+		if len(block) == 0 {
+			return
+		}
+		ref := fmt.Sprintf("第%d行", startLine)
+		q, warnings, err := parseGIFTQuestion(strings.Join(block, "\n"))
+		block = block[:0]
+		if err != nil {
+			skipped = append(skipped, exchangeSkip{Ref: ref, Reason: err.Error()})
+			return
+		}
+		if q == nil {
+			return
+		}
+		q.Ref = ref
+		q.Warnings = warnings
+		questions = append(questions, *q)
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		// 注释行
+		if strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		// 分类由导入时指定的课程代替
+		if strings.HasPrefix(trimmed, "$CATEGORY:") {
+			continue
+		}
+		if len(block) == 0 {
+			startLine = i + 1
+		}
+		block = append(block, line)
+	}
+	flush()
+
+	return questions, skipped
+}
+
+// parseGIFTQuestion 解析单道GIFT题目
+func parseGIFTQuestion(src string) (*exchangeQuestion, []string, error) {
+	src = strings.TrimSpace(src)
+	var warnings []string
+
+	// 题目标题
+	if strings.HasPrefix(src, "::") {
+		end := indexUnescaped(src[2:], "::")
+		if end < 0 {
+			return nil, nil, fmt.Errorf("标题缺少结束的::")
+		}
+		src = strings.TrimSpace(src[2+end+2:])
+	}
+
+	open := indexUnescaped(src, "{")
+	if open < 0 {
+		return nil, nil, fmt.Errorf("描述（没有答案的内容）不支持")
+	}
+	closeRel := indexUnescaped(src[open+1:], "}")
+	if closeRel < 0 {
+		return nil, nil, fmt.Errorf("答案缺少结束的}")
+	}
+	before := src[:open]
+	body := strings.TrimSpace(src[open+1 : open+1+closeRel])
+	after := strings.TrimSpace(src[open+1+closeRel+1:])
+
+	// 文本格式标记
+	isHTML := false
+	before = strings.TrimSpace(before)
+	if strings.HasPrefix(before, "[") {
+		if end := strings.Index(before, "]"); end > 0 {
+			switch strings.ToLower(before[1:end]) {
+			case "html":
+				isHTML = true
+				before = before[end+1:]
+			case "moodle", "plain", "markdown":
+				before = before[end+1:]
+			}
+		}
+	}
+	convert := func(s string) string {
+		s = giftUnescape(s)
+		if isHTML {
+			return htmlToText(s)
+		}
+		return strings.TrimSpace(s)
+	}
+
+	stem := convert(before)
+	if after != "" {
+		// 填空式：答案位于题干中间，以下划线代替
+		stem = strings.TrimSpace(stem + " ____ " + convert(after))
+	}
+	if stem == "" {
+		return nil, nil, fmt.Errorf("题干为空")
+	}
+
+	if body == "" {
+		return nil, nil, fmt.Errorf("问答题不支持")
+	}
+	if strings.HasPrefix(body, "#") {
+		return nil, nil, fmt.Errorf("数值题不支持")
+	}
+
+	// 总体反馈作为解析
+	explanation := ""
+	if idx := indexUnescaped(body, "####"); idx >= 0 {
+		explanation = convert(body[idx+4:])
+		body = strings.TrimSpace(body[:idx])
+	}
+
+	// 判断题
+	judgeParts := splitUnescaped(body, '#')
+	switch strings.ToUpper(strings.TrimSpace(judgeParts[0])) {
+	case "T", "TRUE", "F", "FALSE":
+		if len(judgeParts) > 1 {
+			warnings = append(warnings, "答对/答错反馈已忽略")
+		}
+		answer := "B"
+		if v := strings.ToUpper(strings.TrimSpace(judgeParts[0])); v == "T" || v == "TRUE" {
+			answer = "A"
+		}
+		return &exchangeQuestion{
+			Type:        "judge",
+			Question:    stem,
+			Options:     []string{"正确", "错误"},
+			Answer:      answer,
+			Explanation: explanation,
+		}, warnings, nil
+	}
+
+	if indexUnescaped(body, "->") >= 0 {
+		return nil, nil, fmt.Errorf("匹配题不支持")
+	}
+
+	// 选项
+	type giftAnswer struct {
+		text      string
+		correct   bool
+		weight    float64
+		hasWeight bool
+	}
+	var answers []giftAnswer
+	hasWrongMarker := false
+	feedbackDropped := false
+	for _, item := range splitGIFTAnswers(body) {
+		a := giftAnswer{correct: item.marker == '='}
+		if item.marker == '~' {
+			hasWrongMarker = true
+		}
+		text := strings.TrimSpace(item.text)
+
+		// 权重 %50%
+		if strings.HasPrefix(text, "%") {
+			if end := strings.Index(text[1:], "%"); end >= 0 {
+				if w, err := strconv.ParseFloat(text[1:1+end], 64); err == nil {
+					a.weight, a.hasWeight = w, true
+					a.correct = w > 0
+					text = strings.TrimSpace(text[1+end+1:])
+				}
+			}
+		}
+
+		// 选项反馈
+		if parts := splitUnescaped(text, '#'); len(parts) > 1 {
+			feedbackDropped = true
+			text = parts[0]
+		}
+
+		a.text = convert(text)
+		answers = append(answers, a)
+	}
+
+	if len(answers) == 0 {
+		return nil, nil, fmt.Errorf("没有答案选项")
+	}
+	if !hasWrongMarker {
+		return nil, nil, fmt.Errorf("简答题不支持")
+	}
+
+	q := &exchangeQuestion{
+		Question:    stem,
+		Explanation: explanation,
+	}
+	var correct []int
+	partial := false
+	for i, a := range answers {
+		q.Options = append(q.Options, a.text)
+		if a.correct {
+			correct = append(correct, i)
+		}
+		if a.hasWeight && a.weight != 100 && a.weight != 0 && a.weight != -100 {
+			partial = true
+		}
+	}
+	q.Answer = answerLabels(correct)
+
+	if len(correct) > 1 {
+		q.Type = "multiple"
+		warnings = append(warnings, "多选题按全部选对计分，选项的部分得分已忽略")
+	} else {
+		q.Type = "single"
+		if partial {
+			warnings = append(warnings, "选项的部分得分已忽略")
+		}
+	}
+	if feedbackDropped {
+		warnings = append(warnings, "选项反馈已忽略")
+	}
+	detectJudge(q)
+
+	return q, warnings, nil
+}
+
+// giftAnswerItem 答案区中以 = 或 ~ 开头的一项
+type giftAnswerItem struct {
+	marker rune
+	text   string
+}
+
+// splitGIFTAnswers 按未转义的 = 和 ~ 拆分答案区
+func splitGIFTAnswers(body string) []giftAnswerItem {
+	var items []giftAnswerItem
+	var current *giftAnswerItem
+	var b strings.Builder
+
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		if ch == '\\' && i+1 < len(runes) {
+			b.WriteRune(ch)
+			b.WriteRune(runes[i+1])
+			i++
+			continue
+		}
+		if ch == '=' || ch == '~' {
+			if current != nil {
+				current.text = b.String()
+				items = append(items, *current)
+			}
+			current = &giftAnswerItem{marker: ch}
+			b.Reset()
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	if current != nil {
+		current.text = b.String()
+		items = append(items, *current)
+	}
+	return items
+}
+
+// indexUnescaped 查找未被反斜杠转义的子串位置
+func indexUnescaped(s, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitUnescaped 按未转义的分隔符拆分
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == sep {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// giftUnescape 还原转义字符，\n 转为换行
+func giftUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			next := s[i+1]
+			switch {
+			case next == 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case next == '\\' || strings.IndexByte(giftSpecialChars, next) >= 0:
+				b.WriteByte(next)
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// giftEscape 转义特殊字符，换行转为 \n 以免空行被当作题目分隔
+func giftEscape(s string) string {
+	var b strings.Builder
+	for _, ch := range strings.TrimSpace(s) {
+		switch {
+		case ch == '\\' || strings.ContainsRune(giftSpecialChars, ch):
+			b.WriteRune('\\')
+			b.WriteRune(ch)
+		case ch == '\n':
+			b.WriteString(`\n`)
+		case ch == '\r':
+		default:
+			b.WriteRune(ch)
+		}
+	}
+	return b.String()
+}
+
+// ExportGIFT 导出题库为Moodle GIFT格式，progress 可为nil
+func (s *QuestionIOService) ExportGIFT(ctx context.Context, w io.Writer, courseID uint, progress func(percent int)) (*ExportResult, error) {
+	result := &ExportResult{}
+	var limits exportLimits
+	bw := bufio.NewWriter(w)
+
+	err := forEachExportQuestion(ctx, courseID, scaleProgress(progress, 0, 100), func(q *exportQuestion) error {
+		limits.check(q, true)
+
+		fmt.Fprintf(bw, "// question: %d\n", q.ID)
+		fmt.Fprintf(bw, "::Q%d:: %s {\n", q.ID, giftEscape(q.Question))
+
+		switch q.Type {
+		case "judge":
+			if q.Answer == "A" {
+				bw.WriteString("T\n")
+			} else {
+				bw.WriteString("F\n")
+			}
+		case "multiple":
+			weight := strconv.FormatFloat(100/float64(len(q.Answer)), 'f', -1, 64)
+			if len(weight) > 8 {
+				weight = strconv.FormatFloat(100/float64(len(q.Answer)), 'f', 5, 64)
+			}
+			for _, opt := range q.Options {
+				if strings.Contains(q.Answer, opt.Label) {
+					fmt.Fprintf(bw, "~%%%s%%%s\n", weight, giftEscape(opt.Text))
+				} else {
+					fmt.Fprintf(bw, "~%%-100%%%s\n", giftEscape(opt.Text))
+				}
+			}
+		default:
+			for _, opt := range q.Options {
+				marker := "~"
+				if opt.Label == q.Answer {
+					marker = "="
+				}
+				fmt.Fprintf(bw, "%s%s\n", marker, giftEscape(opt.Text))
+			}
+		}
+
+		if strings.TrimSpace(q.Explanation) != "" {
+			fmt.Fprintf(bw, "####%s\n", giftEscape(q.Explanation))
+		}
+		bw.WriteString("}\n\n")
+
+		result.ExportCount++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	limits.report(result, "GIFT")
+	return result, bw.Flush()
+}
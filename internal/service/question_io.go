@@ -0,0 +1,932 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/logger"
+	"exam-system/internal/pkg/storage"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var QuestionIO = new(QuestionIOService)
+
+// QuestionIOService 题库导入导出
+type QuestionIOService struct{}
+
+// ImportResult 题库导入结果
+type ImportResult struct {
+	ImportCount int      `json:"import_count"`
+	ErrorCount  int      `json:"error_count"`
+	Errors      []string `json:"errors,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"` // 已导入但有内容无法完整表示的题目
+}
+
+// 题库CSV表头，媒体列为 MediaRef 数组的JSON字符串，章节列为 "章名称/小节名称"
+var questionCSVHeader = []string{"ID", "题目类型", "题目内容", "选项", "答案", "解析", "课程ID", "题目类型说明", "题干媒体", "解析媒体", "章节"}
+
+// 题库压缩包内的文件路径
+const (
+	archiveQuestionsFile = "questions.csv"
+	archiveMediaManifest = "media/manifest.json"
+)
+
+// archiveMediaEntry 压缩包中的媒体文件清单项
+type archiveMediaEntry struct {
+	ID          uint   `json:"id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Path        string `json:"path"`
+}
+
+// 题库导入导出任务类型
+const (
+	JobTypeQuestionImport = "question_import"
+	JobTypeQuestionExport = "question_export"
+)
+
+// QuestionImportParams 题库导入任务参数
+type QuestionImportParams struct {
+	Format     string `json:"format"` // csv、zip、gift、aiken 或 qti
+	Size       int64  `json:"size"`   // 上传文件大小
+	UploaderID uint   `json:"uploader_id"`
+	CourseID   uint   `json:"course_id"` // 导入的目标课程，GIFT、Aiken、QTI 格式必填
+}
+
+// QuestionExportParams 题库导出任务参数
+type QuestionExportParams struct {
+	CourseID uint   `json:"course_id"` // 为0时导出全部
+	Format   string `json:"format"`    // csv、zip、gift、aiken 或 qti
+}
+
+// exportQuestion 导出时读取的题目字段
+type exportQuestion struct {
+	ID                uint
+	Type              string
+	Question          string
+	StemMedia         model.MediaRefs
+	Options           model.QuestionOptions
+	Answer            string
+	Explanation       string
+	ExplanationMedia  model.MediaRefs
+	ExplanationSource string
+	CourseID          uint
+	SectionID         uint
+	IsTrial           bool
+}
+
+// EncodeQuestionOptions 将选项转换为数据库存储的规范格式（结构化数组），判断题固定为正确/错误
+func EncodeQuestionOptions(qType string, options model.QuestionOptions) (string, error) {
+	data, err := json.Marshal(model.CanonicalOptions(qType, options))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// encodeOptionsColumn 将选项转换为CSV中的选项列
+// 判断题固定为正确/错误；选项带媒体时为结构化数组，否则为便于编辑的 "A.选项内容" 字符串数组
+func encodeOptionsColumn(qType string, options model.QuestionOptions) (string, error) {
+	if qType == "judge" {
+		return `["A.正确","B.错误"]`, nil
+	}
+
+	hasMedia := false
+	for _, opt := range options {
+		if len(opt.Media) > 0 {
+			hasMedia = true
+			break
+		}
+	}
+
+	var data []byte
+	var err error
+	if hasMedia {
+		structured := make(model.QuestionOptions, len(options))
+		for i, opt := range options {
+			structured[i] = model.QuestionOption{
+				Label: string(rune('A' + i)),
+				Text:  opt.Text,
+				Media: opt.Media,
+			}
+		}
+		data, err = json.Marshal(structured)
+	} else {
+		formatted := make([]string, len(options))
+		for i, opt := range options {
+			formatted[i] = string(rune('A'+i)) + "." + opt.Text
+		}
+		data, err = json.Marshal(formatted)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// exportBatchSize 导出时每批读取的题目数量
+const exportBatchSize = 500
+
+// forEachExportQuestion 按ID分批读取题目，依次交给 fn 处理
+func forEachExportQuestion(ctx context.Context, courseID uint, progress func(done, total int64), fn func(q *exportQuestion) error) error {
+	query := func() *gorm.DB {
+		db := database.DB.Model(&model.Question{})
+		if courseID > 0 {
+			db = db.Where("course_id = ?", courseID)
+		}
+		return db
+	}
+
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		return errors.New("获取题目失败")
+	}
+
+	var lastID uint
+	var done int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var questions []exportQuestion
+		if err := query().Select("id, type, question, stem_media, options, answer, explanation, explanation_media, explanation_source, course_id, section_id, is_trial").
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(exportBatchSize).
+			Find(&questions).Error; err != nil {
+			return errors.New("获取题目失败")
+		}
+		if len(questions) == 0 {
+			break
+		}
+
+		for i := range questions {
+			if err := fn(&questions[i]); err != nil {
+				return err
+			}
+		}
+
+		lastID = questions[len(questions)-1].ID
+		done += int64(len(questions))
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+
+	return nil
+}
+
+// writeQuestionsCSV 按ID分批读取题目并写入CSV，返回题目中引用的媒体ID
+func writeQuestionsCSV(ctx context.Context, w io.Writer, courseID uint, progress func(done, total int64)) ([]uint, error) {
+	// 添加BOM头，解决Excel打开中文乱码问题
+	if _, err := w.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil {
+		return nil, err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(questionCSVHeader); err != nil {
+		return nil, err
+	}
+
+	sectionPaths, err := Chapter.sectionPaths()
+	if err != nil {
+		return nil, errors.New("获取章节失败")
+	}
+
+	// 收集题干、选项、解析中引用的媒体
+	var mediaIDs []uint
+	seen := make(map[uint]bool)
+	collect := func(refs []model.MediaRef) {
+		for _, ref := range refs {
+			if !seen[ref.ID] {
+				seen[ref.ID] = true
+				mediaIDs = append(mediaIDs, ref.ID)
+			}
+		}
+	}
+
+	err = forEachExportQuestion(ctx, courseID, progress, func(q *exportQuestion) error {
+		optionsStr, err := encodeOptionsColumn(q.Type, q.Options)
+		if err != nil {
+			return err
+		}
+
+		record := []string{
+			strconv.FormatUint(uint64(q.ID), 10),
+			q.Type,
+			q.Question,
+			optionsStr,
+			q.Answer,
+			q.Explanation,
+			strconv.FormatUint(uint64(q.CourseID), 10),
+			typeLabel(q.Type),
+			encodeMediaColumn(q.StemMedia),
+			encodeMediaColumn(q.ExplanationMedia),
+			sectionPaths[q.SectionID],
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+
+		collect(q.StemMedia)
+		collect(q.ExplanationMedia)
+		for _, opt := range q.Options {
+			collect(opt.Media)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+	return mediaIDs, writer.Error()
+}
+
+// encodeMediaColumn 媒体引用转换为CSV列内容，无媒体时为空
+func encodeMediaColumn(refs model.MediaRefs) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(refs)
+	return string(data)
+}
+
+// scaleProgress 将分段进度换算为整体百分比
+func scaleProgress(progress func(percent int), from, to int) func(done, total int64) {
+	return func(done, total int64) {
+		if progress == nil || total <= 0 {
+			return
+		}
+		progress(from + int(done*int64(to-from)/total))
+	}
+}
+
+// ExportCSV 导出题库为CSV，progress 可为nil
+func (s *QuestionIOService) ExportCSV(ctx context.Context, w io.Writer, courseID uint, progress func(percent int)) error {
+	_, err := writeQuestionsCSV(ctx, w, courseID, scaleProgress(progress, 0, 100))
+	return err
+}
+
+// ExportArchive 导出题库为zip压缩包，包含题目CSV和题目引用的全部媒体文件
+func (s *QuestionIOService) ExportArchive(ctx context.Context, w io.Writer, courseID uint, progress func(percent int)) error {
+	zw := zip.NewWriter(w)
+
+	csvWriter, err := zw.Create(archiveQuestionsFile)
+	if err != nil {
+		return err
+	}
+	// 题目占前80%进度，媒体文件占剩余部分
+	mediaIDs, err := writeQuestionsCSV(ctx, csvWriter, courseID, scaleProgress(progress, 0, 80))
+	if err != nil {
+		return err
+	}
+
+	manifest, err := writeArchiveMedia(ctx, zw, mediaIDs, scaleProgress(progress, 80, 100))
+	if err != nil {
+		return err
+	}
+
+	manifestWriter, err := zw.Create(archiveMediaManifest)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(manifestWriter).Encode(manifest); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeArchiveMedia 将媒体文件写入压缩包，返回媒体清单
+func writeArchiveMedia(ctx context.Context, zw *zip.Writer, mediaIDs []uint, progress func(done, total int64)) ([]archiveMediaEntry, error) {
+	manifest := make([]archiveMediaEntry, 0, len(mediaIDs))
+	if len(mediaIDs) == 0 {
+		return manifest, nil
+	}
+
+	var medias []model.Media
+	if err := database.DB.Where("id IN ?", mediaIDs).Find(&medias).Error; err != nil {
+		return nil, fmt.Errorf("查询媒体文件失败: %v", err)
+	}
+
+	for i, m := range medias {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entryPath := fmt.Sprintf("media/%d/%s", m.ID, path.Base(m.FileName))
+		if err := copyMediaToArchive(zw, m, entryPath); err != nil {
+			return nil, err
+		}
+		manifest = append(manifest, archiveMediaEntry{
+			ID:          m.ID,
+			FileName:    m.FileName,
+			ContentType: m.ContentType,
+			Path:        entryPath,
+		})
+		progress(int64(i+1), int64(len(medias)))
+	}
+
+	return manifest, nil
+}
+
+// copyMediaToArchive 复制单个媒体文件到压缩包
+func copyMediaToArchive(zw *zip.Writer, m model.Media, entryPath string) error {
+	reader, err := storage.Store.Open(m.StorageKey)
+	if err != nil {
+		return fmt.Errorf("读取媒体文件 %d 失败: %v", m.ID, err)
+	}
+	defer reader.Close()
+
+	entry, err := zw.Create(entryPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+// progressReader 按已读取的字节数报告进度
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	total    int64
+	progress func(done, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	p.progress(p.read, p.total)
+	return n, err
+}
+
+// ImportCSV 从CSV导入题库，size 为文件大小，用于计算进度，progress 可为nil
+func (s *QuestionIOService) ImportCSV(ctx context.Context, r io.Reader, size int64, progress func(percent int)) (*ImportResult, error) {
+	tx := database.DB.Begin()
+	result, err := s.importCSV(ctx, tx, &progressReader{r: r, total: size, progress: scaleProgress(progress, 0, 100)}, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err := commitImport(tx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportArchive 从zip压缩包导入题库，压缩包中的媒体文件会重新保存并替换题目中的媒体ID
+func (s *QuestionIOService) ImportArchive(ctx context.Context, r io.ReaderAt, size int64, uploaderID uint, progress func(percent int)) (*ImportResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("压缩包格式不正确")
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	csvFile, exists := files[archiveQuestionsFile]
+	if !exists {
+		return nil, fmt.Errorf("压缩包中缺少 %s", archiveQuestionsFile)
+	}
+
+	// 媒体记录与题目在同一事务中保存，回滚时删除本次新写入的媒体文件
+	tx := database.DB.Begin()
+	var savedKeys []string
+	result, err := func() (*ImportResult, error) {
+		// 媒体文件占前20%进度，题目占剩余部分
+		mediaMap, keys, err := importArchiveMedia(ctx, tx, files, uploaderID, scaleProgress(progress, 0, 20))
+		savedKeys = keys
+		if err != nil {
+			return nil, err
+		}
+
+		rc, err := csvFile.Open()
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %v", archiveQuestionsFile, err)
+		}
+		defer rc.Close()
+
+		return s.importCSV(ctx, tx, &progressReader{
+			r:        rc,
+			total:    int64(csvFile.UncompressedSize64),
+			progress: scaleProgress(progress, 20, 100),
+		}, mediaMap)
+	}()
+	if err != nil {
+		tx.Rollback()
+		discardMediaFiles(savedKeys)
+		return nil, err
+	}
+
+	committed, err := commitImport(tx, result)
+	if !committed {
+		discardMediaFiles(savedKeys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importArchiveMedia 在事务中保存压缩包中的媒体文件，返回旧媒体ID到新媒体ID的映射和新写入文件的存储路径
+func importArchiveMedia(ctx context.Context, tx *gorm.DB, files map[string]*zip.File, uploaderID uint, progress func(done, total int64)) (map[uint]uint, []string, error) {
+	manifestFile, exists := files[archiveMediaManifest]
+	if !exists {
+		return make(map[uint]uint), nil, nil
+	}
+
+	rc, err := manifestFile.Open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取媒体清单失败: %v", err)
+	}
+	var manifest []archiveMediaEntry
+	err = json.NewDecoder(rc).Decode(&manifest)
+	rc.Close()
+	if err != nil {
+		return nil, nil, errors.New("媒体清单格式不正确")
+	}
+
+	return saveArchiveMedia(ctx, tx, files, manifest, uploaderID, progress)
+}
+
+// saveArchiveMedia 按媒体清单在事务中保存压缩包中的媒体文件，返回旧媒体ID到新媒体ID的映射
+// 以及新写入文件的存储路径（复用已有媒体的不包含在内），事务回滚后调用方通过 discardMediaFiles 删除
+// 出错时返回已写入的存储路径
+func saveArchiveMedia(ctx context.Context, tx *gorm.DB, files map[string]*zip.File, manifest []archiveMediaEntry, uploaderID uint, progress func(done, total int64)) (map[uint]uint, []string, error) {
+	mediaMap := make(map[uint]uint)
+	var savedKeys []string
+	for i, entry := range manifest {
+		if err := ctx.Err(); err != nil {
+			return nil, savedKeys, err
+		}
+
+		f, exists := files[entry.Path]
+		if !exists {
+			return nil, savedKeys, fmt.Errorf("压缩包中缺少媒体文件 %s", entry.Path)
+		}
+
+		src, err := f.Open()
+		if err != nil {
+			return nil, savedKeys, fmt.Errorf("读取媒体文件 %s 失败: %v", entry.Path, err)
+		}
+		media, created, err := Media.save(tx, entry.FileName, src, uploaderID)
+		src.Close()
+		if err != nil {
+			return nil, savedKeys, fmt.Errorf("保存媒体文件 %s 失败: %v", entry.Path, err)
+		}
+		if created {
+			savedKeys = append(savedKeys, media.StorageKey)
+		}
+
+		mediaMap[entry.ID] = media.ID
+		progress(int64(i+1), int64(len(manifest)))
+	}
+
+	return mediaMap, savedKeys, nil
+}
+
+// discardMediaFiles 导入事务回滚后删除本次新写入的媒体文件，媒体记录已随事务撤销
+func discardMediaFiles(keys []string) {
+	for _, key := range keys {
+		if err := storage.Store.Delete(key); err != nil {
+			logger.Errorf("删除媒体文件 %s 失败: %v", key, err)
+		}
+	}
+}
+
+// parseMediaColumn 解析CSV中的媒体列，并按映射替换媒体ID
+func parseMediaColumn(tx *gorm.DB, value string, mediaMap map[uint]uint) (model.MediaRefs, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var refs model.MediaRefs
+	if err := json.Unmarshal([]byte(value), &refs); err != nil {
+		return nil, errors.New("媒体JSON格式错误")
+	}
+	if err := remapMediaRefs(refs, mediaMap); err != nil {
+		return nil, err
+	}
+
+	return Media.normalizeRefs(tx, refs)
+}
+
+// remapMediaRefs 按映射替换媒体ID，mediaMap 为nil时（导入CSV文件）保留原ID
+// 从压缩包导入时引用的媒体必须包含在压缩包中，否则原ID可能指向本系统中无关的媒体
+func remapMediaRefs(refs []model.MediaRef, mediaMap map[uint]uint) error {
+	if mediaMap == nil {
+		return nil
+	}
+	for i := range refs {
+		newID, exists := mediaMap[refs[i].ID]
+		if !exists {
+			return fmt.Errorf("引用的媒体 %d 不在压缩包中", refs[i].ID)
+		}
+		refs[i].ID = newID
+	}
+	return nil
+}
+
+// parseOptionsColumn 解析CSV中的选项列，支持结构化选项数组和 "A.选项内容" 字符串数组
+func parseOptionsColumn(tx *gorm.DB, value string, mediaMap map[uint]uint) (model.QuestionOptions, error) {
+	var structured model.QuestionOptions
+	if err := json.Unmarshal([]byte(value), &structured); err == nil {
+		options := make(model.QuestionOptions, len(structured))
+		for i, opt := range structured {
+			if err := remapMediaRefs(opt.Media, mediaMap); err != nil {
+				return nil, err
+			}
+			options[i] = model.QuestionOption{
+				Label: string(rune('A' + i)),
+				Text:  strings.TrimSpace(opt.Text),
+				Media: opt.Media,
+			}
+		}
+		if err := Media.normalizeOptionRefs(tx, options); err != nil {
+			return nil, err
+		}
+		return options, nil
+	}
+
+	var texts []string
+	if err := json.Unmarshal([]byte(value), &texts); err != nil {
+		return nil, errors.New("选项JSON格式错误")
+	}
+
+	options := make(model.QuestionOptions, len(texts))
+	for i, text := range texts {
+		// 清理选项文本，确保只保留实际内容
+		optionText := strings.TrimSpace(text)
+
+		// 如果文本包含标签前缀（如 "A.选项内容"），则提取实际内容
+		parts := strings.SplitN(optionText, ".", 2)
+		if len(parts) == 2 {
+			optionText = strings.TrimSpace(parts[1])
+		}
+
+		options[i] = model.QuestionOption{
+			Label: string(rune('A' + i)),
+			Text:  optionText,
+		}
+	}
+
+	return options, nil
+}
+
+// maxImportErrors 导入结果中保留的错误明细条数，错误总数仍完整统计
+const maxImportErrors = 100
+
+// importCSV 在事务中逐行导入题目，出错的行跳过并记录原因
+// 返回错误（如任务取消）时调用方回滚事务，否则通过 commitImport 结束事务
+func (s *QuestionIOService) importCSV(ctx context.Context, tx *gorm.DB, r io.Reader, mediaMap map[uint]uint) (*ImportResult, error) {
+	// 检测并跳过BOM头
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bom[0] == 0xEF && bom[1] == 0xBB && bom[2] == 0xBF {
+		br.Discard(3)
+	}
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1 // 允许每行不同的字段数
+
+	// 读取并跳过第一行（表头）
+	if _, err := reader.Read(); err != nil {
+		return nil, errors.New("CSV文件格式不正确")
+	}
+
+	sections := newSectionResolver(tx)
+
+	result := &ImportResult{}
+	addError := func(lineNum int, format string, args ...interface{}) {
+		result.ErrorCount++
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, fmt.Sprintf("第%d行: ", lineNum)+fmt.Sprintf(format, args...))
+		}
+	}
+
+	for lineNum := 2; ; lineNum++ { // 从第2行开始（表头为第1行）
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			addError(lineNum, "读取错误")
+			continue
+		}
+
+		if len(record) < 7 {
+			addError(lineNum, "字段数量不足")
+			continue // 跳过格式不正确的行
+		}
+
+		// 解析数据
+		courseID, err := strconv.ParseUint(record[6], 10, 32)
+		if err != nil {
+			addError(lineNum, "课程ID格式错误")
+			continue
+		}
+
+		// 验证课程是否存在
+		var courseCount int64
+		if err := tx.Model(&model.Course{}).Where("id = ?", courseID).Count(&courseCount).Error; err != nil || courseCount == 0 {
+			addError(lineNum, "课程ID %d 不存在", courseID)
+			continue
+		}
+
+		questionType := strings.TrimSpace(record[1])
+		// 验证题目类型
+		if questionType != "single" && questionType != "multiple" && questionType != "judge" {
+			addError(lineNum, "题目类型错误，只支持single(单选题)、multiple(多选题)或judge(判断题)")
+			continue
+		}
+
+		// 处理选项
+		var questionOptions model.QuestionOptions
+		if questionType == "judge" {
+			// 判断题固定选项格式
+			questionOptions = model.QuestionOptions{
+				{Label: "A", Text: "正确"},
+				{Label: "B", Text: "错误"},
+			}
+		} else {
+			optionsField := strings.TrimSpace(record[3])
+			if optionsField == "" {
+				addError(lineNum, "选项为空")
+				continue
+			}
+
+			questionOptions, err = parseOptionsColumn(tx, optionsField, mediaMap)
+			if err != nil {
+				addError(lineNum, "%s", err.Error())
+				continue
+			}
+		}
+
+		optionsJSON, err := EncodeQuestionOptions(questionType, questionOptions)
+		if err != nil {
+			addError(lineNum, "选项格式化失败")
+			continue
+		}
+
+		// 验证答案
+		answer := strings.TrimSpace(record[4])
+		if answer == "" {
+			addError(lineNum, "答案为空")
+			continue
+		}
+
+		// 清理答案，确保只包含选项序号（ABCDE等）
+		answer = cleanAnswer(answer)
+
+		if msg := checkImportAnswer(questionType, answer, len(questionOptions)); msg != "" {
+			addError(lineNum, "%s", msg)
+			continue
+		}
+
+		// 媒体列
+		var stemMedia, explanationMedia model.MediaRefs
+		if len(record) > 8 {
+			if stemMedia, err = parseMediaColumn(tx, record[8], mediaMap); err != nil {
+				addError(lineNum, "题干媒体: %s", err.Error())
+				continue
+			}
+		}
+		if len(record) > 9 {
+			if explanationMedia, err = parseMediaColumn(tx, record[9], mediaMap); err != nil {
+				addError(lineNum, "解析媒体: %s", err.Error())
+				continue
+			}
+		}
+
+		// 章节列，课程下不存在的章和小节自动创建
+		var sectionID uint
+		if len(record) > 10 {
+			if sectionID, err = sections.resolve(uint(courseID), record[10]); err != nil {
+				addError(lineNum, "%s", err.Error())
+				continue
+			}
+		}
+
+		// 创建题目
+		question := model.Question{
+			Type:             questionType,
+			Question:         record[2],
+			StemMedia:        stemMedia,
+			Answer:           answer,
+			Explanation:      record[5],
+			ExplanationMedia: explanationMedia,
+			CourseID:         uint(courseID),
+			SectionID:        sectionID,
+		}
+
+		if err := createImportedQuestion(tx, &question, optionsJSON); err != nil {
+			addError(lineNum, "%s", err.Error())
+			continue
+		}
+
+		result.ImportCount++
+	}
+
+	return result, nil
+}
+
+// commitImport 结束导入事务：有成功导入的记录时即使存在错误也提交，全部失败时回滚，返回事务是否已提交
+func commitImport(tx *gorm.DB, result *ImportResult) (bool, error) {
+	if result.ImportCount > 0 {
+		if err := tx.Commit().Error; err != nil {
+			tx.Rollback()
+			return false, errors.New("导入失败: " + err.Error())
+		}
+	} else if result.ErrorCount > 0 {
+		// 如果全部导入失败，回滚事务
+		tx.Rollback()
+		return false, nil
+	} else {
+		// 如果没有记录，也提交事务
+		if err := tx.Commit().Error; err != nil {
+			return false, errors.New("导入失败: " + err.Error())
+		}
+	}
+	return true, nil
+}
+
+// createImportedQuestion 保存导入的题目及其选项，并更新检索文档
+func createImportedQuestion(tx *gorm.DB, question *model.Question, optionsJSON string) error {
+	// 跳过ID字段，让数据库自动生成
+	if err := tx.Omit("Options").Create(question).Error; err != nil {
+		return fmt.Errorf("创建题目失败: %s", err.Error())
+	}
+
+	// 使用原生SQL更新options字段为正确的JSON格式
+	if err := tx.Exec("UPDATE questions SET options = ? WHERE id = ?", optionsJSON, question.ID).Error; err != nil {
+		return fmt.Errorf("更新选项数据失败: %s", err.Error())
+	}
+
+	return QuestionSearch.IndexTx(tx, question.ID)
+}
+
+// checkImportAnswer 根据题目类型验证答案格式，返回错误说明
+func checkImportAnswer(questionType, answer string, optionCount int) string {
+	switch questionType {
+	case "judge":
+		if answer != "A" && answer != "B" {
+			return "判断题答案必须为A(正确)或B(错误)"
+		}
+	case "single":
+		// 单选题答案必须是A-Z中的一个字母
+		if len(answer) != 1 || answer[0] < 'A' || answer[0] > 'Z' {
+			return "单选题答案必须是A-Z中的一个字母"
+		}
+		// 检查答案是否在选项范围内
+		if int(answer[0]-'A') >= optionCount {
+			return fmt.Sprintf("答案%s超出了选项范围", answer)
+		}
+	case "multiple":
+		// 多选题答案必须是A-Z的组合，且在选项范围内
+		for _, ch := range answer {
+			if int(ch-'A') >= optionCount {
+				return fmt.Sprintf("答案%s中的%c超出了选项范围", answer, ch)
+			}
+		}
+	}
+	return ""
+}
+
+// cleanAnswer 清理答案，确保只包含选项序号（ABCDE等）
+func cleanAnswer(answer string) string {
+	// 将答案转为大写
+	answer = strings.ToUpper(answer)
+
+	// 过滤出所有A-Z的字符
+	var result strings.Builder
+	for _, ch := range answer {
+		if ch >= 'A' && ch <= 'Z' {
+			result.WriteRune(ch)
+		}
+	}
+
+	return result.String()
+}
+
+// runImportJob 执行题库导入任务
+func (s *QuestionIOService) runImportJob(jc *JobContext) error {
+	var params QuestionImportParams
+	if err := jc.Bind(&params); err != nil {
+		return err
+	}
+
+	input, err := jc.OpenInput()
+	if err != nil {
+		return fmt.Errorf("读取上传文件失败: %v", err)
+	}
+	defer input.Close()
+
+	if params.Format == "" || params.Format == "csv" {
+		result, err := s.ImportCSV(jc, input, params.Size, jc.SetProgress)
+		if err != nil {
+			return err
+		}
+		return jc.SetResult(result)
+	}
+
+	// 其他格式需要随机访问（zip）或整体读取，先复制到临时文件
+	tmp, err := os.CreateTemp("", "question-import-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, input)
+	if err != nil {
+		return fmt.Errorf("读取上传文件失败: %v", err)
+	}
+
+	format := params.Format
+	if format == "zip" && isQTIPackage(tmp, size) {
+		format = "qti"
+	}
+
+	var result *ImportResult
+	switch format {
+	case "zip":
+		result, err = s.ImportArchive(jc, tmp, size, params.UploaderID, jc.SetProgress)
+	case "gift", "aiken", "qti":
+		result, err = s.ImportExchange(jc, format, tmp, size, params.CourseID, jc.SetProgress)
+	default:
+		return fmt.Errorf("不支持的导入格式: %s", params.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	return jc.SetResult(result)
+}
+
+// runExportJob 执行题库导出任务，结果文件供下载
+func (s *QuestionIOService) runExportJob(jc *JobContext) error {
+	var params QuestionExportParams
+	if err := jc.Bind(&params); err != nil {
+		return err
+	}
+
+	switch params.Format {
+	case "zip":
+		return jc.WriteArtifact("questions.zip", func(w io.Writer) error {
+			return s.ExportArchive(jc, w, params.CourseID, jc.SetProgress)
+		})
+	case "", "csv":
+		return jc.WriteArtifact("questions.csv", func(w io.Writer) error {
+			return s.ExportCSV(jc, w, params.CourseID, jc.SetProgress)
+		})
+	}
+
+	// 交换格式记录无法表示的内容
+	var result *ExportResult
+	var err error
+	switch params.Format {
+	case "gift":
+		err = jc.WriteArtifact("questions.gift.txt", func(w io.Writer) (writeErr error) {
+			result, writeErr = s.ExportGIFT(jc, w, params.CourseID, jc.SetProgress)
+			return writeErr
+		})
+	case "aiken":
+		err = jc.WriteArtifact("questions.aiken.txt", func(w io.Writer) (writeErr error) {
+			result, writeErr = s.ExportAiken(jc, w, params.CourseID, jc.SetProgress)
+			return writeErr
+		})
+	case "qti":
+		err = jc.WriteArtifact("questions.qti.zip", func(w io.Writer) (writeErr error) {
+			result, writeErr = s.ExportQTI(jc, w, params.CourseID, jc.SetProgress)
+			return writeErr
+		})
+	default:
+		return fmt.Errorf("不支持的导出格式: %s", params.Format)
+	}
+	if err != nil {
+		return err
+	}
+	return jc.SetResult(result)
+}
@@ -0,0 +1,533 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// IMS QTI 2.1 题目包：imsmanifest.xml 列出全部题目，每道题为一个 assessmentItem 文件
+// 只支持单个 choiceInteraction 的题目，其他交互类型（填空、排序、匹配、问答等）不支持
+
+const (
+	qtiManifestFile   = "imsmanifest.xml"
+	qtiItemNamespace  = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	qtiCPNamespace    = "http://www.imsglobal.org/xsd/imscp_v1p1"
+	qtiItemType       = "imsqti_item_xmlv2p1"
+	qtiMatchCorrect   = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"
+	qtiResponseID     = "RESPONSE"
+	qtiMaxItemSize    = 10 << 20 // 单个题目文件最大10MB
+	qtiExplanationID  = "EXPLANATION"
+	qtiFeedbackOutput = "FEEDBACK"
+)
+
+// qtiManifest 题目包清单
+type qtiManifest struct {
+	XMLName       xml.Name      `xml:"manifest"`
+	Xmlns         string        `xml:"xmlns,attr,omitempty"`
+	Identifier    string        `xml:"identifier,attr"`
+	Organizations struct{}      `xml:"organizations"`
+	Resources     []qtiResource `xml:"resources>resource"`
+}
+
+type qtiResource struct {
+	Identifier string    `xml:"identifier,attr"`
+	Type       string    `xml:"type,attr"`
+	Href       string    `xml:"href,attr"`
+	Files      []qtiFile `xml:"file"`
+}
+
+type qtiFile struct {
+	Href string `xml:"href,attr"`
+}
+
+// qtiItem 导出的 assessmentItem
+type qtiItem struct {
+	XMLName             xml.Name                `xml:"assessmentItem"`
+	Xmlns               string                  `xml:"xmlns,attr"`
+	Identifier          string                  `xml:"identifier,attr"`
+	Title               string                  `xml:"title,attr"`
+	Adaptive            bool                    `xml:"adaptive,attr"`
+	TimeDependent       bool                    `xml:"timeDependent,attr"`
+	ResponseDeclaration qtiResponseDeclaration  `xml:"responseDeclaration"`
+	OutcomeDeclarations []qtiOutcomeDeclaration `xml:"outcomeDeclaration"`
+	ItemBody            qtiItemBody             `xml:"itemBody"`
+	ResponseProcessing  qtiResponseProcessing   `xml:"responseProcessing"`
+	ModalFeedback       *qtiModalFeedback       `xml:"modalFeedback,omitempty"`
+}
+
+type qtiResponseDeclaration struct {
+	Identifier      string   `xml:"identifier,attr"`
+	Cardinality     string   `xml:"cardinality,attr"`
+	BaseType        string   `xml:"baseType,attr"`
+	CorrectResponse []string `xml:"correctResponse>value"`
+}
+
+type qtiOutcomeDeclaration struct {
+	Identifier  string `xml:"identifier,attr"`
+	Cardinality string `xml:"cardinality,attr"`
+	BaseType    string `xml:"baseType,attr"`
+}
+
+type qtiItemBody struct {
+	Paragraphs  []string             `xml:"p"`
+	Interaction qtiChoiceInteraction `xml:"choiceInteraction"`
+}
+
+type qtiChoiceInteraction struct {
+	ResponseIdentifier string            `xml:"responseIdentifier,attr"`
+	Shuffle            bool              `xml:"shuffle,attr"`
+	MaxChoices         int               `xml:"maxChoices,attr"`
+	Choices            []qtiSimpleChoice `xml:"simpleChoice"`
+}
+
+type qtiSimpleChoice struct {
+	Identifier string `xml:"identifier,attr"`
+	Text       string `xml:",chardata"`
+}
+
+type qtiResponseProcessing struct {
+	Template string `xml:"template,attr"`
+}
+
+type qtiModalFeedback struct {
+	OutcomeIdentifier string `xml:"outcomeIdentifier,attr"`
+	ShowHide          string `xml:"showHide,attr"`
+	Identifier        string `xml:"identifier,attr"`
+	Text              string `xml:",chardata"`
+}
+
+// xmlNode 解析任意XML元素，用于读取结构不固定的题目文件
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   []byte     `xml:",innerxml"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *xmlNode) child(name string) *xmlNode {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == name {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// walk 深度优先遍历所有子孙元素
+func (n *xmlNode) walk(fn func(node *xmlNode)) {
+	for i := range n.Nodes {
+		fn(&n.Nodes[i])
+		n.Nodes[i].walk(fn)
+	}
+}
+
+// qtiBlockElements 结束时换行的块级元素
+var qtiBlockElements = map[string]bool{"p": true, "div": true, "br": true, "li": true, "prompt": true}
+
+// text 提取元素中的文本，忽略内嵌的反馈
+func (n *xmlNode) text() string {
+	dec := xml.NewDecoder(bytes.NewReader(n.Inner))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	var b strings.Builder
+	skipDepth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 || strings.HasPrefix(t.Name.Local, "feedback") {
+				skipDepth++
+			}
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if qtiBlockElements[t.Name.Local] {
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if skipDepth == 0 {
+				b.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(blankLinePattern.ReplaceAllString(b.String(), "\n"))
+}
+
+// hasMedia 元素中是否包含图片等媒体
+func (n *xmlNode) hasMedia() bool {
+	found := false
+	n.walk(func(node *xmlNode) {
+		switch node.XMLName.Local {
+		case "img", "object", "audio", "video":
+			found = true
+		}
+	})
+	return found
+}
+
+// isQTIPackage 压缩包中是否包含QTI清单文件
+func isQTIPackage(r io.ReaderAt, size int64) bool {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return false
+	}
+	for _, f := range zr.File {
+		if f.Name == qtiManifestFile {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQTIPackage 解析QTI题目包，按清单顺序读取题目
+func parseQTIPackage(r io.ReaderAt, size int64) ([]exchangeQuestion, []exchangeSkip, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, nil, errors.New("压缩包格式不正确")
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	manifestFile, exists := files[qtiManifestFile]
+	if !exists {
+		return nil, nil, fmt.Errorf("题目包中缺少 %s", qtiManifestFile)
+	}
+	data, err := readZipFile(manifestFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取 %s 失败: %v", qtiManifestFile, err)
+	}
+	var manifest qtiManifest
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%s 格式不正确", qtiManifestFile)
+	}
+
+	var questions []exchangeQuestion
+	var skipped []exchangeSkip
+	for _, res := range manifest.Resources {
+		ref := res.Href
+		if ref == "" {
+			ref = res.Identifier
+		}
+		if !strings.HasPrefix(res.Type, "imsqti_item_xmlv2p") {
+			if strings.HasPrefix(res.Type, "imsqti_") {
+				skipped = append(skipped, exchangeSkip{Ref: ref, Reason: fmt.Sprintf("资源类型 %s 不支持", res.Type)})
+			}
+			continue
+		}
+
+		f, exists := files[path.Clean(res.Href)]
+		if !exists {
+			skipped = append(skipped, exchangeSkip{Ref: ref, Reason: "题目包中缺少该文件"})
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			skipped = append(skipped, exchangeSkip{Ref: ref, Reason: err.Error()})
+			continue
+		}
+
+		q, err := parseQTIItem(data)
+		if err != nil {
+			skipped = append(skipped, exchangeSkip{Ref: ref, Reason: err.Error()})
+			continue
+		}
+		q.Ref = ref
+		questions = append(questions, *q)
+	}
+
+	return questions, skipped, nil
+}
+
+// readZipFile 读取压缩包中的单个文件
+func readZipFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > qtiMaxItemSize {
+		return nil, errors.New("文件过大")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, qtiMaxItemSize))
+}
+
+// parseQTIItem 解析单个 assessmentItem
+func parseQTIItem(data []byte) (*exchangeQuestion, error) {
+	var item xmlNode
+	if err := xml.Unmarshal(data, &item); err != nil {
+		return nil, errors.New("XML格式不正确")
+	}
+	if item.XMLName.Local != "assessmentItem" {
+		return nil, errors.New("不是 assessmentItem 文件")
+	}
+
+	body := item.child("itemBody")
+	if body == nil {
+		return nil, errors.New("缺少 itemBody")
+	}
+
+	// 只支持单个选择交互
+	var interactions []*xmlNode
+	body.walk(func(node *xmlNode) {
+		if strings.HasSuffix(node.XMLName.Local, "Interaction") {
+			interactions = append(interactions, node)
+		}
+	})
+	if len(interactions) == 0 {
+		return nil, errors.New("没有答题交互")
+	}
+	if len(interactions) > 1 {
+		return nil, errors.New("包含多个答题交互，不支持")
+	}
+	interaction := interactions[0]
+	if interaction.XMLName.Local != "choiceInteraction" {
+		return nil, fmt.Errorf("交互类型 %s 不支持", interaction.XMLName.Local)
+	}
+	if !containsNode(body.Nodes, interaction) {
+		return nil, errors.New("选择交互嵌套在其他元素中，不支持")
+	}
+
+	var warnings []string
+
+	// 题干：交互之外的内容加上交互的 prompt
+	var stem []string
+	for i := range body.Nodes {
+		node := &body.Nodes[i]
+		if node == interaction {
+			if prompt := node.child("prompt"); prompt != nil {
+				stem = append(stem, prompt.text())
+			}
+			continue
+		}
+		if t := node.text(); t != "" {
+			stem = append(stem, t)
+		}
+	}
+
+	// 选项
+	var options []string
+	index := make(map[string]int)
+	feedbackDropped := false
+	for i := range interaction.Nodes {
+		choice := &interaction.Nodes[i]
+		if choice.XMLName.Local != "simpleChoice" {
+			continue
+		}
+		index[choice.attr("identifier")] = len(options)
+		options = append(options, choice.text())
+		if choice.child("feedbackInline") != nil {
+			feedbackDropped = true
+		}
+	}
+
+	// 正确答案
+	var correct []int
+	cardinality := "single"
+	for i := range item.Nodes {
+		decl := &item.Nodes[i]
+		if decl.XMLName.Local != "responseDeclaration" || decl.attr("identifier") != interaction.attr("responseIdentifier") {
+			continue
+		}
+		cardinality = decl.attr("cardinality")
+		if cr := decl.child("correctResponse"); cr != nil {
+			for j := range cr.Nodes {
+				if idx, exists := index[strings.TrimSpace(cr.Nodes[j].text())]; exists {
+					correct = append(correct, idx)
+				}
+			}
+		}
+		if decl.child("mapping") != nil {
+			warnings = append(warnings, "选项的部分得分已忽略，按全部选对计分")
+		}
+	}
+	sort.Ints(correct)
+
+	// 反馈作为解析
+	var explanation []string
+	for i := range item.Nodes {
+		if item.Nodes[i].XMLName.Local == "modalFeedback" {
+			if t := item.Nodes[i].text(); t != "" {
+				explanation = append(explanation, t)
+			}
+		}
+	}
+	body.walk(func(node *xmlNode) {
+		if node.XMLName.Local == "feedbackBlock" {
+			if t := node.text(); t != "" {
+				explanation = append(explanation, t)
+			}
+		}
+	})
+
+	if feedbackDropped {
+		warnings = append(warnings, "选项反馈已忽略")
+	}
+	if body.hasMedia() {
+		warnings = append(warnings, "图片等媒体未导入")
+	}
+	if interaction.attr("shuffle") == "true" {
+		warnings = append(warnings, "选项乱序设置已忽略")
+	}
+
+	q := &exchangeQuestion{
+		Type:        "single",
+		Question:    strings.Join(stem, "\n"),
+		Options:     options,
+		Answer:      answerLabels(correct),
+		Explanation: strings.Join(explanation, "\n"),
+		Warnings:    warnings,
+	}
+	if cardinality == "multiple" {
+		q.Type = "multiple"
+	}
+	detectJudge(q)
+
+	return q, nil
+}
+
+// containsNode 元素是否为直接子元素
+func containsNode(nodes []xmlNode, target *xmlNode) bool {
+	for i := range nodes {
+		if &nodes[i] == target {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportQTI 导出题库为 IMS QTI 2.1 题目包（zip），progress 可为nil
+func (s *QuestionIOService) ExportQTI(ctx context.Context, w io.Writer, courseID uint, progress func(percent int)) (*ExportResult, error) {
+	result := &ExportResult{}
+	var limits exportLimits
+	zw := zip.NewWriter(w)
+
+	manifest := qtiManifest{
+		Xmlns:      qtiCPNamespace,
+		Identifier: "MANIFEST-QUESTIONS",
+	}
+
+	err := forEachExportQuestion(ctx, courseID, scaleProgress(progress, 0, 100), func(q *exportQuestion) error {
+		limits.check(q, true)
+
+		identifier := fmt.Sprintf("Q%d", q.ID)
+		href := fmt.Sprintf("items/%s.xml", identifier)
+
+		item := qtiItem{
+			Xmlns:      qtiItemNamespace,
+			Identifier: identifier,
+			Title:      identifier,
+			ResponseDeclaration: qtiResponseDeclaration{
+				Identifier:  qtiResponseID,
+				Cardinality: "single",
+				BaseType:    "identifier",
+			},
+			OutcomeDeclarations: []qtiOutcomeDeclaration{
+				{Identifier: "SCORE", Cardinality: "single", BaseType: "float"},
+			},
+			ItemBody: qtiItemBody{
+				Paragraphs: strings.Split(strings.TrimSpace(q.Question), "\n"),
+				Interaction: qtiChoiceInteraction{
+					ResponseIdentifier: qtiResponseID,
+					MaxChoices:         1,
+				},
+			},
+			ResponseProcessing: qtiResponseProcessing{Template: qtiMatchCorrect},
+		}
+		if q.Type == "multiple" {
+			item.ResponseDeclaration.Cardinality = "multiple"
+			item.ItemBody.Interaction.MaxChoices = 0
+		}
+		for i, text := range exportOptionTexts(q) {
+			label := string(rune('A' + i))
+			item.ItemBody.Interaction.Choices = append(item.ItemBody.Interaction.Choices, qtiSimpleChoice{
+				Identifier: label,
+				Text:       text,
+			})
+		}
+		for _, ch := range q.Answer {
+			item.ResponseDeclaration.CorrectResponse = append(item.ResponseDeclaration.CorrectResponse, string(ch))
+		}
+		if explanation := strings.TrimSpace(q.Explanation); explanation != "" {
+			item.OutcomeDeclarations = append(item.OutcomeDeclarations, qtiOutcomeDeclaration{
+				Identifier: qtiFeedbackOutput, Cardinality: "single", BaseType: "identifier",
+			})
+			item.ModalFeedback = &qtiModalFeedback{
+				OutcomeIdentifier: qtiFeedbackOutput,
+				ShowHide:          "show",
+				Identifier:        qtiExplanationID,
+				Text:              explanation,
+			}
+		}
+
+		entry, err := zw.Create(href)
+		if err != nil {
+			return err
+		}
+		if err := writeXML(entry, item); err != nil {
+			return err
+		}
+
+		manifest.Resources = append(manifest.Resources, qtiResource{
+			Identifier: identifier,
+			Type:       qtiItemType,
+			Href:       href,
+			Files:      []qtiFile{{Href: href}},
+		})
+		result.ExportCount++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := zw.Create(qtiManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeXML(entry, manifest); err != nil {
+		return nil, err
+	}
+
+	limits.report(result, "QTI")
+	return result, zw.Close()
+}
+
+// writeXML 写入带声明的XML文档
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
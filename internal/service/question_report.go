@@ -0,0 +1,300 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var QuestionReport = new(QuestionReportService)
+
+// QuestionReportService 学生题目纠错及管理端处理
+type QuestionReportService struct{}
+
+// reportReasons 纠错原因及说明
+var reportReasons = map[string]string{
+	model.ReportReasonWrongAnswer:      "答案错误",
+	model.ReportReasonWrongExplanation: "解析错误",
+	model.ReportReasonTypo:             "题干或选项有错别字",
+	model.ReportReasonUnclear:          "题意不清",
+	model.ReportReasonOther:            "其他",
+}
+
+// ReportQueueItem 纠错队列项，按题目汇总
+type ReportQueueItem struct {
+	QuestionID     uint           `json:"question_id"`
+	Type           string         `json:"type"`
+	Question       string         `json:"question"`
+	CourseID       uint           `json:"course_id"`
+	CourseName     string         `json:"course_name"`
+	ReportCount    int            `json:"report_count"`  // 纠错总数
+	PendingCount   int            `json:"pending_count"` // 待处理数
+	Reasons        map[string]int `json:"reasons"`       // 各原因的纠错数
+	LatestReportAt time.Time      `json:"latest_report_at"`
+}
+
+// ReportDetail 单条纠错详情
+type ReportDetail struct {
+	model.QuestionReport
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+}
+
+// Create 提交题目纠错，只能纠错已购买课程中的题目
+func (s *QuestionReportService) Create(userId, questionId uint, reason, comment string) (*model.QuestionReport, error) {
+	if _, exists := reportReasons[reason]; !exists {
+		return nil, errors.New("纠错原因不正确")
+	}
+	comment = strings.TrimSpace(comment)
+	if reason == model.ReportReasonOther && comment == "" {
+		return nil, errors.New("请填写纠错说明")
+	}
+
+	var question model.Question
+	if err := database.DB.Select("id, course_id").First(&question, questionId).Error; err != nil {
+		return nil, errors.New("题目不存在")
+	}
+
+	// 检查用户是否有该课程的有效权限
+	if err := Entitlement.CheckAccess(userId, question.CourseID); err != nil {
+		return nil, err
+	}
+
+	// 同一题目有未处理的纠错时不重复提交
+	var pending int64
+	database.DB.Model(&model.QuestionReport{}).
+		Where("user_id = ? AND question_id = ? AND status = ?", userId, questionId, model.ReportStatusPending).
+		Count(&pending)
+	if pending > 0 {
+		return nil, errors.New("您已提交过该题目的纠错，请等待处理")
+	}
+
+	report := &model.QuestionReport{
+		QuestionID: questionId,
+		UserID:     userId,
+		Reason:     reason,
+		Comment:    comment,
+		Status:     model.ReportStatusPending,
+	}
+	if err := database.DB.Create(report).Error; err != nil {
+		return nil, errors.New("提交纠错失败")
+	}
+
+	return report, nil
+}
+
+// ListByUser 获取用户提交的纠错
+func (s *QuestionReportService) ListByUser(userId uint, page, size int) ([]model.QuestionReport, int64, error) {
+	db := database.DB.Model(&model.QuestionReport{}).Where("user_id = ?", userId)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	reports := make([]model.QuestionReport, 0)
+	if err := db.Order("id DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&reports).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return reports, total, nil
+}
+
+// Queue 获取按题目汇总的纠错队列，status 为空时不过滤，否则只返回含该状态纠错的题目
+func (s *QuestionReportService) Queue(status string, courseId uint, page, size int) ([]ReportQueueItem, int64, error) {
+	grouped := database.DB.Table("question_reports").
+		Select("question_reports.question_id, COUNT(*) AS report_count, "+
+			"SUM(CASE WHEN question_reports.status = ? THEN 1 ELSE 0 END) AS pending_count, "+
+			"MAX(question_reports.created_at) AS latest_report_at", model.ReportStatusPending).
+		Joins("JOIN questions ON questions.id = question_reports.question_id AND questions.deleted_at IS NULL").
+		Where("question_reports.deleted_at IS NULL").
+		Group("question_reports.question_id")
+	if courseId > 0 {
+		grouped = grouped.Where("questions.course_id = ?", courseId)
+	}
+	if status != "" {
+		grouped = grouped.Having("SUM(CASE WHEN question_reports.status = ? THEN 1 ELSE 0 END) > 0", status)
+	}
+
+	var total int64
+	if err := database.DB.Table("(?) AS t", grouped).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		QuestionID     uint
+		ReportCount    int
+		PendingCount   int
+		LatestReportAt time.Time
+	}
+	if err := grouped.Order("pending_count DESC, latest_report_at DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return []ReportQueueItem{}, total, nil
+	}
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.QuestionID
+	}
+
+	// 题目信息
+	var questions []model.Question
+	if err := database.DB.Select("id, type, question, course_id").Where("id IN ?", ids).Find(&questions).Error; err != nil {
+		return nil, 0, err
+	}
+	questionMap := make(map[uint]model.Question, len(questions))
+	var courseIds []uint
+	for _, q := range questions {
+		questionMap[q.ID] = q
+		courseIds = append(courseIds, q.CourseID)
+	}
+
+	var courses []model.Course
+	database.DB.Select("id, name").Where("id IN ?", courseIds).Find(&courses)
+	courseNames := make(map[uint]string, len(courses))
+	for _, c := range courses {
+		courseNames[c.ID] = c.Name
+	}
+
+	// 各原因的纠错数
+	var reasonCounts []struct {
+		QuestionID uint
+		Reason     string
+		Total      int
+	}
+	if err := database.DB.Model(&model.QuestionReport{}).
+		Select("question_id, reason, COUNT(*) AS total").
+		Where("question_id IN ?", ids).
+		Group("question_id, reason").
+		Scan(&reasonCounts).Error; err != nil {
+		return nil, 0, err
+	}
+	reasons := make(map[uint]map[string]int)
+	for _, rc := range reasonCounts {
+		if reasons[rc.QuestionID] == nil {
+			reasons[rc.QuestionID] = make(map[string]int)
+		}
+		reasons[rc.QuestionID][rc.Reason] = rc.Total
+	}
+
+	items := make([]ReportQueueItem, 0, len(rows))
+	for _, row := range rows {
+		q := questionMap[row.QuestionID]
+		items = append(items, ReportQueueItem{
+			QuestionID:     row.QuestionID,
+			Type:           q.Type,
+			Question:       q.Question,
+			CourseID:       q.CourseID,
+			CourseName:     courseNames[q.CourseID],
+			ReportCount:    row.ReportCount,
+			PendingCount:   row.PendingCount,
+			Reasons:        reasons[row.QuestionID],
+			LatestReportAt: row.LatestReportAt,
+		})
+	}
+
+	return items, total, nil
+}
+
+// ListByQuestion 获取题目的全部纠错
+func (s *QuestionReportService) ListByQuestion(questionId uint) ([]ReportDetail, error) {
+	var reports []model.QuestionReport
+	if err := database.DB.Preload("User").
+		Where("question_id = ?", questionId).
+		Order("id DESC").
+		Find(&reports).Error; err != nil {
+		return nil, err
+	}
+
+	details := make([]ReportDetail, 0, len(reports))
+	for _, r := range reports {
+		details = append(details, ReportDetail{
+			QuestionReport: r,
+			Username:       r.User.Username,
+			Nickname:       r.User.Nickname,
+		})
+	}
+	return details, nil
+}
+
+// Resolve 处理题目的全部待处理纠错，标记为已修正时通知纠错的学生
+func (s *QuestionReportService) Resolve(questionId uint, status, note string, adminId uint) (int64, error) {
+	if status != model.ReportStatusResolved && status != model.ReportStatusRejected {
+		return 0, errors.New("处理状态不正确")
+	}
+
+	var question model.Question
+	if err := database.DB.Select("id, question").First(&question, questionId).Error; err != nil {
+		return 0, errors.New("题目不存在")
+	}
+
+	var affected int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var reports []model.QuestionReport
+		if err := tx.Where("question_id = ? AND status = ?", questionId, model.ReportStatusPending).
+			Find(&reports).Error; err != nil {
+			return err
+		}
+		if len(reports) == 0 {
+			return errors.New("该题目没有待处理的纠错")
+		}
+
+		now := time.Now()
+		result := tx.Model(&model.QuestionReport{}).
+			Where("question_id = ? AND status = ?", questionId, model.ReportStatusPending).
+			Updates(map[string]interface{}{
+				"status":          status,
+				"resolution_note": note,
+				"resolved_by":     adminId,
+				"resolved_at":     now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+
+		if status != model.ReportStatusResolved {
+			return nil
+		}
+
+		// 每个学生只通知一次
+		content := fmt.Sprintf("您对题目「%s」提交的纠错已处理，题目已修正。", truncateMessage(question.Question, 30))
+		if note != "" {
+			content += "处理说明：" + note
+		}
+		notified := make(map[uint]bool)
+		var notifications []model.Notification
+		for _, r := range reports {
+			if notified[r.UserID] {
+				continue
+			}
+			notified[r.UserID] = true
+			notifications = append(notifications, model.Notification{
+				UserID:    r.UserID,
+				Type:      model.NotificationQuestionFixed,
+				Title:     "您纠错的题目已修正",
+				Content:   content,
+				RelatedID: questionId,
+			})
+		}
+		return Notification.notifyTx(tx, notifications)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return affected, nil
+}
@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var QuestionSearch = new(QuestionSearchService)
+
+// QuestionSearchService 题目全文检索，检索题干、选项和解析
+type QuestionSearchService struct{}
+
+// JobTypeSearchReindex 重建题目检索索引的任务类型
+const JobTypeSearchReindex = "search_reindex"
+
+const (
+	ngramTokenSize      = 2   // 与 MySQL ngram_token_size 默认值一致，更短的关键词改用 LIKE 匹配
+	searchMaxTerms      = 10  // 单次检索最多使用的关键词数
+	searchMaxKeywordLen = 200 // 关键词最大长度（字符）
+	searchIndexBatch    = 500 // 建立索引时每批处理的题目数量
+	highlightContext    = 20  // 高亮片段中匹配位置之前保留的字符数
+	highlightMaxRunes   = 120 // 高亮片段最大长度（字符）
+)
+
+// SearchQuery 检索条件
+type SearchQuery struct {
+	Keyword  string
+	CourseID uint
+	Type     string
+	Page     int
+	Size     int
+}
+
+// SearchHit 检索结果
+type SearchHit struct {
+	QuestionResponse
+	CourseName string            `json:"course_name"`
+	Score      float64           `json:"score"`      // 相关度，越大越相关
+	Highlights map[string]string `json:"highlights"` // 命中字段(question/options/explanation)的高亮片段，关键词以<em>标记
+}
+
+// IndexTx 在事务中更新题目的检索文档，已删除的题目同时删除其文档
+func (s *QuestionSearchService) IndexTx(tx *gorm.DB, ids ...uint) error {
+	for start := 0; start < len(ids); start += searchIndexBatch {
+		end := start + searchIndexBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := s.indexBatch(tx, ids[start:end], time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexBatch 为一批题目生成检索文档
+func (s *QuestionSearchService) indexBatch(tx *gorm.DB, ids []uint, now time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var questions []model.Question
+	if err := tx.Select("id, type, question, options, explanation").
+		Where("id IN ?", ids).
+		Find(&questions).Error; err != nil {
+		return fmt.Errorf("查询题目失败: %v", err)
+	}
+
+	found := make(map[uint]bool, len(questions))
+	docs := make([]model.QuestionSearchDoc, 0, len(questions))
+	for _, q := range questions {
+		found[q.ID] = true
+		docs = append(docs, model.QuestionSearchDoc{
+			QuestionID:  q.ID,
+			Stem:        q.Question,
+			OptionsText: optionsSearchText(q.Type, q.Options),
+			Explanation: q.Explanation,
+			UpdatedAt:   now,
+		})
+	}
+
+	if len(docs) > 0 {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&docs).Error; err != nil {
+			return fmt.Errorf("保存检索文档失败: %v", err)
+		}
+	}
+
+	var missing []uint
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		if err := tx.Where("question_id IN ?", missing).Delete(&model.QuestionSearchDoc{}).Error; err != nil {
+			return fmt.Errorf("删除检索文档失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// optionsSearchText 将选项拼接为检索文本，每行一个选项
+func optionsSearchText(qType string, options model.QuestionOptions) string {
+	if qType == "judge" {
+		return ""
+	}
+	lines := make([]string, 0, len(options))
+	for _, opt := range options {
+		lines = append(lines, opt.Label+". "+opt.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Rebuild 重建全部题目的检索文档，progress 可为nil
+func (s *QuestionSearchService) Rebuild(ctx context.Context, progress func(percent int)) error {
+	if progress == nil {
+		progress = func(int) {}
+	}
+	// 取整到秒，避免数据库时间精度导致刚保存的文档被当作过期清除
+	now := time.Now().Truncate(time.Second)
+
+	var total int64
+	if err := database.DB.Model(&model.Question{}).Count(&total).Error; err != nil {
+		return fmt.Errorf("获取题目总数失败: %v", err)
+	}
+
+	var lastID uint
+	var done int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var ids []uint
+		if err := database.DB.Model(&model.Question{}).
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(searchIndexBatch).
+			Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("查询题目失败: %v", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		if err := s.indexBatch(database.DB, ids, now); err != nil {
+			return err
+		}
+
+		lastID = ids[len(ids)-1]
+		done += int64(len(ids))
+		if total > 0 {
+			progress(int(done * 95 / total))
+		}
+	}
+
+	// 清除已删除题目的文档
+	if err := database.DB.Where("updated_at < ?", now).Delete(&model.QuestionSearchDoc{}).Error; err != nil {
+		return fmt.Errorf("清理检索文档失败: %v", err)
+	}
+	return nil
+}
+
+// EnsureIndex 已有题目但检索文档为空时提交重建任务
+func (s *QuestionSearchService) EnsureIndex() error {
+	var docs int64
+	if err := database.DB.Model(&model.QuestionSearchDoc{}).Count(&docs).Error; err != nil {
+		return err
+	}
+	if docs > 0 {
+		return nil
+	}
+
+	var questions int64
+	if err := database.DB.Model(&model.Question{}).Count(&questions).Error; err != nil {
+		return err
+	}
+	if questions == 0 {
+		return nil
+	}
+
+	_, err := Job.Submit(JobTypeSearchReindex, nil, 0)
+	return err
+}
+
+// runReindexJob 执行重建检索索引任务
+func (s *QuestionSearchService) runReindexJob(jc *JobContext) error {
+	return s.Rebuild(jc, jc.SetProgress)
+}
+
+// Search 管理端检索，范围为全部题目
+func (s *QuestionSearchService) Search(q SearchQuery) ([]SearchHit, int64, error) {
+	var courseIds []uint
+	if q.CourseID > 0 {
+		courseIds = []uint{q.CourseID}
+	}
+	return s.search(q, courseIds)
+}
+
+// SearchForUser 学生检索，范围为已购买且未过期的课程
+func (s *QuestionSearchService) SearchForUser(userId uint, q SearchQuery) ([]SearchHit, int64, error) {
+	courseIds, err := Entitlement.CourseIDs(userId)
+	if err != nil {
+		return nil, 0, errors.New("查询已购课程失败")
+	}
+
+	if q.CourseID > 0 {
+		purchased := false
+		for _, id := range courseIds {
+			if id == q.CourseID {
+				purchased = true
+				break
+			}
+		}
+		if !purchased {
+			return nil, 0, errors.New("您尚未购买该课程或课程已过期")
+		}
+		courseIds = []uint{q.CourseID}
+	}
+	if len(courseIds) == 0 {
+		return []SearchHit{}, 0, nil
+	}
+
+	return s.search(q, courseIds)
+}
+
+// search 执行检索，courseIds 为空时不限课程
+func (s *QuestionSearchService) search(q SearchQuery, courseIds []uint) ([]SearchHit, int64, error) {
+	keyword := strings.TrimSpace(q.Keyword)
+	if keyword == "" {
+		return nil, 0, errors.New("请输入检索关键词")
+	}
+	if utf8.RuneCountInString(keyword) > searchMaxKeywordLen {
+		return nil, 0, fmt.Errorf("检索关键词不能超过%d个字", searchMaxKeywordLen)
+	}
+	terms := searchTerms(keyword)
+	if len(terms) == 0 {
+		return nil, 0, errors.New("请输入有效的检索关键词")
+	}
+
+	db := database.DB.Table("question_search_docs AS d").
+		Joins("JOIN questions q ON q.id = d.question_id AND q.deleted_at IS NULL")
+	if len(courseIds) > 0 {
+		db = db.Where("q.course_id IN ?", courseIds)
+	}
+	if q.Type != "" && q.Type != "all" {
+		db = db.Where("q.type = ?", q.Type)
+	}
+
+	// 长度不小于分词长度的关键词走全文索引，每个关键词作为短语且必须命中；更短的关键词用 LIKE 匹配
+	var fulltext []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= ngramTokenSize {
+			fulltext = append(fulltext, `+"`+term+`"`)
+			continue
+		}
+		like := "%" + escapeLike(term) + "%"
+		db = db.Where("(d.stem LIKE ? OR d.options_text LIKE ? OR d.explanation LIKE ?)", like, like, like)
+	}
+
+	scoreExpr := "0"
+	var scoreArgs []interface{}
+	if len(fulltext) > 0 {
+		against := strings.Join(fulltext, " ")
+		db = db.Where("MATCH(d.stem, d.options_text, d.explanation) AGAINST (? IN BOOLEAN MODE)", against)
+		scoreExpr = "MATCH(d.stem, d.options_text, d.explanation) AGAINST (? IN BOOLEAN MODE)"
+		scoreArgs = append(scoreArgs, against)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("检索失败: %v", err)
+	}
+
+	var rows []struct {
+		QuestionID uint
+		Score      float64
+	}
+	if err := db.Select("d.question_id, "+scoreExpr+" AS score", scoreArgs...).
+		Order("score DESC, d.question_id DESC").
+		Offset((q.Page - 1) * q.Size).
+		Limit(q.Size).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, fmt.Errorf("检索失败: %v", err)
+	}
+	if len(rows) == 0 {
+		return []SearchHit{}, total, nil
+	}
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.QuestionID
+	}
+
+	var questions []model.Question
+	if err := database.DB.Where("id IN ?", ids).Find(&questions).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询题目失败: %v", err)
+	}
+	questionMap := make(map[uint]model.Question, len(questions))
+	var questionCourseIds []uint
+	for _, question := range questions {
+		questionMap[question.ID] = question
+		questionCourseIds = append(questionCourseIds, question.CourseID)
+	}
+
+	var courses []model.Course
+	database.DB.Select("id, name").Where("id IN ?", questionCourseIds).Find(&courses)
+	courseNames := make(map[uint]string, len(courses))
+	for _, c := range courses {
+		courseNames[c.ID] = c.Name
+	}
+
+	hits := make([]SearchHit, 0, len(rows))
+	for _, row := range rows {
+		question, exists := questionMap[row.QuestionID]
+		if !exists {
+			continue
+		}
+
+		options := question.Options
+		if question.Type == "judge" {
+			options = model.JudgeOptions()
+		}
+
+		highlights := make(map[string]string)
+		if fragment := highlight(question.Question, terms); fragment != "" {
+			highlights["question"] = fragment
+		}
+		if fragment := highlight(optionsSearchText(question.Type, question.Options), terms); fragment != "" {
+			highlights["options"] = fragment
+		}
+		if fragment := highlight(question.Explanation, terms); fragment != "" {
+			highlights["explanation"] = fragment
+		}
+
+		hits = append(hits, SearchHit{
+			QuestionResponse: QuestionResponse{
+				ID:               question.ID,
+				Type:             question.Type,
+				Question:         question.Question,
+				StemMedia:        question.StemMedia,
+				Options:          options,
+				Answer:           question.Answer,
+				Explanation:      question.Explanation,
+				ExplanationMedia: question.ExplanationMedia,
+				CourseID:         question.CourseID,
+			},
+			CourseName: courseNames[question.CourseID],
+			Score:      row.Score,
+			Highlights: highlights,
+		})
+	}
+
+	return hits, total, nil
+}
+
+// searchTerms 按空白和标点拆分关键词，去重后按长度从长到短排列
+func searchTerms(keyword string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(keyword), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+
+	seen := make(map[string]bool)
+	var terms []string
+	for _, f := range fields {
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		terms = append(terms, f)
+		if len(terms) >= searchMaxTerms {
+			break
+		}
+	}
+
+	sort.SliceStable(terms, func(i, j int) bool {
+		return utf8.RuneCountInString(terms[i]) > utf8.RuneCountInString(terms[j])
+	})
+	return terms
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// highlight 生成高亮片段：从首个命中位置附近截取，HTML转义后以<em>标记关键词，未命中时返回空字符串
+// terms 需为小写且按长度从长到短排列
+func highlight(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// 个别字符转小写后长度变化时按原文匹配
+		lower = runes
+	}
+
+	// 标记命中区间
+	matched := make([]bool, len(runes))
+	first := -1
+	for i := 0; i < len(lower); i++ {
+		for _, term := range terms {
+			tr := []rune(term)
+			if i+len(tr) > len(lower) || string(lower[i:i+len(tr)]) != term {
+				continue
+			}
+			for j := i; j < i+len(tr); j++ {
+				matched[j] = true
+			}
+			if first < 0 {
+				first = i
+			}
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start := first - highlightContext
+	if start < 0 {
+		start = 0
+	}
+	end := start + highlightMaxRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	inMatch := false
+	for i := start; i < end; i++ {
+		if matched[i] && !inMatch {
+			b.WriteString("<em>")
+			inMatch = true
+		} else if !matched[i] && inMatch {
+			b.WriteString("</em>")
+			inMatch = false
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+	}
+	if inMatch {
+		b.WriteString("</em>")
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"exam-system/internal/model"
 	"exam-system/internal/pkg/database"
+	"math"
 	"time"
 )
 
@@ -43,6 +44,23 @@ type SystemInfo struct {
 	LastMonthIncome    float64          `json:"last_month_income"`    // 上月收入
 }
 
+// TrialConversion 课程的试用转化统计
+type TrialConversion struct {
+	CourseID       uint    `json:"course_id"`
+	CourseName     string  `json:"course_name"`
+	TrialUsers     int64   `json:"trial_users"`     // 试用人数
+	ConvertedUsers int64   `json:"converted_users"` // 试用后购买的人数
+	ConversionRate float64 `json:"conversion_rate"` // 转化率，0-1
+}
+
+// TrialStatistics 试用转化统计
+type TrialStatistics struct {
+	TrialUsers     int64             `json:"trial_users"`
+	ConvertedUsers int64             `json:"converted_users"`
+	ConversionRate float64           `json:"conversion_rate"`
+	Courses        []TrialConversion `json:"courses"` // 按试用人数从多到少排列
+}
+
 // GetSalesStatistics 获取销售统计数据
 func (s *StatisticsService) GetSalesStatistics(startTime, endTime time.Time, dimension TimeDimension) (*SalesStatistics, error) {
 	// 初始化响应
@@ -58,6 +76,7 @@ func (s *StatisticsService) GetSalesStatistics(startTime, endTime time.Time, dim
 
 	err := database.DB.Model(&model.Order{}).
 		Where("status = ? AND pay_time BETWEEN ? AND ?", "paid", startTime, endTime).
+		Where("parent_order_id = 0"). // 套餐子订单不计入订单数
 		Select("SUM(amount) as total_sales, COUNT(*) as total_orders").
 		Scan(&totalStats).Error
 	if err != nil {
@@ -90,6 +109,7 @@ func (s *StatisticsService) GetSalesStatistics(startTime, endTime time.Time, dim
 	var timeStats []TimeStats
 	err = database.DB.Model(&model.Order{}).
 		Where("status = ? AND pay_time BETWEEN ? AND ?", "paid", startTime, endTime).
+		Where("parent_order_id = 0"). // 套餐子订单不计入订单数
 		Select("DATE_FORMAT(pay_time, ?) as time_point, SUM(amount) as sales, COUNT(*) as order_count", timeFormat).
 		Group("time_point").
 		Order("time_point ASC").
@@ -133,6 +153,7 @@ func (s *StatisticsService) GetSystemInfo() (*SystemInfo, error) {
 	}
 	var orderStatusCounts []OrderStatusCount
 	if err := database.DB.Model(&model.Order{}).
+		Where("parent_order_id = 0"). // 套餐子订单不计入订单数
 		Select("status, COUNT(*) as count").
 		Group("status").
 		Find(&orderStatusCounts).Error; err != nil {
@@ -194,3 +215,51 @@ func (s *StatisticsService) GetSystemInfo() (*SystemInfo, error) {
 
 	return result, nil
 }
+
+// GetTrialStatistics 试用转化统计，统计首次试用时间在指定范围内的用户的转化情况
+// 试用后通过订单（购买、卡券兑换、套餐）开通了该课程的用户视为已转化
+func (s *StatisticsService) GetTrialStatistics(startTime, endTime time.Time) (*TrialStatistics, error) {
+	var rows []TrialConversion
+	err := database.DB.Table("trial_usages").
+		Select("trial_usages.course_id, COUNT(*) AS trial_users, "+
+			"SUM(EXISTS (SELECT 1 FROM entitlements WHERE entitlements.user_id = trial_usages.user_id "+
+			"AND entitlements.course_id = trial_usages.course_id AND entitlements.order_id > 0 "+
+			"AND entitlements.start_at >= trial_usages.first_at)) AS converted_users").
+		Where("trial_usages.first_at BETWEEN ? AND ?", startTime, endTime).
+		Group("trial_usages.course_id").
+		Order("trial_users DESC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	courseIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		courseIDs = append(courseIDs, row.CourseID)
+	}
+	var courses []model.Course
+	database.DB.Unscoped().Where("id IN ?", courseIDs).Find(&courses)
+	names := make(map[uint]string, len(courses))
+	for i := range courses {
+		names[courses[i].ID] = CourseCategory.CourseDisplayName(&courses[i])
+	}
+
+	result := &TrialStatistics{Courses: make([]TrialConversion, 0, len(rows))}
+	for _, row := range rows {
+		row.CourseName = names[row.CourseID]
+		row.ConversionRate = conversionRate(row.ConvertedUsers, row.TrialUsers)
+		result.TrialUsers += row.TrialUsers
+		result.ConvertedUsers += row.ConvertedUsers
+		result.Courses = append(result.Courses, row)
+	}
+	result.ConversionRate = conversionRate(result.ConvertedUsers, result.TrialUsers)
+	return result, nil
+}
+
+// conversionRate 转化率，保留4位小数
+func conversionRate(converted, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(converted)/float64(total)*10000) / 10000
+}
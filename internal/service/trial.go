@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var Trial = new(TrialService)
+
+// TrialService 课程免费试用，未购买的用户可以练习课程的试用题目
+// 试用题目为标记为试用的题目，加上按录入顺序的前 TrialCount 道题
+type TrialService struct{}
+
+// QuestionIDs 课程的试用题目ID
+func (s *TrialService) QuestionIDs(course *model.Course) ([]uint, error) {
+	var ids []uint
+	if err := database.DB.Model(&model.Question{}).
+		Where("course_id = ? AND is_trial = ?", course.ID, true).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	if course.TrialCount > 0 {
+		// MySQL 不支持在 IN 子查询中使用 LIMIT，先查出前N道题的ID
+		var firstIDs []uint
+		if err := database.DB.Model(&model.Question{}).
+			Where("course_id = ?", course.ID).
+			Order("id").
+			Limit(course.TrialCount).
+			Pluck("id", &firstIDs).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, firstIDs...)
+	}
+	return ids, nil
+}
+
+// Count 课程的试用题目数
+func (s *TrialService) Count(course *model.Course) int {
+	ids, err := s.QuestionIDs(course)
+	if err != nil {
+		return 0
+	}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return len(seen)
+}
+
+// IsTrialQuestion 题目是否为所属课程的试用题目
+func (s *TrialService) IsTrialQuestion(question *model.Question) bool {
+	if question.IsTrial {
+		return true
+	}
+	var course model.Course
+	if err := database.DB.Select("id, trial_count").First(&course, question.CourseID).Error; err != nil || course.TrialCount <= 0 {
+		return false
+	}
+	var before int64
+	database.DB.Model(&model.Question{}).
+		Where("course_id = ? AND id < ?", question.CourseID, question.ID).
+		Count(&before)
+	return before < int64(course.TrialCount)
+}
+
+// GetQuestions 获取课程的试用题目，包含答案和解析，只开放已发布的课程
+func (s *TrialService) GetQuestions(userId, courseId uint, questionType string) ([]QuestionResponse, error) {
+	var course model.Course
+	if err := database.DB.First(&course, courseId).Error; err != nil {
+		return nil, errors.New("课程不存在")
+	}
+	if err := Course.CheckPurchasable(&course); err != nil {
+		return nil, err
+	}
+
+	ids, err := s.QuestionIDs(&course)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("该课程暂无试用题目")
+	}
+
+	query := database.DB.Table("questions").Where("id IN ?", ids).Order("id")
+	if questionType != "" && questionType != "all" {
+		query = query.Where("type = ?", questionType)
+	}
+	questions, err := Question.list(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已购买的用户不计入试用
+	if !Entitlement.HasAccess(userId, courseId) {
+		s.record(userId, courseId, "view_count")
+	}
+	return questions, nil
+}
+
+// RecordAnswer 未购买的用户提交试用题目答案时记录试用
+func (s *TrialService) RecordAnswer(userId uint, question *model.Question) {
+	if Entitlement.HasAccess(userId, question.CourseID) || !s.IsTrialQuestion(question) {
+		return
+	}
+	s.record(userId, question.CourseID, "answer_count")
+}
+
+// record 累加用户对课程的试用次数，首次试用时创建记录
+func (s *TrialService) record(userId, courseId uint, column string) {
+	if userId == 0 {
+		return
+	}
+	now := time.Now()
+	usage := model.TrialUsage{UserID: userId, CourseID: courseId, FirstAt: now, LastAt: now}
+	switch column {
+	case "view_count":
+		usage.ViewCount = 1
+	case "answer_count":
+		usage.AnswerCount = 1
+	}
+	database.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "course_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			column:    gorm.Expr(column+" + ?", 1),
+			"last_at": now,
+		}),
+	}).Create(&usage)
+}
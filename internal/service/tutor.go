@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"exam-system/internal/config"
+	"exam-system/internal/model"
+	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/llm"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var Tutor = new(TutorService)
+
+// TutorService 题目答疑对话：学生针对一道题目向AI提问，回答以题目、答案和解析为依据
+// 所有消息都会保存，学生清空对话只影响之后请求带上的上下文，管理员仍可查看完整记录
+type TutorService struct{}
+
+// ErrTutorQuotaExceeded 当日提问次数已用完
+var ErrTutorQuotaExceeded = errors.New("今日答疑次数已用完，请明天再试")
+
+// TutorMessageView 学生端展示的对话消息
+type TutorMessageView struct {
+	ID        uint      `json:"id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TutorHistory 学生端的对话记录及当日剩余提问次数
+type TutorHistory struct {
+	QuestionID uint               `json:"question_id"`
+	Messages   []TutorMessageView `json:"messages"`
+	DailyLimit int                `json:"daily_limit"`
+	Remaining  int                `json:"remaining"`
+}
+
+// TutorReply 一次提问的回答
+type TutorReply struct {
+	Question  TutorMessageView `json:"question"`
+	Reply     TutorMessageView `json:"reply"`
+	Remaining int              `json:"remaining"`
+}
+
+// TutorConversationItem 管理端对话列表中的一条记录
+type TutorConversationItem struct {
+	model.TutorConversation
+	Username     string `json:"username"`
+	Nickname     string `json:"nickname"`
+	QuestionText string `json:"question_text"`
+}
+
+// TutorQuestionStat 按题目汇总的答疑情况，提问多的题目通常是学生普遍困惑的题目
+type TutorQuestionStat struct {
+	QuestionID    uint      `json:"question_id"`
+	QuestionText  string    `json:"question_text"`
+	Conversations int       `json:"conversations"`
+	Messages      int       `json:"messages"`
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+// tutorPromptData 答疑提示词模板的变量
+type tutorPromptData struct {
+	Type        string
+	Question    string
+	Options     string
+	Answer      string
+	Explanation string
+	Message     string
+}
+
+func newTutorMessageView(m *model.TutorMessage) TutorMessageView {
+	return TutorMessageView{
+		ID:        m.ID,
+		Role:      m.Role,
+		Content:   m.Content,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// History 获取学生在题目下的对话记录，不包括清空之前的消息
+func (s *TutorService) History(userId, questionId uint) (*TutorHistory, error) {
+	if _, err := s.accessibleQuestion(userId, questionId); err != nil {
+		return nil, err
+	}
+
+	history := &TutorHistory{
+		QuestionID: questionId,
+		Messages:   []TutorMessageView{},
+		DailyLimit: config.GlobalConfig.AI.Tutor.DailyLimit,
+	}
+	used, err := s.usedToday(database.DB, userId)
+	if err != nil {
+		return nil, err
+	}
+	history.Remaining = remainingQuota(history.DailyLimit, used)
+
+	var conversation model.TutorConversation
+	err = database.DB.Where("user_id = ? AND question_id = ?", userId, questionId).First(&conversation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []model.TutorMessage
+	if err := s.visibleMessages(&conversation).Order("id").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		history.Messages = append(history.Messages, newTutorMessageView(&messages[i]))
+	}
+	return history, nil
+}
+
+// Ask 学生提问，回答以题目信息为依据并带上最近的对话作为上下文
+// 调用模型失败时删除本次提问，不计入当日次数
+func (s *TutorService) Ask(ctx context.Context, userId, questionId uint, message string) (*TutorReply, error) {
+	cfg := config.GlobalConfig.AI.Tutor
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return nil, errors.New("请输入问题")
+	}
+	if utf8.RuneCountInString(message) > cfg.MaxMessageLength {
+		return nil, fmt.Errorf("问题不能超过%d个字", cfg.MaxMessageLength)
+	}
+
+	question, err := s.accessibleQuestion(userId, questionId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 锁定用户记录，保证同一用户并发提问时次数统计准确
+	var conversation model.TutorConversation
+	var asked model.TutorMessage
+	var used int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&model.User{}, userId).Error; err != nil {
+			return errors.New("用户不存在")
+		}
+		var err error
+		if used, err = s.usedToday(tx, userId); err != nil {
+			return err
+		}
+		if used >= int64(cfg.DailyLimit) {
+			return ErrTutorQuotaExceeded
+		}
+
+		conversation = model.TutorConversation{UserID: userId, QuestionID: questionId}
+		if err := tx.Where(&conversation).
+			Attrs(model.TutorConversation{CourseID: question.CourseID, LastMessageAt: time.Now()}).
+			FirstOrCreate(&conversation).Error; err != nil {
+			return err
+		}
+
+		asked = model.TutorMessage{
+			ConversationID: conversation.ID,
+			UserID:         userId,
+			Role:           model.TutorRoleUser,
+			Content:        message,
+		}
+		return tx.Create(&asked).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := AIUsage.Reserve(AIUsageMeta{
+		UserID:     userId,
+		Feature:    model.AIFeatureTutor,
+		CourseID:   question.CourseID,
+		QuestionID: question.ID,
+	})
+	if err != nil {
+		database.DB.Delete(&asked)
+		return nil, err
+	}
+	gen, err := s.reply(ctx, question, &conversation, &asked)
+	AIUsage.Finish(usage, gen, err)
+	if err != nil {
+		database.DB.Delete(&asked)
+		return nil, err
+	}
+
+	answer := model.TutorMessage{
+		ConversationID:   conversation.ID,
+		UserID:           userId,
+		Role:             model.TutorRoleAssistant,
+		Content:          gen.Content,
+		Model:            gen.Model,
+		PromptVersion:    gen.PromptVersion,
+		PromptTokens:     gen.Usage.PromptTokens,
+		CompletionTokens: gen.Usage.CompletionTokens,
+		Cost:             gen.Cost,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&answer).Error; err != nil {
+			return err
+		}
+		return tx.Model(&conversation).Updates(map[string]interface{}{
+			"message_count":   gorm.Expr("message_count + 1"),
+			"last_message_at": answer.CreatedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存回答失败: %v", err)
+	}
+
+	return &TutorReply{
+		Question:  newTutorMessageView(&asked),
+		Reply:     newTutorMessageView(&answer),
+		Remaining: remainingQuota(cfg.DailyLimit, used+1),
+	}, nil
+}
+
+// reply 生成回答，上下文为题目信息和本次提问之前最近的若干条消息
+func (s *TutorService) reply(ctx context.Context, question *model.Question, conversation *model.TutorConversation, asked *model.TutorMessage) (*GeneratedText, error) {
+	optionsText := ""
+	for _, opt := range question.Options {
+		optionsText += fmt.Sprintf("%s. %s\n", opt.Label, opt.Text)
+	}
+	req, err := llm.Prompt("tutor", tutorPromptData{
+		Type:        typeLabel(question.Type),
+		Question:    question.Question,
+		Options:     strings.TrimRight(optionsText, "\n"),
+		Answer:      question.Answer,
+		Explanation: question.Explanation,
+		Message:     asked.Content,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var history []model.TutorMessage
+	if err := s.visibleMessages(conversation).
+		Where("id < ?", asked.ID).
+		Order("id DESC").
+		Limit(config.GlobalConfig.AI.Tutor.HistoryMessages).
+		Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("查询对话记录失败: %v", err)
+	}
+
+	// 历史消息按时间顺序插在提问之前
+	messages := make([]llm.Message, 0, len(req.Messages)+len(history))
+	messages = append(messages, req.Messages[:len(req.Messages)-1]...)
+	for i := len(history) - 1; i >= 0; i-- {
+		messages = append(messages, llm.Message{Role: history[i].Role, Content: history[i].Content})
+	}
+	req.Messages = append(messages, req.Messages[len(req.Messages)-1])
+
+	resp, err := llm.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return newGeneratedText(req, resp, "AI未返回有效回答")
+}
+
+// Reset 清空学生在题目下的对话，之后的提问不再带上之前的消息
+func (s *TutorService) Reset(userId, questionId uint) error {
+	now := time.Now()
+	return database.DB.Model(&model.TutorConversation{}).
+		Where("user_id = ? AND question_id = ?", userId, questionId).
+		Update("reset_at", now).Error
+}
+
+// accessibleQuestion 获取学生可以提问的题目，只能针对已购买且未过期课程中的题目
+func (s *TutorService) accessibleQuestion(userId, questionId uint) (*model.Question, error) {
+	var question model.Question
+	if err := database.DB.First(&question, questionId).Error; err != nil {
+		return nil, errors.New("题目不存在")
+	}
+
+	if err := Entitlement.CheckAccess(userId, question.CourseID); err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// visibleMessages 对话中清空之后的消息
+func (s *TutorService) visibleMessages(conversation *model.TutorConversation) *gorm.DB {
+	db := database.DB.Where("conversation_id = ?", conversation.ID)
+	if conversation.ResetAt != nil {
+		db = db.Where("created_at > ?", *conversation.ResetAt)
+	}
+	return db
+}
+
+// usedToday 用户当天已提问的次数
+func (s *TutorService) usedToday(db *gorm.DB, userId uint) (int64, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var count int64
+	err := db.Model(&model.TutorMessage{}).
+		Where("user_id = ? AND role = ? AND created_at >= ?", userId, model.TutorRoleUser, today).
+		Count(&count).Error
+	return count, err
+}
+
+func remainingQuota(limit int, used int64) int {
+	if remaining := limit - int(used); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Conversations 管理端获取答疑对话列表，最近有消息的在前
+func (s *TutorService) Conversations(courseId, questionId, userId uint, page, size int) ([]TutorConversationItem, int64, error) {
+	db := database.DB.Model(&model.TutorConversation{})
+	if courseId > 0 {
+		db = db.Where("tutor_conversations.course_id = ?", courseId)
+	}
+	if questionId > 0 {
+		db = db.Where("tutor_conversations.question_id = ?", questionId)
+	}
+	if userId > 0 {
+		db = db.Where("tutor_conversations.user_id = ?", userId)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	items := []TutorConversationItem{}
+	if err := db.Select("tutor_conversations.*, users.username, users.nickname, questions.question AS question_text").
+		Joins("LEFT JOIN users ON users.id = tutor_conversations.user_id").
+		Joins("LEFT JOIN questions ON questions.id = tutor_conversations.question_id").
+		Order("tutor_conversations.last_message_at DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Scan(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// Transcript 管理端获取对话的完整记录，包括学生清空之前的消息
+func (s *TutorService) Transcript(conversationId uint) (*model.TutorConversation, []model.TutorMessage, error) {
+	var conversation model.TutorConversation
+	if err := database.DB.First(&conversation, conversationId).Error; err != nil {
+		return nil, nil, errors.New("对话不存在")
+	}
+
+	var messages []model.TutorMessage
+	if err := database.DB.Where("conversation_id = ?", conversationId).Order("id").Find(&messages).Error; err != nil {
+		return nil, nil, err
+	}
+	return &conversation, messages, nil
+}
+
+// QuestionStats 按题目汇总课程的答疑情况，提问人数多的在前
+func (s *TutorService) QuestionStats(courseId uint, page, size int) ([]TutorQuestionStat, int64, error) {
+	base := func() *gorm.DB {
+		db := database.DB.Model(&model.TutorConversation{}).Where("tutor_conversations.message_count > 0")
+		if courseId > 0 {
+			db = db.Where("tutor_conversations.course_id = ?", courseId)
+		}
+		return db
+	}
+
+	var total int64
+	if err := base().Distinct("tutor_conversations.question_id").Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	stats := []TutorQuestionStat{}
+	if err := base().Select("tutor_conversations.question_id, MAX(questions.question) AS question_text, " +
+		"COUNT(*) AS conversations, SUM(tutor_conversations.message_count) AS messages, " +
+		"MAX(tutor_conversations.last_message_at) AS last_message_at").
+		Joins("LEFT JOIN questions ON questions.id = tutor_conversations.question_id").
+		Group("tutor_conversations.question_id").
+		Order("conversations DESC, messages DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Scan(&stats).Error; err != nil {
+		return nil, 0, err
+	}
+	return stats, total, nil
+}
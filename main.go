@@ -25,7 +25,9 @@ import (
 	"exam-system/internal/middleware"
 	"exam-system/internal/pkg/banner"
 	"exam-system/internal/pkg/database"
+	"exam-system/internal/pkg/llm"
 	"exam-system/internal/pkg/logger"
+	"exam-system/internal/pkg/storage"
 	"exam-system/internal/router"
 	"exam-system/internal/service"
 	"fmt"
@@ -124,6 +126,180 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:  "normalize-options",
+				Usage: "将题目选项统一改写为规范格式，并列出无法解析的题目",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "只检查并统计，不修改数据",
+					},
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "配置文件路径",
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					configPath, err := resolveConfigPath(cmd)
+					if err != nil {
+						return err
+					}
+					os.Setenv("CONFIG_PATH", configPath)
+
+					// 初始化配置和依赖
+					if _, err := config.Load(); err != nil {
+						return fmt.Errorf("加载配置失败: %v", err)
+					}
+					if err := logger.Setup(); err != nil {
+						return fmt.Errorf("初始化日志系统失败: %v", err)
+					}
+					if err := database.Setup(); err != nil {
+						return fmt.Errorf("数据库初始化失败: %v", err)
+					}
+
+					dryRun := cmd.Bool("dry-run")
+					result, err := service.Question.NormalizeOptions(dryRun)
+					if err != nil {
+						return fmt.Errorf("规范化选项失败: %v", err)
+					}
+
+					for _, f := range result.Failures {
+						raw := []rune(f.Raw)
+						if len(raw) > 100 {
+							raw = append(raw[:100], []rune("...")...)
+						}
+						fmt.Printf("题目 %d: %s，原始数据: %s\n", f.QuestionID, f.Reason, string(raw))
+					}
+
+					action := "已改写"
+					if dryRun {
+						action = "需改写"
+					}
+					fmt.Printf("共检查 %d 道题目，%s %d 道，无法解析 %d 道。\n",
+						result.Total, action, result.Converted, len(result.Failures))
+					return nil
+				},
+			},
+			{
+				Name:  "course-archive",
+				Usage: "导出或导入课程归档包，用于在不同部署之间迁移课程",
+				Commands: []*cli.Command{
+					{
+						Name:  "export",
+						Usage: "将课程导出为归档包",
+						Flags: []cli.Flag{
+							&cli.UintFlag{
+								Name:     "course-id",
+								Usage:    "需要导出的课程ID",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Usage:   "归档包保存路径，默认为 course-<课程ID>.zip",
+							},
+							&cli.StringFlag{
+								Name:    "config",
+								Aliases: []string{"c"},
+								Usage:   "配置文件路径",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if err := setupArchiveCommand(cmd); err != nil {
+								return err
+							}
+
+							courseID := cmd.Uint("course-id")
+							output := cmd.String("output")
+							if output == "" {
+								output = fmt.Sprintf("course-%d.zip", courseID)
+							}
+
+							f, err := os.Create(output)
+							if err != nil {
+								return fmt.Errorf("创建归档包文件失败: %v", err)
+							}
+							if err := service.CourseArchive.Export(ctx, f, courseID, nil); err != nil {
+								f.Close()
+								os.Remove(output)
+								return fmt.Errorf("导出课程失败: %v", err)
+							}
+							if err := f.Close(); err != nil {
+								return fmt.Errorf("保存归档包失败: %v", err)
+							}
+
+							fmt.Printf("课程 %d 已导出到 %s。\n", courseID, output)
+							return nil
+						},
+					},
+					{
+						Name:  "import",
+						Usage: "导入课程归档包",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Aliases:  []string{"f"},
+								Usage:    "归档包文件路径",
+								Required: true,
+							},
+							&cli.UintFlag{
+								Name:  "category-id",
+								Usage: "导入的目标分类ID，不指定时按归档包中的分类路径导入",
+							},
+							&cli.StringFlag{
+								Name:  "conflict",
+								Usage: "分类下已有同名课程时的处理方式：error(失败)、rename(重命名)、overwrite(覆盖)",
+								Value: service.CourseConflictError,
+							},
+							&cli.StringFlag{
+								Name:    "config",
+								Aliases: []string{"c"},
+								Usage:   "配置文件路径",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							if err := setupArchiveCommand(cmd); err != nil {
+								return err
+							}
+
+							f, err := os.Open(cmd.String("file"))
+							if err != nil {
+								return fmt.Errorf("打开归档包失败: %v", err)
+							}
+							defer f.Close()
+							info, err := f.Stat()
+							if err != nil {
+								return fmt.Errorf("读取归档包失败: %v", err)
+							}
+
+							result, err := service.CourseArchive.Import(ctx, f, info.Size(), service.CourseImportParams{
+								Size:       info.Size(),
+								CategoryID: cmd.Uint("category-id"),
+								Conflict:   cmd.String("conflict"),
+							}, nil)
+							if err != nil {
+								return fmt.Errorf("导入课程失败: %v", err)
+							}
+
+							for _, msg := range result.Errors {
+								fmt.Println(msg)
+							}
+							for _, msg := range result.Warnings {
+								fmt.Println(msg)
+							}
+							action := "已导入为新课程"
+							if result.Overwritten {
+								action = "已覆盖课程"
+							}
+							fmt.Printf("%s %s (ID %d)：方案 %d 个，章 %d 个，小节 %d 个，媒体文件 %d 个，题目 %d 道，失败 %d 道。\n",
+								action, result.CourseName, result.CourseID, result.PlanCount, result.ChapterCount,
+								result.SectionCount, result.MediaCount, result.ImportCount, result.ErrorCount)
+							return nil
+						},
+					},
+				},
+			},
 		},
 	}
 
@@ -165,13 +341,52 @@ func startApp() error {
 		return fmt.Errorf("初始化管理员账号失败: %v", err)
 	}
 
+	// 将旧版课程中的分类名称迁移到分类表
+	if migrated, err := service.CourseCategory.MigrateLegacy(); err != nil {
+		logger.Fatalf("迁移课程分类失败: %v", err)
+		return fmt.Errorf("迁移课程分类失败: %v", err)
+	} else if migrated > 0 {
+		logger.Infof("已将 %d 门课程的分类迁移到分类表", migrated)
+	}
+
+	// 为已支付的历史订单生成课程权限
+	if synced, err := service.Entitlement.Backfill(); err != nil {
+		logger.Fatalf("同步课程权限失败: %v", err)
+		return fmt.Errorf("同步课程权限失败: %v", err)
+	} else if synced > 0 {
+		logger.Infof("已为 %d 个历史订单生成课程权限", synced)
+	}
+
 	logger.Info("数据库初始化完成")
 
+	// 初始化媒体存储
+	if err := storage.Setup(); err != nil {
+		logger.Fatalf("媒体存储初始化失败: %v", err)
+		return fmt.Errorf("媒体存储初始化失败: %v", err)
+	}
+
+	// 初始化AI模型服务
+	if err := llm.Setup(); err != nil {
+		logger.Fatalf("AI模型服务初始化失败: %v", err)
+		return fmt.Errorf("AI模型服务初始化失败: %v", err)
+	}
+	logger.Infof("AI模型服务: %s", llm.Default.Name())
+
 	// 启动定时任务
 	service.Cron.Start()
 	defer service.Cron.Stop()
 	logger.Info("定时任务启动完成")
 
+	// 启动后台任务
+	service.Job.Start()
+	defer service.Job.Stop()
+	logger.Info("后台任务启动完成")
+
+	// 检索索引为空时（如升级后首次启动）自动重建
+	if err := service.QuestionSearch.EnsureIndex(); err != nil {
+		logger.Errorf("提交重建检索索引任务失败: %v", err)
+	}
+
 	// 设置gin模式
 	gin.SetMode(config.GlobalConfig.Server.Mode)
 	if config.GlobalConfig.Server.Mode == "release" {
@@ -206,6 +421,29 @@ func startApp() error {
 	return nil
 }
 
+// setupArchiveCommand 为课程归档包命令初始化配置、日志、数据库和媒体存储
+func setupArchiveCommand(cmd *cli.Command) error {
+	configPath, err := resolveConfigPath(cmd)
+	if err != nil {
+		return err
+	}
+	os.Setenv("CONFIG_PATH", configPath)
+
+	if _, err := config.Load(); err != nil {
+		return fmt.Errorf("加载配置失败: %v", err)
+	}
+	if err := logger.Setup(); err != nil {
+		return fmt.Errorf("初始化日志系统失败: %v", err)
+	}
+	if err := database.Setup(); err != nil {
+		return fmt.Errorf("数据库初始化失败: %v", err)
+	}
+	if err := storage.Setup(); err != nil {
+		return fmt.Errorf("媒体存储初始化失败: %v", err)
+	}
+	return nil
+}
+
 // resolveConfigPath 获取配置文件路径
 func resolveConfigPath(cmd *cli.Command) (string, error) {
 	configPath := cmd.String("config")